@@ -0,0 +1,191 @@
+package authz
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The reasons a request may be denied.  Like error codes, reasons are part
+// of the api and will never change.
+const (
+	ReasonNotOwner         = "not_owner"
+	ReasonNotMember        = "not_member"
+	ReasonTransferRequired = "transfer_required"
+	ReasonNotParty         = "not_party"
+	ReasonConsented        = "already_consented"
+)
+
+// A denial is a forbidden request, along with the reason it was denied.
+// Denials unwrap to ErrForbidden, and their messages contain it, so they
+// may be matched with errs.Is.
+type Denial struct {
+	Reason    string    `json:"reason"`
+	Subject   string    `json:"subject"`
+	ServiceId uuid.UUID `json:"service_id"`
+	Team      string    `json:"team,omitempty"`
+}
+
+func (d *Denial) Error() string {
+	var msg string
+	switch d.Reason {
+	case ReasonNotOwner:
+		msg = fmt.Sprintf("Principal [%v] is not a member of [%v], which owns service [%v]", d.Subject, d.Team, d.ServiceId)
+	case ReasonNotMember:
+		msg = fmt.Sprintf("Principal [%v] may not assign service [%v] to [%v], of which it is not a member", d.Subject, d.ServiceId, d.Team)
+	case ReasonTransferRequired:
+		msg = fmt.Sprintf("Service [%v] is owned by [%v]. Changing its owner requires a transfer", d.ServiceId, d.Team)
+	case ReasonNotParty:
+		msg = fmt.Sprintf("Principal [%v] is not a member of either team of the transfer of service [%v]", d.Subject, d.ServiceId)
+	case ReasonConsented:
+		msg = fmt.Sprintf("Principal [%v] may only consent for [%v], which has already consented to the transfer of service [%v]", d.Subject, d.Team, d.ServiceId)
+	default:
+		msg = fmt.Sprintf("Principal [%v] may not modify service [%v]", d.Subject, d.ServiceId)
+	}
+	return fmt.Sprintf("%v (%v): %v", msg, d.Reason, core.ErrForbidden)
+}
+
+func (d *Denial) Unwrap() error {
+	return core.ErrForbidden
+}
+
+func deny(reason string, principal core.Principal, serviceId uuid.UUID, team string) error {
+	return &Denial{reason, principal.Subject, serviceId, team}
+}
+
+// Storage authorizes writes against the owners of services.  It decorates
+// any storage implementation on behalf of a single principal, so a storage
+// is created for every request.
+//
+// Unowned services may be modified by any principal, and may be claimed by
+// a member of the claiming team.  Owned services (and their versions) may
+// only be modified by members of the owning team, and may only change
+// owners by transfer.  Admins are exempt.
+//
+// Writes are checked against the current state of their services before
+// they're made.  Since that state is read in a separate transaction, writes
+// to guarded storage (see core.GuardedStorage) are checked again within
+// their own transactions, so an owner can't change between the check and
+// the write (e.g. a version can't be added by the previous owner once a
+// transfer is approved).
+//
+// Scopes are not checked here.  They are enforced by the transports before
+// requests reach storage.  Reads pass through.
+type Storage struct {
+	core.Storage
+	principal core.Principal
+}
+
+func NewStorage(raw core.Storage, principal core.Principal) *Storage {
+	ret := &Storage{raw, principal}
+	if !principal.Scope.Allows(core.ScopeAdmin) {
+		ret.Storage = core.Guarded(raw, ret.check)
+	}
+	return ret
+}
+
+func (s *Storage) SaveService(svc core.Service) (err error) {
+	if err = s.authorize(core.SaveServiceWrite(svc), nil); err != nil {
+		return
+	}
+	return s.Storage.SaveService(svc)
+}
+
+func (s *Storage) SaveVersion(v core.Version) (err error) {
+	if err = s.authorize(core.SaveVersionWrite(v), nil); err != nil {
+		return
+	}
+	return s.Storage.SaveVersion(v)
+}
+
+// Writes are authorized in order, against the state of the catalog as the
+// earlier writes of the batch leave it.  A denied write aborts the batch.
+func (s *Storage) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	written := make(map[uuid.UUID]*core.Service)
+	for i, w := range writes {
+		if e := s.authorize(w, written); e != nil {
+			ret = core.NewAbortedResults(len(writes), i, e)
+			err = core.BatchError(ret)
+			return
+		}
+
+		switch {
+		case w.Service != nil && w.Delete:
+			written[w.Service.Id] = nil
+		case w.Service != nil:
+			written[w.Service.Id] = w.Service
+		}
+	}
+	return s.Storage.SaveBatch(writes)
+}
+
+// The written services are those left by the earlier writes of a batch
+// (nil if deleted).  Otherwise, writes are authorized against the current
+// state of the service.  Malformed writes are never authorized.
+func (s *Storage) authorize(w core.Write, written map[uuid.UUID]*core.Service) error {
+	if err := w.Validate(); err != nil {
+		return errors.Wrapf(err, "Exactly one of service or version must be set")
+	}
+	if s.principal.Scope.Allows(core.ScopeAdmin) {
+		return nil
+	}
+
+	id := w.ServiceId()
+
+	cur, ok := written[id]
+	if !ok {
+		var err error
+		if cur, err = s.current(id); err != nil {
+			return err
+		}
+	}
+	return s.check(cur, w)
+}
+
+// Checks the write against the current state of its service (nil if it
+// doesn't exist).  This is the guard of the underlying storage.
+func (s *Storage) check(cur *core.Service, w core.Write) error {
+	id := w.ServiceId()
+
+	var owner string
+	if cur != nil {
+		owner = cur.Owner
+	}
+
+	if owner != "" && !s.principal.MemberOf(owner) {
+		return deny(ReasonNotOwner, s.principal, id, owner)
+	}
+
+	if w.Service == nil || w.Delete || w.Service.Owner == owner {
+		return nil
+	}
+
+	// Revisions that already exist can never be saved, so stale saves are
+	// left to fail with a conflict.
+	if cur != nil && w.Service.Version <= cur.Version {
+		return nil
+	}
+
+	if owner != "" {
+		return deny(ReasonTransferRequired, s.principal, id, owner)
+	}
+
+	if !s.principal.MemberOf(w.Service.Owner) {
+		return deny(ReasonNotMember, s.principal, id, w.Service.Owner)
+	}
+	return nil
+}
+
+// Returns the current state of the service (if any) from the underlying storage.
+func (s *Storage) current(id uuid.UUID) (*core.Service, error) {
+	catalog, err := s.Storage.ListServices(
+		core.NewFilter(core.FilterByServiceId(id)),
+		core.NewPage(core.Limit(1)))
+	if err != nil || len(catalog.Services) == 0 {
+		return nil, err
+	}
+	return &catalog.Services[0], nil
+}
@@ -0,0 +1,192 @@
+package authz
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, e := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, e) {
+		return
+	}
+
+	raw, err := svcsql.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	alice := NewStorage(raw, core.Principal{Subject: "alice", Scope: core.ScopeWrite, Teams: []string{"payments"}})
+	bob := NewStorage(raw, core.Principal{Subject: "bob", Scope: core.ScopeWrite, Teams: []string{"billing"}})
+	admin := NewStorage(raw, core.Principal{Subject: "admin", Scope: core.ScopeAdmin})
+
+	reason := func(err error) string {
+		var d *Denial
+		if !assert.True(t, errors.As(err, &d)) {
+			return ""
+		}
+		return d.Reason
+	}
+
+	svc := core.NewService("name", "desc").SetOwner("payments")
+	if !t.Run("SaveService_Create", func(t *testing.T) {
+		assert.Nil(t, alice.SaveService(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Create_NotMember", func(t *testing.T) {
+		err := bob.SaveService(core.NewService("name", "desc").SetOwner("payments"))
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+		assert.Equal(t, ReasonNotMember, reason(err))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Update", func(t *testing.T) {
+		svc = svc.SetDesc("updated").Increment()
+		assert.Nil(t, alice.SaveService(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Update_NotOwner", func(t *testing.T) {
+		err := bob.SaveService(svc.SetDesc("stolen").Increment())
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+		assert.Equal(t, ReasonNotOwner, reason(err))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Update_TransferRequired", func(t *testing.T) {
+		err := alice.SaveService(svc.SetOwner("billing").Increment())
+		assert.Equal(t, ReasonTransferRequired, reason(err))
+
+		err = alice.SaveService(svc.SetOwner("").Increment())
+		assert.Equal(t, ReasonTransferRequired, reason(err))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Admin", func(t *testing.T) {
+		svc = svc.SetOwner("billing").Increment()
+		assert.Nil(t, admin.SaveService(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveVersion", func(t *testing.T) {
+		assert.Nil(t, bob.SaveVersion(core.NewVersion(svc.Id, "v1")))
+
+		err := alice.SaveVersion(core.NewVersion(svc.Id, "v2"))
+		assert.Equal(t, ReasonNotOwner, reason(err))
+	}) {
+		return
+	}
+
+	unowned := core.NewService("unowned", "desc")
+	if !t.Run("SaveService_Unowned", func(t *testing.T) {
+		if !assert.Nil(t, bob.SaveService(unowned)) {
+			return
+		}
+
+		unowned = unowned.SetDesc("updated").Increment()
+		assert.Nil(t, alice.SaveService(unowned))
+		assert.Nil(t, bob.SaveVersion(core.NewVersion(unowned.Id, "v1")))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Claim", func(t *testing.T) {
+		err := alice.SaveService(unowned.SetOwner("billing").Increment())
+		assert.Equal(t, ReasonNotMember, reason(err))
+
+		unowned = unowned.SetOwner("payments").Increment()
+		assert.Nil(t, alice.SaveService(unowned))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch", func(t *testing.T) {
+		created := core.NewService("batch", "desc").SetOwner("billing")
+		_, err := bob.SaveBatch([]core.Write{
+			core.SaveServiceWrite(created),
+			core.SaveVersionWrite(core.NewVersion(created.Id, "v1")),
+		})
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Denied", func(t *testing.T) {
+		results, err := bob.SaveBatch([]core.Write{
+			core.SaveVersionWrite(core.NewVersion(svc.Id, "v2")),
+			core.DeleteServiceWrite(unowned),
+		})
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+		if !assert.Equal(t, 2, len(results)) {
+			return
+		}
+		assert.True(t, errs.Is(results[0].Err(), core.ErrAborted))
+		assert.True(t, errs.Is(results[1].Err(), core.ErrForbidden))
+
+		catalog, err := raw.ListServices(core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(catalog.Versions[svc.Id]))
+	}) {
+		return
+	}
+
+	if !t.Run("Malformed", func(t *testing.T) {
+		assert.True(t, errs.Is(alice.authorize(core.Write{}, nil), core.ErrState))
+		assert.True(t, errs.Is(admin.authorize(core.Write{}, nil), core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveVersion_OwnerChanged", func(t *testing.T) {
+		transferred := core.NewService("transferred", "desc").SetOwner("payments")
+		if !assert.Nil(t, alice.SaveService(transferred)) {
+			return
+		}
+
+		// The service changes owner after alice has read it.
+		stale := staleStorage{raw, core.Catalog{Services: []core.Service{transferred}}}
+		if !assert.Nil(t, admin.SaveService(transferred.SetOwner("billing").Increment())) {
+			return
+		}
+
+		err := NewStorage(stale, alice.principal).SaveVersion(core.NewVersion(transferred.Id, "v1"))
+		assert.Equal(t, ReasonNotOwner, reason(err))
+	}) {
+		return
+	}
+}
+
+// A storage whose listings are stale, as though the listed services changed
+// after they were read.
+type staleStorage struct {
+	core.Storage
+	stale core.Catalog
+}
+
+func (s staleStorage) ListServices(core.Filter, core.Page) (core.Catalog, error) {
+	return s.stale, nil
+}
+
+func (s staleStorage) WithGuard(guard core.Guard) core.Storage {
+	return staleStorage{core.Guarded(s.Storage, guard), s.stale}
+}
@@ -0,0 +1,123 @@
+package authz
+
+import (
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Transfers moves services between teams on behalf of a single principal.
+// A principal consents to a transfer for each of its teams, so a transfer
+// requested by a member of both teams completes immediately.  Otherwise, a
+// member of the other team must approve it.
+//
+// Admins are not exempt, since consent may only be given by the members of
+// a team.  They may still cancel transfers, or change owners directly.
+type Transfers struct {
+	raw       core.TransferStorage
+	storage   core.Storage
+	principal core.Principal
+}
+
+func NewTransfers(raw core.TransferStorage, storage core.Storage, principal core.Principal) *Transfers {
+	return &Transfers{raw, storage, principal}
+}
+
+// Requests the transfer of an owned service to the team.  Unowned services
+// need no transfer, since they may be claimed by any member of a team.
+func (t *Transfers) Request(serviceId uuid.UUID, to string) (ret core.Transfer, err error) {
+	if err = core.ValidateTeam(to); err != nil {
+		return
+	}
+
+	catalog, err := t.storage.ListServices(
+		core.NewFilter(core.FilterByServiceId(serviceId)),
+		core.NewPage(core.Limit(1)))
+	if err != nil {
+		return
+	}
+	if len(catalog.Services) == 0 {
+		err = errors.Wrapf(core.ErrNoService, "No such service [%v]", serviceId)
+		return
+	}
+
+	from := catalog.Services[0].Owner
+	switch {
+	case from == "":
+		err = errors.Wrapf(core.ErrState, "Service [%v] is unowned. It may be claimed by updating its owner", serviceId)
+		return
+	case from == to:
+		err = errors.Wrapf(core.ErrState, "Service [%v] is already owned by [%v]", serviceId, to)
+		return
+	case !t.principal.MemberOf(from) && !t.principal.MemberOf(to):
+		err = deny(ReasonNotParty, t.principal, serviceId, "")
+		return
+	}
+
+	ret = core.NewTransfer(serviceId, from, to)
+	if t.principal.MemberOf(from) {
+		ret.FromBy = t.principal.Subject
+	} else {
+		ret.ToBy = t.principal.Subject
+	}
+	if err = t.raw.SaveTransfer(ret); err != nil {
+		return
+	}
+
+	if t.principal.MemberOf(from) && t.principal.MemberOf(to) {
+		ret, err = t.raw.ConsentTransfer(core.Transfer{Id: ret.Id, ToBy: t.principal.Subject})
+	}
+	return
+}
+
+// Approves a pending transfer on behalf of the team that has yet to consent.
+func (t *Transfers) Approve(id uuid.UUID) (ret core.Transfer, err error) {
+	ret, err = t.raw.LoadTransfer(id)
+	if err != nil {
+		return
+	}
+
+	consent := core.Transfer{Id: id}
+	if ret.FromBy == "" && t.principal.MemberOf(ret.From) {
+		consent.FromBy = t.principal.Subject
+	}
+	if ret.ToBy == "" && t.principal.MemberOf(ret.To) {
+		consent.ToBy = t.principal.Subject
+	}
+
+	if consent.FromBy == "" && consent.ToBy == "" {
+		switch {
+		case t.principal.MemberOf(ret.From):
+			err = deny(ReasonConsented, t.principal, ret.ServiceId, ret.From)
+		case t.principal.MemberOf(ret.To):
+			err = deny(ReasonConsented, t.principal, ret.ServiceId, ret.To)
+		default:
+			err = deny(ReasonNotParty, t.principal, ret.ServiceId, "")
+		}
+		return
+	}
+
+	ret, err = t.raw.ConsentTransfer(consent)
+	return
+}
+
+// Cancels a pending transfer.  Either team may cancel a transfer.
+func (t *Transfers) Cancel(id uuid.UUID) (err error) {
+	transfer, err := t.raw.LoadTransfer(id)
+	if err != nil {
+		return
+	}
+
+	if !t.principal.Scope.Allows(core.ScopeAdmin) &&
+		!t.principal.MemberOf(transfer.From) &&
+		!t.principal.MemberOf(transfer.To) {
+		err = deny(ReasonNotParty, t.principal, transfer.ServiceId, "")
+		return
+	}
+	return t.raw.CancelTransfer(id)
+}
+
+// Lists the transfers of a service, oldest first.
+func (t *Transfers) List(serviceId uuid.UUID) ([]core.Transfer, error) {
+	return t.raw.ListTransfers(serviceId)
+}
@@ -0,0 +1,157 @@
+package authz
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransfers(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, e := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, e) {
+		return
+	}
+
+	store, err := svcsql.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	raw, err := svcsql.NewSqlTransferStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	alice := NewTransfers(raw, store, core.Principal{Subject: "alice", Scope: core.ScopeWrite, Teams: []string{"payments"}})
+	bob := NewTransfers(raw, store, core.Principal{Subject: "bob", Scope: core.ScopeWrite, Teams: []string{"billing"}})
+	carol := NewTransfers(raw, store, core.Principal{Subject: "carol", Scope: core.ScopeWrite, Teams: []string{"payments", "billing"}})
+	admin := NewTransfers(raw, store, core.Principal{Subject: "admin", Scope: core.ScopeAdmin})
+
+	reason := func(err error) string {
+		var d *Denial
+		if !assert.True(t, errors.As(err, &d)) {
+			return ""
+		}
+		return d.Reason
+	}
+
+	owner := func(svc core.Service) string {
+		catalog, err := store.ListServices(core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			t.FailNow()
+		}
+		return catalog.Services[0].Owner
+	}
+
+	svc := core.NewService("name", "desc").SetOwner("payments")
+	if !assert.Nil(t, store.SaveService(svc)) {
+		return
+	}
+
+	if !t.Run("Request_Unowned", func(t *testing.T) {
+		unowned := core.NewService("unowned", "desc")
+		if !assert.Nil(t, store.SaveService(unowned)) {
+			return
+		}
+
+		_, err := alice.Request(unowned.Id, "billing")
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("Request_NotParty", func(t *testing.T) {
+		_, err := bob.Request(svc.Id, "platform")
+		assert.Equal(t, ReasonNotParty, reason(err))
+	}) {
+		return
+	}
+
+	var transfer core.Transfer
+	if !t.Run("Request", func(t *testing.T) {
+		transfer, err = alice.Request(svc.Id, "billing")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "alice", transfer.FromBy)
+		assert.True(t, transfer.Pending())
+		assert.Equal(t, "payments", owner(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("Approve_Consented", func(t *testing.T) {
+		_, err := alice.Approve(transfer.Id)
+		assert.Equal(t, ReasonConsented, reason(err))
+	}) {
+		return
+	}
+
+	if !t.Run("Approve_Admin", func(t *testing.T) {
+		_, err := admin.Approve(transfer.Id)
+		assert.Equal(t, ReasonNotParty, reason(err))
+	}) {
+		return
+	}
+
+	if !t.Run("Approve", func(t *testing.T) {
+		transfer, err = bob.Approve(transfer.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "bob", transfer.ToBy)
+		assert.False(t, transfer.Pending())
+		assert.Equal(t, "billing", owner(svc))
+	}) {
+		return
+	}
+
+	// Members of both teams consent for both.
+	if !t.Run("Request_BothTeams", func(t *testing.T) {
+		transfer, err := carol.Request(svc.Id, "payments")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, transfer.Pending())
+		assert.Equal(t, "payments", owner(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("Cancel", func(t *testing.T) {
+		transfer, err := bob.Request(svc.Id, "billing")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "bob", transfer.ToBy)
+
+		outsider := NewTransfers(raw, store, core.Principal{Subject: "dave", Scope: core.ScopeWrite, Teams: []string{"platform"}})
+		assert.Equal(t, ReasonNotParty, reason(outsider.Cancel(transfer.Id)))
+
+		assert.Nil(t, alice.Cancel(transfer.Id))
+		assert.True(t, errs.Is(alice.Cancel(transfer.Id), core.ErrNoTransfer))
+		assert.Equal(t, "payments", owner(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("List", func(t *testing.T) {
+		list, err := bob.List(svc.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 2, len(list))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	Size int
+	TTL  time.Duration
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{Size: 1024, TTL: 30 * time.Second}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the maximum number of listings retained by the cache.
+func WithSize(size int) Option {
+	return func(o *Options) {
+		o.Size = size
+	}
+}
+
+// Sets the maximum age of a cached listing.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// A snapshot of the cache's statistics.
+type Stats struct {
+	Size          int    `json:"size"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// Returns the ratio of hits to lookups.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Listings are keyed by the complete set of filter and page options.
+type key struct {
+	Name, Desc, Id string
+	Set            uint8 // bitmask of the filter fields that are set
+	Offset, Limit  uint64
+	OrderBy        string
+}
+
+func newKey(filter core.Filter, page core.Page) (ret key) {
+	ret = key{Offset: page.Offset, Limit: page.Limit, OrderBy: page.OrderBy}
+	if filter.NameContains != nil {
+		ret.Name, ret.Set = *filter.NameContains, ret.Set|1
+	}
+	if filter.DescContains != nil {
+		ret.Desc, ret.Set = *filter.DescContains, ret.Set|2
+	}
+	if filter.ServiceId != nil {
+		ret.Id, ret.Set = filter.ServiceId.String(), ret.Set|4
+	}
+	return
+}
+
+type entry struct {
+	key     key
+	filter  core.Filter
+	catalog core.Catalog
+	expires time.Time
+}
+
+// Returns true if the entry lists the given service.
+func (e *entry) contains(id uuid.UUID) bool {
+	for _, s := range e.catalog.Services {
+		if s.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the entry is a single page of a larger listing (or may be).
+func (e *entry) partial() bool {
+	return e.key.Offset > 0 || uint64(len(e.catalog.Services)) >= e.key.Limit
+}
+
+// Storage is a read-through cache of service listings.  It decorates any
+// storage implementation and is transparent to its consumers.
+//
+// Writes invalidate the listings they may affect, without reading from the
+// underlying storage.  Saving a service invalidates any listing that
+// contains it or whose filter matches its new state (since its membership
+// and position within the listing may have changed).  A listing that matched
+// only its prior state must have contained it, unless the listing is a
+// partial page, so partial pages are invalidated by every service write.
+// Saving a version only invalidates the listings that contain its service.
+//
+// A listing is only retained if no write occurred while it was being read
+// from the underlying storage, which ensures that a stale read can never
+// overwrite an invalidation.  All other operations pass through.
+type Storage struct {
+	raw core.Storage
+	*listings
+}
+
+// The listings are shared by every storage attributed to an actor.
+type listings struct {
+	opts  Options
+	lock  sync.Mutex
+	lru   *list.List // front is most recently used
+	index map[key]*list.Element
+	epoch uint64 // incremented by every write
+	stats Stats
+}
+
+func NewStorage(raw core.Storage, fns ...Option) *Storage {
+	return &Storage{raw, &listings{
+		opts:  buildOptions(fns...),
+		lru:   list.New(),
+		index: make(map[key]*list.Element),
+	}}
+}
+
+// Attributes writes to the actor (if the underlying storage is audited).
+// The returned storage shares the listings of the cache.
+func (s *Storage) WithActor(actor core.Actor) core.Storage {
+	return &Storage{core.Attribute(s.raw, actor), s.listings}
+}
+
+// Traces operations as children of the span (if the underlying storage is
+// traced).  The returned storage shares the listings of the cache.
+func (s *Storage) WithSpan(span core.Span) core.Storage {
+	return &Storage{core.Trace(s.raw, span), s.listings}
+}
+
+// Guards writes (if the underlying storage is guarded).  The returned
+// storage shares the listings of the cache.
+func (s *Storage) WithGuard(guard core.Guard) core.Storage {
+	return &Storage{core.Guarded(s.raw, guard), s.listings}
+}
+
+// Returns a snapshot of the cache statistics.
+func (s *Storage) Stats() (ret Stats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret = s.stats
+	ret.Size = s.lru.Len()
+	return
+}
+
+func (s *Storage) SaveService(svc core.Service) (err error) {
+	s.beginWrite()
+	defer s.endWrite(func(e *entry) bool {
+		return serviceAffects(e, svc)
+	})
+
+	err = s.raw.SaveService(svc)
+	return
+}
+
+func (s *Storage) SaveVersion(v core.Version) (err error) {
+	s.beginWrite()
+	defer s.endWrite(func(e *entry) bool {
+		return versionAffects(e, v)
+	})
+
+	err = s.raw.SaveVersion(v)
+	return
+}
+
+// A batch invalidates the union of the listings affected by its writes.
+func (s *Storage) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	s.beginWrite()
+	defer s.endWrite(func(e *entry) bool {
+		for _, w := range writes {
+			if w.Service != nil && serviceAffects(e, *w.Service) {
+				return true
+			}
+			if w.Version != nil && versionAffects(e, *w.Version) {
+				return true
+			}
+		}
+		return false
+	})
+
+	ret, err = s.raw.SaveBatch(writes)
+	return
+}
+
+func (s *Storage) ListServices(filter core.Filter, page core.Page) (ret core.Catalog, err error) {
+	k := newKey(filter, page)
+
+	s.lock.Lock()
+	if elem, ok := s.index[k]; ok {
+		e := elem.Value.(*entry)
+		if time.Now().Before(e.expires) {
+			s.lru.MoveToFront(elem)
+			s.stats.Hits++
+			s.lock.Unlock()
+			ret = copyCatalog(e.catalog)
+			return
+		}
+
+		s.remove(elem)
+	}
+	s.stats.Misses++
+	epoch := s.epoch
+	s.lock.Unlock()
+
+	ret, err = s.raw.ListServices(filter, page)
+	if err != nil || s.opts.Size <= 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.epoch != epoch {
+		return
+	}
+
+	if elem, ok := s.index[k]; ok {
+		s.remove(elem)
+	}
+
+	s.index[k] = s.lru.PushFront(&entry{k, filter, copyCatalog(ret), time.Now().Add(s.opts.TTL)})
+	for s.lru.Len() > s.opts.Size {
+		s.remove(s.lru.Back())
+		s.stats.Evictions++
+	}
+	return
+}
+
+func (s *Storage) ListChanges(since uint64, limit uint64) ([]core.Change, error) {
+	return s.raw.ListChanges(since, limit)
+}
+
+func (s *Storage) LatestSeq() (uint64, error) {
+	return s.raw.LatestSeq()
+}
+
+func (s *Storage) Export(history bool) (core.Snapshot, error) {
+	return s.raw.Export(history)
+}
+
+// Counts aren't cached, so that they're never stale.
+func (s *Storage) Count() (services, versions int, err error) {
+	return core.Count(s.raw)
+}
+
+// Imports may touch any number of services, so every listing is invalidated.
+func (s *Storage) Import(snapshot core.Snapshot, mode core.ImportMode) (ret core.ImportResult, err error) {
+	s.beginWrite()
+	defer s.endWrite(func(*entry) bool {
+		return true
+	})
+
+	ret, err = s.raw.Import(snapshot, mode)
+	return
+}
+
+func serviceAffects(e *entry, svc core.Service) bool {
+	return e.contains(svc.Id) || e.filter.Matches(svc) || e.partial()
+}
+
+func versionAffects(e *entry, v core.Version) bool {
+	return e.contains(v.ServiceId)
+}
+
+// Writes bump the epoch both before and after they are applied, so that
+// any listing read concurrently with the write is discarded.
+func (s *Storage) beginWrite() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.epoch++
+}
+
+func (s *Storage) endWrite(affected func(*entry) bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.epoch++
+
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if affected(elem.Value.(*entry)) {
+			s.remove(elem)
+			s.stats.Invalidations++
+		}
+		elem = next
+	}
+}
+
+// Must be called with the lock held.
+func (s *Storage) remove(elem *list.Element) {
+	delete(s.index, elem.Value.(*entry).key)
+	s.lru.Remove(elem)
+}
+
+// Listings are copied on the way in and out of the cache so that callers
+// can't mutate cached values.
+func copyCatalog(c core.Catalog) (ret core.Catalog) {
+	ret = c
+	ret.Services = append([]core.Service(nil), c.Services...)
+	ret.Versions = make(map[uuid.UUID][]core.Version, len(c.Versions))
+	for id, versions := range c.Versions {
+		ret.Versions[id] = append([]core.Version(nil), versions...)
+	}
+	return
+}
@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, e := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, e) {
+		return
+	}
+
+	raw, err := svcsql.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store := NewStorage(raw, WithSize(2), WithTTL(time.Hour))
+
+	svc1 := core.NewService("svc1", "desc")
+	svc2 := core.NewService("svc2", "other")
+	if !assert.Nil(t, store.SaveService(svc1)) {
+		return
+	}
+	if !assert.Nil(t, store.SaveService(svc2)) {
+		return
+	}
+
+	byName := core.NewFilter(core.FilterByName("svc1"))
+	byDesc := core.NewFilter(core.FilterByDesc("other"))
+
+	if !t.Run("ListServices_Miss", func(t *testing.T) {
+		catalog, err := store.ListServices(byName, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, []core.Service{svc1}, catalog.Services)
+		assert.Equal(t, Stats{Size: 1, Misses: 1}, store.Stats())
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices_Hit", func(t *testing.T) {
+		catalog, err := store.ListServices(byName, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, []core.Service{svc1}, catalog.Services)
+		assert.Equal(t, Stats{Size: 1, Hits: 1, Misses: 1}, store.Stats())
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices_DistinctPages", func(t *testing.T) {
+		catalog, err := store.ListServices(byName, core.NewPage(core.Offset(1)))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Empty(t, catalog.Services)
+		assert.Equal(t, uint64(2), store.Stats().Misses)
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices_Evict", func(t *testing.T) {
+		if _, err := store.ListServices(byDesc, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+
+		stats := store.Stats()
+		assert.Equal(t, 2, stats.Size)
+		assert.Equal(t, uint64(1), stats.Evictions)
+
+		// The least recently used listing (byName) was evicted
+		if _, err := store.ListServices(byName, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, uint64(4), store.Stats().Misses)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveVersion_Invalidates", func(t *testing.T) {
+		version := core.NewVersion(svc1.Id, "v1")
+		if !assert.Nil(t, store.SaveVersion(version)) {
+			return
+		}
+
+		// Only the listing containing svc1 is affected
+		stats := store.Stats()
+		assert.Equal(t, 1, stats.Size)
+		assert.Equal(t, uint64(1), stats.Invalidations)
+
+		catalog, err := store.ListServices(byName, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []core.Version{version}, catalog.Versions[svc1.Id])
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Invalidates", func(t *testing.T) {
+		before := store.Stats()
+
+		// The renamed service no longer matches the name filter, but
+		// does now match the description filter.
+		updated := svc1.Update(func(s *core.Service) {
+			s.Name, s.Desc = "renamed", "other"
+		}).Increment()
+		if !assert.Nil(t, store.SaveService(updated)) {
+			return
+		}
+
+		assert.Equal(t, 0, store.Stats().Size)
+		assert.Equal(t, before.Invalidations+2, store.Stats().Invalidations)
+
+		catalog, err := store.ListServices(byName, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, catalog.Services)
+
+		catalog, err = store.ListServices(byDesc, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 2, len(catalog.Services))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Unaffected", func(t *testing.T) {
+		before := store.Stats()
+		if !assert.Nil(t, store.SaveService(core.NewService("svc3", "unrelated"))) {
+			return
+		}
+
+		assert.Equal(t, before.Size, store.Stats().Size)
+		assert.Equal(t, before.Invalidations, store.Stats().Invalidations)
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices_Expired", func(t *testing.T) {
+		store := NewStorage(raw, WithTTL(time.Millisecond))
+		if _, err := store.ListServices(byDesc, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		if _, err := store.ListServices(byDesc, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, uint64(2), store.Stats().Misses)
+	}) {
+		return
+	}
+
+	if !t.Run("ListChanges", func(t *testing.T) {
+		changes, err := store.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 5, len(changes))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Invalidates", func(t *testing.T) {
+		if _, err := store.ListServices(byDesc, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+
+		before := store.Stats()
+		if _, err := store.SaveBatch([]core.Write{
+			core.SaveVersionWrite(core.NewVersion(svc2.Id, "v1")),
+		}); !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, before.Invalidations+1, store.Stats().Invalidations)
+
+		catalog, err := store.ListServices(byDesc, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(catalog.Versions[svc2.Id]))
+	}) {
+		return
+	}
+
+	if !t.Run("ConsentTransfer_Invalidates", func(t *testing.T) {
+		transfers, err := svcsql.NewSqlTransferStore(db, sql.NewSchemaRegistry("TEST"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		owned := core.NewService("owned", "desc").SetOwner("payments")
+		if !assert.Nil(t, store.SaveService(owned)) {
+			return
+		}
+
+		byId := core.NewFilter(core.FilterByServiceId(owned.Id))
+		if _, err := store.ListServices(byId, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+
+		transfer := core.NewTransfer(owned.Id, "payments", "billing")
+		transfer.FromBy = "alice"
+		if !assert.Nil(t, transfers.SaveTransfer(transfer)) {
+			return
+		}
+
+		if _, err := NewTransferStorage(transfers, store).ConsentTransfer(core.Transfer{Id: transfer.Id, ToBy: "bob"}); !assert.Nil(t, err) {
+			return
+		}
+
+		catalog, err := store.ListServices(byId, core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			return
+		}
+		assert.Equal(t, "billing", catalog.Services[0].Owner)
+	}) {
+		return
+	}
+
+	if !t.Run("WithActor_Invalidates", func(t *testing.T) {
+		svc := core.NewService("attributed", "desc")
+		if !assert.Nil(t, store.SaveService(svc)) {
+			return
+		}
+
+		byId := core.NewFilter(core.FilterByServiceId(svc.Id))
+		if _, err := store.ListServices(byId, core.NewPage()); !assert.Nil(t, err) {
+			return
+		}
+
+		before := store.Stats()
+		actor := store.WithActor(core.Actor{Subject: "token:alice"})
+		if !assert.Nil(t, actor.SaveService(svc.Increment().SetDesc("updated"))) {
+			return
+		}
+
+		assert.Equal(t, before.Invalidations+1, store.Stats().Invalidations)
+
+		catalog, err := store.ListServices(byId, core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			return
+		}
+		assert.Equal(t, "updated", catalog.Services[0].Desc)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_PartialPage", func(t *testing.T) {
+		// The second page of the description filter only lists svc2, since
+		// the renamed svc1 precedes it.
+		second := core.NewPage(core.Offset(1), core.Limit(1))
+		catalog, err := store.ListServices(byDesc, second)
+		if !assert.Nil(t, err) || !assert.Equal(t, []core.Service{svc2}, catalog.Services) {
+			return
+		}
+
+		current, err := store.ListServices(core.NewFilter(core.FilterByServiceId(svc1.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(current.Services)) {
+			return
+		}
+
+		// Once svc1 no longer matches, svc2 moves to the first page.
+		if !assert.Nil(t, store.SaveService(current.Services[0].SetDesc("desc").Increment())) {
+			return
+		}
+
+		catalog, err = store.ListServices(byDesc, second)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, catalog.Services)
+	}) {
+		return
+	}
+}
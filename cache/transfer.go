@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Completed transfers save their services beneath the cache, so consents
+// invalidate the listings that contain the service.  All other operations
+// pass through.
+type TransferStorage struct {
+	core.TransferStorage
+	cache *Storage
+}
+
+func NewTransferStorage(raw core.TransferStorage, cache *Storage) *TransferStorage {
+	return &TransferStorage{raw, cache}
+}
+
+// Attributes writes to the actor (if the underlying storage is audited).
+func (t *TransferStorage) WithActor(actor core.Actor) core.TransferStorage {
+	return &TransferStorage{core.AttributeTransfers(t.TransferStorage, actor), t.cache}
+}
+
+func (t *TransferStorage) ConsentTransfer(transfer core.Transfer) (ret core.Transfer, err error) {
+	stored, err := t.TransferStorage.LoadTransfer(transfer.Id)
+	if err != nil {
+		return
+	}
+
+	t.cache.beginWrite()
+	defer t.cache.endWrite(func(e *entry) bool {
+		return e.contains(stored.ServiceId)
+	})
+
+	ret, err = t.TransferStorage.ConsentTransfer(transfer)
+	return
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	uuid "github.com/satori/go.uuid"
+	"github.com/urfave/cli"
+)
+
+var (
+	AuditServiceFlag = tool.StringFlag{
+		Name:  "service",
+		Usage: "Return the entries of the service with the given id",
+	}
+
+	AuditActorFlag = tool.StringFlag{
+		Name:  "actor",
+		Usage: "Return the entries of the given subject (e.g. token:ci)",
+	}
+
+	AuditSinceFlag = tool.StringFlag{
+		Name:  "since",
+		Usage: "Return the entries created since the given RFC3339 time, or duration ago (e.g. 24h)",
+	}
+
+	AuditVerifyFlag = tool.BoolFlag{
+		Name:  "verify",
+		Usage: "Verify the hash chain of the entire log",
+	}
+
+	AuditCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "audit",
+			Usage: "audit",
+			Info:  "Lists the audit log of writes to the catalog",
+			Help: `
+Lists who changed what, oldest first.  Requires an admin token.
+
+Entries are chained by hash, so that modifying or removing an entry is
+detectable.  With --verify, the entire log is read and its chain verified.
+`,
+			Flags: tool.NewFlags(
+				AddrFlag,
+				TokenFlag,
+				AuditServiceFlag,
+				AuditActorFlag,
+				AuditSinceFlag,
+				OffsetFlag,
+				LimitFlag,
+				AuditVerifyFlag,
+			),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewAuditClient(raw, enc.Json)
+				if c.Bool(AuditVerifyFlag.Name) {
+					return verifyAudit(env, client)
+				}
+
+				var filter []func(*core.AuditFilter)
+				if raw := c.String(AuditServiceFlag.Name); raw != "" {
+					id, err := uuid.FromString(raw)
+					if err != nil {
+						return errors.Wrapf(err, "Invalid flag --%v", AuditServiceFlag.Name)
+					}
+					filter = append(filter, core.AuditByServiceId(id))
+				}
+				if actor := c.String(AuditActorFlag.Name); actor != "" {
+					filter = append(filter, core.AuditByActor(actor))
+				}
+				if raw := c.String(AuditSinceFlag.Name); raw != "" {
+					since, err := parseSince(raw)
+					if err != nil {
+						return errors.Wrapf(err, "Invalid flag --%v", AuditSinceFlag.Name)
+					}
+					filter = append(filter, core.AuditSince(since))
+				}
+
+				page := core.NewPage()
+				if offset := c.Uint(OffsetFlag.Name); offset > 0 {
+					page = page.Update(core.Offset(uint64(offset)))
+				}
+				if limit := c.Uint(LimitFlag.Name); limit > 0 {
+					page = page.Update(core.Limit(uint64(limit)))
+				}
+
+				entries, err := client.ListAudit(core.NewAuditFilter(filter...), page)
+				if err != nil {
+					return
+				}
+
+				w := tabwriter.NewWriter(env.Terminal.IO.Out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "SEQ\tCREATED\tACTOR\tCLIENT\tOPERATION\tSERVICE\tREQUEST")
+				for _, e := range entries {
+					fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+						e.Seq, e.Created.Format(time.RFC3339), orNone(e.Actor.Subject), orNone(e.Actor.ClientIp),
+						e.Operation, e.ServiceId, orNone(e.Actor.RequestId))
+				}
+				return w.Flush()
+			},
+		})
+)
+
+// Reads the log a page at a time, verifying each page against the last
+// entry of the previous one.
+func verifyAudit(env tool.Environment, client core.AuditTransport) (err error) {
+	var prev string
+	var num uint64
+	for {
+		var entries []core.AuditEntry
+		entries, err = client.ListAudit(core.NewAuditFilter(), core.NewPage(core.Offset(num), core.Limit(1024)))
+		if err != nil || len(entries) == 0 {
+			break
+		}
+
+		if err = core.VerifyAudit(prev, entries); err != nil {
+			return
+		}
+
+		prev, num = entries[len(entries)-1].Hash, num+uint64(len(entries))
+	}
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(env.Terminal.IO.Out, "Verified [%v] audit entries. The latest hash is [%v]\n", num, orNone(prev))
+	return
+}
+
+// Parses either an RFC3339 time or a duration before now.
+func parseSince(raw string) (time.Time, error) {
+	if dur, err := time.ParseDuration(raw); err == nil {
+		return time.Now().Add(-dur), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func orNone(str string) string {
+	if str == "" {
+		return "-"
+	}
+	return str
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"crypto/tls"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/config"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	"github.com/pkopriv2/services-catalog/logfile"
+	"github.com/urfave/cli"
+)
+
+// The defaults of the flags that override the server configuration.  Flags
+// only override the configuration when they're given.
+var defaults = config.Default()
+
+var (
+	ConfigFlag = tool.StringFlag{
+		Name:  "config",
+		Usage: "The yaml configuration file (defaults to KONGHQ_CONFIG)",
+	}
+
+	LogLevelFlag = tool.StringFlag{
+		Name:    "log-level",
+		Usage:   "The level of the server's logs: one of off, error, info or debug",
+		Default: defaults.LogLevel,
+	}
+
+	StorageFlag = tool.StringFlag{
+		Name:  "storage",
+		Usage: "The storage backend: one of memory or sqlite (inferred from --db if empty)",
+	}
+
+	TLSCertFlag = tool.StringFlag{
+		Name:  "tls-cert",
+		Usage: "The certificate with which requests are served over TLS",
+	}
+
+	TLSKeyFlag = tool.StringFlag{
+		Name:  "tls-key",
+		Usage: "The private key of the TLS certificate",
+	}
+
+	TLSClientCAFlag = tool.StringFlag{
+		Name:  "tls-client-ca",
+		Usage: "The CA that must have signed the certificates of clients (enables mutual TLS)",
+	}
+
+	ReadTimeoutFlag = tool.StringFlag{
+		Name:    "read-timeout",
+		Usage:   "The time a client has to send a request (0 is unlimited)",
+		Default: defaults.Timeouts.Read.Std().String(),
+	}
+
+	WriteTimeoutFlag = tool.StringFlag{
+		Name:    "write-timeout",
+		Usage:   "The time the server has to write a response, including streams (0 is unlimited)",
+		Default: defaults.Timeouts.Write.Std().String(),
+	}
+
+	ShutdownGraceFlag = tool.StringFlag{
+		Name:    "shutdown-grace",
+		Usage:   "How long the server reports it isn't ready before it stops accepting requests",
+		Default: defaults.Timeouts.Grace.Std().String(),
+	}
+
+	ShutdownTimeoutFlag = tool.StringFlag{
+		Name:    "shutdown-timeout",
+		Usage:   "How long the server waits for requests in flight to finish",
+		Default: defaults.Timeouts.Shutdown.Std().String(),
+	}
+)
+
+// Returns the configuration of the server: the file given by --config (or
+// KONGHQ_CONFIG), overridden by the environment, and then by any flags that
+// were given.
+func loadConfig(c *cli.Context) (ret config.Config, err error) {
+	ret = config.Default()
+
+	path := c.String(ConfigFlag.Name)
+	if path == "" {
+		path = os.Getenv("KONGHQ_CONFIG")
+	}
+	if path != "" {
+		if ret, err = config.Load(path); err != nil {
+			return
+		}
+	}
+
+	if err = ret.ApplyEnv(os.LookupEnv); err != nil {
+		return
+	}
+
+	for name, ptr := range map[string]*string{
+		AddrFlag.Name:        &ret.Addr,
+		GrpcAddrFlag.Name:    &ret.GrpcAddr,
+		LogLevelFlag.Name:    &ret.LogLevel,
+		StorageFlag.Name:     &ret.Storage.Backend,
+		DbFlag.Name:          &ret.Storage.Path,
+		TLSCertFlag.Name:     &ret.TLS.Cert,
+		TLSKeyFlag.Name:      &ret.TLS.Key,
+		TLSClientCAFlag.Name: &ret.TLS.ClientCA,
+	} {
+		if c.IsSet(name) {
+			*ptr = c.String(name)
+		}
+	}
+
+	for name, ptr := range map[string]*config.Duration{
+		ReadTimeoutFlag.Name:     &ret.Timeouts.Read,
+		WriteTimeoutFlag.Name:    &ret.Timeouts.Write,
+		ReadyTimeoutFlag.Name:    &ret.Timeouts.Ready,
+		ShutdownGraceFlag.Name:   &ret.Timeouts.Grace,
+		ShutdownTimeoutFlag.Name: &ret.Timeouts.Shutdown,
+	} {
+		if !c.IsSet(name) {
+			continue
+		}
+
+		dur, err := time.ParseDuration(c.String(name))
+		if err != nil {
+			return ret, errors.Wrapf(err, "Invalid flag --%v", name)
+		}
+		*ptr = config.Duration(dur)
+	}
+
+	for name, ptr := range map[string]*uint{
+		ReadRateFlag.Name:   &ret.RateLimits.Read.Rate,
+		ReadBurstFlag.Name:  &ret.RateLimits.Read.Burst,
+		WriteRateFlag.Name:  &ret.RateLimits.Write.Rate,
+		WriteBurstFlag.Name: &ret.RateLimits.Write.Burst,
+	} {
+		if c.IsSet(name) {
+			*ptr = c.Uint(name)
+		}
+	}
+
+	err = ret.Validate()
+	return
+}
+
+// The settings of a running server that may be changed by reloading its
+// configuration.
+type reloadable struct {
+	level   *logfile.Level
+	network *svchttp.Network
+	reads   *core.RateLimiter
+	writes  *core.RateLimiter
+}
+
+// The settings that may be reloaded are applied before the server begins
+// listening, so that its first connections are served with them.
+func newReloadable(cfg config.Config, level *logfile.Level) (ret *reloadable, err error) {
+	ret = &reloadable{
+		level:   level,
+		network: svchttp.NewNetwork(nil),
+		reads:   core.NewRateLimiter(cfg.RateLimits.Read),
+		writes:  core.NewRateLimiter(cfg.RateLimits.Write),
+	}
+	err = ret.apply(cfg)
+	return
+}
+
+// Applies the configuration.  Nothing is applied if the certificates fail
+// to load.  Whether TLS is enabled is fixed once the server is listening,
+// so the certificates are only replaced while it's enabled.
+func (r *reloadable) apply(cfg config.Config) (err error) {
+	var certs *tls.Config
+	if cfg.TLS.Enabled() {
+		if certs, err = cfg.TLS.Load(); err != nil {
+			return
+		}
+		r.network.SetTLS(certs)
+	}
+
+	level, err := cfg.Level()
+	if err != nil {
+		return
+	}
+
+	r.level.Set(level)
+	r.network.SetTimeouts(cfg.Timeouts.Read.Std(), cfg.Timeouts.Write.Std())
+	r.reads.SetLimit(cfg.RateLimits.Read)
+	r.writes.SetLimit(cfg.RateLimits.Write)
+	return
+}
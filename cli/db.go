@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/config"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/urfave/cli"
+)
+
+// The name of the table that tracks the versions of the catalog schemas.
+const SchemaRegistry = "KONGHQ"
+
+var (
+	DbFlag = tool.StringFlag{
+		Name:  "db",
+		Usage: "The sqlite database file (defaults to KONGHQ_DB_ADDR)",
+	}
+
+	BackupCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "backup",
+			Usage: "backup <path>",
+			Info:  "Takes an online backup of the catalog database",
+			Help: `
+Downloads a consistent copy of the catalog database from a running server.
+The backup is verified before it is written to the given path.
+`,
+			Flags: tool.NewFlags(AddrFlag, TokenFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewBackupClient(raw, enc.Json)
+
+				path := c.Args().First()
+				if path == "" {
+					err = errors.Errorf("Missing required argument <path>")
+					return
+				}
+
+				if _, err = os.Stat(path); err == nil {
+					err = errors.Errorf("Backup file already exists [%v]", path)
+					return
+				}
+
+				// Download to a partial file, so that an interrupted backup
+				// is never mistaken for a complete one.
+				partial := path + ".partial"
+				file, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+				if err != nil {
+					return
+				}
+				defer os.Remove(partial)
+
+				size, err := client.Backup(file)
+				if err = closeAll(err, file); err != nil {
+					return
+				}
+
+				if err = svcsql.VerifyBackup(partial, SchemaRegistry); err != nil {
+					return
+				}
+
+				if err = os.Rename(partial, path); err != nil {
+					return
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out, "Wrote backup [%v] (%v bytes)\n", path, size)
+				return
+			},
+		})
+
+	RestoreCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "restore",
+			Usage: "restore <path>",
+			Info:  "Restores the catalog database from a backup",
+			Help: `
+Replaces the catalog database with the given backup.  The backup must be an
+intact catalog database whose schemas are supported by this release.  The
+server must be stopped while the database is restored.  The database is
+given by --db, KONGHQ_DB_ADDR or the storage path of the config file.
+`,
+			Flags: tool.NewFlags(DbFlag, ConfigFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				path := c.Args().First()
+				if path == "" {
+					err = errors.Errorf("Missing required argument <path>")
+					return
+				}
+
+				db, err := dbFile(c)
+				if err != nil {
+					return
+				}
+
+				unlock, err := lockDb(db)
+				if err != nil {
+					return
+				}
+				defer unlock()
+
+				if err = svcsql.VerifyBackup(path, SchemaRegistry); err != nil {
+					return
+				}
+
+				// Copy the backup next to the database, so that it may be
+				// renamed into place atomically.
+				tmp := db + ".restore"
+				if err = copyFile(path, tmp); err != nil {
+					os.Remove(tmp)
+					return
+				}
+
+				if err = os.Rename(tmp, db); err != nil {
+					os.Remove(tmp)
+					return
+				}
+
+				// Any journal left behind belongs to the old database and
+				// would corrupt the new one.
+				for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+					if e := os.Remove(db + suffix); e != nil && !os.IsNotExist(e) {
+						err = errors.Wrapf(e, "Restored database [%v], but unable to remove its stale journal", db)
+						return
+					}
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out, "Restored database [%v] from backup [%v]\n", db, path)
+				return
+			},
+		})
+
+	DbCommand = tool.NewGroup(
+		tool.GroupDef{
+			Name: "db",
+			Info: "Manages the catalog database",
+		},
+		BackupCommand,
+		RestoreCommand)
+)
+
+// Returns the database file given by the flag, environment or config file,
+// just as the server would.  Commands that operate on the database directly
+// are unable to reach an in-memory database.
+func dbFile(c *cli.Context) (ret string, err error) {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return
+	}
+
+	if cfg.Storage.Resolve() == config.Memory {
+		err = errors.Errorf("Unable to operate on an in-memory database. Set --%v, KONGHQ_DB_ADDR or storage.path", DbFlag.Name)
+		return
+	}
+
+	ret = cfg.Storage.Path
+	return
+}
+
+// Takes an exclusive lock on the database, which the server holds for as
+// long as it's running.  Sqlite only locks the database file itself during
+// transactions, so the lock is taken on a file beside it.
+func lockDb(db string) (unlock func() error, err error) {
+	file, err := os.OpenFile(db+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			err = errors.Errorf("Database [%v] is in use. Is the server running?", db)
+		}
+		return
+	}
+
+	unlock = file.Close
+	return
+}
+
+// Copies the file and syncs it to stable storage.
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	return closeAll(err, out)
+}
+
+func closeAll(err error, all ...io.Closer) error {
+	for _, c := range all {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	http "github.com/pkopriv2/golang-sdk/http/client"
 	"github.com/pkopriv2/golang-sdk/lang/enc"
 	"github.com/pkopriv2/golang-sdk/lang/tool"
 	"github.com/pkopriv2/services-catalog/core"
@@ -55,6 +54,7 @@ var (
 			Info:  "Lists the services catalog",
 			Flags: tool.NewFlags(
 				AddrFlag,
+				TokenFlag,
 				NameFlag,
 				DescFlag,
 				IdFlag,
@@ -64,7 +64,12 @@ var (
 				VerboseFlag,
 			),
 			Exec: func(env tool.Environment, c *cli.Context) (err error) {
-				client := svchttp.NewClient(http.NewDefaultClient(c.String(AddrFlag.Name)), enc.Json)
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewClient(raw, enc.Json)
 
 				filter := core.NewFilter()
 				if name := c.String(NameFlag.Name); name != "" {
@@ -118,20 +123,20 @@ var (
 	serviceLsTemplate = `
 Services(Total={{.Num}}):
 
-    {{ "#/id" | col 36 | header }} {{ "#/name" | col 12 | header }} {{ "#/desc" | header }}
+    {{ "#/id" | col 36 | header }} {{ "#/name" | col 12 | header }} {{ "#/owner" | col 12 | header }} {{ "#/desc" | header }}
 
 {{- range .Catalog.Services}}
-  {{"*" | item }} {{ .Id.String | col 36 }} {{ .Name | col 12 }} {{ .Desc }}
+  {{"*" | item }} {{ .Id.String | col 36 }} {{ .Name | col 12 }} {{ .Owner | col 12 }} {{ .Desc }}
 {{- end}}
 `
 
 	serviceLsVTemplate = `
 Services(Total={{.Num}}):
 
-    {{ "#/id" | col 36 | header }} {{ "#/name" | col 12 | header }} {{ "#/desc" | header }}
+    {{ "#/id" | col 36 | header }} {{ "#/name" | col 12 | header }} {{ "#/owner" | col 12 | header }} {{ "#/desc" | header }}
 
 {{- range .Catalog.Services}}
-  {{"*" | item }} {{ .Id.String | col 36  }} {{ .Name | col 12 }} {{ .Desc }}
+  {{"*" | item }} {{ .Id.String | col 36  }} {{ .Name | col 12 }} {{ .Owner | col 12 }} {{ .Desc }}
 {{- range index $.Catalog.Versions .Id }}
       - {{ .Name }} ({{ .Created | since | info }})
 {{- end}}
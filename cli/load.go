@@ -3,7 +3,6 @@ package cli
 import (
 	"fmt"
 
-	http "github.com/pkopriv2/golang-sdk/http/client"
 	"github.com/pkopriv2/golang-sdk/lang/enc"
 	"github.com/pkopriv2/golang-sdk/lang/tool"
 	"github.com/pkopriv2/services-catalog/core"
@@ -19,31 +18,30 @@ var (
 			Info:  "Loads some services into the catalog",
 			Flags: tool.NewFlags(
 				AddrFlag,
+				TokenFlag,
 				NameFlag,
 				DescFlag,
 				OffsetFlag,
 				LimitFlag),
 			Exec: func(env tool.Environment, c *cli.Context) (err error) {
-				client := svchttp.NewClient(http.NewDefaultClient(c.String(AddrFlag.Name)), enc.Json)
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
 
-				for i := 0; i < 32; i++ {
-					svc, err := client.SaveService(
-						core.NewService(
-							fmt.Sprintf("service-%v", i),
-							fmt.Sprintf("description-%v", i)))
-					if err != nil {
-						return err
-					}
+				client := svchttp.NewClient(raw, enc.Json)
 
-					_, err = client.SaveVersion(
-						core.NewVersion(svc.Id, fmt.Sprintf("version-%v", 0)))
-					if err != nil {
-						return err
-					}
+				// Each service and its versions are written in a single batch.
+				for i := 0; i < 32; i++ {
+					svc := core.NewService(
+						fmt.Sprintf("service-%v", i),
+						fmt.Sprintf("description-%v", i))
 
-					_, err = client.SaveVersion(
-						core.NewVersion(svc.Id, fmt.Sprintf("version-%v", 1)))
-					if err != nil {
+					if _, err := client.SaveBatch([]core.Write{
+						core.SaveServiceWrite(svc),
+						core.SaveVersionWrite(core.NewVersion(svc.Id, fmt.Sprintf("version-%v", 0))),
+						core.SaveVersionWrite(core.NewVersion(svc.Id, fmt.Sprintf("version-%v", 1))),
+					}); err != nil {
 						return err
 					}
 
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	"github.com/pkopriv2/services-catalog/manifest"
+	"github.com/urfave/cli"
+)
+
+var (
+	ManifestFlag = tool.StringFlag{
+		Name:  "filename, f",
+		Usage: "The manifest file or directory of manifests",
+	}
+
+	PruneFlag = tool.BoolFlag{
+		Name:  "prune",
+		Usage: "Delete any service or version that is not declared by a manifest",
+	}
+
+	PlanCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "plan",
+			Usage: "plan -f <path>",
+			Info:  "Shows the changes required to apply manifests",
+			Help: `
+Compares the services declared by the manifests against the live catalog
+and prints the services and versions that would be created, updated or
+(with --prune) deleted.
+`,
+			Flags: tool.NewFlags(AddrFlag, TokenFlag, ManifestFlag, PruneFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewClient(raw, enc.Json)
+
+				plan, err := newPlan(client, c)
+				if err != nil {
+					return
+				}
+
+				plan.Print(env.Terminal.IO.Out)
+				return
+			},
+		})
+
+	ApplyCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "apply",
+			Usage: "apply -f <path>",
+			Info:  "Applies manifests to the catalog",
+			Help: `
+Computes the plan for the manifests and applies it.  Updates and deletes
+only succeed if the service hasn't changed since the plan was computed.
+Plans with up to 1024 changes are applied atomically.  Larger plans are
+applied in several batches, so a failure may leave the plan partially
+applied; the number of changes applied is reported.
+`,
+			Flags: tool.NewFlags(AddrFlag, TokenFlag, ManifestFlag, PruneFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewClient(raw, enc.Json)
+
+				plan, err := newPlan(client, c)
+				if err != nil {
+					return
+				}
+
+				plan.Print(env.Terminal.IO.Out)
+				if plan.Empty() {
+					return
+				}
+
+				writes := plan.Writes()
+				for applied := 0; applied < len(writes); {
+					num := len(writes) - applied
+					if num > core.MaxBatchSize {
+						num = core.MaxBatchSize
+					}
+
+					if _, err = client.SaveBatch(writes[applied : applied+num]); err != nil {
+						if applied > 0 {
+							err = errors.Wrapf(err, "Partially applied plan [%v of %v changes]", applied, len(writes))
+						}
+						return
+					}
+					applied += num
+				}
+
+				fmt.Fprintln(env.Terminal.IO.Out, "Applied plan")
+				return
+			},
+		})
+)
+
+func newPlan(client core.Transport, c *cli.Context) (ret manifest.Plan, err error) {
+	path := c.String("filename")
+	if path == "" {
+		err = errors.Errorf("Missing required flag [--filename]")
+		return
+	}
+
+	services, err := manifest.Load(path)
+	if err != nil {
+		return
+	}
+
+	snapshot, err := client.Export(false)
+	if err != nil {
+		return
+	}
+
+	ret, err = manifest.NewPlan(services, snapshot, c.Bool(PruneFlag.Name))
+	return
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	"github.com/urfave/cli"
+)
+
+var (
+	HistoryFlag = tool.BoolFlag{
+		Name:  "history",
+		Usage: "Include every revision of each service",
+	}
+
+	FormatFlag = tool.StringFlag{
+		Name:  "format",
+		Usage: "The document format. Must be one of [json, yaml] (defaults to the file extension, otherwise json)",
+	}
+
+	OutFlag = tool.StringFlag{
+		Name:  "out",
+		Usage: "The file to write (defaults to stdout)",
+	}
+
+	FileFlag = tool.StringFlag{
+		Name:  "file",
+		Usage: "The file to read",
+	}
+
+	ModeFlag = tool.StringFlag{
+		Name:    "mode",
+		Usage:   "How existing services are handled. Must be one of [merge, replace, skip-existing]",
+		Default: string(core.ImportMerge),
+	}
+
+	ExportCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "export",
+			Usage: "export",
+			Info:  "Exports a snapshot of the catalog",
+			Help: `
+Exports every service and version into a versioned json or yaml document,
+which may later be imported into any catalog.
+`,
+			Flags: tool.NewFlags(
+				AddrFlag,
+				TokenFlag,
+				HistoryFlag,
+				FormatFlag,
+				OutFlag,
+			),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewClient(raw, enc.Json)
+
+				out := c.String(OutFlag.Name)
+
+				format, err := snapshotFormat(c.String(FormatFlag.Name), out)
+				if err != nil {
+					return
+				}
+
+				snapshot, err := client.Export(c.Bool(HistoryFlag.Name))
+				if err != nil {
+					return
+				}
+
+				var body []byte
+				if format == enc.Json {
+					err = enc.Json.EncodeIndent(snapshot, &body)
+				} else {
+					err = format.EncodeBinary(snapshot, &body)
+				}
+				if err != nil {
+					return
+				}
+
+				if out == "" {
+					fmt.Fprintln(env.Terminal.IO.Out, string(body))
+					return
+				}
+
+				if err = ioutil.WriteFile(out, body, 0644); err != nil {
+					return
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out,
+					"Exported [%v] services and [%v] versions to [%v]\n", len(snapshot.Services), len(snapshot.Versions), out)
+				return
+			},
+		})
+
+	ImportCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "import",
+			Usage: "import --file <path>",
+			Info:  "Imports a snapshot into the catalog",
+			Help: `
+Imports a snapshot previously created by the export command.  Ids, revisions
+and timestamps are preserved.  The mode determines how services that already
+exist are handled:
+
+  merge          Adds any missing revisions and versions
+  replace        Replaces the revisions and versions with those of the snapshot
+  skip-existing  Leaves the service untouched
+`,
+			Flags: tool.NewFlags(
+				AddrFlag,
+				TokenFlag,
+				FileFlag,
+				FormatFlag,
+				ModeFlag,
+			),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewClient(raw, enc.Json)
+
+				file := c.String(FileFlag.Name)
+				if file == "" {
+					err = errors.Errorf("Missing required flag [--%v]", FileFlag.Name)
+					return
+				}
+
+				mode, err := core.ParseImportMode(c.String(ModeFlag.Name))
+				if err != nil {
+					return
+				}
+
+				format, err := snapshotFormat(c.String(FormatFlag.Name), file)
+				if err != nil {
+					return
+				}
+
+				body, err := ioutil.ReadFile(file)
+				if err != nil {
+					return
+				}
+
+				var snapshot core.Snapshot
+				if err = format.DecodeBinary(body, &snapshot); err != nil {
+					err = errors.Wrapf(err, "Error reading snapshot [%v]", file)
+					return
+				}
+
+				result, err := client.Import(snapshot, mode)
+				if err != nil {
+					return
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out,
+					"Imported snapshot [created=%v, updated=%v, skipped=%v, revisions=%v, versions=%v]\n",
+					result.Created, result.Updated, result.Skipped, result.Revisions, result.Versions)
+				return
+			},
+		})
+)
+
+// Returns the encoding of a snapshot document.  An explicit format takes
+// precedence over the file extension.
+func snapshotFormat(format, file string) (ret enc.EncoderDecoder, err error) {
+	if format == "" {
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml":
+			format = "yaml"
+		default:
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "json":
+		ret = enc.Json
+	case "yaml":
+		ret = enc.Yaml
+	default:
+		err = errors.Errorf("Invalid format [%v]. Must be one of [json, yaml]", format)
+	}
+	return
+}
@@ -1,25 +1,155 @@
 package cli
 
 import (
+	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
 	http "github.com/pkopriv2/golang-sdk/http/server"
 	"github.com/pkopriv2/golang-sdk/lang/context"
-	"github.com/pkopriv2/golang-sdk/lang/net"
 	"github.com/pkopriv2/golang-sdk/lang/sql"
 	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/cache"
+	"github.com/pkopriv2/services-catalog/config"
+	"github.com/pkopriv2/services-catalog/core"
+	svcgrpc "github.com/pkopriv2/services-catalog/grpc"
+	"github.com/pkopriv2/services-catalog/health"
 	svchttp "github.com/pkopriv2/services-catalog/http"
+	"github.com/pkopriv2/services-catalog/jwt"
+	"github.com/pkopriv2/services-catalog/logfile"
+	"github.com/pkopriv2/services-catalog/metrics"
 	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/pkopriv2/services-catalog/trace"
+	"github.com/pkopriv2/services-catalog/webhook"
 	"github.com/urfave/cli"
+	gogrpc "google.golang.org/grpc"
 )
 
 var (
 	AddrFlag = tool.StringFlag{
 		Name:    "addr",
 		Usage:   "The address to bind",
-		Default: ":8080",
+		Default: defaults.Addr,
+	}
+
+	GrpcAddrFlag = tool.StringFlag{
+		Name:  "grpc-addr",
+		Usage: "The address to bind the gRPC server (disabled if empty)",
+	}
+
+	CacheSizeFlag = tool.UintFlag{
+		Name:  "cache-size",
+		Usage: "The number of service listings to cache (0 disables caching)",
+	}
+
+	CacheTTLFlag = tool.StringFlag{
+		Name:    "cache-ttl",
+		Usage:   "The maximum age of a cached service listing",
+		Default: "30s",
+	}
+
+	IdempotencyWindowFlag = tool.StringFlag{
+		Name:    "idempotency-window",
+		Usage:   "How long the responses to writes with an Idempotency-Key are replayed",
+		Default: "24h",
+	}
+
+	ReadRateFlag = tool.UintFlag{
+		Name:    "read-rate",
+		Usage:   "The reads per second allowed to each client (0 disables limiting)",
+		Default: defaults.RateLimits.Read.Rate,
+	}
+
+	ReadBurstFlag = tool.UintFlag{
+		Name:    "read-burst",
+		Usage:   "The most reads each client may make at once",
+		Default: defaults.RateLimits.Read.Burst,
+	}
+
+	WriteRateFlag = tool.UintFlag{
+		Name:    "write-rate",
+		Usage:   "The writes per second allowed to each client (0 disables limiting)",
+		Default: defaults.RateLimits.Write.Rate,
+	}
+
+	WriteBurstFlag = tool.UintFlag{
+		Name:    "write-burst",
+		Usage:   "The most writes each client may make at once",
+		Default: defaults.RateLimits.Write.Burst,
+	}
+
+	ReadyTimeoutFlag = tool.StringFlag{
+		Name:    "ready-timeout",
+		Usage:   "The deadline of each readiness check",
+		Default: defaults.Timeouts.Ready.Std().String(),
+	}
+
+	AccessLogFlag = tool.StringFlag{
+		Name:  "access-log",
+		Usage: "The file to which json access logs are written (stdout if empty)",
+	}
+
+	AccessLogMaxSizeFlag = tool.UintFlag{
+		Name:    "access-log-max-size",
+		Usage:   "The size in megabytes at which the access log is rotated (0 never rotates)",
+		Default: 100,
+	}
+
+	AccessLogMaxFilesFlag = tool.UintFlag{
+		Name:    "access-log-max-files",
+		Usage:   "The number of rotated access logs that are retained",
+		Default: 5,
+	}
+
+	TraceExporterFlag = tool.StringFlag{
+		Name:    "trace-exporter",
+		Usage:   "The exporter of traces: one of none, json or otlp",
+		Default: "none",
+	}
+
+	TraceFileFlag = tool.StringFlag{
+		Name:  "trace-file",
+		Usage: "The file to which the json exporter writes spans (stdout if empty)",
+	}
+
+	TraceEndpointFlag = tool.StringFlag{
+		Name:    "trace-endpoint",
+		Usage:   "The OTLP/HTTP endpoint to which the otlp exporter sends spans",
+		Default: trace.DefaultOtlpEndpoint,
+	}
+
+	NoAuthFlag = tool.BoolFlag{
+		Name:  "no-auth",
+		Usage: "Disables authentication. Anyone who can reach the server may read and write the catalog",
+	}
+
+	WebhookPrivateFlag = tool.BoolFlag{
+		Name:  "webhook-private-networks",
+		Usage: "Allows webhooks to be delivered to localhost and private networks",
+	}
+
+	JwksFlag = tool.StringFlag{
+		Name:  "jwks",
+		Usage: "A JWKS file or url. Enables JWTs signed by its keys (RS256 or ES256) as bearer tokens",
+	}
+
+	JwtIssuerFlag = tool.StringFlag{
+		Name:  "jwt-issuer",
+		Usage: "The issuer of JWTs (required with --jwks)",
+	}
+
+	JwtAudienceFlag = tool.StringFlag{
+		Name:  "jwt-audience",
+		Usage: "The audience of JWTs (required with --jwks)",
+	}
+
+	JwtRuleFlag = tool.StringsFlag{
+		Name:  "jwt-rule",
+		Usage: "Grants a scope to JWTs whose claim has a value, e.g. groups:platform=admin (repeatable)",
 	}
 
 	StartCommand = tool.NewCommand(
@@ -28,53 +158,501 @@ var (
 			Usage: "start",
 			Info:  "Starts a server instance",
 			Help: `
-Starts a local server.
+Starts a local server.  Requests must carry a bearer token created with
+'catalog token create', unless authentication is disabled.
+
+When a JWKS is given, JWTs issued by an identity provider are also accepted.
+Their claims are mapped to scopes by rules, and the highest scope granted by
+any rule applies.  Tokens that match no rule are forbidden from everything:
+
+  catalog start --jwks https://idp.example.com/.well-known/jwks.json \
+    --jwt-issuer https://idp.example.com --jwt-audience catalog \
+    --jwt-rule groups:engineering=read --jwt-rule groups:platform=admin
+
+Each client (a token, or an ip if unauthenticated) is limited to a rate of
+reads and a separate rate of writes.  Requests over the limit are rejected
+with a 429, and should be retried after the delay in their Retry-After header.
+
+Settings may be read from a yaml file given by --config (or KONGHQ_CONFIG).
+The environment overrides the file, and flags override both:
+
+  catalog start --config /etc/catalog/catalog.yaml --log-level debug
+
+Requests (over http and gRPC) are served over TLS when a certificate and key
+are given, and clients must present a certificate signed by --tls-client-ca
+if it's given.
+
+On SIGINT or SIGTERM, the server reports it isn't ready for the shutdown
+grace, then stops accepting requests and waits up to the shutdown timeout for
+those in flight.  A second signal skips the grace.  On SIGHUP, the config is
+reloaded: the log level, rate limits, read and write timeouts and TLS
+certificates are applied, and any other changes require a restart.
 `,
-			Flags: tool.NewFlags(AddrFlag),
+			Flags: tool.NewFlags(
+				ConfigFlag,
+				AddrFlag,
+				GrpcAddrFlag,
+				LogLevelFlag,
+				StorageFlag,
+				DbFlag,
+				TLSCertFlag,
+				TLSKeyFlag,
+				TLSClientCAFlag,
+				ReadTimeoutFlag,
+				WriteTimeoutFlag,
+				ShutdownGraceFlag,
+				ShutdownTimeoutFlag,
+				CacheSizeFlag,
+				CacheTTLFlag,
+				IdempotencyWindowFlag,
+				ReadRateFlag,
+				ReadBurstFlag,
+				WriteRateFlag,
+				WriteBurstFlag,
+				ReadyTimeoutFlag,
+				AccessLogFlag,
+				AccessLogMaxSizeFlag,
+				AccessLogMaxFilesFlag,
+				TraceExporterFlag,
+				TraceFileFlag,
+				TraceEndpointFlag,
+				NoAuthFlag,
+				WebhookPrivateFlag,
+				JwksFlag,
+				JwtIssuerFlag,
+				JwtAudienceFlag,
+				JwtRuleFlag,
+			),
 			Exec: func(env tool.Environment, c *cli.Context) (err error) {
-				driver, err := dialSqlite(env)
+				cfg, err := loadConfig(c)
 				if err != nil {
 					return
 				}
-				defer driver.Close()
 
-				store, err := svcsql.NewSqlStore(driver, sql.NewSchemaRegistry("KONGHQ"))
+				lvl, err := cfg.Level()
 				if err != nil {
 					return
 				}
 
-				ctx := context.NewContext(os.Stdout, context.Info)
+				// The level is shared by every logger of the server, so that
+				// it may be changed on reload.
+				level := logfile.NewLevel(lvl)
+				ctx := context.NewContextWithLogger(logfile.NewLogger(os.Stdout, level))
 				defer ctx.Close()
 
+				if cfg.Storage.Resolve() == config.Sqlite {
+					unlock, err := lockDb(cfg.Storage.Path)
+					if err != nil {
+						return err
+					}
+					defer unlock()
+				}
+
+				driver, err := dialStorage(ctx, cfg.Storage)
+				if err != nil {
+					return
+				}
+				defer driver.Close()
+
+				stores, err := openStores(driver)
+				if err != nil {
+					return
+				}
+
+				storage, transfers := stores.services, stores.transfers
+				cached, err := newCache(c, stores.services)
+				if err != nil {
+					return
+				}
+				if cached != nil {
+					defer func() {
+						stats := cached.Stats()
+						env.Context.Logger().Info("Cache statistics [hits=%v,misses=%v,ratio=%.2f]",
+							stats.Hits, stats.Misses, stats.HitRatio())
+					}()
+					storage, transfers = cached, cache.NewTransferStorage(transfers, cached)
+				}
+
+				// Storage is instrumented above the cache, so that its metrics
+				// reflect the latency seen by requests.
+				registry := metrics.NewRegistry()
+				storage = metrics.NewStorage(storage, registry)
+
+				// Spans are exported after the server has stopped, so that
+				// those of the final requests aren't lost.
+				tracer, closeTracer, err := newTracer(ctx, c)
+				if err != nil {
+					return
+				}
+				defer closeTracer()
+				if tracer != nil {
+					storage = trace.NewStorage(storage)
+				}
+
+				window, err := time.ParseDuration(c.String(IdempotencyWindowFlag.Name))
+				if err != nil {
+					return
+				}
+
+				accessLog, err := openAccessLog(c)
+				if err != nil {
+					return
+				}
+				defer accessLog.Close()
+
+				auth, err := newAuthenticator(ctx, c, stores.tokens)
+				if err != nil {
+					return
+				}
+
+				live, err := newReloadable(cfg, level)
+				if err != nil {
+					return
+				}
+
+				probes := newProbes(cfg, driver)
+				drainer := live.network.Drainer()
+				opts := append([]http.Option{
+					http.WithListener(live.network, cfg.Addr),
+					http.WithDependency(svchttp.StorageKey, storage),
+					http.WithDependency(svchttp.WebhookStorageKey, stores.hooks),
+					http.WithDependency(svchttp.TransferStorageKey, transfers),
+					http.WithDependency(svchttp.AuditStorageKey, stores.audit),
+					http.WithDependency(svchttp.BackupStorageKey, stores.backups),
+					http.WithDependency(svchttp.MetricsRegistryKey, registry),
+					http.WithDependency(svchttp.HealthRegistryKey, probes),
+				}, middleware(live, drainer, auth, svchttp.NewIdempotencyMiddleware(stores.keys, window), registry, accessLog, tracer)...)
+
+				private := c.Bool(WebhookPrivateFlag.Name)
+
+				var dispatchOpts []webhook.Option
+				if private {
+					dispatchOpts = append(dispatchOpts, webhook.WithPrivateNetworks())
+				}
+
+				dispatcher := webhook.NewDispatcher(ctx, stores.services, stores.hooks, dispatchOpts...)
+				defer dispatcher.Close()
+
 				server, err := http.Serve(ctx,
-					http.Build(svchttp.ServiceHandlers),
-					http.WithListener(net.NewTCP4Network(), c.String(AddrFlag.Name)),
-					http.WithDependency(svchttp.StorageKey, store),
-					http.WithMiddleware(http.TimerMiddleware),
-					http.WithMiddleware(http.RouteMiddleware))
+					http.Build(
+						svchttp.ServiceHandlers,
+						svchttp.NewWebhookHandlers(private),
+						svchttp.TransferHandlers,
+						svchttp.AuditHandlers,
+						svchttp.AdminHandlers,
+						svchttp.GraphQLHandlers,
+						svchttp.MetricsHandlers,
+						svchttp.HealthHandlers,
+						svchttp.OpenAPIHandlers),
+					opts...)
 				if err != nil {
 					return
 				}
 				defer server.Close()
 
+				grpcServer, err := serveGrpc(ctx, cfg, storage, live, auth)
+				if err != nil {
+					return
+				}
+				if grpcServer != nil {
+					defer grpcServer.Close()
+				}
+
+				if cfg.TLS.Enabled() {
+					ctx.Logger().Info("Serving TLS on [%v]", server.Address())
+				}
+
 				sig := make(chan os.Signal, 2)
-				signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-				<-sig
+				signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+				defer signal.Stop(sig)
+
+				running := reload(ctx, c, cfg, live, sig)
+				shutdown(ctx, running, sig, probes, server, drainer, grpcServer)
 				return
 			},
 		})
 )
 
-func dialSqlite(env tool.Environment) (ret sql.Driver, err error) {
-	dbAddr := os.Getenv("KONGHQ_DB_ADDR")
-	switch dbAddr {
-	case "", ":memory:":
-		env.Context.Logger().Info("Using in-memory sqlite instance")
-		ret, err = sql.NewSqlLiteDialer().Embed(env.Context)
+// The stores of the catalog, each of which is kept in the catalog database.
+type stores struct {
+	services  core.Storage
+	transfers core.TransferStorage
+	hooks     core.WebhookStorage
+	audit     core.AuditStorage
+	backups   core.BackupStorage
+	keys      core.IdempotencyStorage
+	tokens    core.TokenStorage
+}
+
+// Opens every store, migrating its schemas if necessary.
+func openStores(driver sql.Driver) (ret stores, err error) {
+	if ret.services, err = svcsql.NewSqlStore(driver, sql.NewSchemaRegistry(SchemaRegistry)); err != nil {
+		return
+	}
+	if ret.transfers, err = svcsql.NewSqlTransferStore(driver, sql.NewSchemaRegistry(SchemaRegistry)); err != nil {
+		return
+	}
+	if ret.hooks, err = svcsql.NewSqlWebhookStore(driver, sql.NewSchemaRegistry(SchemaRegistry)); err != nil {
+		return
+	}
+	if ret.audit, err = svcsql.NewSqlAuditStore(driver, sql.NewSchemaRegistry(SchemaRegistry)); err != nil {
+		return
+	}
+	if ret.backups, err = svcsql.NewSqlBackupStore(driver); err != nil {
+		return
+	}
+	if ret.keys, err = svcsql.NewSqlIdempotencyStore(driver, sql.NewSchemaRegistry(SchemaRegistry)); err != nil {
+		return
+	}
+	ret.tokens, err = svcsql.NewSqlTokenStore(driver, sql.NewSchemaRegistry(SchemaRegistry))
+	return
+}
+
+// Returns the cache of service listings, if caching is enabled.
+func newCache(c *cli.Context, storage core.Storage) (ret *cache.Storage, err error) {
+	size := c.Uint(CacheSizeFlag.Name)
+	if size == 0 {
+		return
+	}
+
+	ttl, err := time.ParseDuration(c.String(CacheTTLFlag.Name))
+	if err != nil {
+		return
+	}
+
+	ret = cache.NewStorage(storage, cache.WithSize(int(size)), cache.WithTTL(ttl))
+	return
+}
+
+// Returns the tracer of the configured exporter, if tracing is enabled.  The
+// returned function closes the tracer and then the file it exports to.
+func newTracer(ctx context.Context, c *cli.Context) (ret *trace.Tracer, closer func(), err error) {
+	closer = func() {}
+	switch exporter := c.String(TraceExporterFlag.Name); exporter {
+	case "none":
+		return
+	case "json":
+		var out io.Writer = os.Stdout
+		var file *logfile.File
+		if path := c.String(TraceFileFlag.Name); path != "" {
+			if file, err = logfile.Open(path); err != nil {
+				return
+			}
+			out = file
+		}
+
+		ret = trace.NewTracer(ctx, trace.NewJsonExporter(out))
+		closer = func() {
+			ret.Close()
+			if file != nil {
+				file.Close()
+			}
+		}
+	case "otlp":
+		ret = trace.NewTracer(ctx, trace.NewOtlpExporter(c.String(TraceEndpointFlag.Name)))
+		closer = func() {
+			ret.Close()
+		}
+	default:
+		err = errors.Errorf("Invalid flag --%v [%v]", TraceExporterFlag.Name, exporter)
+	}
+	return
+}
+
+// Opens the access log, which is stdout unless a file is given.
+func openAccessLog(c *cli.Context) (ret io.WriteCloser, err error) {
+	path := c.String(AccessLogFlag.Name)
+	if path == "" {
+		ret = nopCloser{os.Stdout}
+		return
+	}
+
+	ret, err = logfile.Open(path,
+		logfile.WithMaxSize(int64(c.Uint(AccessLogMaxSizeFlag.Name))<<20),
+		logfile.WithMaxBackups(int(c.Uint(AccessLogMaxFilesFlag.Name))))
+	return
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// The instance is ready once its storage answers and its schemas are
+// migrated, until it begins to drain.
+func newProbes(cfg config.Config, driver sql.Driver) (ret *health.Registry) {
+	ret = health.NewRegistry(health.WithTimeout(cfg.Timeouts.Ready.Std()))
+	ret.Register("storage", svcsql.NewPingCheck(driver))
+	ret.Register("migrations", svcsql.NewMigrationCheck(driver, SchemaRegistry))
+	return
+}
+
+// Returns the authenticator of requests, which accepts the catalog's tokens
+// and any JWTs.  Returns nil if authentication is disabled.
+func newAuthenticator(ctx context.Context, c *cli.Context, tokens core.TokenStorage) (ret core.Authenticator, err error) {
+	if c.Bool(NoAuthFlag.Name) {
+		ctx.Logger().Info("Authentication is disabled")
+		return
+	}
+
+	jwts, err := newJwtAuthenticator(c)
+	if err != nil {
 		return
 	}
 
-	env.Context.Logger().Info("Using sqlite driver [%v]", dbAddr)
-	ret, err = sql.NewSqlLiteDialer().Connect(env.Context, dbAddr)
+	existing, err := tokens.ListTokens()
+	if err != nil {
+		return
+	}
+	if len(existing) == 0 && jwts == nil {
+		ctx.Logger().Info("No tokens exist. Create one with 'catalog token create' or start with --%v", NoAuthFlag.Name)
+	}
+
+	ret = core.NewAuthenticator(tokens, jwts)
 	return
 }
+
+// Returns the middleware of the http server, in the order it's installed.
+//
+// Authentication must run before any other middleware that acts on the
+// request, so it's installed last but for those that observe every request.
+// Clients are rate limited by their identity, so the rate limit runs just
+// after authentication.  Requests may be read by any middleware, so the
+// drainer runs first.
+func middleware(live *reloadable, drainer *svchttp.Drainer, auth core.Authenticator, idempotency http.Middleware, registry *metrics.Registry, accessLog io.Writer, tracer *trace.Tracer) (ret []http.Option) {
+	ret = []http.Option{
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(idempotency),
+		http.WithMiddleware(svchttp.NewRateLimiterMiddleware(live.reads, live.writes)),
+	}
+	if auth != nil {
+		ret = append(ret, http.WithMiddleware(svchttp.NewAuthMiddleware(auth)))
+	}
+	ret = append(ret,
+		http.WithMiddleware(svchttp.NewMetricsMiddleware(registry)),
+		http.WithMiddleware(svchttp.NewAccessLogMiddleware(accessLog)))
+	if tracer != nil {
+		ret = append(ret, http.WithMiddleware(svchttp.NewTraceMiddleware(tracer)))
+	}
+	ret = append(ret,
+		http.WithMiddleware(svchttp.NewRequestIdMiddleware()),
+		http.WithMiddleware(drainer.Middleware()))
+	return
+}
+
+// Serves gRPC, if an address is configured.  The gRPC server shares the
+// rate limits and certificates of the http server, including those reloaded.
+func serveGrpc(ctx context.Context, cfg config.Config, storage core.Storage, live *reloadable, auth core.Authenticator) (ret *svcgrpc.Server, err error) {
+	if cfg.GrpcAddr == "" {
+		return
+	}
+
+	var opts []gogrpc.ServerOption
+	if auth != nil {
+		opts = svcgrpc.AuthOptions(auth)
+	}
+	opts = append(opts, svcgrpc.RateLimitOptions(live.reads, live.writes)...)
+	if cfg.TLS.Enabled() {
+		opts = append(opts, svcgrpc.TLSOption(live.network.TLS))
+	}
+
+	ret, err = svcgrpc.Serve(ctx, storage, cfg.GrpcAddr, opts...)
+	return
+}
+
+// Reloads the config on every SIGHUP, until any other signal is received.
+// Returns the config the server is running with.
+func reload(ctx context.Context, c *cli.Context, cfg config.Config, live *reloadable, sig <-chan os.Signal) (running config.Config) {
+	running = cfg
+	for s := <-sig; s == syscall.SIGHUP; s = <-sig {
+		next, err := loadConfig(c)
+		if err == nil {
+			err = live.apply(next)
+		}
+		if err != nil {
+			ctx.Logger().Error("Unable to reload config. Keeping the current config: %+v", err)
+			continue
+		}
+
+		// Settings that require a restart are compared with those the
+		// server started with, since they're never applied.
+		if pending := cfg.RequiresRestart(next); len(pending) > 0 {
+			ctx.Logger().Info("Changes to %v require a restart", pending)
+		}
+		ctx.Logger().Info("Reloaded config")
+		running = next
+	}
+	return
+}
+
+// Returns the authenticator of JWTs, if a JWKS is configured.
+func newJwtAuthenticator(c *cli.Context) (ret core.Authenticator, err error) {
+	jwks := c.String(JwksFlag.Name)
+	if jwks == "" {
+		return
+	}
+
+	var rules []jwt.Rule
+	for _, raw := range c.StringSlice(JwtRuleFlag.Name) {
+		rule, err := jwt.ParseRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return jwt.NewVerifier(jwks,
+		jwt.WithIssuer(c.String(JwtIssuerFlag.Name)),
+		jwt.WithAudience(c.String(JwtAudienceFlag.Name)),
+		jwt.WithRules(rules...))
+}
+
+func dialStorage(ctx context.Context, cfg config.Storage) (ret sql.Driver, err error) {
+	if cfg.Resolve() == config.Memory {
+		ctx.Logger().Info("Using in-memory sqlite instance")
+		ret, err = sql.NewSqlLiteDialer().Embed(ctx)
+		return
+	}
+
+	ctx.Logger().Info("Using sqlite driver [%v]", cfg.Path)
+	ret, err = sql.NewSqlLiteDialer().Connect(ctx, cfg.Path)
+	return
+}
+
+// Drains the server.  It first reports it isn't ready, so that it's removed
+// from its load balancers, then stops accepting requests and waits for
+// those in flight.  Another signal cuts the grace short.
+func shutdown(ctx context.Context, cfg config.Config, sig <-chan os.Signal, probes *health.Registry, server *http.Server, drainer *svchttp.Drainer, grpcServer *svcgrpc.Server) {
+	probes.Drain()
+
+	grace := cfg.Timeouts.Grace.Std()
+	ctx.Logger().Info("Draining. Waiting [%v] before closing the listener", grace)
+	select {
+	case <-time.After(grace):
+	case <-sig:
+	}
+
+	timeout := cfg.Timeouts.Shutdown.Std()
+	if err := server.Close(); err != nil {
+		ctx.Logger().Error("Error closing listener: %+v", err)
+	}
+
+	var wait sync.WaitGroup
+	if grpcServer != nil {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			grpcServer.Drain(timeout)
+		}()
+	}
+
+	if err := drainer.Drain(timeout); err != nil {
+		ctx.Logger().Error("Shutting down: %+v", err)
+	}
+	wait.Wait()
+	ctx.Logger().Info("Shut down")
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/lang/config"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/pkopriv2/services-catalog/trace"
+	uuid "github.com/satori/go.uuid"
+	"github.com/urfave/cli"
+)
+
+// The config key of the token sent by clients, when no token is given.
+const TokenConfigKey = "auth.token"
+
+var (
+	TokenFlag = tool.StringFlag{
+		Name:  "token",
+		Usage: "The token to authenticate with (defaults to KONGHQ_TOKEN or auth.token in ~/.kong/config.yaml)",
+	}
+
+	TokenNameFlag = tool.StringFlag{
+		Name:  "name",
+		Usage: "The name of the token",
+	}
+
+	TokenScopeFlag = tool.StringFlag{
+		Name:    "scope",
+		Usage:   "The scope of the token [read,write,admin]",
+		Default: "read",
+	}
+
+	TokenTTLFlag = tool.StringFlag{
+		Name:  "ttl",
+		Usage: "How long the token is valid (never expires if empty)",
+	}
+
+	TokenTeamFlag = tool.StringsFlag{
+		Name:  "team",
+		Usage: "A team the token's principal is a member of. May be repeated",
+	}
+
+	CreateTokenCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "create",
+			Usage: "create --name <name>",
+			Info:  "Creates a token",
+			Help: `
+Creates a token and prints its secret.  The secret is never stored, so it
+cannot be printed again.  Tokens are written directly to the database, so
+the first token may be created before the server is started.
+
+The principal of the token is a member of its teams, so it may modify the
+services those teams own.
+`,
+			Flags: tool.NewFlags(DbFlag, TokenNameFlag, TokenScopeFlag, TokenTTLFlag, TokenTeamFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				name := c.String(TokenNameFlag.Name)
+				if name == "" {
+					err = errors.Errorf("Missing required flag --%v", TokenNameFlag.Name)
+					return
+				}
+
+				scope, err := core.ParseScope(c.String(TokenScopeFlag.Name))
+				if err != nil {
+					return
+				}
+
+				var ttl time.Duration
+				if raw := c.String(TokenTTLFlag.Name); raw != "" {
+					if ttl, err = time.ParseDuration(raw); err != nil {
+						return
+					}
+				}
+
+				store, driver, err := openTokenStore(env, c)
+				if err != nil {
+					return
+				}
+				defer driver.Close()
+
+				token, secret, err := core.NewToken(name, scope, ttl, c.StringSlice(TokenTeamFlag.Name)...)
+				if err != nil {
+					return
+				}
+
+				if err = store.SaveToken(token); err != nil {
+					return
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out, "Created token [%v] (%v)\n\n%v\n", token.Name, token.Id, secret)
+				return
+			},
+		})
+
+	ListTokensCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "list",
+			Usage: "list",
+			Info:  "Lists the tokens",
+			Flags: tool.NewFlags(DbFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				store, driver, err := openTokenStore(env, c)
+				if err != nil {
+					return
+				}
+				defer driver.Close()
+
+				tokens, err := store.ListTokens()
+				if err != nil {
+					return
+				}
+
+				w := tabwriter.NewWriter(env.Terminal.IO.Out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tNAME\tSCOPE\tTEAMS\tCREATED\tEXPIRES")
+				for _, t := range tokens {
+					expires := "never"
+					if !t.Expires.IsZero() {
+						expires = t.Expires.Format(time.RFC3339)
+					}
+					fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", t.Id, t.Name, t.Scope, strings.Join(t.Teams, ","), t.Created.Format(time.RFC3339), expires)
+				}
+				return w.Flush()
+			},
+		})
+
+	RevokeTokenCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "revoke",
+			Usage: "revoke <id>",
+			Info:  "Revokes a token",
+			Flags: tool.NewFlags(DbFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				id, err := uuid.FromString(c.Args().First())
+				if err != nil {
+					err = errors.Wrapf(err, "Missing or invalid argument <id>")
+					return
+				}
+
+				store, driver, err := openTokenStore(env, c)
+				if err != nil {
+					return
+				}
+				defer driver.Close()
+
+				if err = store.RevokeToken(id); err != nil {
+					return
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out, "Revoked token [%v]\n", id)
+				return
+			},
+		})
+
+	TokenCommand = tool.NewGroup(
+		tool.GroupDef{
+			Name: "token",
+			Info: "Manages the tokens that authenticate with the server",
+		},
+		CreateTokenCommand,
+		ListTokensCommand,
+		RevokeTokenCommand)
+)
+
+// Opens the token store of the database.  The driver must be closed once
+// the store is no longer needed.
+func openTokenStore(env tool.Environment, c *cli.Context) (ret core.TokenStorage, driver sql.Driver, err error) {
+	db, err := dbFile(c)
+	if err != nil {
+		return
+	}
+
+	driver, err = sql.NewSqlLiteDialer().Connect(env.Context, db)
+	if err != nil {
+		return
+	}
+
+	if ret, err = svcsql.NewSqlTokenStore(driver, sql.NewSchemaRegistry(SchemaRegistry)); err != nil {
+		driver.Close()
+	}
+	return
+}
+
+// Returns a client of the server, which authenticates with the configured
+// token (if any).
+func connect(env tool.Environment, c *cli.Context) (ret http.Client, err error) {
+	ret = http.NewDefaultClient(c.String(AddrFlag.Name))
+
+	token := c.String(TokenFlag.Name)
+	if token == "" {
+		token = os.Getenv("KONGHQ_TOKEN")
+	}
+	if token == "" {
+		if _, err = env.Config.Get(TokenConfigKey, config.String, &token); err != nil {
+			return
+		}
+	}
+
+	if token != "" {
+		ret = svchttp.NewBearerClient(ret, token)
+	}
+
+	// Deploy tooling may invoke the cli within a trace, which the server
+	// then joins.
+	if header := os.Getenv("TRACEPARENT"); header != "" {
+		parent, err := trace.ParseTraceparent(header)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid environment variable TRACEPARENT")
+		}
+		ret = svchttp.NewTracingClient(ret, nil, parent)
+	}
+	return
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	uuid "github.com/satori/go.uuid"
+	"github.com/urfave/cli"
+)
+
+var (
+	TransferToFlag = tool.StringFlag{
+		Name:  "to",
+		Usage: "The team to transfer the service to",
+	}
+
+	RequestTransferCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "request",
+			Usage: "request <service-id> --to <team>",
+			Info:  "Requests the transfer of a service to another team",
+			Help: `
+Requests the transfer of a service to another team.  The request consents
+on behalf of the caller's teams, so the transfer completes once a member
+of the other team approves it.
+`,
+			Flags: tool.NewFlags(AddrFlag, TokenFlag, TransferToFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				id, err := uuid.FromString(c.Args().First())
+				if err != nil {
+					err = errors.Wrapf(err, "Missing or invalid argument <service-id>")
+					return
+				}
+
+				to := c.String(TransferToFlag.Name)
+				if to == "" {
+					err = errors.Errorf("Missing required flag --%v", TransferToFlag.Name)
+					return
+				}
+
+				client, err := connectTransfers(env, c)
+				if err != nil {
+					return
+				}
+
+				transfer, err := client.RequestTransfer(id, to)
+				if err != nil {
+					return
+				}
+
+				printTransfer(env, transfer)
+				return
+			},
+		})
+
+	ApproveTransferCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "approve",
+			Usage: "approve <id>",
+			Info:  "Approves a transfer on behalf of the team that has yet to consent",
+			Flags: tool.NewFlags(AddrFlag, TokenFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				id, err := uuid.FromString(c.Args().First())
+				if err != nil {
+					err = errors.Wrapf(err, "Missing or invalid argument <id>")
+					return
+				}
+
+				client, err := connectTransfers(env, c)
+				if err != nil {
+					return
+				}
+
+				transfer, err := client.ApproveTransfer(id)
+				if err != nil {
+					return
+				}
+
+				printTransfer(env, transfer)
+				return
+			},
+		})
+
+	CancelTransferCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "cancel",
+			Usage: "cancel <id>",
+			Info:  "Cancels a pending transfer",
+			Flags: tool.NewFlags(AddrFlag, TokenFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				id, err := uuid.FromString(c.Args().First())
+				if err != nil {
+					err = errors.Wrapf(err, "Missing or invalid argument <id>")
+					return
+				}
+
+				client, err := connectTransfers(env, c)
+				if err != nil {
+					return
+				}
+
+				if err = client.CancelTransfer(id); err != nil {
+					return
+				}
+
+				fmt.Fprintf(env.Terminal.IO.Out, "Cancelled transfer [%v]\n", id)
+				return
+			},
+		})
+
+	ListTransfersCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "list",
+			Usage: "list <service-id>",
+			Info:  "Lists the transfers of a service",
+			Flags: tool.NewFlags(AddrFlag, TokenFlag),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				id, err := uuid.FromString(c.Args().First())
+				if err != nil {
+					err = errors.Wrapf(err, "Missing or invalid argument <service-id>")
+					return
+				}
+
+				client, err := connectTransfers(env, c)
+				if err != nil {
+					return
+				}
+
+				transfers, err := client.ListTransfers(id)
+				if err != nil {
+					return
+				}
+
+				w := tabwriter.NewWriter(env.Terminal.IO.Out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tFROM\tTO\tFROM_BY\tTO_BY\tCREATED\tCOMPLETED")
+				for _, t := range transfers {
+					completed := "pending"
+					if !t.Pending() {
+						completed = t.Completed.Format(time.RFC3339)
+					}
+					fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+						t.Id, t.From, t.To, t.FromBy, t.ToBy, t.Created.Format(time.RFC3339), completed)
+				}
+				return w.Flush()
+			},
+		})
+
+	TransferCommand = tool.NewGroup(
+		tool.GroupDef{
+			Name: "transfer",
+			Info: "Transfers the ownership of services between teams",
+		},
+		RequestTransferCommand,
+		ApproveTransferCommand,
+		CancelTransferCommand,
+		ListTransfersCommand)
+)
+
+func connectTransfers(env tool.Environment, c *cli.Context) (ret core.TransferTransport, err error) {
+	raw, err := connect(env, c)
+	if err != nil {
+		return
+	}
+
+	ret = svchttp.NewTransferClient(raw, enc.Json)
+	return
+}
+
+func printTransfer(env tool.Environment, t core.Transfer) {
+	if t.Pending() {
+		fmt.Fprintf(env.Terminal.IO.Out, "Transfer [%v] of service [%v] from [%v] to [%v] is pending approval\n", t.Id, t.ServiceId, t.From, t.To)
+		return
+	}
+	fmt.Fprintf(env.Terminal.IO.Out, "Transferred service [%v] from [%v] to [%v]\n", t.ServiceId, t.From, t.To)
+}
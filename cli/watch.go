@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/tool"
+	"github.com/pkopriv2/services-catalog/core"
+	svchttp "github.com/pkopriv2/services-catalog/http"
+	uuid "github.com/satori/go.uuid"
+	"github.com/urfave/cli"
+)
+
+var (
+	SinceFlag = tool.UintFlag{
+		Name:  "since",
+		Usage: "Resume watching after the given change sequence",
+	}
+
+	FromBeginningFlag = tool.BoolFlag{
+		Name:  "from-beginning",
+		Usage: "Replay every change in the log before watching",
+	}
+
+	WatchCommand = tool.NewCommand(
+		tool.CommandDef{
+			Name:  "watch",
+			Usage: "watch",
+			Info:  "Prints changes to the catalog as they happen",
+			Flags: tool.NewFlags(
+				AddrFlag,
+				TokenFlag,
+				NameFlag,
+				DescFlag,
+				IdFlag,
+				SinceFlag,
+				FromBeginningFlag,
+			),
+			Exec: func(env tool.Environment, c *cli.Context) (err error) {
+				raw, err := connect(env, c)
+				if err != nil {
+					return
+				}
+
+				client := svchttp.NewClient(raw, enc.Json)
+
+				filter := core.NewFilter()
+				if name := c.String(NameFlag.Name); name != "" {
+					filter = filter.Update(core.FilterByName(name))
+				}
+				if desc := c.String(DescFlag.Name); desc != "" {
+					filter = filter.Update(core.FilterByDesc(desc))
+				}
+				if raw := c.String(IdFlag.Name); raw != "" {
+					id, err := uuid.FromString(raw)
+					if err != nil {
+						return err
+					}
+
+					filter = filter.Update(core.FilterByServiceId(id))
+				}
+
+				since := uint64(c.Uint(SinceFlag.Name))
+				if c.Bool(FromBeginningFlag.Name) {
+					since = core.FromBeginning
+				}
+
+				watcher, err := client.Watch(filter, since)
+				if err != nil {
+					return
+				}
+				defer watcher.Close()
+
+				sig := make(chan os.Signal, 2)
+				signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+				for {
+					select {
+					case <-sig:
+						return
+					case change, ok := <-watcher.Changes():
+						if !ok {
+							return watcher.Err()
+						}
+
+						if err = tool.DisplayStdOut(env, changeTemplate, tool.WithData(change)); err != nil {
+							return
+						}
+					}
+				}
+			},
+		})
+)
+
+var (
+	changeTemplate = `
+{{- if .Service }}
+  {{"*" | item }} {{ .Seq | printf "%6d" | info }} {{ .Type | printf "%-14v" }} {{ .Service.Id.String | col 36 }} {{ .Service.Name | col 12 }} (version={{ .Service.Version }})
+{{- else }}
+  {{"*" | item }} {{ .Seq | printf "%6d" | info }} {{ .Type | printf "%-14v" }} {{ .Version.ServiceId.String | col 36 }} {{ .Version.Name }}
+{{- end }}
+`
+)
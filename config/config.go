@@ -0,0 +1,292 @@
+package config
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+var (
+	ErrInvalid = errors.New("Config:ErrInvalid")
+)
+
+// The supported storage backends.  The memory backend is lost when the
+// server stops.
+const (
+	Memory = "memory"
+	Sqlite = "sqlite"
+)
+
+// The configuration of the server.  Settings are read from a yaml file,
+// then overridden by the environment, and finally by flags.  For example:
+//
+//	addr: :8443
+//	log_level: info
+//	storage:
+//	  backend: sqlite
+//	  path: /var/lib/catalog/catalog.db
+//	tls:
+//	  cert: /etc/catalog/tls.crt
+//	  key: /etc/catalog/tls.key
+//	  client_ca: /etc/catalog/clients.crt
+//	timeouts:
+//	  read: 30s
+//	  shutdown: 30s
+//	rate_limits:
+//	  read: {rate: 100, burst: 200}
+//	  write: {rate: 20, burst: 40}
+type Config struct {
+	Addr       string     `yaml:"addr"`
+	GrpcAddr   string     `yaml:"grpc_addr"`
+	LogLevel   string     `yaml:"log_level"`
+	Storage    Storage    `yaml:"storage"`
+	TLS        TLS        `yaml:"tls"`
+	Timeouts   Timeouts   `yaml:"timeouts"`
+	RateLimits RateLimits `yaml:"rate_limits"`
+}
+
+type Storage struct {
+	Backend string `yaml:"backend"` // inferred from the path if empty
+	Path    string `yaml:"path"`
+}
+
+// Returns the backend, inferring it from the path if it's unset.  A path
+// of :memory: is the memory backend, as it is to sqlite.
+func (s Storage) Resolve() string {
+	if s.Backend != "" {
+		return s.Backend
+	}
+	if s.Path == "" || s.Path == ":memory:" {
+		return Memory
+	}
+	return Sqlite
+}
+
+// Requests are served over TLS if a certificate is given.  Clients must
+// present a certificate signed by the client CA, if one is given.
+type TLS struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	ClientCA string `yaml:"client_ca"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.Cert != "" || t.Key != ""
+}
+
+// A zero read or write timeout is unlimited.
+type Timeouts struct {
+	Read     Duration `yaml:"read"`     // the time a client has to send a request
+	Write    Duration `yaml:"write"`    // the time the server has to write a response
+	Ready    Duration `yaml:"ready"`    // the deadline of each readiness check
+	Grace    Duration `yaml:"grace"`    // how long the server fails readiness before it stops accepting requests
+	Shutdown Duration `yaml:"shutdown"` // how long the server waits for requests in flight to finish
+}
+
+type RateLimits struct {
+	Read  core.RateLimit `yaml:"read"`
+	Write core.RateLimit `yaml:"write"`
+}
+
+// A duration that's written as a string in yaml (e.g. 30s).
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+	var raw string
+	if err = unmarshal(&raw); err != nil {
+		return
+	}
+
+	dur, err := time.ParseDuration(raw)
+	if err != nil {
+		return
+	}
+
+	*d = Duration(dur)
+	return
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// Returns the configuration used in the absence of any other.
+func Default() Config {
+	return Config{
+		Addr:     ":8080",
+		LogLevel: "info",
+		Timeouts: Timeouts{
+			Read:     Duration(30 * time.Second),
+			Ready:    Duration(2 * time.Second),
+			Grace:    Duration(5 * time.Second),
+			Shutdown: Duration(30 * time.Second),
+		},
+		RateLimits: RateLimits{
+			Read:  core.RateLimit{Rate: 100, Burst: 200},
+			Write: core.RateLimit{Rate: 20, Burst: 40},
+		},
+	}
+}
+
+// Loads the configuration from a yaml file.  Settings missing from the
+// file keep their defaults.
+func Load(path string) (ret Config, err error) {
+	ret = Default()
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if err = enc.Yaml.DecodeBinary(raw, &ret); err != nil {
+		err = errors.Wrapf(ErrInvalid, "Invalid config file [%v]: %v", path, err)
+	}
+	return
+}
+
+// The environment variables that override the configuration.
+const (
+	EnvAddr            = "KONGHQ_ADDR"
+	EnvGrpcAddr        = "KONGHQ_GRPC_ADDR"
+	EnvLogLevel        = "KONGHQ_LOG_LEVEL"
+	EnvStorage         = "KONGHQ_STORAGE"
+	EnvDbAddr          = "KONGHQ_DB_ADDR"
+	EnvTLSCert         = "KONGHQ_TLS_CERT"
+	EnvTLSKey          = "KONGHQ_TLS_KEY"
+	EnvTLSClientCA     = "KONGHQ_TLS_CLIENT_CA"
+	EnvReadTimeout     = "KONGHQ_READ_TIMEOUT"
+	EnvWriteTimeout    = "KONGHQ_WRITE_TIMEOUT"
+	EnvShutdownGrace   = "KONGHQ_SHUTDOWN_GRACE"
+	EnvShutdownTimeout = "KONGHQ_SHUTDOWN_TIMEOUT"
+)
+
+// Overrides the configuration with the variables found by the lookup
+// (e.g. os.LookupEnv).
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) (err error) {
+	overrides := []struct {
+		name  string
+		apply func(string) error
+	}{
+		{EnvAddr, setString(&c.Addr)},
+		{EnvGrpcAddr, setString(&c.GrpcAddr)},
+		{EnvLogLevel, setString(&c.LogLevel)},
+		{EnvStorage, setString(&c.Storage.Backend)},
+		{EnvDbAddr, setString(&c.Storage.Path)},
+		{EnvTLSCert, setString(&c.TLS.Cert)},
+		{EnvTLSKey, setString(&c.TLS.Key)},
+		{EnvTLSClientCA, setString(&c.TLS.ClientCA)},
+		{EnvReadTimeout, setDuration(&c.Timeouts.Read)},
+		{EnvWriteTimeout, setDuration(&c.Timeouts.Write)},
+		{EnvShutdownGrace, setDuration(&c.Timeouts.Grace)},
+		{EnvShutdownTimeout, setDuration(&c.Timeouts.Shutdown)},
+	}
+
+	for _, o := range overrides {
+		val, ok := lookup(o.name)
+		if !ok {
+			continue
+		}
+		if err = o.apply(val); err != nil {
+			return errors.Wrapf(ErrInvalid, "Invalid environment variable [%v=%v]: %v", o.name, val, err)
+		}
+	}
+	return
+}
+
+func setString(ptr *string) func(string) error {
+	return func(val string) error {
+		*ptr = val
+		return nil
+	}
+}
+
+func setDuration(ptr *Duration) func(string) error {
+	return func(val string) (err error) {
+		dur, err := time.ParseDuration(val)
+		if err == nil {
+			*ptr = Duration(dur)
+		}
+		return
+	}
+}
+
+// Returns the level of the server's logs.
+func (c Config) Level() (context.LogLevel, error) {
+	return context.ParseLogLevel(c.LogLevel)
+}
+
+func (c Config) Validate() error {
+	if c.Addr == "" {
+		return errors.Wrapf(ErrInvalid, "Missing address")
+	}
+	if _, err := c.Level(); err != nil {
+		return errors.Wrapf(ErrInvalid, "Invalid log level [%v]", c.LogLevel)
+	}
+
+	switch c.Storage.Resolve() {
+	case Memory:
+	case Sqlite:
+		if c.Storage.Path == "" {
+			return errors.Wrapf(ErrInvalid, "The sqlite backend requires a path")
+		}
+	default:
+		return errors.Wrapf(ErrInvalid, "Invalid storage backend [%v]. Must be one of [%v, %v]", c.Storage.Backend, Memory, Sqlite)
+	}
+
+	if c.TLS.Enabled() && (c.TLS.Cert == "" || c.TLS.Key == "") {
+		return errors.Wrapf(ErrInvalid, "TLS requires both a certificate and a key")
+	}
+	if c.TLS.ClientCA != "" && !c.TLS.Enabled() {
+		return errors.Wrapf(ErrInvalid, "A client CA requires TLS")
+	}
+
+	for name, d := range map[string]Duration{
+		"read":     c.Timeouts.Read,
+		"write":    c.Timeouts.Write,
+		"ready":    c.Timeouts.Ready,
+		"grace":    c.Timeouts.Grace,
+		"shutdown": c.Timeouts.Shutdown,
+	} {
+		if d < 0 {
+			return errors.Wrapf(ErrInvalid, "Negative %v timeout [%v]", name, d.Std())
+		}
+	}
+	if c.Timeouts.Ready == 0 {
+		return errors.Wrapf(ErrInvalid, "The ready timeout must be positive")
+	}
+	return nil
+}
+
+// Returns the settings that differ from the next configuration, but which
+// only take effect once the server restarts.  The log level, rate limits,
+// timeouts (but for the ready timeout) and the TLS certificates (though not
+// whether TLS is enabled) may change while the server is running.
+func (c Config) RequiresRestart(next Config) (ret []string) {
+	if c.Addr != next.Addr {
+		ret = append(ret, "addr")
+	}
+	if c.GrpcAddr != next.GrpcAddr {
+		ret = append(ret, "grpc_addr")
+	}
+	if c.Storage.Resolve() != next.Storage.Resolve() {
+		ret = append(ret, "storage.backend")
+	}
+	if c.Storage.Path != next.Storage.Path {
+		ret = append(ret, "storage.path")
+	}
+	if c.TLS.Enabled() != next.TLS.Enabled() {
+		ret = append(ret, "tls")
+	}
+	if c.Timeouts.Ready != next.Timeouts.Ready {
+		ret = append(ret, "timeouts.ready")
+	}
+	return
+}
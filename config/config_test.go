@@ -0,0 +1,192 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/tlstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if !assert.Nil(t, ioutil.WriteFile(path, []byte(data), 0600)) {
+			t.FailNow()
+		}
+		return path
+	}
+
+	if !t.Run("Default_Valid", func(t *testing.T) {
+		cfg := Default()
+		assert.Nil(t, cfg.Validate())
+		assert.Equal(t, Memory, cfg.Storage.Resolve())
+		assert.False(t, cfg.TLS.Enabled())
+	}) {
+		return
+	}
+
+	if !t.Run("Load", func(t *testing.T) {
+		cfg, err := Load(write("catalog.yaml", `
+addr: :8443
+log_level: debug
+storage:
+  path: /var/lib/catalog.db
+timeouts:
+  read: 10s
+  shutdown: 1m
+rate_limits:
+  write: {rate: 5, burst: 10}
+`))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, ":8443", cfg.Addr)
+		assert.Equal(t, "debug", cfg.LogLevel)
+		assert.Equal(t, Sqlite, cfg.Storage.Resolve())
+		assert.Equal(t, "/var/lib/catalog.db", cfg.Storage.Path)
+		assert.Equal(t, 10*time.Second, cfg.Timeouts.Read.Std())
+		assert.Equal(t, time.Minute, cfg.Timeouts.Shutdown.Std())
+		assert.Equal(t, core.RateLimit{Rate: 5, Burst: 10}, cfg.RateLimits.Write)
+
+		// Settings missing from the file keep their defaults
+		assert.Equal(t, Default().Timeouts.Ready, cfg.Timeouts.Ready)
+		assert.Equal(t, Default().RateLimits.Read, cfg.RateLimits.Read)
+	}) {
+		return
+	}
+
+	if !t.Run("Load_Invalid", func(t *testing.T) {
+		_, err := Load(write("invalid.yaml", "timeouts:\n  read: soon\n"))
+		assert.True(t, errors.Is(err, ErrInvalid))
+
+		_, err = Load(filepath.Join(dir, "missing.yaml"))
+		assert.NotNil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("ApplyEnv", func(t *testing.T) {
+		env := map[string]string{
+			EnvAddr:            ":9090",
+			EnvDbAddr:          ":memory:",
+			EnvShutdownTimeout: "5s",
+		}
+
+		cfg := Default()
+		cfg.Addr, cfg.LogLevel = ":8443", "debug"
+		if !assert.Nil(t, cfg.ApplyEnv(func(name string) (val string, ok bool) {
+			val, ok = env[name]
+			return
+		})) {
+			return
+		}
+
+		assert.Equal(t, ":9090", cfg.Addr)
+		assert.Equal(t, "debug", cfg.LogLevel)
+		assert.Equal(t, Memory, cfg.Storage.Resolve())
+		assert.Equal(t, 5*time.Second, cfg.Timeouts.Shutdown.Std())
+	}) {
+		return
+	}
+
+	if !t.Run("ApplyEnv_Invalid", func(t *testing.T) {
+		cfg := Default()
+		err := cfg.ApplyEnv(func(name string) (string, bool) {
+			return "soon", name == EnvReadTimeout
+		})
+		assert.True(t, errors.Is(err, ErrInvalid))
+	}) {
+		return
+	}
+
+	if !t.Run("Validate", func(t *testing.T) {
+		invalid := map[string]func(*Config){
+			"addr":       func(c *Config) { c.Addr = "" },
+			"level":      func(c *Config) { c.LogLevel = "loud" },
+			"backend":    func(c *Config) { c.Storage.Backend = "postgres" },
+			"path":       func(c *Config) { c.Storage.Backend = Sqlite },
+			"key":        func(c *Config) { c.TLS.Cert = "tls.crt" },
+			"client_ca":  func(c *Config) { c.TLS.ClientCA = "ca.crt" },
+			"negative":   func(c *Config) { c.Timeouts.Read = Duration(-time.Second) },
+			"ready_zero": func(c *Config) { c.Timeouts.Ready = 0 },
+		}
+
+		for name, fn := range invalid {
+			cfg := Default()
+			fn(&cfg)
+			assert.True(t, errors.Is(cfg.Validate(), ErrInvalid), name)
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("RequiresRestart", func(t *testing.T) {
+		cur := Default()
+
+		next := Default()
+		next.LogLevel = "debug"
+		next.Timeouts.Read = Duration(time.Second)
+		next.RateLimits.Read = core.RateLimit{Rate: 1, Burst: 1}
+		assert.Empty(t, cur.RequiresRestart(next))
+
+		next.Addr = ":9090"
+		next.Storage.Path = "catalog.db"
+		next.TLS = TLS{Cert: "tls.crt", Key: "tls.key"}
+		assert.Equal(t, []string{"addr", "storage.backend", "storage.path", "tls"}, cur.RequiresRestart(next))
+	}) {
+		return
+	}
+
+	ca := tlstest.NewCertificate(t, nil)
+	server := tlstest.NewCertificate(t, &ca)
+
+	certPath := write("tls.crt", string(tlstest.EncodeCertificate(server)))
+	keyPath := write("tls.key", string(tlstest.EncodeKey(t, server)))
+	caPath := write("ca.crt", string(tlstest.EncodeCertificate(ca)))
+
+	if !t.Run("TLS_Load", func(t *testing.T) {
+		conf, err := TLS{Cert: certPath, Key: keyPath}.Load()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(conf.Certificates))
+		assert.Equal(t, tls.NoClientCert, conf.ClientAuth)
+	}) {
+		return
+	}
+
+	if !t.Run("TLS_Load_ClientCA", func(t *testing.T) {
+		conf, err := TLS{Cert: certPath, Key: keyPath, ClientCA: caPath}.Load()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, tls.RequireAndVerifyClientCert, conf.ClientAuth)
+		assert.NotNil(t, conf.ClientCAs)
+	}) {
+		return
+	}
+
+	if !t.Run("TLS_Load_Invalid", func(t *testing.T) {
+		_, err := TLS{Cert: certPath, Key: caPath}.Load()
+		assert.True(t, errors.Is(err, ErrInvalid))
+
+		_, err = TLS{Cert: certPath, Key: keyPath, ClientCA: keyPath}.Load()
+		assert.True(t, errors.Is(err, ErrInvalid))
+	}) {
+		return
+	}
+}
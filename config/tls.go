@@ -0,0 +1,41 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// Loads the certificates into a server configuration.  If a client CA is
+// given, clients must present a certificate it signed (i.e. mutual TLS).
+// Files are read once, so they must be loaded again to pick up renewals.
+func (t TLS) Load() (ret *tls.Config, err error) {
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		err = errors.Wrapf(ErrInvalid, "Unable to load certificate [%v] and key [%v]: %v", t.Cert, t.Key, err)
+		return
+	}
+
+	ret = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.ClientCA == "" {
+		return
+	}
+
+	pem, err := ioutil.ReadFile(t.ClientCA)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalid, "Unable to read client CA [%v]: %v", t.ClientCA, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Wrapf(ErrInvalid, "No certificates in client CA [%v]", t.ClientCA)
+	}
+
+	ret.ClientCAs, ret.ClientAuth = pool, tls.RequireAndVerifyClientCert
+	return
+}
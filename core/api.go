@@ -12,6 +12,7 @@ var (
 	ErrState     = errors.New("Core:ErrState")
 	ErrConflict  = errors.New("Core:ErrConflict")
 	ErrNoService = errors.New("Core:ErrNoService")
+	ErrNoVersion = errors.New("Core:ErrNoVersion")
 )
 
 // This defines the core service data type.
@@ -21,12 +22,16 @@ var (
 // identifier to address the service. There is an additional versioning column
 // that allows services to be updated, and which is also used for concurrency
 // control.  The unique key for a service is then (id, version).
+//
+// Services may be owned by a team, whose members alone may update the service
+// or publish its versions.  Ownership may only change hands by a transfer.
 type Service struct {
-	Id      uuid.UUID `json:"id,omitempty"`
-	Name    string    `json:"name"`
-	Desc    string    `json:"desc"`
-	Version int       `json:"version,omitempty"`
-	Updated time.Time `json:"updated,omitempty"`
+	Id      uuid.UUID `json:"id,omitempty" yaml:"id"`
+	Name    string    `json:"name" yaml:"name"`
+	Desc    string    `json:"desc" yaml:"desc"`
+	Owner   string    `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Version int       `json:"version,omitempty" yaml:"version"`
+	Updated time.Time `json:"updated,omitempty" yaml:"updated"`
 }
 
 func NewService(name, desc string) Service {
@@ -70,6 +75,14 @@ func (s Service) SetDesc(desc string) (ret Service) {
 	})
 }
 
+// Set the owning team of the service.  Owned services may only change teams
+// by transfer.
+func (s Service) SetOwner(owner string) (ret Service) {
+	return s.Update(func(s *Service) {
+		s.Owner = owner
+	})
+}
+
 // This describes a service version. Services may contain many versions. They may
 // also contain none. This wasn't explicitly discussed so definitely taking a liberty
 // here. If this feature is wrong, then some of the following APIs may be a little
@@ -81,9 +94,9 @@ func (s Service) SetDesc(desc string) (ret Service) {
 // are immutable.
 //
 type Version struct {
-	ServiceId uuid.UUID `json:"service_id"`
-	Name      string    `json:"name"`
-	Created   time.Time `json:"created"`
+	ServiceId uuid.UUID `json:"service_id" yaml:"service_id"`
+	Name      string    `json:"name" yaml:"name"`
+	Created   time.Time `json:"created" yaml:"created"`
 }
 
 func NewVersion(serviceId uuid.UUID, name string) Version {
@@ -129,9 +142,32 @@ type Storage interface {
 	// Adds a version. Implementations must verify that the associated service exists.
 	SaveVersion(Version) error
 
+	// Applies the writes atomically and in order, such that later writes observe
+	// earlier ones. A result is returned for every write. If any write fails,
+	// none are applied and the returned error describes the failed write.
+	SaveBatch([]Write) ([]WriteResult, error)
+
 	// List services. May provide filtering and paging options. An empty filter will
 	// be equivalent to "list all".
 	ListServices(Filter, Page) (Catalog, error)
+
+	// Lists up to limit changes whose sequence is strictly greater than since, in
+	// sequence order. Every mutation must be recorded in the same transaction as
+	// the mutation, so that consumers resuming from a sequence never observe gaps.
+	ListChanges(since uint64, limit uint64) ([]Change, error)
+
+	// Returns the sequence of the most recent change, or zero if the log is
+	// empty.
+	LatestSeq() (uint64, error)
+
+	// Exports a consistent snapshot of the catalog. When history is requested,
+	// every revision of every service is included.
+	Export(history bool) (Snapshot, error)
+
+	// Imports a snapshot atomically, preserving its ids, revisions and timestamps.
+	// The mode determines how services that already exist are handled. Every
+	// write must be recorded in the change log.
+	Import(Snapshot, ImportMode) (ImportResult, error)
 }
 
 // This is the primary client interface. This project will come shipped with an HTTP client transport.
@@ -141,12 +177,47 @@ type Transport interface {
 	// are allowed.
 	SaveService(Service) (Service, error)
 
+	// Applies the function to the latest revision of the service and saves the
+	// result as the next revision. The update is retried if the service is
+	// concurrently updated.
+	UpdateService(id uuid.UUID, fn func(*Service)) (Service, error)
+
 	// Adds a version. Multiple versions of the same name and service are not allowed.
 	// The corresponding service must exist.
 	SaveVersion(Version) (Version, error)
 
+	// Returns the latest revision of the service. Returns ErrNoService if none exists.
+	GetService(id uuid.UUID) (Service, error)
+
+	// Returns the named version of the service. Returns ErrNoService if the service
+	// doesn't exist, or ErrNoVersion if the version doesn't.
+	GetVersion(serviceId uuid.UUID, name string) (Version, error)
+
+	// Applies the writes atomically and in order. New services may be assigned
+	// an id by the caller, so that later writes in the batch may refer to them.
+	// The results contain the saved values, or the errors of the failed writes.
+	SaveBatch([]Write) ([]WriteResult, error)
+
 	// List services. May provide filtering and paging options. An empty filter will
 	// be equivalent to "list all". Implementations may implement additional constraints
 	// on the input paging options.
 	ListServices(Filter, Page) (Catalog, error)
+
+	// Lists the changes that occurred after the given sequence. Consumers
+	// should resume from the sequence of the last change they processed.
+	ListChanges(since uint64, limit uint64) ([]Change, error)
+
+	// Watches the catalog for changes that occur after the given sequence.
+	// A zero sequence watches from the current end of the log, and
+	// FromBeginning replays the log in its entirety.  Only changes
+	// to services matching the filter are delivered.  Callers must close the
+	// watcher once finished.
+	Watch(filter Filter, since uint64) (Watcher, error)
+
+	// Exports a snapshot of the catalog, optionally with the full revision
+	// history of every service.
+	Export(history bool) (Snapshot, error)
+
+	// Imports a snapshot into the catalog.
+	Import(Snapshot, ImportMode) (ImportResult, error)
 }
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrTampered = errors.New("Core:ErrTampered")
+)
+
+// An actor is the party responsible for a write: the subject of the
+// principal that made the request, the address it was made from and the
+// id of the request.  Writes made without a principal (e.g. when
+// authentication is disabled) have no subject.
+type Actor struct {
+	Subject   string `json:"subject,omitempty"`
+	ClientIp  string `json:"client_ip,omitempty"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
+// An audit entry records a single write to the catalog, along with the
+// actor that made it and the encoded service or version before and after
+// the write.  Saves have no before state (unless they revise a service),
+// and deletes have no after state.
+//
+// Entries are written in the same transaction as the write itself, and
+// are chained together by hash: every entry includes the hash of its
+// predecessor.  Modifying or removing an entry breaks the chain of every
+// entry that follows, which may be detected with VerifyAudit.
+type AuditEntry struct {
+	Seq       uint64          `json:"seq"`
+	Actor     Actor           `json:"actor"`
+	Operation ChangeType      `json:"operation"`
+	ServiceId uuid.UUID       `json:"service_id"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Created   time.Time       `json:"created"`
+	Prev      string          `json:"prev"`
+	Hash      string          `json:"hash"`
+}
+
+// Returns an entry that audits the change on behalf of the actor.  The
+// sequence and hashes are assigned by storage, once the entry is chained.
+func NewAuditEntry(actor Actor, change Change, before interface{}) (ret AuditEntry, err error) {
+	ret = AuditEntry{
+		Actor:     actor,
+		Operation: change.Type,
+		ServiceId: change.ServiceId,
+		Created:   time.Now().UTC(),
+	}
+
+	if before != nil {
+		if ret.Before, err = json.Marshal(before); err != nil {
+			return
+		}
+	}
+
+	switch change.Type {
+	case ServiceSaved:
+		ret.After, err = json.Marshal(change.Service)
+	case VersionSaved:
+		ret.After, err = json.Marshal(change.Version)
+	}
+	return
+}
+
+// Chains the entry to its predecessor, assigning its sequence and hash.
+// The first entry of the log is chained to the empty entry.
+func (e AuditEntry) Chain(prev AuditEntry) (ret AuditEntry) {
+	ret = e
+	ret.Seq, ret.Prev = prev.Seq+1, prev.Hash
+	ret.Hash = ret.ComputeHash()
+	return
+}
+
+// Returns the hex encoded sha256 of the entry's json encoding, excluding
+// its own hash.
+func (e AuditEntry) ComputeHash() string {
+	e.Hash, e.Created = "", e.Created.UTC()
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		panic(err) // the entry consists solely of encodable values
+	}
+
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verifies that the entries are intact and consecutive.  Entries must be in
+// sequence order, and the first must follow the entry with the given hash
+// (empty if the entries begin the log).  Returns ErrTampered otherwise.
+func VerifyAudit(prev string, entries []AuditEntry) error {
+	for _, e := range entries {
+		if e.Prev != prev {
+			return errors.Wrapf(ErrTampered, "Audit entry [%v] does not follow its predecessor", e.Seq)
+		}
+		if e.Hash != e.ComputeHash() {
+			return errors.Wrapf(ErrTampered, "Audit entry [%v] does not match its hash", e.Seq)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Filters audit entries by service, actor subject and time.  Nil fields
+// match everything.
+type AuditFilter struct {
+	ServiceId *uuid.UUID `json:"service_id,omitempty"`
+	Actor     *string    `json:"actor,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+}
+
+// Builds a filter from a list of builder functions
+func NewAuditFilter(fns ...func(*AuditFilter)) (ret AuditFilter) {
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Returns a filter function that matches the entries of a service.
+func AuditByServiceId(id uuid.UUID) func(*AuditFilter) {
+	return func(f *AuditFilter) {
+		f.ServiceId = &id
+	}
+}
+
+// Returns a filter function that matches the entries of an actor.
+func AuditByActor(subject string) func(*AuditFilter) {
+	return func(f *AuditFilter) {
+		f.Actor = &subject
+	}
+}
+
+// Returns a filter function that matches the entries created at or after
+// the given time.
+func AuditSince(since time.Time) func(*AuditFilter) {
+	return func(f *AuditFilter) {
+		f.Since = &since
+	}
+}
+
+// Implemented by storage engines that audit writes.  Writes made through
+// the returned storage are attributed to the actor.  Writes made through
+// the original storage are attributed to no one.
+type AuditedStorage interface {
+	WithActor(Actor) Storage
+}
+
+// The transfer storage counterpart of AuditedStorage, since completing a
+// transfer writes to the catalog.
+type AuditedTransferStorage interface {
+	WithActor(Actor) TransferStorage
+}
+
+// Returns a storage whose writes are attributed to the actor.  Storage that
+// isn't audited is returned as is.
+func Attribute(storage Storage, actor Actor) Storage {
+	if audited, ok := storage.(AuditedStorage); ok {
+		return audited.WithActor(actor)
+	}
+	return storage
+}
+
+// Returns a transfer storage whose writes are attributed to the actor.
+// Storage that isn't audited is returned as is.
+func AttributeTransfers(storage TransferStorage, actor Actor) TransferStorage {
+	if audited, ok := storage.(AuditedTransferStorage); ok {
+		return audited.WithActor(actor)
+	}
+	return storage
+}
+
+// The storage of the audit log.  Entries are written by the catalog
+// storage, so this only reads them.
+type AuditStorage interface {
+
+	// Lists entries in sequence order.  May provide filtering and paging options.
+	ListAudit(AuditFilter, Page) ([]AuditEntry, error)
+}
+
+// The client interface for reading the audit log.
+type AuditTransport interface {
+
+	// Lists entries in sequence order.  May provide filtering and paging options.
+	ListAudit(AuditFilter, Page) ([]AuditEntry, error)
+}
@@ -0,0 +1,20 @@
+package core
+
+import (
+	"io"
+)
+
+// Implemented by storage engines that support online backups.
+type BackupStorage interface {
+
+	// Writes a consistent copy of the entire database to a new file at the
+	// given path.  Reads and writes may continue while the backup is taken.
+	Backup(path string) error
+}
+
+// The client interface for backups.
+type BackupTransport interface {
+
+	// Streams a consistent copy of the entire database to the writer.
+	Backup(io.Writer) (int64, error)
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrAborted = errors.New("Core:ErrAborted")
+)
+
+// The maximum number of writes in a single batch.
+const MaxBatchSize = 1024
+
+// A write is a single element of a batch.  Exactly one of the service
+// or version must be set.  Deletes remove the service (along with all of
+// its revisions and versions) or the version instead of saving it.  A
+// service may only be deleted at its latest revision.
+type Write struct {
+	Service *Service `json:"service,omitempty"`
+	Version *Version `json:"version,omitempty"`
+	Delete  bool     `json:"delete,omitempty"`
+}
+
+func SaveServiceWrite(s Service) Write {
+	return Write{Service: &s}
+}
+
+func SaveVersionWrite(v Version) Write {
+	return Write{Version: &v}
+}
+
+func DeleteServiceWrite(s Service) Write {
+	return Write{Service: &s, Delete: true}
+}
+
+func DeleteVersionWrite(v Version) Write {
+	return Write{Version: &v, Delete: true}
+}
+
+// Returns an error if the write is not well-formed.  This only verifies the
+// shape of the write.  Storage implementations are responsible for any
+// further validation.
+func (w Write) Validate() error {
+	if (w.Service == nil) == (w.Version == nil) {
+		return ErrState
+	}
+	return nil
+}
+
+// Validates the write and assigns its server-side fields (e.g. the ids of
+// new services).  Transports prepare writes before they reach storage.
+func (w *Write) Prepare() error {
+	if err := w.Validate(); err != nil {
+		return errors.Wrapf(err, "Exactly one of service or version must be set")
+	}
+
+	// Deletes need only identify their target.
+	if w.Delete {
+		switch {
+		case w.Service != nil && w.Service.Id == (uuid.UUID{}):
+			return errors.Wrapf(ErrState, "Invalid id")
+		case w.Version != nil && w.Version.ServiceId == (uuid.UUID{}):
+			return errors.Wrapf(ErrState, "Invalid service id")
+		case w.Version != nil && w.Version.Name == "":
+			return errors.Wrapf(ErrState, "Invalid name")
+		}
+		return nil
+	}
+
+	if w.Service != nil {
+		svc := *w.Service
+		switch {
+		case svc.Name == "":
+			return errors.Wrapf(ErrState, "Invalid name")
+		case svc.Desc == "":
+			return errors.Wrapf(ErrState, "Invalid description")
+		case svc.Version < 0:
+			return errors.Wrapf(ErrState, "Invalid version")
+		}
+
+		if svc.Version == 0 && svc.Id == (uuid.UUID{}) {
+			svc = svc.SetId(uuid.NewV1())
+		} else {
+			svc = svc.Update()
+		}
+
+		w.Service = &svc
+		return nil
+	}
+
+	v := *w.Version
+	switch {
+	case v.ServiceId == (uuid.UUID{}):
+		return errors.Wrapf(ErrState, "Invalid service id")
+	case v.Name == "":
+		return errors.Wrapf(ErrState, "Invalid name")
+	}
+
+	v = v.SetCreated(time.Now().UTC())
+	w.Version = &v
+	return nil
+}
+
+// Returns the id of the service affected by the write.
+func (w Write) ServiceId() uuid.UUID {
+	if w.Service != nil {
+		return w.Service.Id
+	}
+	if w.Version != nil {
+		return w.Version.ServiceId
+	}
+	return uuid.UUID{}
+}
+
+// The result of a single write within a batch.  On success, the result
+// contains the written value.  Otherwise, the result contains the error.
+// Because batches are atomic, the failure of any single write aborts all
+// other writes in the batch with ErrAborted.
+type WriteResult struct {
+	Service *Service `json:"service,omitempty"`
+	Version *Version `json:"version,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func (r WriteResult) Ok() bool {
+	return r.Error == ""
+}
+
+// Returns the error of the write, if any.  The error message is preserved
+// verbatim, so the standard errors may be matched with errs.Is.
+func (r WriteResult) Err() error {
+	if r.Ok() {
+		return nil
+	}
+	return errors.New(r.Error)
+}
+
+// Builds the results of a batch that failed at the given index.  Every
+// other write is aborted.
+func NewAbortedResults(num int, idx int, cause error) (ret []WriteResult) {
+	ret = make([]WriteResult, num)
+	for i := range ret {
+		ret[i].Error = ErrAborted.Error()
+	}
+	if idx >= 0 && idx < num {
+		ret[idx].Error = cause.Error()
+	}
+	return
+}
+
+// Builds the results of a batch that was committed.
+func NewCommittedResults(writes []Write) (ret []WriteResult) {
+	ret = make([]WriteResult, len(writes))
+	for i, w := range writes {
+		ret[i].Service, ret[i].Version = w.Service, w.Version
+	}
+	return
+}
+
+// Returns the error that caused the batch to fail, annotated with the
+// index of the offending write.
+func BatchError(results []WriteResult) (err error) {
+	for i, r := range results {
+		if r.Ok() {
+			continue
+		}
+		if err == nil || r.Error != ErrAborted.Error() {
+			err = errors.Wrapf(r.Err(), "Batch write [%v] failed", i)
+		}
+		if r.Error != ErrAborted.Error() {
+			return
+		}
+	}
+	return
+}
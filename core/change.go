@@ -0,0 +1,145 @@
+package core
+
+import (
+	"io"
+	"math"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// The type of mutation described by a change.
+type ChangeType string
+
+const (
+	ServiceSaved   ChangeType = "service.saved"
+	VersionSaved   ChangeType = "version.saved"
+	ServiceDeleted ChangeType = "service.deleted"
+	VersionDeleted ChangeType = "version.deleted"
+)
+
+// A change is an entry in the catalog's append-only event log. Every mutation
+// of the catalog produces exactly one change, written in the same transaction
+// as the mutation itself. Changes are assigned a monotonically increasing
+// sequence number by the storage layer, which consumers may use to resume
+// reading the log from their last observed position.
+//
+// Only one of Service or Version will be populated, depending on the type of
+// change.
+type Change struct {
+	Seq       uint64     `json:"seq"`
+	Type      ChangeType `json:"type"`
+	ServiceId uuid.UUID  `json:"service_id"`
+	Service   *Service   `json:"service,omitempty"`
+	Version   *Version   `json:"version,omitempty"`
+	Created   time.Time  `json:"created"`
+}
+
+// Returns a change describing the save of a service. The sequence
+// is assigned by storage.
+func NewServiceChange(svc Service) Change {
+	return Change{
+		Type:      ServiceSaved,
+		ServiceId: svc.Id,
+		Service:   &svc,
+		Created:   time.Now().UTC(),
+	}
+}
+
+// Returns a change describing the save of a version. The sequence
+// is assigned by storage.
+func NewVersionChange(v Version) Change {
+	return Change{
+		Type:      VersionSaved,
+		ServiceId: v.ServiceId,
+		Version:   &v,
+		Created:   time.Now().UTC(),
+	}
+}
+
+// Returns a change describing the deletion of a service, along with
+// all of its revisions and versions.
+func NewServiceDeletedChange(svc Service) (ret Change) {
+	ret = NewServiceChange(svc)
+	ret.Type = ServiceDeleted
+	return
+}
+
+// Returns a change describing the deletion of a version.
+func NewVersionDeletedChange(v Version) (ret Change) {
+	ret = NewVersionChange(v)
+	ret.Type = VersionDeleted
+	return
+}
+
+// A watcher is a live subscription to the stream of catalog changes.
+type Watcher interface {
+	io.Closer
+
+	// Returns the channel of changes.  The channel is closed once the
+	// watcher has been closed or the underlying stream has failed.
+	Changes() <-chan Change
+
+	// Returns the failure that terminated the stream, if any.  Only
+	// valid once the changes channel has been closed.
+	Err() error
+}
+
+// A change matcher determines whether changes apply to a filter.  Version
+// changes don't carry the name or description of their service, so the
+// service is resolved from storage (and remembered) when the filter
+// requires it.  Matchers are not safe for concurrent use.
+type ChangeMatcher struct {
+	storage  Storage
+	services map[uuid.UUID]Service
+}
+
+func NewChangeMatcher(storage Storage) *ChangeMatcher {
+	return &ChangeMatcher{storage, make(map[uuid.UUID]Service)}
+}
+
+func (m *ChangeMatcher) Matches(filter Filter, c Change) (ok bool, err error) {
+	if c.Service != nil {
+		m.services[c.ServiceId] = *c.Service
+		ok = filter.Matches(*c.Service)
+		return
+	}
+
+	if filter.ById() {
+		ok = filter.ServiceId == nil || *filter.ServiceId == c.ServiceId
+		return
+	}
+
+	svc, found := m.services[c.ServiceId]
+	if !found {
+		catalog, err := m.storage.ListServices(
+			NewFilter(FilterByServiceId(c.ServiceId)),
+			NewPage(Limit(1)))
+		if err != nil || len(catalog.Services) == 0 {
+			return false, err
+		}
+
+		svc = catalog.Services[0]
+		m.services[c.ServiceId] = svc
+	}
+
+	ok = filter.Matches(svc)
+	return
+}
+
+// Watches given a zero sequence start from the end of the log, since that
+// is what most consumers want.  Consumers that want the entire log (e.g.
+// to build a replica) watch from FromBeginning instead.
+const FromBeginning uint64 = math.MaxUint64
+
+// Returns the sequence after which a watch from since starts.
+func WatchStart(storage Storage, since uint64) (uint64, error) {
+	switch since {
+	case 0:
+		return storage.LatestSeq()
+	case FromBeginning:
+		return 0, nil
+	default:
+		return since, nil
+	}
+}
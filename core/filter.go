@@ -1,6 +1,10 @@
 package core
 
-import uuid "github.com/satori/go.uuid"
+import (
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
 
 var (
 	EmptyFilter = NewFilter()
@@ -29,6 +33,31 @@ func (f Filter) Update(fns ...func(*Filter)) (ret Filter) {
 	return
 }
 
+// Returns true if the service satisfies every constraint of the filter.
+// Matching is case-insensitive, which mirrors the behavior of the sql
+// storage implementation.
+func (f Filter) Matches(svc Service) bool {
+	if f.ServiceId != nil && *f.ServiceId != svc.Id {
+		return false
+	}
+	if f.NameContains != nil && !containsFold(svc.Name, *f.NameContains) {
+		return false
+	}
+	if f.DescContains != nil && !containsFold(svc.Desc, *f.DescContains) {
+		return false
+	}
+	return true
+}
+
+// Returns true if the filter only constrains services by id.
+func (f Filter) ById() bool {
+	return f.NameContains == nil && f.DescContains == nil
+}
+
+func containsFold(str, match string) bool {
+	return strings.Contains(strings.ToLower(str), strings.ToLower(match))
+}
+
 // Returns a filter function that matches services by id.
 func FilterByServiceId(id uuid.UUID) func(*Filter) {
 	return func(f *Filter) {
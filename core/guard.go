@@ -0,0 +1,23 @@
+package core
+
+// A guard decides whether a write may be applied, given the current state
+// of the write's service (nil if the service doesn't exist).  The state is
+// that left by any earlier writes of the same batch.
+type Guard func(cur *Service, w Write) error
+
+// Implemented by storage engines that consult a guard within the
+// transaction of every write, so that its decision can't be invalidated by
+// a concurrent write.  Writes the guard rejects fail with its error.
+type GuardedStorage interface {
+	WithGuard(Guard) Storage
+}
+
+// Returns a storage whose writes are guarded.  Storage that can't guard
+// its writes is returned as is, so callers must still check writes before
+// making them.
+func Guarded(storage Storage, guard Guard) Storage {
+	if guarded, ok := storage.(GuardedStorage); ok {
+		return guarded.WithGuard(guard)
+	}
+	return storage
+}
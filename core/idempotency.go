@@ -0,0 +1,69 @@
+package core
+
+import (
+	"time"
+)
+
+// Reservations that are not completed within the lease expire, so that
+// the key of a request that never finished (e.g. the server failed) may be
+// reclaimed by a retry rather than being held for the whole window.
+const IdempotencyLease = time.Minute
+
+// An idempotency record holds the response to a write that was sent with
+// an idempotency key, so that retries of the write may be answered with
+// the original response rather than being applied twice.  A record is
+// reserved before the write is applied and completed once the response
+// is known.  Records are identified by their key, and the fingerprint
+// ensures that a key is only ever replayed for the request that
+// reserved it.
+type IdempotencyRecord struct {
+	Key         string            `json:"key"`
+	Fingerprint string            `json:"fingerprint"`
+	Code        int               `json:"code,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Mime        string            `json:"mime,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	Created     time.Time         `json:"created"`
+	Expires     time.Time         `json:"expires"`
+}
+
+func NewIdempotencyRecord(key, fingerprint string, window time.Duration) IdempotencyRecord {
+	now := time.Now().UTC()
+	return IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Created:     now,
+		Expires:     now.Add(window),
+	}
+}
+
+// Returns true once the response has been recorded.
+func (r IdempotencyRecord) Completed() bool {
+	return r.Code != 0
+}
+
+// Returns the time at which the reservation of the record expires, unless
+// it has been completed.
+func (r IdempotencyRecord) LeaseExpires() time.Time {
+	if lease := r.Created.Add(IdempotencyLease); lease.Before(r.Expires) {
+		return lease
+	}
+	return r.Expires
+}
+
+// Implemented by storage engines that support idempotent writes.
+type IdempotencyStorage interface {
+
+	// Reserves the key of the record until its lease expires.  If the key
+	// is held by a record that has not expired, that record is returned and
+	// nothing is reserved.
+	Reserve(IdempotencyRecord) (existing IdempotencyRecord, reserved bool, err error)
+
+	// Records the response of a reserved record, which then expires at the
+	// end of its window.  Fails if the reservation has been reclaimed.
+	Complete(IdempotencyRecord) error
+
+	// Releases a reserved record, so that its request may be retried.  A
+	// reservation that has been reclaimed is left alone.
+	Release(IdempotencyRecord) error
+}
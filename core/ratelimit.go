@@ -0,0 +1,116 @@
+package core
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited = errors.New("Core:ErrRateLimited")
+)
+
+// A rate limit allows a sustained number of requests per second, with
+// bursts of up to a number of requests.  A zero rate is unlimited.
+type RateLimit struct {
+	Rate  uint
+	Burst uint
+}
+
+func (r RateLimit) Unlimited() bool {
+	return r.Rate == 0
+}
+
+// The most keys a limiter tracks.  Beyond this, the least recently used
+// key is forgotten.
+const maxRateLimitKeys = 10000
+
+// A rate limiter maintains a token bucket per key (e.g. per client).  Each
+// bucket starts full, holds at most the burst, and refills at the rate.
+// Buckets are ordered by their use, so that the limiter may forget the
+// least recently used in constant time.
+type RateLimiter struct {
+	limit   RateLimit
+	now     func() time.Time
+	lock    sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+}
+
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	ret := &RateLimiter{
+		now:     time.Now,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	ret.SetLimit(limit)
+	return ret
+}
+
+// Changes the limit.  Buckets keep their tokens, up to the new burst.
+func (l *RateLimiter) SetLimit(limit RateLimit) {
+	if limit.Burst < limit.Rate {
+		limit.Burst = limit.Rate
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.limit = limit
+}
+
+// Takes a token from the key's bucket.  If the bucket is empty, returns
+// false and how long until a token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.limit.Unlimited() {
+		return true, 0
+	}
+
+	now := l.now()
+
+	var b *bucket
+	if elem, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(elem)
+		b = elem.Value.(*bucket)
+	} else {
+		if len(l.buckets) >= maxRateLimitKeys {
+			l.evict()
+		}
+		b = &bucket{key: key, tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	b.refill(l.limit, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / float64(l.limit.Rate)
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Forgets the least recently used bucket.  The bucket has most likely
+// refilled, in which case it's indistinguishable from a new one.
+func (l *RateLimiter) evict() {
+	oldest := l.lru.Back()
+	l.lru.Remove(oldest)
+	delete(l.buckets, oldest.Value.(*bucket).key)
+}
+
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+}
+
+func (b *bucket) refill(limit RateLimit, now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*float64(limit.Rate))
+		b.updated = now
+	}
+}
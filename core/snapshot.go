@@ -0,0 +1,128 @@
+package core
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The current version of the snapshot document.  Incremented whenever the
+// document changes in a way that older readers can't understand.
+const SnapshotVersion = 1
+
+// A snapshot is a portable copy of the catalog.  Services are ordered by
+// (id, version).  When the snapshot includes history, every revision of
+// each service is present.  Otherwise, only the latest revisions are.
+type Snapshot struct {
+	Version  int       `json:"version" yaml:"version"`
+	Created  time.Time `json:"created" yaml:"created"`
+	History  bool      `json:"history" yaml:"history"`
+	Services []Service `json:"services" yaml:"services"`
+	Versions []Version `json:"versions" yaml:"versions"`
+}
+
+func NewSnapshot(history bool, services []Service, versions []Version) Snapshot {
+	return Snapshot{
+		Version:  SnapshotVersion,
+		Created:  time.Now().UTC(),
+		History:  history,
+		Services: services,
+		Versions: versions,
+	}
+}
+
+// Verifies that the snapshot is readable and self-consistent.
+func (s Snapshot) Validate() error {
+	if s.Version != SnapshotVersion {
+		return errors.Wrapf(ErrState, "Unsupported snapshot version [%v]. Expected [%v]", s.Version, SnapshotVersion)
+	}
+
+	type revision struct {
+		id      uuid.UUID
+		version int
+	}
+
+	revisions := make(map[revision]bool)
+	services := make(map[uuid.UUID]bool)
+	for _, svc := range s.Services {
+		if svc.Id == (uuid.UUID{}) || svc.Name == "" {
+			return errors.Wrapf(ErrState, "Invalid service [%v]. Id and name must not be empty", svc.Id)
+		}
+
+		rev := revision{svc.Id, svc.Version}
+		if revisions[rev] {
+			return errors.Wrapf(ErrState, "Duplicate revision [%v] of service [%v]", svc.Version, svc.Id)
+		}
+		revisions[rev], services[svc.Id] = true, true
+	}
+
+	for _, v := range s.Versions {
+		if !services[v.ServiceId] {
+			return errors.Wrapf(ErrState, "Version [%v] refers to unknown service [%v]", v.Name, v.ServiceId)
+		}
+		if v.Name == "" {
+			return errors.Wrapf(ErrState, "Invalid version of service [%v]. Name must not be empty", v.ServiceId)
+		}
+	}
+	return nil
+}
+
+// An import mode determines how imported services that already exist
+// in the catalog are handled.
+type ImportMode string
+
+const (
+
+	// Adds the revisions and versions that are missing from the catalog.
+	// Existing revisions and versions are left untouched.
+	ImportMerge ImportMode = "merge"
+
+	// Replaces the revisions and versions of existing services with those
+	// of the snapshot.
+	ImportReplace ImportMode = "replace"
+
+	// Leaves existing services (and their versions) untouched.
+	ImportSkipExisting ImportMode = "skip-existing"
+)
+
+func ParseImportMode(raw string) (ret ImportMode, err error) {
+	switch ret = ImportMode(raw); ret {
+	case ImportMerge, ImportReplace, ImportSkipExisting:
+		return
+	}
+
+	err = errors.Wrapf(ErrState, "Invalid import mode [%v]. Must be one of [merge, replace, skip-existing]", raw)
+	return
+}
+
+// Describes the effects of an import.
+type ImportResult struct {
+	Created   int `json:"created" yaml:"created"`     // services that did not exist
+	Updated   int `json:"updated" yaml:"updated"`     // existing services that were merged or replaced
+	Skipped   int `json:"skipped" yaml:"skipped"`     // existing services that were left untouched
+	Revisions int `json:"revisions" yaml:"revisions"` // service revisions written
+	Versions  int `json:"versions" yaml:"versions"`   // versions written
+}
+
+// Implemented by storage engines that count the catalog without reading it.
+type CountedStorage interface {
+
+	// Returns the number of services (at their latest revision) and versions
+	// in the catalog.
+	Count() (services, versions int, err error)
+}
+
+// Returns the number of services and versions in the catalog.  Storage that
+// doesn't count them is counted by exporting the catalog.
+func Count(storage Storage) (services, versions int, err error) {
+	if counted, ok := storage.(CountedStorage); ok {
+		return counted.Count()
+	}
+
+	snapshot, err := storage.Export(false)
+	if err != nil {
+		return
+	}
+	return len(snapshot.Services), len(snapshot.Versions), nil
+}
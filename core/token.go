@@ -0,0 +1,203 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrNoToken      = errors.New("Core:ErrNoToken")
+	ErrUnauthorized = errors.New("Core:ErrUnauthorized")
+	ErrForbidden    = errors.New("Core:ErrForbidden")
+)
+
+// The scope of a token determines what it may do.  Scopes are ordered:
+// write tokens may also read, and admin tokens may do anything.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeRanks = map[Scope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+func ParseScope(raw string) (ret Scope, err error) {
+	ret = Scope(raw)
+	if _, ok := scopeRanks[ret]; !ok {
+		err = errors.Wrapf(ErrState, "Invalid scope [%v]. Must be one of [read, write, admin]", raw)
+	}
+	return
+}
+
+// Returns true if the scope grants the required scope.
+func (s Scope) Allows(required Scope) bool {
+	return scopeRanks[s] > 0 && scopeRanks[s] >= scopeRanks[required]
+}
+
+// The prefix of every token secret, which makes leaked secrets easy to
+// recognize.
+const TokenPrefix = "cat_"
+
+// An API token.  Only the hash of a token's secret is stored, so the secret
+// is only ever known to whoever created the token.  Secrets are random, so
+// a plain hash is enough to protect them.  A zero expiry never expires.
+type Token struct {
+	Id      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Scope   Scope     `json:"scope"`
+	Teams   []string  `json:"teams,omitempty"`
+	Hash    string    `json:"-"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Creates a token along with its secret.  A zero ttl never expires.  The
+// token acts as a member of the given teams.
+func NewToken(name string, scope Scope, ttl time.Duration, teams ...string) (ret Token, secret string, err error) {
+	if _, err = ParseScope(string(scope)); err != nil {
+		return
+	}
+
+	for _, t := range teams {
+		if err = ValidateTeam(t); err != nil {
+			return
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	secret = TokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	ret = Token{
+		Id:      uuid.NewV1(),
+		Name:    name,
+		Scope:   scope,
+		Teams:   teams,
+		Hash:    HashSecret(secret),
+		Created: now,
+	}
+	if ttl > 0 {
+		ret.Expires = now.Add(ttl)
+	}
+	return
+}
+
+// Returns the hash by which a secret is stored.
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func (t Token) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// Returns the principal authenticated by the token.  Token names needn't be
+// unique, so the subject is derived from the token's id (and prefixed to keep
+// it apart from those of other authenticators).  The name is for display.
+func (t Token) Principal() Principal {
+	return Principal{Subject: "token:" + t.Id.String(), Name: t.Name, Scope: t.Scope, Teams: t.Teams}
+}
+
+// The identity that authenticated a request.  The subject uniquely
+// identifies the principal, and is what requests are attributed to (e.g. in
+// the audit log, rate limits and idempotency keys).  The name is only for
+// display.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name,omitempty"`
+	Scope   Scope    `json:"scope"`
+	Teams   []string `json:"teams,omitempty"`
+}
+
+// Returns true if the principal is a member of the team.
+func (p Principal) MemberOf(team string) bool {
+	for _, t := range p.Teams {
+		if t == team {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns ErrForbidden unless the principal has the scope.
+func (p Principal) Authorize(scope Scope) error {
+	if p.Scope.Allows(scope) {
+		return nil
+	}
+	return errors.Wrapf(ErrForbidden, "Principal [%v] has scope [%v]. Requires [%v]", p.Subject, p.Scope, scope)
+}
+
+// Authenticates the secrets carried by bearer tokens.
+type Authenticator interface {
+
+	// Returns the principal of the secret.  Returns ErrUnauthorized if the
+	// secret is invalid or expired.
+	Authenticate(secret string) (Principal, error)
+}
+
+type AuthenticatorFunc func(string) (Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(secret string) (Principal, error) {
+	return f(secret)
+}
+
+// Returns an authenticator of API tokens.  Secrets that aren't API tokens
+// (i.e. lack the TokenPrefix) are given to the fallback, if any.
+func NewAuthenticator(store TokenStorage, fallback Authenticator) Authenticator {
+	return AuthenticatorFunc(func(secret string) (ret Principal, err error) {
+		if !strings.HasPrefix(secret, TokenPrefix) && fallback != nil {
+			return fallback.Authenticate(secret)
+		}
+
+		token, err := store.LoadTokenByHash(HashSecret(secret))
+		if err != nil {
+			if errors.Is(err, ErrNoToken) {
+				err = errors.Wrapf(ErrUnauthorized, "Invalid bearer token")
+			}
+			return
+		}
+
+		if token.Expired(time.Now()) {
+			err = errors.Wrapf(ErrUnauthorized, "Token [%v] expired at [%v]", token.Name, token.Expires)
+			return
+		}
+
+		ret = token.Principal()
+		return
+	})
+}
+
+// Implemented by storage engines that store tokens.
+type TokenStorage interface {
+
+	// Adds a token.
+	SaveToken(Token) error
+
+	// Loads the token whose secret has the given hash.  Returns ErrNoToken
+	// if none exists.
+	LoadTokenByHash(hash string) (Token, error)
+
+	// Lists all tokens, oldest first.
+	ListTokens() ([]Token, error)
+
+	// Revokes a token, which may never be used again.  Returns ErrNoToken
+	// if none exists.
+	RevokeToken(uuid.UUID) error
+}
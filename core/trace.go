@@ -0,0 +1,33 @@
+package core
+
+// A span times a single unit of work within a trace.  Spans are created
+// by tracers, which are free to discard them, so implementations must be
+// safe to use whether or not they're recorded.
+type Span interface {
+
+	// Starts a span that is a child of this one.
+	Child(name string) Span
+
+	// Annotates the span.
+	SetAttribute(key, val string)
+
+	// Ends the span, marking it failed if the error is non-nil.  Calls
+	// after the first are ignored.
+	End(err error)
+}
+
+// Implemented by storage engines that trace their operations.  Operations
+// performed through the returned storage are recorded as children of the
+// span.
+type TracedStorage interface {
+	WithSpan(Span) Storage
+}
+
+// Returns a storage whose operations are traced as children of the span.
+// Storage that isn't traced is returned as is.
+func Trace(storage Storage, span Span) Storage {
+	if traced, ok := storage.(TracedStorage); ok {
+		return traced.WithSpan(span)
+	}
+	return storage
+}
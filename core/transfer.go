@@ -0,0 +1,101 @@
+package core
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrNoTransfer = errors.New("Core:ErrNoTransfer")
+)
+
+// Teams are named by the identity provider (or by whoever creates tokens),
+// so their names are only loosely constrained.
+func ValidateTeam(team string) error {
+	if team == "" || strings.ContainsAny(team, ", \t\n") {
+		return errors.Wrapf(ErrState, "Invalid team [%v]. Must be non-empty, without commas or spaces", team)
+	}
+	return nil
+}
+
+// A transfer moves the ownership of a service between teams.  A transfer
+// completes once members of both teams have consented to it, at which point
+// the service is saved at its next revision with its new owner.  Whoever
+// requests the transfer consents on behalf of their own team.
+type Transfer struct {
+	Id        uuid.UUID `json:"id"`
+	ServiceId uuid.UUID `json:"service_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	FromBy    string    `json:"from_by,omitempty"` // the subject that consented for the owning team
+	ToBy      string    `json:"to_by,omitempty"`   // the subject that consented for the receiving team
+	Created   time.Time `json:"created"`
+	Completed time.Time `json:"completed,omitempty"`
+}
+
+func NewTransfer(serviceId uuid.UUID, from, to string) Transfer {
+	return Transfer{
+		Id:        uuid.NewV1(),
+		ServiceId: serviceId,
+		From:      from,
+		To:        to,
+		Created:   time.Now().UTC(),
+	}
+}
+
+// Returns true once both teams have consented.
+func (t Transfer) Consented() bool {
+	return t.FromBy != "" && t.ToBy != ""
+}
+
+func (t Transfer) Pending() bool {
+	return t.Completed.IsZero()
+}
+
+// Implemented by storage engines that store transfers.  Transfers must be
+// stored alongside the services, so that a transfer completes atomically
+// with the save of the service.
+type TransferStorage interface {
+
+	// Adds a pending transfer.  Returns ErrConflict if the service already
+	// has a pending transfer.
+	SaveTransfer(Transfer) error
+
+	// Loads a transfer.  Returns ErrNoTransfer if none exists.
+	LoadTransfer(uuid.UUID) (Transfer, error)
+
+	// Lists the transfers of a service, oldest first.
+	ListTransfers(serviceId uuid.UUID) ([]Transfer, error)
+
+	// Records the consents of a pending transfer.  Once both teams have
+	// consented, the transfer is completed and the service is saved at its
+	// next revision with its new owner, all in the same transaction.
+	// Returns ErrConflict if the transfer is no longer pending, or if the
+	// service is no longer owned by the transferring team.
+	ConsentTransfer(Transfer) (Transfer, error)
+
+	// Cancels a pending transfer.  Returns ErrNoTransfer if no such transfer
+	// is pending.
+	CancelTransfer(uuid.UUID) error
+}
+
+// The client interface for transferring services between teams.  Transfers
+// are made on behalf of the caller, who consents for each of its teams.
+type TransferTransport interface {
+
+	// Requests the transfer of an owned service to the team.
+	RequestTransfer(serviceId uuid.UUID, to string) (Transfer, error)
+
+	// Approves a pending transfer on behalf of the team that has yet to
+	// consent.  The service changes owners once both teams have consented.
+	ApproveTransfer(uuid.UUID) (Transfer, error)
+
+	// Cancels a pending transfer.
+	CancelTransfer(uuid.UUID) error
+
+	// Lists the transfers of a service, oldest first.
+	ListTransfers(serviceId uuid.UUID) ([]Transfer, error)
+}
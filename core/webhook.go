@@ -0,0 +1,183 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrNoWebhook = errors.New("Core:ErrNoWebhook")
+)
+
+// A webhook is a subscription to catalog changes.  Matching changes are
+// posted to the url, signed with the subscription's secret.  An empty
+// set of events subscribes to every type of change.
+type Webhook struct {
+	Id      uuid.UUID    `json:"id,omitempty"`
+	Url     string       `json:"url"`
+	Secret  string       `json:"secret,omitempty"`
+	Events  []ChangeType `json:"events,omitempty"`
+	Filter  Filter       `json:"filter"`
+	Created time.Time    `json:"created,omitempty"`
+}
+
+func NewWebhook(url, secret string, events ...ChangeType) Webhook {
+	return Webhook{
+		Id:      uuid.NewV1(),
+		Url:     url,
+		Secret:  secret,
+		Events:  events,
+		Created: time.Now().UTC(),
+	}
+}
+
+func (w Webhook) Update(fns ...func(*Webhook)) (ret Webhook) {
+	ret = w
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Set the filter of the webhook.
+func (w Webhook) SetFilter(filter Filter) Webhook {
+	return w.Update(func(w *Webhook) {
+		w.Filter = filter
+	})
+}
+
+// Returns true if the webhook subscribes to the given type of change.
+func (w Webhook) Accepts(typ ChangeType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// The state of a delivery.  Deliveries begin pending and end either
+// delivered or dead.  Dead deliveries have exhausted their attempts and
+// are retained for inspection.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+// A delivery is a single change destined for a single webhook.
+type Delivery struct {
+	Id          uuid.UUID      `json:"id"`
+	WebhookId   uuid.UUID      `json:"webhook_id"`
+	Seq         uint64         `json:"seq"`
+	Type        ChangeType     `json:"type"`
+	Payload     []byte         `json:"payload"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"next_attempt"`
+	LastError   string         `json:"last_error,omitempty"`
+	Created     time.Time      `json:"created"`
+	Updated     time.Time      `json:"updated"`
+}
+
+func NewDelivery(hook Webhook, change Change, payload []byte) Delivery {
+	now := time.Now().UTC()
+	return Delivery{
+		Id:          uuid.NewV1(),
+		WebhookId:   hook.Id,
+		Seq:         change.Seq,
+		Type:        change.Type,
+		Payload:     payload,
+		Status:      DeliveryPending,
+		NextAttempt: now,
+		Created:     now,
+		Updated:     now,
+	}
+}
+
+// An attempt records the outcome of a single delivery attempt.  A zero
+// code indicates that no response was received.
+type Attempt struct {
+	DeliveryId uuid.UUID     `json:"delivery_id"`
+	Num        int           `json:"num"`
+	Code       int           `json:"code"`
+	Error      string        `json:"error,omitempty"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Returns true if the attempt was accepted by the receiver.
+func (a Attempt) Ok() bool {
+	return a.Code >= 200 && a.Code < 300
+}
+
+// Filters deliveries by webhook and status.  Nil fields match everything.
+type DeliveryFilter struct {
+	WebhookId *uuid.UUID      `json:"webhook_id,omitempty"`
+	Status    *DeliveryStatus `json:"status,omitempty"`
+}
+
+// The storage of webhook subscriptions and their deliveries.  This is
+// kept separate from the catalog storage, but implementations will
+// typically share the same database.
+type WebhookStorage interface {
+
+	// Adds/updates a webhook.
+	SaveWebhook(Webhook) error
+
+	// Deletes a webhook.  Pending deliveries of the webhook are abandoned.
+	DeleteWebhook(uuid.UUID) error
+
+	// Loads a webhook.  Returns ErrNoWebhook if none exists.
+	LoadWebhook(uuid.UUID) (Webhook, error)
+
+	// Lists all webhooks.
+	ListWebhooks() ([]Webhook, error)
+
+	// Enqueues pending deliveries and advances the cursor into the change
+	// log in a single transaction, so that no change is delivered twice.
+	EnqueueDeliveries(cursor uint64, deliveries []Delivery) error
+
+	// Returns the sequence of the last change that has been enqueued.
+	ReadCursor() (uint64, error)
+
+	// Lists the webhook's pending deliveries whose next attempt is due, oldest
+	// first.
+	ListDue(webhookId uuid.UUID, now time.Time, limit uint64) ([]Delivery, error)
+
+	// Records an attempt and the resulting state of the delivery.
+	RecordAttempt(Delivery, Attempt) error
+
+	// Lists deliveries.  May provide filtering and paging options.
+	ListDeliveries(DeliveryFilter, Page) ([]Delivery, error)
+
+	// Lists the attempts of a delivery, in order.
+	ListAttempts(uuid.UUID) ([]Attempt, error)
+}
+
+// The client interface for managing webhooks.
+type WebhookTransport interface {
+
+	// Adds/updates a webhook.  If no secret is provided on creation, one
+	// will be generated and returned.  Secrets are never returned otherwise.
+	SaveWebhook(Webhook) (Webhook, error)
+
+	// Deletes a webhook.
+	DeleteWebhook(uuid.UUID) error
+
+	// Lists all webhooks.
+	ListWebhooks() ([]Webhook, error)
+
+	// Lists deliveries.  Dead deliveries may be found by filtering on status.
+	ListDeliveries(DeliveryFilter, Page) ([]Delivery, error)
+
+	// Lists the attempts of a delivery.
+	ListAttempts(uuid.UUID) ([]Attempt, error)
+}
@@ -3,9 +3,12 @@ module github.com/pkopriv2/services-catalog
 go 1.16
 
 require (
+	github.com/graphql-go/graphql v0.8.0
 	github.com/pkg/errors v0.9.1
 	github.com/pkopriv2/golang-sdk v0.0.0-20211122034214-9a99ade2f5af
 	github.com/satori/go.uuid v1.2.0
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.7.0
 	github.com/urfave/cli v1.20.0
+	google.golang.org/grpc v1.41.0
+	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	gocontext "context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/grpc/pb"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const authorizationHeader = "authorization"
+
+// The scope required by each method, by name.  Methods that aren't listed
+// require the admin scope.
+var methodScopes = map[string]core.Scope{
+	"GetService":   core.ScopeRead,
+	"GetVersion":   core.ScopeRead,
+	"ListServices": core.ScopeRead,
+	"ListChanges":  core.ScopeRead,
+	"Watch":        core.ScopeRead,
+	"Export":       core.ScopeRead,
+	"SaveService":  core.ScopeWrite,
+	"SaveVersion":  core.ScopeWrite,
+	"SaveBatch":    core.ScopeWrite,
+	"Import":       core.ScopeAdmin,
+}
+
+// Returns the scope required by the method, given its full name.
+func methodScope(method string) core.Scope {
+	name := strings.TrimPrefix(method, "/"+pb.Catalog_ServiceDesc.ServiceName+"/")
+	if scope, ok := methodScopes[name]; ok {
+		return scope
+	}
+	return core.ScopeAdmin
+}
+
+// Returns the server options that authenticate calls by their bearer
+// tokens.  Calls are rejected with the same errors as the http server.
+// Handlers may retrieve the principal of a call with Authenticated.
+func AuthOptions(auth core.Authenticator) []gogrpc.ServerOption {
+	return []gogrpc.ServerOption{
+		gogrpc.ChainUnaryInterceptor(
+			func(ctx gocontext.Context, req interface{}, info *gogrpc.UnaryServerInfo, h gogrpc.UnaryHandler) (interface{}, error) {
+				principal, err := authenticate(ctx, auth, info.FullMethod)
+				if err != nil {
+					return nil, statusError(err)
+				}
+				return h(gocontext.WithValue(ctx, principalKey{}, principal), req)
+			}),
+		gogrpc.ChainStreamInterceptor(
+			func(srv interface{}, stream gogrpc.ServerStream, info *gogrpc.StreamServerInfo, h gogrpc.StreamHandler) error {
+				principal, err := authenticate(stream.Context(), auth, info.FullMethod)
+				if err != nil {
+					return statusError(err)
+				}
+				return h(srv, &authenticatedStream{stream, gocontext.WithValue(stream.Context(), principalKey{}, principal)})
+			}),
+	}
+}
+
+// Returns the principal of the call, if it carries a token with the scope
+// required by the method.
+func authenticate(ctx gocontext.Context, auth core.Authenticator, method string) (ret core.Principal, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	header := md.Get(authorizationHeader)
+	if len(header) == 0 {
+		err = errors.Wrapf(core.ErrUnauthorized, "Missing bearer token")
+		return
+	}
+
+	secret := strings.TrimPrefix(header[0], "Bearer ")
+	if secret == header[0] || secret == "" {
+		err = errors.Wrapf(core.ErrUnauthorized, "Invalid authorization. Must be a bearer token")
+		return
+	}
+
+	if ret, err = auth.Authenticate(secret); err != nil {
+		return
+	}
+	err = ret.Authorize(methodScope(method))
+	return
+}
+
+type principalKey struct{}
+
+// Streams carry their principal in their context.
+type authenticatedStream struct {
+	gogrpc.ServerStream
+	ctx gocontext.Context
+}
+
+func (s *authenticatedStream) Context() gocontext.Context {
+	return s.ctx
+}
+
+// Returns the principal that authenticated the call.  Returns false if the
+// call was not authenticated (e.g. authentication is disabled).
+func Authenticated(ctx gocontext.Context) (ret core.Principal, ok bool) {
+	ret, ok = ctx.Value(principalKey{}).(core.Principal)
+	return
+}
+
+// Returns a dial option that sends the token with every call, over any
+// connection.  Servers that serve TLS should be dialed WithSecureToken.
+func WithToken(token string) gogrpc.DialOption {
+	return gogrpc.WithPerRPCCredentials(bearerCredentials{token, false})
+}
+
+// Returns a dial option that sends the token with every call, but only over
+// connections secured by TLS (see WithTLS).  Calls over plaintext fail
+// rather than send the token in the clear.
+func WithSecureToken(token string) gogrpc.DialOption {
+	return gogrpc.WithPerRPCCredentials(bearerCredentials{token, true})
+}
+
+type bearerCredentials struct {
+	token  string
+	secure bool
+}
+
+func (c bearerCredentials) GetRequestMetadata(gocontext.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorizationHeader: "Bearer " + c.token}, nil
+}
+
+func (c bearerCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+var _ credentials.PerRPCCredentials = bearerCredentials{}
@@ -0,0 +1,194 @@
+package grpc
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAuth(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := Serve(ctx, store, "localhost:0", AuthOptions(core.NewAuthenticator(tokens, nil))...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	connect := func(opts ...gogrpc.DialOption) core.Transport {
+		conn, err := gogrpc.Dial(server.Address(), append(opts, gogrpc.WithInsecure())...)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() { conn.Close() })
+		return NewClient(conn)
+	}
+
+	subjects := make(map[string]string)
+	newToken := func(scope core.Scope, teams ...string) string {
+		token, secret, err := core.NewToken(string(scope), scope, 0, teams...)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		subjects[secret] = token.Principal().Subject
+		return secret
+	}
+
+	if !t.Run("Unauthorized", func(t *testing.T) {
+		_, err := connect().ListServices(core.NewFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+
+		var e *Error
+		if assert.True(t, errors.As(err, &e)) {
+			assert.Equal(t, codes.Unauthenticated, e.Code)
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Unauthorized_InvalidToken", func(t *testing.T) {
+		_, err := connect(WithToken("cat_invalid")).ListServices(core.NewFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	if !t.Run("Read", func(t *testing.T) {
+		transport := connect(WithToken(newToken(core.ScopeRead)))
+
+		_, err := transport.ListServices(core.NewFilter(), core.NewPage())
+		assert.Nil(t, err)
+
+		_, err = transport.SaveService(core.Service{Name: "name", Desc: "desc"})
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+
+		var e *Error
+		if assert.True(t, errors.As(err, &e)) {
+			assert.Equal(t, codes.PermissionDenied, e.Code)
+		}
+	}) {
+		return
+	}
+
+	var writer string
+	if !t.Run("Write", func(t *testing.T) {
+		secret := newToken(core.ScopeWrite)
+		transport := connect(WithToken(secret))
+		writer = subjects[secret]
+
+		_, err := transport.SaveService(core.Service{Name: "name", Desc: "desc"})
+		assert.Nil(t, err)
+
+		snapshot, err := transport.Export(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		_, err = transport.Import(snapshot, core.ImportSkipExisting)
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+	}) {
+		return
+	}
+
+	if !t.Run("Owner", func(t *testing.T) {
+		owner := connect(WithToken(newToken(core.ScopeWrite, "payments")))
+
+		svc, err := owner.SaveService(core.Service{Name: "name", Desc: "desc", Owner: "payments"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "payments", svc.Owner)
+
+		_, err = connect(WithToken(newToken(core.ScopeWrite, "billing"))).SaveVersion(core.NewVersion(svc.Id, "v1"))
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+
+		var e *Error
+		if assert.True(t, errors.As(err, &e)) {
+			assert.Equal(t, codes.PermissionDenied, e.Code)
+		}
+
+		_, err = owner.SaveVersion(core.NewVersion(svc.Id, "v1"))
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("Audit", func(t *testing.T) {
+		audit, err := sqlsvc.NewSqlAuditStore(db, sql.NewSchemaRegistry("TEST"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		entries, err := audit.ListAudit(core.NewAuditFilter(core.AuditByActor(writer)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.NotEmpty(t, entries) {
+			return
+		}
+		assert.Equal(t, "127.0.0.1", entries[0].Actor.ClientIp)
+	}) {
+		return
+	}
+
+	if !t.Run("Admin", func(t *testing.T) {
+		transport := connect(WithToken(newToken(core.ScopeAdmin)))
+
+		snapshot, err := transport.Export(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		_, err = transport.Import(snapshot, core.ImportSkipExisting)
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("Admin_ImportAudited", func(t *testing.T) {
+		secret := newToken(core.ScopeAdmin)
+
+		svc := core.NewService("imported", "desc")
+		_, err := connect(WithToken(secret)).Import(
+			core.NewSnapshot(false, []core.Service{svc}, nil), core.ImportMerge)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		audit, err := sqlsvc.NewSqlAuditStore(db, sql.NewSchemaRegistry("TEST"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		entries, err := audit.ListAudit(core.NewAuditFilter(core.AuditByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, subjects[secret], entries[0].Actor.Subject)
+		assert.Equal(t, "127.0.0.1", entries[0].Actor.ClientIp)
+	}) {
+		return
+	}
+}
@@ -0,0 +1,275 @@
+package grpc
+
+import (
+	gocontext "context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/grpc/pb"
+	uuid "github.com/satori/go.uuid"
+	gogrpc "google.golang.org/grpc"
+)
+
+// A gRPC client of the catalog.  The client is interchangeable with the
+// http client.
+type Client struct {
+	Raw pb.CatalogClient
+}
+
+func NewClient(conn gogrpc.ClientConnInterface) core.Transport {
+	return &Client{pb.NewCatalogClient(conn)}
+}
+
+func (c *Client) SaveService(svc core.Service) (ret core.Service, err error) {
+	resp, err := c.Raw.SaveService(gocontext.Background(), fromService(svc))
+	if err != nil {
+		err = readError(err)
+		return
+	}
+	return toService(resp)
+}
+
+// The maximum number of attempts made by UpdateService.
+const maxUpdateAttempts = 10
+
+func (c *Client) UpdateService(id uuid.UUID, fn func(*core.Service)) (ret core.Service, err error) {
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var latest core.Service
+		if latest, err = c.GetService(id); err != nil {
+			return
+		}
+
+		ret, err = c.SaveService(latest.Update(fn, func(s *core.Service) {
+			s.Id, s.Version = latest.Id, latest.Version+1
+		}))
+		if !errs.Is(err, core.ErrConflict) {
+			return
+		}
+	}
+
+	err = errors.Wrapf(err, "Unable to update service [%v] after [%v] attempts", id, maxUpdateAttempts)
+	return
+}
+
+func (c *Client) SaveVersion(ver core.Version) (ret core.Version, err error) {
+	resp, err := c.Raw.SaveVersion(gocontext.Background(), fromVersion(ver))
+	if err != nil {
+		err = readError(err)
+		return
+	}
+	return toVersion(resp)
+}
+
+func (c *Client) GetService(id uuid.UUID) (ret core.Service, err error) {
+	resp, err := c.Raw.GetService(gocontext.Background(), &pb.GetServiceRequest{Id: formatId(id)})
+	if err != nil {
+		err = readError(err)
+		return
+	}
+	return toService(resp)
+}
+
+func (c *Client) GetVersion(serviceId uuid.UUID, name string) (ret core.Version, err error) {
+	resp, err := c.Raw.GetVersion(gocontext.Background(), &pb.GetVersionRequest{
+		ServiceId: formatId(serviceId),
+		Name:      name,
+	})
+	if err != nil {
+		err = readError(err)
+		return
+	}
+	return toVersion(resp)
+}
+
+func (c *Client) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	req := &pb.SaveBatchRequest{}
+	for _, w := range writes {
+		req.Writes = append(req.Writes, fromWrite(w))
+	}
+
+	resp, err := c.Raw.SaveBatch(gocontext.Background(), req)
+	if err != nil {
+		err = readError(err)
+		return
+	}
+
+	for _, r := range resp.Results {
+		result, err := toWriteResult(r)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, result)
+	}
+
+	if resp.Aborted {
+		err = core.BatchError(ret)
+	}
+	return
+}
+
+// Services are streamed, and reassembled into a catalog.
+func (c *Client) ListServices(filter core.Filter, page core.Page) (ret core.Catalog, err error) {
+	stream, err := c.Raw.ListServices(gocontext.Background(), &pb.ListServicesRequest{
+		Filter: fromFilter(filter),
+		Page:   fromPage(page),
+	})
+	if err != nil {
+		err = readError(err)
+		return
+	}
+
+	ret = core.Catalog{
+		Services: []core.Service{},
+		Versions: make(map[uuid.UUID][]core.Version),
+		Offset:   page.Offset,
+		Limit:    page.Limit,
+	}
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, readError(err)
+		}
+
+		svc, err := toService(entry.Service)
+		if err != nil {
+			return ret, err
+		}
+
+		versions, err := toVersions(entry.Versions)
+		if err != nil {
+			return ret, err
+		}
+
+		ret.Services = append(ret.Services, svc)
+		if len(versions) > 0 {
+			ret.Versions[svc.Id] = versions
+		}
+	}
+}
+
+func (c *Client) ListChanges(since uint64, limit uint64) (ret []core.Change, err error) {
+	resp, err := c.Raw.ListChanges(gocontext.Background(), &pb.ListChangesRequest{
+		Since: since,
+		Limit: limit,
+	})
+	if err != nil {
+		err = readError(err)
+		return
+	}
+
+	for _, raw := range resp.Changes {
+		change, err := toChange(raw)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, change)
+	}
+	return
+}
+
+// The watch has begun once the server has sent its headers, so changes made
+// after Watch returns are guaranteed to be observed.
+func (c *Client) Watch(filter core.Filter, since uint64) (ret core.Watcher, err error) {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+
+	stream, err := c.Raw.Watch(ctx, &pb.WatchRequest{
+		Filter: fromFilter(filter),
+		Since:  since,
+	})
+	if err != nil {
+		cancel()
+		err = readError(err)
+		return
+	}
+
+	if _, err = stream.Header(); err != nil {
+		cancel()
+		err = readError(err)
+		return
+	}
+
+	watcher := &streamWatcher{
+		changes: make(chan core.Change, 64),
+		cancel:  cancel,
+	}
+	go watcher.read(ctx, stream)
+	ret = watcher
+	return
+}
+
+func (c *Client) Export(history bool) (ret core.Snapshot, err error) {
+	resp, err := c.Raw.Export(gocontext.Background(), &pb.ExportRequest{History: history})
+	if err != nil {
+		err = readError(err)
+		return
+	}
+	return toSnapshot(resp)
+}
+
+func (c *Client) Import(snapshot core.Snapshot, mode core.ImportMode) (ret core.ImportResult, err error) {
+	resp, err := c.Raw.Import(gocontext.Background(), &pb.ImportRequest{
+		Snapshot: fromSnapshot(snapshot),
+		Mode:     string(mode),
+	})
+	if err != nil {
+		err = readError(err)
+		return
+	}
+	return toImportResult(resp), nil
+}
+
+// A watcher that receives changes from a stream until it is closed.
+type streamWatcher struct {
+	changes chan core.Change
+	cancel  func()
+	err     error
+}
+
+func (w *streamWatcher) Changes() <-chan core.Change {
+	return w.changes
+}
+
+func (w *streamWatcher) Err() error {
+	return w.err
+}
+
+func (w *streamWatcher) Close() error {
+	w.cancel()
+	return nil
+}
+
+// Receives changes until the stream ends or the watcher is closed.  Always
+// closes the changes channel on return.  A stream may only end in error,
+// since the server never completes a watch.
+func (w *streamWatcher) read(ctx gocontext.Context, stream pb.Catalog_WatchClient) {
+	defer close(w.changes)
+	for {
+		raw, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				w.err = readError(err)
+				if err == io.EOF {
+					w.err = io.ErrUnexpectedEOF
+				}
+			}
+			return
+		}
+
+		change, err := toChange(raw)
+		if err != nil {
+			w.err = err
+			return
+		}
+
+		select {
+		case w.changes <- change:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/grpc/pb"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Conversions between the core types and their messages.  Ids are sent as
+// strings, where the empty string is the empty id, and zero times are sent
+// as unset timestamps.
+
+func parseId(raw string) (ret uuid.UUID, err error) {
+	if raw == "" {
+		return
+	}
+
+	ret, err = uuid.FromString(raw)
+	if err != nil {
+		err = errors.Wrapf(core.ErrState, "Invalid id [%v]", raw)
+	}
+	return
+}
+
+func formatId(id uuid.UUID) string {
+	if id == (uuid.UUID{}) {
+		return ""
+	}
+	return id.String()
+}
+
+func toTime(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func fromTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func toService(s *pb.Service) (ret core.Service, err error) {
+	if s == nil {
+		err = errors.Wrapf(core.ErrState, "Missing service")
+		return
+	}
+
+	ret.Id, err = parseId(s.Id)
+	ret.Name, ret.Desc, ret.Owner, ret.Version, ret.Updated = s.Name, s.Desc, s.Owner, int(s.Version), toTime(s.Updated)
+	return
+}
+
+func fromService(s core.Service) *pb.Service {
+	return &pb.Service{
+		Id:      formatId(s.Id),
+		Name:    s.Name,
+		Desc:    s.Desc,
+		Owner:   s.Owner,
+		Version: int64(s.Version),
+		Updated: fromTime(s.Updated),
+	}
+}
+
+func toVersion(v *pb.Version) (ret core.Version, err error) {
+	if v == nil {
+		err = errors.Wrapf(core.ErrState, "Missing version")
+		return
+	}
+
+	ret.ServiceId, err = parseId(v.ServiceId)
+	ret.Name, ret.Created = v.Name, toTime(v.Created)
+	return
+}
+
+func fromVersion(v core.Version) *pb.Version {
+	return &pb.Version{
+		ServiceId: formatId(v.ServiceId),
+		Name:      v.Name,
+		Created:   fromTime(v.Created),
+	}
+}
+
+func toVersions(all []*pb.Version) (ret []core.Version, err error) {
+	for _, v := range all {
+		ver, err := toVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ver)
+	}
+	return
+}
+
+func fromVersions(all []core.Version) (ret []*pb.Version) {
+	for _, v := range all {
+		ret = append(ret, fromVersion(v))
+	}
+	return
+}
+
+func toFilter(f *pb.Filter) (ret core.Filter, err error) {
+	if f == nil {
+		return
+	}
+
+	ret.NameContains, ret.DescContains = f.NameContains, f.DescContains
+	if f.ServiceId != nil {
+		id, err := parseId(*f.ServiceId)
+		if err != nil {
+			return ret, err
+		}
+		ret.ServiceId = &id
+	}
+	return
+}
+
+func fromFilter(f core.Filter) *pb.Filter {
+	ret := &pb.Filter{
+		NameContains: f.NameContains,
+		DescContains: f.DescContains,
+	}
+	if f.ServiceId != nil {
+		id := f.ServiceId.String()
+		ret.ServiceId = &id
+	}
+	return ret
+}
+
+// Unset fields of the page are defaulted.
+func toPage(p *pb.Page) (ret core.Page) {
+	ret = core.NewPage()
+	if p == nil {
+		return
+	}
+
+	ret.Offset = p.Offset
+	if p.Limit > 0 {
+		ret.Limit = p.Limit
+	}
+	if p.OrderBy != "" {
+		ret.OrderBy = p.OrderBy
+	}
+	return
+}
+
+func fromPage(p core.Page) *pb.Page {
+	return &pb.Page{
+		Offset:  p.Offset,
+		Limit:   p.Limit,
+		OrderBy: p.OrderBy,
+	}
+}
+
+func toChange(c *pb.Change) (ret core.Change, err error) {
+	ret.Seq, ret.Type, ret.Created = c.Seq, core.ChangeType(c.Type), toTime(c.Created)
+	if ret.ServiceId, err = parseId(c.ServiceId); err != nil {
+		return
+	}
+
+	if c.Service != nil {
+		svc, err := toService(c.Service)
+		if err != nil {
+			return ret, err
+		}
+		ret.Service = &svc
+	}
+	if c.Version != nil {
+		ver, err := toVersion(c.Version)
+		if err != nil {
+			return ret, err
+		}
+		ret.Version = &ver
+	}
+	return
+}
+
+func fromChange(c core.Change) *pb.Change {
+	ret := &pb.Change{
+		Seq:       c.Seq,
+		Type:      string(c.Type),
+		ServiceId: formatId(c.ServiceId),
+		Created:   fromTime(c.Created),
+	}
+	if c.Service != nil {
+		ret.Service = fromService(*c.Service)
+	}
+	if c.Version != nil {
+		ret.Version = fromVersion(*c.Version)
+	}
+	return ret
+}
+
+func toWrite(w *pb.Write) (ret core.Write, err error) {
+	ret.Delete = w.Delete
+	if w.Service != nil {
+		svc, err := toService(w.Service)
+		if err != nil {
+			return ret, err
+		}
+		ret.Service = &svc
+	}
+	if w.Version != nil {
+		ver, err := toVersion(w.Version)
+		if err != nil {
+			return ret, err
+		}
+		ret.Version = &ver
+	}
+	return
+}
+
+func fromWrite(w core.Write) *pb.Write {
+	ret := &pb.Write{Delete: w.Delete}
+	if w.Service != nil {
+		ret.Service = fromService(*w.Service)
+	}
+	if w.Version != nil {
+		ret.Version = fromVersion(*w.Version)
+	}
+	return ret
+}
+
+func toWriteResult(r *pb.WriteResult) (ret core.WriteResult, err error) {
+	ret.Error = r.Error
+	if r.Service != nil {
+		svc, err := toService(r.Service)
+		if err != nil {
+			return ret, err
+		}
+		ret.Service = &svc
+	}
+	if r.Version != nil {
+		ver, err := toVersion(r.Version)
+		if err != nil {
+			return ret, err
+		}
+		ret.Version = &ver
+	}
+	return
+}
+
+func fromWriteResult(r core.WriteResult) *pb.WriteResult {
+	ret := &pb.WriteResult{Error: r.Error}
+	if r.Service != nil {
+		ret.Service = fromService(*r.Service)
+	}
+	if r.Version != nil {
+		ret.Version = fromVersion(*r.Version)
+	}
+	return ret
+}
+
+func toSnapshot(s *pb.Snapshot) (ret core.Snapshot, err error) {
+	if s == nil {
+		err = errors.Wrapf(core.ErrState, "Missing snapshot")
+		return
+	}
+
+	ret.Version, ret.Created, ret.History = int(s.Version), toTime(s.Created), s.History
+	for _, svc := range s.Services {
+		tmp, err := toService(svc)
+		if err != nil {
+			return ret, err
+		}
+		ret.Services = append(ret.Services, tmp)
+	}
+
+	ret.Versions, err = toVersions(s.Versions)
+	return
+}
+
+func fromSnapshot(s core.Snapshot) *pb.Snapshot {
+	ret := &pb.Snapshot{
+		Version:  int32(s.Version),
+		Created:  fromTime(s.Created),
+		History:  s.History,
+		Versions: fromVersions(s.Versions),
+	}
+	for _, svc := range s.Services {
+		ret.Services = append(ret.Services, fromService(svc))
+	}
+	return ret
+}
+
+func toImportResult(r *pb.ImportResult) core.ImportResult {
+	return core.ImportResult{
+		Created:   int(r.Created),
+		Updated:   int(r.Updated),
+		Skipped:   int(r.Skipped),
+		Revisions: int(r.Revisions),
+		Versions:  int(r.Versions),
+	}
+}
+
+func fromImportResult(r core.ImportResult) *pb.ImportResult {
+	return &pb.ImportResult{
+		Created:   int64(r.Created),
+		Updated:   int64(r.Updated),
+		Skipped:   int64(r.Skipped),
+		Revisions: int64(r.Revisions),
+		Versions:  int64(r.Versions),
+	}
+}
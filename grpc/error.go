@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A status returned by the server.  Decoded errors unwrap to the matching
+// core error, so they may be matched with errs.Is across the network.
+type Error struct {
+	Code    codes.Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Several core errors share a code, so the core error is recovered from the
+// message, which always contains it.
+func (e *Error) Unwrap() error {
+	for _, m := range errorMappings {
+		if strings.Contains(e.Message, m.Err.Error()) {
+			return m.Err
+		}
+	}
+	return nil
+}
+
+type errorMapping struct {
+	Err  error
+	Code codes.Code
+}
+
+// Errors are mapped by the first core error they contain.
+var errorMappings = []errorMapping{
+	{core.ErrState, codes.InvalidArgument},
+	{core.ErrNoService, codes.NotFound},
+	{core.ErrNoVersion, codes.NotFound},
+	{core.ErrConflict, codes.Aborted},
+	{core.ErrAborted, codes.Aborted},
+	{core.ErrUnauthorized, codes.Unauthenticated},
+	{core.ErrForbidden, codes.PermissionDenied},
+	{core.ErrRateLimited, codes.ResourceExhausted},
+}
+
+// Returns the status of the error.  Unrecognized errors are internal errors.
+func statusError(err error) error {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.Err) || strings.Contains(err.Error(), m.Err.Error()) {
+			return status.Error(m.Code, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Reads the error from a call.  Errors that aren't statuses (e.g. a
+// cancelled context) are returned as is.
+func readError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return &Error{s.Code(), s.Message()}
+}
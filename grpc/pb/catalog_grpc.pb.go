@@ -0,0 +1,506 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CatalogClient is the client API for Catalog service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CatalogClient interface {
+	// Adds/updates a service.  A service without a version is created.
+	SaveService(ctx context.Context, in *Service, opts ...grpc.CallOption) (*Service, error)
+	// Adds a version.  The corresponding service must exist.
+	SaveVersion(ctx context.Context, in *Version, opts ...grpc.CallOption) (*Version, error)
+	// Returns the latest revision of a service.
+	GetService(ctx context.Context, in *GetServiceRequest, opts ...grpc.CallOption) (*Service, error)
+	// Returns the named version of a service.
+	GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*Version, error)
+	// Applies the writes atomically and in order.  An aborted batch is not
+	// an error, so that the results of every write are returned.
+	SaveBatch(ctx context.Context, in *SaveBatchRequest, opts ...grpc.CallOption) (*SaveBatchResponse, error)
+	// Streams a page of services, each along with its versions.
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (Catalog_ListServicesClient, error)
+	// Lists the changes that occurred after the given sequence.
+	ListChanges(ctx context.Context, in *ListChangesRequest, opts ...grpc.CallOption) (*ListChangesResponse, error)
+	// Streams the changes that occur after the given sequence, until the
+	// call is cancelled.  A zero sequence watches from the current end of
+	// the log.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Catalog_WatchClient, error)
+	// Exports a snapshot of the catalog.
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	// Imports a snapshot into the catalog.
+	Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportResult, error)
+}
+
+type catalogClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCatalogClient(cc grpc.ClientConnInterface) CatalogClient {
+	return &catalogClient{cc}
+}
+
+func (c *catalogClient) SaveService(ctx context.Context, in *Service, opts ...grpc.CallOption) (*Service, error) {
+	out := new(Service)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/SaveService", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) SaveVersion(ctx context.Context, in *Version, opts ...grpc.CallOption) (*Version, error) {
+	out := new(Version)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/SaveVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) GetService(ctx context.Context, in *GetServiceRequest, opts ...grpc.CallOption) (*Service, error) {
+	out := new(Service)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/GetService", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*Version, error) {
+	out := new(Version)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/GetVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) SaveBatch(ctx context.Context, in *SaveBatchRequest, opts ...grpc.CallOption) (*SaveBatchResponse, error) {
+	out := new(SaveBatchResponse)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/SaveBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (Catalog_ListServicesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Catalog_ServiceDesc.Streams[0], "/catalog.v1.Catalog/ListServices", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &catalogListServicesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Catalog_ListServicesClient interface {
+	Recv() (*CatalogEntry, error)
+	grpc.ClientStream
+}
+
+type catalogListServicesClient struct {
+	grpc.ClientStream
+}
+
+func (x *catalogListServicesClient) Recv() (*CatalogEntry, error) {
+	m := new(CatalogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *catalogClient) ListChanges(ctx context.Context, in *ListChangesRequest, opts ...grpc.CallOption) (*ListChangesResponse, error) {
+	out := new(ListChangesResponse)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/ListChanges", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Catalog_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Catalog_ServiceDesc.Streams[1], "/catalog.v1.Catalog/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &catalogWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Catalog_WatchClient interface {
+	Recv() (*Change, error)
+	grpc.ClientStream
+}
+
+type catalogWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *catalogWatchClient) Recv() (*Change, error) {
+	m := new(Change)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *catalogClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/Export", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogClient) Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportResult, error) {
+	out := new(ImportResult)
+	err := c.cc.Invoke(ctx, "/catalog.v1.Catalog/Import", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CatalogServer is the server API for Catalog service.
+// All implementations must embed UnimplementedCatalogServer
+// for forward compatibility
+type CatalogServer interface {
+	// Adds/updates a service.  A service without a version is created.
+	SaveService(context.Context, *Service) (*Service, error)
+	// Adds a version.  The corresponding service must exist.
+	SaveVersion(context.Context, *Version) (*Version, error)
+	// Returns the latest revision of a service.
+	GetService(context.Context, *GetServiceRequest) (*Service, error)
+	// Returns the named version of a service.
+	GetVersion(context.Context, *GetVersionRequest) (*Version, error)
+	// Applies the writes atomically and in order.  An aborted batch is not
+	// an error, so that the results of every write are returned.
+	SaveBatch(context.Context, *SaveBatchRequest) (*SaveBatchResponse, error)
+	// Streams a page of services, each along with its versions.
+	ListServices(*ListServicesRequest, Catalog_ListServicesServer) error
+	// Lists the changes that occurred after the given sequence.
+	ListChanges(context.Context, *ListChangesRequest) (*ListChangesResponse, error)
+	// Streams the changes that occur after the given sequence, until the
+	// call is cancelled.  A zero sequence watches from the current end of
+	// the log.
+	Watch(*WatchRequest, Catalog_WatchServer) error
+	// Exports a snapshot of the catalog.
+	Export(context.Context, *ExportRequest) (*Snapshot, error)
+	// Imports a snapshot into the catalog.
+	Import(context.Context, *ImportRequest) (*ImportResult, error)
+	mustEmbedUnimplementedCatalogServer()
+}
+
+// UnimplementedCatalogServer must be embedded to have forward compatible implementations.
+type UnimplementedCatalogServer struct {
+}
+
+func (UnimplementedCatalogServer) SaveService(context.Context, *Service) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveService not implemented")
+}
+func (UnimplementedCatalogServer) SaveVersion(context.Context, *Version) (*Version, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveVersion not implemented")
+}
+func (UnimplementedCatalogServer) GetService(context.Context, *GetServiceRequest) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetService not implemented")
+}
+func (UnimplementedCatalogServer) GetVersion(context.Context, *GetVersionRequest) (*Version, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
+func (UnimplementedCatalogServer) SaveBatch(context.Context, *SaveBatchRequest) (*SaveBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveBatch not implemented")
+}
+func (UnimplementedCatalogServer) ListServices(*ListServicesRequest, Catalog_ListServicesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (UnimplementedCatalogServer) ListChanges(context.Context, *ListChangesRequest) (*ListChangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChanges not implemented")
+}
+func (UnimplementedCatalogServer) Watch(*WatchRequest, Catalog_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedCatalogServer) Export(context.Context, *ExportRequest) (*Snapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedCatalogServer) Import(context.Context, *ImportRequest) (*ImportResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedCatalogServer) mustEmbedUnimplementedCatalogServer() {}
+
+// UnsafeCatalogServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CatalogServer will
+// result in compilation errors.
+type UnsafeCatalogServer interface {
+	mustEmbedUnimplementedCatalogServer()
+}
+
+func RegisterCatalogServer(s grpc.ServiceRegistrar, srv CatalogServer) {
+	s.RegisterService(&Catalog_ServiceDesc, srv)
+}
+
+func _Catalog_SaveService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Service)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).SaveService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/SaveService",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).SaveService(ctx, req.(*Service))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_SaveVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Version)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).SaveVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/SaveVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).SaveVersion(ctx, req.(*Version))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_GetService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).GetService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/GetService",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).GetService(ctx, req.(*GetServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/GetVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).GetVersion(ctx, req.(*GetVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_SaveBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).SaveBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/SaveBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).SaveBatch(ctx, req.(*SaveBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_ListServices_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListServicesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CatalogServer).ListServices(m, &catalogListServicesServer{stream})
+}
+
+type Catalog_ListServicesServer interface {
+	Send(*CatalogEntry) error
+	grpc.ServerStream
+}
+
+type catalogListServicesServer struct {
+	grpc.ServerStream
+}
+
+func (x *catalogListServicesServer) Send(m *CatalogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Catalog_ListChanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).ListChanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/ListChanges",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).ListChanges(ctx, req.(*ListChangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CatalogServer).Watch(m, &catalogWatchServer{stream})
+}
+
+type Catalog_WatchServer interface {
+	Send(*Change) error
+	grpc.ServerStream
+}
+
+type catalogWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *catalogWatchServer) Send(m *Change) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Catalog_Export_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).Export(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/Export",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).Export(ctx, req.(*ExportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Catalog_Import_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServer).Import(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catalog.v1.Catalog/Import",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServer).Import(ctx, req.(*ImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Catalog_ServiceDesc is the grpc.ServiceDesc for Catalog service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Catalog_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "catalog.v1.Catalog",
+	HandlerType: (*CatalogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SaveService",
+			Handler:    _Catalog_SaveService_Handler,
+		},
+		{
+			MethodName: "SaveVersion",
+			Handler:    _Catalog_SaveVersion_Handler,
+		},
+		{
+			MethodName: "GetService",
+			Handler:    _Catalog_GetService_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _Catalog_GetVersion_Handler,
+		},
+		{
+			MethodName: "SaveBatch",
+			Handler:    _Catalog_SaveBatch_Handler,
+		},
+		{
+			MethodName: "ListChanges",
+			Handler:    _Catalog_ListChanges_Handler,
+		},
+		{
+			MethodName: "Export",
+			Handler:    _Catalog_Export_Handler,
+		},
+		{
+			MethodName: "Import",
+			Handler:    _Catalog_Import_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListServices",
+			Handler:       _Catalog_ListServices_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Catalog_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpc/pb/catalog.proto",
+}
@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	gocontext "context"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Sent with calls that are rate limited.  Holds the seconds until the
+	// client may retry.
+	RetryAfterMetadata = "retry-after"
+)
+
+// Returns the server options that limit the rate of calls per client, by
+// the same limiters as the http server (so a client's budget is shared by
+// both).  Reads are the methods that require no more than the read scope.
+// Clients are identified by the subject of their token or, if they aren't
+// authenticated, by their ip.  Calls over the limit fail with
+// ResourceExhausted.  A stream is limited as a single call, when it opens.
+//
+// Clients are identified by authentication, so these should be given after
+// the AuthOptions (the first interceptor is the first to run).
+func RateLimitOptions(reads, writes *core.RateLimiter) []gogrpc.ServerOption {
+	allow := func(ctx gocontext.Context, method string) error {
+		limiter := writes
+		if methodScope(method) == core.ScopeRead {
+			limiter = reads
+		}
+
+		ok, wait := limiter.Allow(clientKey(ctx))
+		if ok {
+			return nil
+		}
+
+		secs := int(math.Ceil(wait.Seconds()))
+		gogrpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadata, fmt.Sprintf("%v", secs)))
+		return statusError(
+			errors.Wrapf(core.ErrRateLimited, "Too many requests. Retry after [%vs]", secs))
+	}
+
+	return []gogrpc.ServerOption{
+		gogrpc.ChainUnaryInterceptor(
+			func(ctx gocontext.Context, req interface{}, info *gogrpc.UnaryServerInfo, h gogrpc.UnaryHandler) (interface{}, error) {
+				if err := allow(ctx, info.FullMethod); err != nil {
+					return nil, err
+				}
+				return h(ctx, req)
+			}),
+		gogrpc.ChainStreamInterceptor(
+			func(srv interface{}, stream gogrpc.ServerStream, info *gogrpc.StreamServerInfo, h gogrpc.StreamHandler) error {
+				if err := allow(stream.Context(), info.FullMethod); err != nil {
+					return err
+				}
+				return h(srv, stream)
+			}),
+	}
+}
+
+func clientKey(ctx gocontext.Context) string {
+	actor := actorOf(ctx)
+	if actor.Subject != "" {
+		return actor.Subject
+	}
+	return "ip:" + actor.ClientIp
+}
@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	gocontext "context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/grpc/pb"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	reads := core.NewRateLimiter(core.RateLimit{Rate: 1, Burst: 2})
+	writes := core.NewRateLimiter(core.RateLimit{Rate: 1, Burst: 1})
+
+	opts := append(AuthOptions(core.NewAuthenticator(tokens, nil)), RateLimitOptions(reads, writes)...)
+	server, err := Serve(ctx, store, "localhost:0", opts...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	connect := func(opts ...gogrpc.DialOption) *Client {
+		conn, err := gogrpc.Dial(server.Address(), append(opts, gogrpc.WithInsecure())...)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() { conn.Close() })
+		return NewClient(conn).(*Client)
+	}
+
+	newToken := func(name string) string {
+		token, secret, err := core.NewToken(name, core.ScopeWrite, 0)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		return secret
+	}
+
+	alice := connect(WithToken(newToken("alice")))
+
+	if !t.Run("Reads_Burst", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := alice.ListChanges(0, 10)
+			if !assert.Nil(t, err) {
+				return
+			}
+		}
+
+		var header metadata.MD
+		_, err := alice.Raw.GetService(gocontext.Background(),
+			&pb.GetServiceRequest{Id: core.NewService("svc", "").Id.String()}, gogrpc.Header(&header))
+
+		err = readError(err)
+		assert.True(t, errs.Is(err, core.ErrRateLimited))
+
+		var e *Error
+		if assert.True(t, errors.As(err, &e)) {
+			assert.Equal(t, codes.ResourceExhausted, e.Code)
+		}
+		assert.Equal(t, []string{"1"}, header.Get(RetryAfterMetadata))
+	}) {
+		return
+	}
+
+	if !t.Run("Reads_Stream", func(t *testing.T) {
+		_, err := alice.ListServices(core.NewFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrRateLimited))
+	}) {
+		return
+	}
+
+	if !t.Run("Writes_Separate", func(t *testing.T) {
+		_, err := alice.SaveService(core.NewService("svc", "desc"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		_, err = alice.SaveService(core.NewService("svc2", "desc"))
+		assert.True(t, errs.Is(err, core.ErrRateLimited))
+	}) {
+		return
+	}
+
+	if !t.Run("Clients_Separate", func(t *testing.T) {
+		_, err := connect(WithToken(newToken("bob"))).ListChanges(0, 10)
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+}
@@ -0,0 +1,402 @@
+package grpc
+
+import (
+	gocontext "context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/services-catalog/authz"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/grpc/pb"
+	uuid "github.com/satori/go.uuid"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// Sent as a header of watch streams once the starting sequence has been
+	// determined, so that clients know the watch has begun.
+	WatchSeqHeader = "watch-seq"
+
+	// Identifies the request in the audit log.
+	RequestIdMetadata = "x-request-id"
+)
+
+var (
+	WatchPollInterval = 250 * time.Millisecond
+	WatchBatchSize    = uint64(256)
+)
+
+// A gRPC server of the catalog, backed by storage.  The server applies the
+// same validation as the http server, so the two may be run side by side.
+type Server struct {
+	ctx      context.Context
+	raw      *gogrpc.Server
+	listener net.Listener
+}
+
+func Serve(ctx context.Context, storage core.Storage, addr string, opts ...gogrpc.ServerOption) (ret *Server, err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+
+	ret = &Server{ctx.Sub("Grpc(%v)", listener.Addr()), gogrpc.NewServer(opts...), listener}
+	pb.RegisterCatalogServer(ret.raw, &catalogServer{ctx: ret.ctx, storage: storage})
+
+	// Stopping the server closes the listener and cancels open streams.
+	ret.ctx.Control().Defer(func(error) {
+		ret.raw.Stop()
+	})
+
+	go func() {
+		logger := ret.ctx.Logger()
+		logger.Info("Starting")
+		defer logger.Info("Stopping")
+		if err := ret.raw.Serve(listener); err != nil {
+			ret.ctx.Control().Fail(err)
+		}
+	}()
+	return
+}
+
+func (s *Server) Close() error {
+	return s.ctx.Close()
+}
+
+// Stops accepting calls and waits for those in progress to finish, up to
+// the timeout, before closing the server.  Streams that remain open after
+// the timeout are canceled.
+func (s *Server) Drain(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.raw.GracefulStop()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.ctx.Logger().Error("Streams still open after [%v]. Canceling them", timeout)
+	}
+	return s.Close()
+}
+
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+type catalogServer struct {
+	pb.UnimplementedCatalogServer
+	ctx     context.Context
+	storage core.Storage
+}
+
+// Every call goes through the caller's storage, so that its writes are
+// attributed to the caller, and authenticated calls are authorized against
+// the owners of services.
+func (s *catalogServer) storageOf(ctx gocontext.Context) core.Storage {
+	storage := core.Attribute(s.storage, actorOf(ctx))
+	if principal, ok := Authenticated(ctx); ok {
+		return authz.NewStorage(storage, principal)
+	}
+	return storage
+}
+
+// Returns the actor to whom the call's writes are attributed.  Request ids
+// are sent as metadata.
+func actorOf(ctx gocontext.Context) (ret core.Actor) {
+	if principal, ok := Authenticated(ctx); ok {
+		ret.Subject = principal.Subject
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		ret.ClientIp = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ret.ClientIp); err == nil {
+			ret.ClientIp = host
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(RequestIdMetadata); len(ids) > 0 {
+		ret.RequestId = ids[0]
+	}
+	return
+}
+
+func (s *catalogServer) SaveService(ctx gocontext.Context, req *pb.Service) (*pb.Service, error) {
+	svc, err := toService(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	w := core.SaveServiceWrite(svc)
+	if err := w.Prepare(); err != nil {
+		return nil, statusError(err)
+	}
+
+	s.ctx.Logger().Debug("Adding service [name=%v,version=%v]", w.Service.Name, w.Service.Version)
+	if err := s.storageOf(ctx).SaveService(*w.Service); err != nil {
+		return nil, statusError(err)
+	}
+	return fromService(*w.Service), nil
+}
+
+func (s *catalogServer) SaveVersion(ctx gocontext.Context, req *pb.Version) (*pb.Version, error) {
+	ver, err := toVersion(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	w := core.SaveVersionWrite(ver)
+	if err := w.Prepare(); err != nil {
+		return nil, statusError(err)
+	}
+
+	s.ctx.Logger().Debug("Adding version [service=%v,name=%v]", w.Version.ServiceId, w.Version.Name)
+	if err := s.storageOf(ctx).SaveVersion(*w.Version); err != nil {
+		return nil, statusError(err)
+	}
+	return fromVersion(*w.Version), nil
+}
+
+func (s *catalogServer) GetService(ctx gocontext.Context, req *pb.GetServiceRequest) (*pb.Service, error) {
+	id, err := parseId(req.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	svc, _, err := loadService(s.storageOf(ctx), id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return fromService(svc), nil
+}
+
+func (s *catalogServer) GetVersion(ctx gocontext.Context, req *pb.GetVersionRequest) (*pb.Version, error) {
+	id, err := parseId(req.ServiceId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	_, versions, err := loadService(s.storageOf(ctx), id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	for _, v := range versions {
+		if v.Name == req.Name {
+			return fromVersion(v), nil
+		}
+	}
+	return nil, statusError(errors.Wrapf(core.ErrNoVersion, "No such version [%v/%v]", id, req.Name))
+}
+
+// Invalid and failed writes abort the batch, which is reported in the
+// response along with the result of every write.
+func (s *catalogServer) SaveBatch(ctx gocontext.Context, req *pb.SaveBatchRequest) (*pb.SaveBatchResponse, error) {
+	if len(req.Writes) == 0 || len(req.Writes) > core.MaxBatchSize {
+		return nil, statusError(errors.Wrapf(core.ErrState, "Invalid batch. Must contain between 1 and %v writes", core.MaxBatchSize))
+	}
+
+	writes := make([]core.Write, len(req.Writes))
+	invalid := make([]core.WriteResult, len(req.Writes))
+	failed := false
+	for i, w := range req.Writes {
+		write, err := toWrite(w)
+		if err == nil {
+			err = write.Prepare()
+		}
+		if err != nil {
+			invalid[i].Error, failed = err.Error(), true
+		}
+		writes[i] = write
+	}
+	if failed {
+		for i := range invalid {
+			if invalid[i].Ok() {
+				invalid[i].Error = core.ErrAborted.Error()
+			}
+		}
+		return batchResponse(invalid, true), nil
+	}
+
+	s.ctx.Logger().Debug("Applying batch [writes=%v]", len(writes))
+	results, err := s.storageOf(ctx).SaveBatch(writes)
+	if err != nil {
+		if len(results) != len(writes) {
+			return nil, statusError(err)
+		}
+		return batchResponse(results, true), nil
+	}
+	return batchResponse(results, false), nil
+}
+
+func batchResponse(results []core.WriteResult, aborted bool) *pb.SaveBatchResponse {
+	ret := &pb.SaveBatchResponse{Aborted: aborted}
+	for _, r := range results {
+		ret.Results = append(ret.Results, fromWriteResult(r))
+	}
+	return ret
+}
+
+func (s *catalogServer) ListServices(req *pb.ListServicesRequest, stream pb.Catalog_ListServicesServer) error {
+	filter, err := toFilter(req.Filter)
+	if err != nil {
+		return statusError(err)
+	}
+
+	page := toPage(req.Page)
+	if page.Limit > 1024 {
+		return statusError(errors.Wrapf(core.ErrState, "Invalid limit. Must be <= 1024"))
+	}
+
+	catalog, err := s.storageOf(stream.Context()).ListServices(filter, page)
+	if err != nil {
+		return statusError(err)
+	}
+
+	for _, svc := range catalog.Services {
+		if err := stream.Send(&pb.CatalogEntry{
+			Service:  fromService(svc),
+			Versions: fromVersions(catalog.Versions[svc.Id]),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *catalogServer) ListChanges(ctx gocontext.Context, req *pb.ListChangesRequest) (*pb.ListChangesResponse, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = 1024
+	}
+	if limit > 1024 {
+		return nil, statusError(errors.Wrapf(core.ErrState, "Invalid limit. Must be <= 1024"))
+	}
+
+	changes, err := s.storageOf(ctx).ListChanges(req.Since, limit)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	ret := &pb.ListChangesResponse{}
+	for _, c := range changes {
+		ret.Changes = append(ret.Changes, fromChange(c))
+	}
+	return ret, nil
+}
+
+// Tails the change log, sending each matching change.  The stream ends once
+// the client cancels the call or the server is closed.
+func (s *catalogServer) Watch(req *pb.WatchRequest, stream pb.Catalog_WatchServer) (err error) {
+	filter, err := toFilter(req.Filter)
+	if err != nil {
+		return statusError(err)
+	}
+
+	storage := s.storageOf(stream.Context())
+
+	since, err := core.WatchStart(storage, req.Since)
+	if err != nil {
+		return statusError(err)
+	}
+
+	if err = stream.SendHeader(metadata.Pairs(WatchSeqHeader, strconv.FormatUint(since, 10))); err != nil {
+		return
+	}
+
+	matcher := core.NewChangeMatcher(storage)
+	for {
+		changes, err := storage.ListChanges(since, WatchBatchSize)
+		if err != nil {
+			s.ctx.Logger().Error("Error reading changes [since=%v]: %+v", since, err)
+			return statusError(err)
+		}
+
+		for _, c := range changes {
+			since = c.Seq
+
+			ok, err := matcher.Matches(filter, c)
+			if err != nil {
+				return statusError(err)
+			}
+			if !ok {
+				continue
+			}
+
+			if err := stream.Send(fromChange(c)); err != nil {
+				return err
+			}
+		}
+
+		if uint64(len(changes)) == WatchBatchSize {
+			continue
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.ctx.Control().Closed():
+			return nil
+		case <-time.After(WatchPollInterval):
+		}
+	}
+}
+
+func (s *catalogServer) Export(ctx gocontext.Context, req *pb.ExportRequest) (*pb.Snapshot, error) {
+	snapshot, err := s.storageOf(ctx).Export(req.History)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return fromSnapshot(snapshot), nil
+}
+
+func (s *catalogServer) Import(ctx gocontext.Context, req *pb.ImportRequest) (*pb.ImportResult, error) {
+	raw := req.Mode
+	if raw == "" {
+		raw = string(core.ImportMerge)
+	}
+
+	mode, err := core.ParseImportMode(raw)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	snapshot, err := toSnapshot(req.Snapshot)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	s.ctx.Logger().Info("Importing snapshot [services=%v,versions=%v,mode=%v]",
+		len(snapshot.Services), len(snapshot.Versions), mode)
+
+	result, err := s.storageOf(ctx).Import(snapshot, mode)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return fromImportResult(result), nil
+}
+
+// Loads the latest revision of a service and its versions.
+func loadService(storage core.Storage, id uuid.UUID) (svc core.Service, versions []core.Version, err error) {
+	catalog, err := storage.ListServices(
+		core.NewFilter(core.FilterByServiceId(id)),
+		core.NewPage(core.Limit(1)))
+	if err != nil {
+		return
+	}
+
+	if len(catalog.Services) == 0 {
+		err = errors.Wrapf(core.ErrNoService, "No such service [%v]", id)
+		return
+	}
+
+	svc, versions = catalog.Services[0], catalog.Versions[id]
+	return
+}
@@ -0,0 +1,277 @@
+package grpc
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := Serve(ctx, store, "localhost:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	conn, err := gogrpc.Dial(server.Address(), gogrpc.WithInsecure())
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	transport := NewClient(conn)
+
+	var svc core.Service
+	if !t.Run("SaveService", func(t *testing.T) {
+		svc, err = transport.SaveService(core.Service{Name: "name", Desc: "desc"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.NotEqual(t, uuid.UUID{}, svc.Id)
+		assert.False(t, svc.Updated.IsZero())
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Updated", func(t *testing.T) {
+		svc, err = transport.SaveService(svc.Increment().SetDesc("desc2"))
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Conflict", func(t *testing.T) {
+		_, err := transport.SaveService(svc)
+		assert.True(t, errs.Is(err, core.ErrConflict))
+
+		var e *Error
+		if assert.True(t, errors.As(err, &e)) {
+			assert.Equal(t, codes.Aborted, e.Code)
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Invalid", func(t *testing.T) {
+		_, err := transport.SaveService(core.Service{Name: "name"})
+		assert.True(t, errors.Is(err, core.ErrState))
+	}) {
+		return
+	}
+
+	v := core.NewVersion(svc.Id, "v1")
+	if !t.Run("SaveVersion", func(t *testing.T) {
+		v, err = transport.SaveVersion(v)
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveVersion_NoService", func(t *testing.T) {
+		_, err := transport.SaveVersion(core.NewVersion(uuid.NewV1(), "v1"))
+		assert.True(t, errors.Is(err, core.ErrNoService))
+	}) {
+		return
+	}
+
+	if !t.Run("GetService", func(t *testing.T) {
+		ret, err := transport.GetService(svc.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, svc.Id, ret.Id)
+		assert.Equal(t, svc.Version, ret.Version)
+		assert.Equal(t, svc.Desc, ret.Desc)
+	}) {
+		return
+	}
+
+	if !t.Run("GetService_NoService", func(t *testing.T) {
+		_, err := transport.GetService(uuid.NewV1())
+		assert.True(t, errors.Is(err, core.ErrNoService))
+	}) {
+		return
+	}
+
+	if !t.Run("GetVersion", func(t *testing.T) {
+		ret, err := transport.GetVersion(svc.Id, v.Name)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, v.Name, ret.Name)
+		assert.True(t, v.Created.Equal(ret.Created))
+	}) {
+		return
+	}
+
+	if !t.Run("GetVersion_NoVersion", func(t *testing.T) {
+		_, err := transport.GetVersion(svc.Id, "missing")
+		assert.True(t, errors.Is(err, core.ErrNoVersion))
+	}) {
+		return
+	}
+
+	if !t.Run("UpdateService", func(t *testing.T) {
+		updated, err := transport.UpdateService(svc.Id, func(s *core.Service) {
+			s.Desc = "updated"
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, svc.Version+1, updated.Version)
+		assert.Equal(t, "updated", updated.Desc)
+		svc = updated
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if _, err := transport.SaveService(core.Service{Name: "other", Desc: "desc"}); !assert.Nil(t, err) {
+				return
+			}
+		}
+
+		catalog, err := transport.ListServices(core.NewFilter(), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 4, len(catalog.Services))
+		assert.Equal(t, []core.Version{v}, catalog.Versions[svc.Id])
+
+		catalog, err = transport.ListServices(
+			core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			return
+		}
+		assert.Equal(t, svc.Desc, catalog.Services[0].Desc)
+	}) {
+		return
+	}
+
+	if !t.Run("ListChanges", func(t *testing.T) {
+		changes, err := transport.ListChanges(0, 2)
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(changes)) {
+			return
+		}
+		assert.Equal(t, core.ServiceSaved, changes[0].Type)
+		assert.Equal(t, svc.Id, changes[0].ServiceId)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch", func(t *testing.T) {
+		id := uuid.NewV1()
+		results, err := transport.SaveBatch([]core.Write{
+			core.SaveServiceWrite(core.Service{Id: id, Name: "batch", Desc: "desc"}),
+			core.SaveVersionWrite(core.Version{ServiceId: id, Name: "v1"}),
+		})
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(results)) {
+			return
+		}
+		assert.Equal(t, id, results[0].Service.Id)
+		assert.Equal(t, "v1", results[1].Version.Name)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Aborted", func(t *testing.T) {
+		results, err := transport.SaveBatch([]core.Write{
+			core.SaveServiceWrite(core.Service{Name: "aborted", Desc: "desc"}),
+			core.SaveVersionWrite(core.Version{ServiceId: svc.Id, Name: v.Name}),
+		})
+		assert.True(t, errs.Is(err, core.ErrConflict))
+		if !assert.Equal(t, 2, len(results)) {
+			return
+		}
+		assert.True(t, errs.Is(results[0].Err(), core.ErrAborted))
+		assert.True(t, errs.Is(results[1].Err(), core.ErrConflict))
+	}) {
+		return
+	}
+
+	if !t.Run("Watch", func(t *testing.T) {
+		watcher, err := transport.Watch(core.NewFilter(core.FilterByServiceId(svc.Id)), 0)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer watcher.Close()
+
+		if _, err := transport.SaveService(core.Service{Name: "unwatched", Desc: "desc"}); !assert.Nil(t, err) {
+			return
+		}
+		if _, err := transport.SaveVersion(core.NewVersion(svc.Id, "v2")); !assert.Nil(t, err) {
+			return
+		}
+
+		select {
+		case change := <-watcher.Changes():
+			assert.Equal(t, core.VersionSaved, change.Type)
+			assert.Equal(t, "v2", change.Version.Name)
+		case <-time.After(5 * time.Second):
+			t.Fail()
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Watch_Close", func(t *testing.T) {
+		watcher, err := transport.Watch(core.NewFilter(), 0)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Nil(t, watcher.Close())
+
+		select {
+		case _, ok := <-watcher.Changes():
+			assert.False(t, ok)
+			assert.Nil(t, watcher.Err())
+		case <-time.After(5 * time.Second):
+			t.Fail()
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Export_Import", func(t *testing.T) {
+		snapshot, err := transport.Export(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, snapshot.History)
+		assert.NotEmpty(t, snapshot.Services)
+
+		result, err := transport.Import(snapshot, core.ImportSkipExisting)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 0, result.Created)
+		assert.NotZero(t, result.Skipped)
+	}) {
+		return
+	}
+}
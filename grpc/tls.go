@@ -0,0 +1,28 @@
+package grpc
+
+import (
+	"crypto/tls"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Returns a server option that serves TLS.  The configuration is retrieved
+// for every connection, so that its certificates may be replaced while the
+// server is running.  A client CA in the configuration requires clients to
+// present certificates (i.e. mutual TLS), as it does for the http server.
+func TLSOption(config func() *tls.Config) gogrpc.ServerOption {
+	return gogrpc.Creds(credentials.NewTLS(&tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			// gRPC is served over http/2, which must be negotiated.
+			ret := config().Clone()
+			ret.NextProtos = []string{"h2"}
+			return ret, nil
+		},
+	}))
+}
+
+// Returns a dial option that connects over TLS.
+func WithTLS(config *tls.Config) gogrpc.DialOption {
+	return gogrpc.WithTransportCredentials(credentials.NewTLS(config))
+}
@@ -0,0 +1,100 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/pkopriv2/services-catalog/tlstest"
+	"github.com/stretchr/testify/assert"
+	gogrpc "google.golang.org/grpc"
+)
+
+func TestTLS(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	token, secret, err := core.NewToken("reader", core.ScopeRead, 0)
+	if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+		return
+	}
+
+	ca := tlstest.NewCertificate(t, nil)
+	cert := tlstest.NewCertificate(t, &ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+
+	opts := append(AuthOptions(core.NewAuthenticator(tokens, nil)), TLSOption(func() *tls.Config {
+		return config
+	}))
+
+	server, err := Serve(ctx, store, "localhost:0", opts...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	connect := func(opts ...gogrpc.DialOption) core.Transport {
+		conn, err := gogrpc.Dial(server.Address(), opts...)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() { conn.Close() })
+		return NewClient(conn)
+	}
+
+	if !t.Run("SecureToken_Plaintext", func(t *testing.T) {
+		_, err := gogrpc.Dial(server.Address(), gogrpc.WithInsecure(), WithSecureToken(secret))
+		assert.NotNil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("ClientCertificate_Missing", func(t *testing.T) {
+		_, err := connect(WithTLS(&tls.Config{RootCAs: pool}), WithSecureToken(secret)).ListServices(core.NewFilter(), core.NewPage())
+		if assert.NotNil(t, err) {
+			assert.NotContains(t, err.Error(), "x509")
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("MutualTLS", func(t *testing.T) {
+		client := tlstest.NewCertificate(t, &ca)
+		transport := connect(
+			WithTLS(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{client}}),
+			WithSecureToken(secret))
+
+		_, err := transport.ListServices(core.NewFilter(), core.NewPage())
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+}
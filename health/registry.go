@@ -0,0 +1,206 @@
+package health
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDraining = errors.New("Health:ErrDraining")
+	ErrTimeout  = errors.New("Health:ErrTimeout")
+)
+
+// A check returns an error if the subsystem it probes is unable to serve.
+type Check func() error
+
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+)
+
+// The reasons a check may fail.  Reports are served to anyone who can reach
+// the instance, so they only give the reason.  The error itself is kept for
+// the logs.
+const (
+	ReasonDraining = "draining"
+	ReasonTimeout  = "timeout"
+	ReasonFailed   = "failed"
+)
+
+// The outcome of a single check.
+type Result struct {
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	Duration string `json:"duration"`
+	Err      error  `json:"-"`
+}
+
+// A report passes only if every check passed.
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+func (r Report) Passed() bool {
+	return r.Status == Pass
+}
+
+type Option func(*Options)
+
+type Options struct {
+	Timeout time.Duration
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{Timeout: 2 * time.Second}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the deadline of each check.  A check that misses its deadline fails.
+func WithTimeout(dur time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = dur
+	}
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+	lock  sync.Mutex
+	run   *flight // the run in flight, if any
+}
+
+// A single run of a check, which every caller waits on until it completes.
+type flight struct {
+	done chan struct{}
+	err  error
+}
+
+// Starts a run of the check, unless one is already in flight, in which case
+// its result is shared.  A check that hangs therefore holds at most one
+// goroutine, however often it's probed.
+func (c *namedCheck) start() *flight {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.run != nil {
+		return c.run
+	}
+
+	f := &flight{done: make(chan struct{})}
+	c.run = f
+	go func() {
+		f.err = c.check()
+
+		c.lock.Lock()
+		c.run = nil
+		c.lock.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+// A registry holds the checks that determine whether an instance is ready
+// to serve traffic.  Any subsystem may register a check.  Every registry
+// has a "drain" check, which fails once the instance begins to drain.
+type Registry struct {
+	opts     Options
+	lock     sync.Mutex
+	checks   []*namedCheck
+	draining int32
+}
+
+func NewRegistry(fns ...Option) *Registry {
+	ret := &Registry{opts: buildOptions(fns...)}
+	ret.Register("drain", func() error {
+		if ret.Draining() {
+			return ErrDraining
+		}
+		return nil
+	})
+	return ret
+}
+
+// Registers a check.  Checks are reported in the order they're registered.
+func (r *Registry) Register(name string, check Check) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.checks = append(r.checks, &namedCheck{name: name, check: check})
+}
+
+// Marks the instance as draining, so that it's no longer ready.
+func (r *Registry) Drain() {
+	atomic.StoreInt32(&r.draining, 1)
+}
+
+func (r *Registry) Draining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+// Runs every check concurrently and reports their results.
+func (r *Registry) Check() (ret Report) {
+	r.lock.Lock()
+	checks := append([]*namedCheck(nil), r.checks...)
+	r.lock.Unlock()
+
+	ret = Report{Status: Pass, Checks: make([]Result, len(checks))}
+
+	var wait sync.WaitGroup
+	for i, c := range checks {
+		wait.Add(1)
+		go func(i int, c *namedCheck) {
+			defer wait.Done()
+			ret.Checks[i] = r.run(c)
+		}(i, c)
+	}
+	wait.Wait()
+
+	for _, c := range ret.Checks {
+		if c.Status != Pass {
+			ret.Status = Fail
+		}
+	}
+	return
+}
+
+// Checks that miss their deadline are abandoned, rather than canceled.  The
+// next run waits on the abandoned one, rather than starting another.
+func (r *Registry) run(c *namedCheck) (ret Result) {
+	ret = Result{Name: c.name, Status: Pass}
+
+	start := time.Now()
+	f := c.start()
+
+	var err error
+	select {
+	case <-f.done:
+		err = f.err
+	case <-time.After(r.opts.Timeout):
+		err = errors.Wrapf(ErrTimeout, "Check exceeded its deadline [%v]", r.opts.Timeout)
+	}
+
+	ret.Duration = time.Since(start).String()
+	if err != nil {
+		ret.Status, ret.Reason, ret.Err = Fail, reason(err), err
+	}
+	return
+}
+
+func reason(err error) string {
+	switch {
+	case errors.Is(err, ErrDraining):
+		return ReasonDraining
+	case errors.Is(err, ErrTimeout):
+		return ReasonTimeout
+	default:
+		return ReasonFailed
+	}
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(WithTimeout(50 * time.Millisecond))
+
+	var failing error
+	reg.Register("storage", func() error {
+		return failing
+	})
+
+	if !t.Run("Check_Pass", func(t *testing.T) {
+		report := reg.Check()
+		assert.True(t, report.Passed())
+		if !assert.Equal(t, 2, len(report.Checks)) {
+			return
+		}
+		assert.Equal(t, "drain", report.Checks[0].Name)
+		assert.Equal(t, Result{Name: "storage", Status: Pass, Duration: report.Checks[1].Duration}, report.Checks[1])
+	}) {
+		return
+	}
+
+	if !t.Run("Check_Fail", func(t *testing.T) {
+		failing = errors.New("unreachable")
+		defer func() {
+			failing = nil
+		}()
+
+		report := reg.Check()
+		assert.Equal(t, Fail, report.Status)
+		assert.Equal(t, Pass, report.Checks[0].Status)
+		assert.Equal(t, Fail, report.Checks[1].Status)
+		assert.Equal(t, ReasonFailed, report.Checks[1].Reason)
+		assert.Equal(t, failing, report.Checks[1].Err)
+	}) {
+		return
+	}
+
+	if !t.Run("Check_Timeout", func(t *testing.T) {
+		var runs int32
+		reg := NewRegistry(WithTimeout(50 * time.Millisecond))
+		reg.Register("slow", func() error {
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(time.Second)
+			return nil
+		})
+
+		start := time.Now()
+		report := reg.Check()
+		assert.True(t, time.Since(start) < time.Second)
+		assert.Equal(t, Fail, report.Status)
+		assert.Equal(t, ReasonTimeout, report.Checks[1].Reason)
+		assert.True(t, errors.Is(report.Checks[1].Err, ErrTimeout))
+
+		// The abandoned run is still in flight, so it isn't run again.
+		report = reg.Check()
+		assert.Equal(t, Fail, report.Status)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	}) {
+		return
+	}
+
+	if !t.Run("Drain", func(t *testing.T) {
+		reg.Drain()
+		assert.True(t, reg.Draining())
+
+		report := reg.Check()
+		assert.Equal(t, Fail, report.Status)
+		assert.Equal(t, ReasonDraining, report.Checks[0].Reason)
+		assert.Equal(t, Pass, report.Checks[1].Status)
+	}) {
+		return
+	}
+}
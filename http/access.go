@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+)
+
+// An access log entry describes a single request.
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Latency   float64   `json:"latency_ms"`
+	Subject   string    `json:"subject,omitempty"`
+	ClientIp  string    `json:"client_ip"`
+	RequestId string    `json:"request_id"`
+	TraceId   string    `json:"trace_id,omitempty"`
+}
+
+// Returns a middleware that writes an entry per request to the log, as a
+// line of json.  The latency of a streamed response only covers the time
+// until the stream begins.
+//
+// Requests are logged whether or not they were authenticated, so this should
+// be installed after the auth middleware (in order to run before it), but
+// before the request id middleware.
+func NewAccessLogMiddleware(log io.Writer) http.Middleware {
+	var lock sync.Mutex
+	encoder := json.NewEncoder(log)
+
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) http.Response {
+			logged := &loggedRequest{req, ""}
+
+			start := time.Now()
+			resp := h(env, logged)
+			if resp == nil {
+				resp = http.StatusOK
+			}
+
+			return func(b http.ResponseBuilder) (err error) {
+				rec := &codeRecorder{b, 200}
+				if err = resp(rec); err != nil {
+					rec.code = 500
+				}
+
+				actor := actorOf(req)
+				entry := AccessEntry{
+					Time:      start.UTC(),
+					Method:    req.Method(),
+					Route:     routeOf(req),
+					Path:      req.URL().Path,
+					Status:    rec.code,
+					Latency:   float64(time.Since(start).Microseconds()) / 1000,
+					Subject:   logged.subject,
+					ClientIp:  actor.ClientIp,
+					RequestId: actor.RequestId,
+				}
+
+				if span, ok := spanOf(logged); ok {
+					entry.TraceId = span.Context().TraceId.String()
+				}
+
+				lock.Lock()
+				defer lock.Unlock()
+				if e := encoder.Encode(entry); e != nil {
+					env.Logger().Error("Unable to write access log: %v", e)
+				}
+				return
+			}
+		}
+	}
+}
+
+// A logged request records the subject of its principal, once it has been
+// authenticated by a later middleware.
+type loggedRequest struct {
+	http.Request
+	subject string
+}
+
+func (r *loggedRequest) Unwrap() http.Request {
+	return r.Request
+}
+
+// Records the subject on the request's access log entry, if it's logged.
+func logSubject(req http.Request, subject string) {
+	for req != nil {
+		switch r := req.(type) {
+		case *loggedRequest:
+			r.subject = subject
+			return
+		case interface{ Unwrap() http.Request }:
+			req = r.Unwrap()
+		default:
+			return
+		}
+	}
+}
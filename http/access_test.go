@@ -0,0 +1,192 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// A buffer that may be written by concurrent requests.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+// Returns the entries written since the last call.
+func (b *syncBuffer) Entries() (ret []AccessEntry, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	scanner := bufio.NewScanner(&b.buf)
+	for scanner.Scan() {
+		var entry AccessEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return
+		}
+		ret = append(ret, entry)
+	}
+	err = scanner.Err()
+	return
+}
+
+func TestAccessLog(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	log := &syncBuffer{}
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))),
+		http.WithMiddleware(NewAccessLogMiddleware(log)),
+		http.WithMiddleware(NewRequestIdMiddleware()))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	token, secret, err := core.NewToken("writer", core.ScopeWrite, 0)
+	if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+		return
+	}
+
+	get := func(raw client.Client, path string) (code int, id string, body []byte, err error) {
+		err = raw.Call(
+			client.BuildRequest(
+				client.Get(path),
+				client.WithHeader(headers.Accept, enc.Json.Mime())),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				resp.ReadHeader(RequestIdHeader, &id)
+				return resp.ReadBody(&body)
+			})
+		return
+	}
+
+	if !t.Run("RequestId_Generated", func(t *testing.T) {
+		_, id, _, err := get(NewBearerClient(server.Connect(), secret), "/v1/services")
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, err = uuid.FromString(id)
+		assert.Nil(t, err)
+
+		entries, err := log.Entries()
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, id, entries[0].RequestId)
+		assert.Equal(t, token.Principal().Subject, entries[0].Subject)
+		assert.Equal(t, "127.0.0.1", entries[0].ClientIp)
+		assert.Equal(t, "GET", entries[0].Method)
+		assert.Equal(t, "/v1/services", entries[0].Route)
+		assert.Equal(t, 200, entries[0].Status)
+	}) {
+		return
+	}
+
+	if !t.Run("RequestId_Propagated", func(t *testing.T) {
+		raw := &requestIdClient{NewBearerClient(server.Connect(), secret), "load-1"}
+
+		id := uuid.NewV4()
+		code, echoed, body, err := get(raw, "/v1/services/"+id.String())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 404, code)
+		assert.Equal(t, "load-1", echoed)
+
+		var envelope Error
+		if !assert.Nil(t, json.Unmarshal(body, &envelope)) {
+			return
+		}
+		assert.Equal(t, CodeNoService, envelope.Code)
+		assert.Equal(t, "load-1", envelope.RequestId)
+
+		entries, err := log.Entries()
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, "load-1", entries[0].RequestId)
+		assert.Equal(t, "/v1/services/{id}", entries[0].Route)
+		assert.Equal(t, "/v1/services/"+id.String(), entries[0].Path)
+		assert.Equal(t, 404, entries[0].Status)
+	}) {
+		return
+	}
+
+	if !t.Run("RequestId_Invalid", func(t *testing.T) {
+		raw := &requestIdClient{server.Connect(), strings.Repeat("x", 129)}
+
+		code, id, _, err := get(raw, "/v1/services")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 401, code)
+		_, err = uuid.FromString(id)
+		assert.Nil(t, err)
+
+		entries, err := log.Entries()
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, id, entries[0].RequestId)
+		assert.Equal(t, "", entries[0].Subject)
+		assert.Equal(t, 401, entries[0].Status)
+	}) {
+		return
+	}
+
+	if !t.Run("Client_Error", func(t *testing.T) {
+		_, err := NewClient(NewBearerClient(server.Connect(), secret), enc.Json).GetService(uuid.NewV4())
+		assert.True(t, errs.Is(err, core.ErrNoService))
+
+		entries, e := log.Entries()
+		if !assert.Nil(t, e) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Contains(t, err.Error(), "[request_id="+entries[0].RequestId+"]")
+	}) {
+		return
+	}
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+const (
+	BackupStorageKey = "storage.backup"
+
+	// The mime type of a sqlite database file.
+	Sqlite = "application/vnd.sqlite3"
+)
+
+// Uses the dependency injector to retrieve the backup implementation
+func getBackupStorage(env http.Environment) (ret core.BackupStorage) {
+	env.Assign(BackupStorageKey, &ret)
+	return
+}
+
+// Register the administrative handlers
+func AdminHandlers(svc *http.Service) {
+
+	// The backup is taken before the response begins, so that failures are
+	// reported with a proper status.  Thereafter, the backup is streamed from
+	// a temporary file which is removed once the response completes.
+	register(svc, http.Get("/v1/admin/backup"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getBackupStorage(env)
+
+			dir, err := ioutil.TempDir("", "catalog-backup")
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			path := filepath.Join(dir, "catalog.db")
+
+			start := time.Now()
+			if err := storage.Backup(path); err != nil {
+				os.RemoveAll(dir)
+				ret = replyError(err)
+				return
+			}
+
+			file, err := newTempFile(dir, path)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			logger.Info("Took backup [size=%v,duration=%v]", file.size, time.Since(start))
+			ret = http.Reply(
+				http.WithCode(200),
+				http.WithHeader("Content-Length", fmt.Sprintf("%v", file.size)),
+				http.WithHeader("Content-Disposition",
+					fmt.Sprintf("attachment; filename=catalog-%v.db", start.UTC().Format("20060102T150405Z"))),
+				http.WithContent(Sqlite, file))
+			return
+		})
+}
+
+// A temp file is removed (along with its directory) once it has been
+// written.  The server copies bodies with io.Copy, which defers to
+// WriteTo, so the file is removed even if the client disconnects.
+type tempFile struct {
+	dir  string
+	file *os.File
+	size int64
+}
+
+func newTempFile(dir, path string) (ret *tempFile, err error) {
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	ret = &tempFile{dir, file, info.Size()}
+	return
+}
+
+func (t *tempFile) Read(p []byte) (int, error) {
+	return t.file.Read(p)
+}
+
+func (t *tempFile) WriteTo(w io.Writer) (n int64, err error) {
+	defer os.RemoveAll(t.dir)
+	defer t.file.Close()
+	return io.Copy(w, t.file)
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminServer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	dir, err := ioutil.TempDir("", "catalog-admin-test")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	backups, err := sqlsvc.NewSqlBackupStore(db)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(AdminHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(BackupStorageKey, backups),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.Nil(t, store.SaveService(core.NewService("name", "desc"))) {
+		return
+	}
+
+	transport := NewBackupClient(server.Connect(), enc.Json)
+
+	if !t.Run("Backup", func(t *testing.T) {
+		path := filepath.Join(dir, "backup.db")
+
+		file, err := os.Create(path)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		size, err := transport.Backup(file)
+		if !assert.Nil(t, err) || !assert.Nil(t, file.Close()) {
+			return
+		}
+
+		info, err := os.Stat(path)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, info.Size(), size)
+		assert.Nil(t, sqlsvc.VerifyBackup(path, "TEST"))
+	}) {
+		return
+	}
+}
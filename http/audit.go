@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+const (
+	AuditStorageKey = "storage.audit"
+)
+
+// Uses the dependency injector to retrieve the audit storage implementation
+func getAuditStorage(env http.Environment) (ret core.AuditStorage) {
+	env.Assign(AuditStorageKey, &ret)
+	return
+}
+
+// Returns the actor to whom the request's writes are attributed.  The
+// client ip is the remote address of the connection, since forwarded
+// headers may be forged.
+func actorOf(req http.Request) (ret core.Actor) {
+	if principal, ok := Authenticated(req); ok {
+		ret.Subject = principal.Subject
+	}
+
+	ret.ClientIp = req.Remote()
+	if host, _, err := net.SplitHostPort(ret.ClientIp); err == nil {
+		ret.ClientIp = host
+	}
+
+	req.ReadHeader(RequestIdHeader, &ret.RequestId)
+	return
+}
+
+// Decodes an RFC3339 timestamp.
+func timestamp(val string, raw interface{}) (err error) {
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return
+	}
+
+	switch ptr := raw.(type) {
+	default:
+		err = errors.Errorf("Cannot assign value [%v] to [%v]", val, reflect.ValueOf(raw))
+	case *time.Time:
+		*ptr = t
+	case **time.Time:
+		*ptr = &t
+	}
+	return
+}
+
+// Register the audit log handlers
+func AuditHandlers(svc *http.Service) {
+
+	register(svc, http.Get("/v1/audit"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getAuditStorage(env)
+
+			var filter core.AuditFilter
+			if err := http.ParseQueryParams(req,
+				http.Param("service", http.UUID, &filter.ServiceId),
+				http.Param("actor", http.String, &filter.Actor),
+				http.Param("since", timestamp, &filter.Since),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			page := core.NewPage()
+			if err := http.ParseQueryParams(req,
+				http.Param("offset", http.Uint64, &page.Offset),
+				http.Param("limit", http.Uint64, &page.Limit),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if ret = assertTrue(page.Limit <= 1024, "Invalid limit. Must be <= 1024"); ret != nil {
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			entries, err := storage.ListAudit(filter, page)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, entries)
+			return
+		})
+}
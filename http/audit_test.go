@@ -0,0 +1,145 @@
+package http
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sends a fixed request id with every request.
+type requestIdClient struct {
+	client.Client
+	id string
+}
+
+func (c *requestIdClient) Call(req client.Request, fn func(client.Response) error) error {
+	return c.Client.Call(client.BuildRequest(req, client.WithHeader(RequestIdHeader, c.id)), fn)
+}
+
+func TestAuditServer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	audit, err := sqlsvc.NewSqlAuditStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers, AuditHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithDependency(AuditStorageKey, audit),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	subjects := make(map[string]string)
+	newToken := func(name string, scope core.Scope) string {
+		token, secret, err := core.NewToken(name, scope, 0)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		subjects[name] = token.Principal().Subject
+		return secret
+	}
+
+	writer, admin := newToken("writer", core.ScopeWrite), newToken("admin", core.ScopeAdmin)
+
+	start := time.Now().Add(-time.Second)
+
+	var svc core.Service
+	if !t.Run("SaveService", func(t *testing.T) {
+		raw := &requestIdClient{NewBearerClient(server.Connect(), writer), "req-1"}
+		svc, err = NewClient(raw, enc.Json).SaveService(core.NewService("name", "desc"))
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("ListAudit_Forbidden", func(t *testing.T) {
+		_, err := NewAuditClient(NewBearerClient(server.Connect(), writer), enc.Json).
+			ListAudit(core.NewAuditFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+	}) {
+		return
+	}
+
+	transport := NewAuditClient(NewBearerClient(server.Connect(), admin), enc.Json)
+	if !t.Run("ListAudit", func(t *testing.T) {
+		entries, err := transport.ListAudit(core.NewAuditFilter(), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+
+		assert.Equal(t, core.Actor{Subject: subjects["writer"], ClientIp: "127.0.0.1", RequestId: "req-1"}, entries[0].Actor)
+		assert.Equal(t, core.ServiceSaved, entries[0].Operation)
+		assert.Equal(t, svc.Id, entries[0].ServiceId)
+		assert.Nil(t, core.VerifyAudit("", entries))
+	}) {
+		return
+	}
+
+	if !t.Run("ListAudit_Filter", func(t *testing.T) {
+		entries, err := transport.ListAudit(
+			core.NewAuditFilter(
+				core.AuditByServiceId(svc.Id),
+				core.AuditByActor(subjects["writer"]),
+				core.AuditSince(start)),
+			core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(entries))
+
+		entries, err = transport.ListAudit(core.NewAuditFilter(core.AuditByActor(subjects["admin"])), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, entries)
+
+		entries, err = transport.ListAudit(core.NewAuditFilter(core.AuditSince(time.Now().Add(time.Hour))), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, entries)
+	}) {
+		return
+	}
+
+	if !t.Run("ListAudit_InvalidLimit", func(t *testing.T) {
+		_, err := transport.ListAudit(core.NewAuditFilter(), core.NewPage(core.Limit(2048)))
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+}
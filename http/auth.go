@@ -0,0 +1,182 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+const (
+	WWWAuthenticateHeader = "WWW-Authenticate"
+)
+
+// The scope required by a route.  Rules are matched in order, by method
+// (if any) and path prefix.  Requests that match no rule require the read
+// scope to read and the write scope to write.
+type scopeRule struct {
+	Method string
+	Prefix string
+	Scope  core.Scope
+}
+
+var scopeRules = []scopeRule{
+	{"", "/v1/admin/", core.ScopeAdmin},
+	{"", "/v1/webhooks", core.ScopeAdmin},
+	{"", "/v1/deliveries", core.ScopeAdmin},
+	{"", "/v1/audit", core.ScopeAdmin},
+	{"POST", "/v1/import", core.ScopeAdmin},
+
+	// Queries are reads, so mutations are authorized by their resolvers.
+	{"POST", "/v1/graphql", core.ScopeRead},
+}
+
+// Routes that may be called without a token.
+var publicRoutes = []http.Route{
+	http.Get("/v1/openapi.json"),
+	http.Get("/healthz"),
+	http.Get("/readyz"),
+}
+
+func isPublic(route http.Route) bool {
+	for _, r := range publicRoutes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the scope required by the request, or false if the request
+// requires no token.
+func requiredScope(req http.Request) (core.Scope, bool) {
+	method, path := req.Method(), req.URL().Path
+	if isPublic(http.Route{Method: method, Path: path}) {
+		return "", false
+	}
+
+	for _, r := range scopeRules {
+		if (r.Method == "" || r.Method == method) && strings.HasPrefix(path, r.Prefix) {
+			return r.Scope, true
+		}
+	}
+
+	switch method {
+	case "GET", "HEAD":
+		return core.ScopeRead, true
+	default:
+		return core.ScopeWrite, true
+	}
+}
+
+// Returns a middleware that authenticates requests by their bearer tokens.
+// Requests without a valid token are rejected with a 401, and requests
+// whose principal lacks the required scope are rejected with a 403.
+//
+// Authentication must happen before any other middleware acts on the
+// request, so this should be the last middleware installed (the last
+// middleware is the first to run), save for those that only observe it.
+func NewAuthMiddleware(auth core.Authenticator) http.Middleware {
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) (ret http.Response) {
+			scope, required := requiredScope(req)
+			if !required {
+				return h(env, req)
+			}
+
+			principal, err := authenticate(auth, req)
+			if err != nil {
+				return unauthorized(err)
+			}
+
+			logSubject(req, principal.Subject)
+			if err := principal.Authorize(scope); err != nil {
+				return replyError(err)
+			}
+
+			env.Logger().Debug("Authenticated principal [subject=%v,scope=%v]", principal.Subject, principal.Scope)
+			return h(env, &authenticatedRequest{req, principal})
+		}
+	}
+}
+
+// Returns the principal of the request's bearer token.
+func authenticate(auth core.Authenticator, req http.Request) (ret core.Principal, err error) {
+	var header string
+	if !req.ReadHeader(headers.Authorization, &header) {
+		err = errors.Wrapf(core.ErrUnauthorized, "Missing bearer token")
+		return
+	}
+
+	secret := strings.TrimPrefix(header, "Bearer ")
+	if secret == header || secret == "" {
+		err = errors.Wrapf(core.ErrUnauthorized, "Invalid authorization. Must be a bearer token")
+		return
+	}
+
+	return auth.Authenticate(secret)
+}
+
+// Unauthorized requests tell the client how to authenticate.
+func unauthorized(err error) http.Response {
+	if !errs.Is(err, core.ErrUnauthorized) {
+		return replyError(err)
+	}
+	return http.Reply(
+		replyError(err),
+		http.WithHeader(WWWAuthenticateHeader, `Bearer realm="catalog"`))
+}
+
+// An authenticated request carries its principal.
+type authenticatedRequest struct {
+	http.Request
+	principal core.Principal
+}
+
+func (r *authenticatedRequest) Unwrap() http.Request {
+	return r.Request
+}
+
+// Returns the principal that authenticated the request.  Returns false if
+// the request was not authenticated (e.g. authentication is disabled).
+func Authenticated(req http.Request) (core.Principal, bool) {
+	for req != nil {
+		switch r := req.(type) {
+		case *authenticatedRequest:
+			return r.principal, true
+		case interface{ Unwrap() http.Request }:
+			req = r.Unwrap()
+		default:
+			return core.Principal{}, false
+		}
+	}
+	return core.Principal{}, false
+}
+
+// Returns an error unless the request may act with the given scope.  Used
+// by handlers whose required scope depends on the request's content.
+func authorize(req http.Request, scope core.Scope) error {
+	principal, ok := Authenticated(req)
+	if !ok {
+		return nil
+	}
+	return principal.Authorize(scope)
+}
+
+// Returns a client that sends the token with every request.
+func NewBearerClient(raw client.Client, token string) client.Client {
+	return &bearerClient{raw, token}
+}
+
+type bearerClient struct {
+	raw   client.Client
+	token string
+}
+
+func (c *bearerClient) Call(req client.Request, fn func(client.Response) error) error {
+	return c.raw.Call(client.BuildRequest(req, client.WithBearer(c.token)), fn)
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	hooks, err := sqlsvc.NewSqlWebhookStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	keys, err := sqlsvc.NewSqlIdempotencyStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Bearers other than API tokens are given to the fallback, which stands
+	// in for a JWT verifier.
+	jwts := core.AuthenticatorFunc(func(secret string) (ret core.Principal, err error) {
+		if secret != "jwt" {
+			err = errors.Wrapf(core.ErrUnauthorized, "Invalid jwt")
+			return
+		}
+		ret = core.Principal{Subject: "alice", Scope: core.ScopeRead, Teams: []string{"engineering"}}
+		return
+	})
+
+	// Handlers may retrieve the principal of the request.
+	whoami := func(svc *http.Service) {
+		svc.Register(http.Get("/v1/whoami"),
+			func(env http.Environment, req http.Request) http.Response {
+				principal, _ := Authenticated(req)
+				return http.Ok(enc.Json, principal)
+			})
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers, WebhookHandlers, GraphQLHandlers, OpenAPIHandlers, whoami),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithDependency(WebhookStorageKey, hooks),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewIdempotencyMiddleware(keys, time.Hour)),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, jwts))))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	subjects := make(map[core.Scope]string)
+	newToken := func(scope core.Scope, ttl time.Duration) (ret string) {
+		token, secret, err := core.NewToken(string(scope), scope, ttl)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		subjects[scope] = token.Principal().Subject
+		return secret
+	}
+
+	read, write, admin := newToken(core.ScopeRead, 0), newToken(core.ScopeWrite, time.Hour), newToken(core.ScopeAdmin, 0)
+
+	connect := func(token string) client.Client {
+		return NewBearerClient(server.Connect(), token)
+	}
+
+	if !t.Run("Public", func(t *testing.T) {
+		err := server.Connect().Call(
+			client.BuildRequest(client.Get("/v1/openapi.json")),
+			client.ExpectCode(200))
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("Unauthorized", func(t *testing.T) {
+		var challenge string
+		err := server.Connect().Call(
+			client.BuildRequest(client.Get("/v1/services")),
+			func(resp client.Response) error {
+				resp.ReadHeader(WWWAuthenticateHeader, &challenge)
+				return expectCode(200)(resp)
+			})
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+		assert.Equal(t, `Bearer realm="catalog"`, challenge)
+	}) {
+		return
+	}
+
+	if !t.Run("Unauthorized_InvalidToken", func(t *testing.T) {
+		_, err := NewClient(connect("cat_invalid"), enc.Json).ListServices(core.NewFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	if !t.Run("Unauthorized_Expired", func(t *testing.T) {
+		token, secret, err := core.NewToken("expired", core.ScopeAdmin, time.Hour)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		token.Expires = time.Now().Add(-time.Minute)
+		if !assert.Nil(t, tokens.SaveToken(token)) {
+			return
+		}
+
+		_, err = NewClient(connect(secret), enc.Json).ListServices(core.NewFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	if !t.Run("Read", func(t *testing.T) {
+		transport := NewClient(connect(read), enc.Json)
+
+		_, err := transport.ListServices(core.NewFilter(), core.NewPage())
+		assert.Nil(t, err)
+
+		_, err = transport.SaveService(core.Service{Name: "name", Desc: "desc"})
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+	}) {
+		return
+	}
+
+	if !t.Run("Write", func(t *testing.T) {
+		_, err := NewClient(connect(write), enc.Json).SaveService(core.Service{Name: "name", Desc: "desc"})
+		assert.Nil(t, err)
+
+		_, err = NewWebhookClient(connect(write), enc.Json).ListWebhooks()
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+	}) {
+		return
+	}
+
+	if !t.Run("Admin", func(t *testing.T) {
+		_, err := NewWebhookClient(connect(admin), enc.Json).ListWebhooks()
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("Principal", func(t *testing.T) {
+		var principal core.Principal
+		err := connect("jwt").Call(
+			client.BuildRequest(client.Get("/v1/whoami")),
+			client.ExpectStruct(enc.DefaultRegistry, &principal))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "alice", principal.Subject)
+		assert.Equal(t, []string{"engineering"}, principal.Teams)
+
+		err = connect(read).Call(
+			client.BuildRequest(client.Get("/v1/whoami")),
+			client.ExpectStruct(enc.DefaultRegistry, &principal))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, subjects[core.ScopeRead], principal.Subject)
+		assert.Equal(t, string(core.ScopeRead), principal.Name)
+	}) {
+		return
+	}
+
+	if !t.Run("Principal_Unauthorized", func(t *testing.T) {
+		_, err := NewClient(connect("invalid"), enc.Json).ListServices(core.NewFilter(), core.NewPage())
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	// Mutations are authorized by their resolvers, which must find the
+	// token beneath the request buffered by the idempotency middleware.
+	if !t.Run("GraphQL", func(t *testing.T) {
+		mutate := func(token string) (ret graphQLResult, err error) {
+			err = connect(token).Call(
+				client.BuildRequest(
+					client.Post("/v1/graphql"),
+					client.WithHeader(IdempotencyKeyHeader, uuid.NewV1().String()),
+					client.WithStruct(enc.Json, GraphQLRequest{
+						Query: `mutation { saveService(service: {name: "name", desc: "desc"}) { id } }`,
+					})),
+				func(resp client.Response) error {
+					if err := expectCode(200)(resp); err != nil {
+						return err
+					}
+					return client.RequireStruct(resp, enc.DefaultRegistry, &ret)
+				})
+			return
+		}
+
+		ret, err := mutate(read)
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Equal(t, CodeForbidden, ret.Errors[0].Extensions["code"])
+
+		ret, err = mutate(write)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, ret.Errors)
+	}) {
+		return
+	}
+}
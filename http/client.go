@@ -1,47 +1,259 @@
 package http
 
 import (
+	"io"
+	gohttp "net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
 	http "github.com/pkopriv2/golang-sdk/http/client"
 	"github.com/pkopriv2/golang-sdk/http/headers"
 	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
 	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
 )
 
+type ClientOption func(*ClientOptions)
+
+type ClientOptions struct {
+	Retries int
+	Backoff time.Duration
+}
+
+func buildClientOptions(fns ...ClientOption) (ret ClientOptions) {
+	ret = ClientOptions{
+		Retries: 3,
+		Backoff: 100 * time.Millisecond,
+	}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the number of times a request is retried after being rate limited,
+// or a write is retried after failing to receive a response.
+func WithRetries(num int) ClientOption {
+	return func(o *ClientOptions) {
+		o.Retries = num
+	}
+}
+
+// Sets the delay before the first retry.  The delay doubles on each
+// subsequent retry.  Rate limited requests wait as long as the server asks
+// instead.
+func WithBackoff(dur time.Duration) ClientOption {
+	return func(o *ClientOptions) {
+		o.Backoff = dur
+	}
+}
+
 type Client struct {
-	Raw http.Client
-	Enc enc.Encoder
+	Raw     http.Client
+	Enc     enc.Encoder
+	Options ClientOptions
+}
+
+func NewClient(raw http.Client, enc enc.Encoder, fns ...ClientOption) core.Transport {
+	return &Client{raw, enc, buildClientOptions(fns...)}
+}
+
+func NewWebhookClient(raw http.Client, enc enc.Encoder, fns ...ClientOption) core.WebhookTransport {
+	return &Client{raw, enc, buildClientOptions(fns...)}
+}
+
+func NewTransferClient(raw http.Client, enc enc.Encoder, fns ...ClientOption) core.TransferTransport {
+	return &Client{raw, enc, buildClientOptions(fns...)}
+}
+
+func NewAuditClient(raw http.Client, enc enc.Encoder, fns ...ClientOption) core.AuditTransport {
+	return &Client{raw, enc, buildClientOptions(fns...)}
 }
 
-func NewClient(raw http.Client, enc enc.Encoder) core.Transport {
-	return &Client{raw, enc}
+func NewBackupClient(raw http.Client, enc enc.Encoder) core.BackupTransport {
+	return &Client{raw, enc, buildClientOptions()}
 }
 
+// Requests are retried if they were rate limited, after the delay given by
+// their Retry-After header or else the backoff.
+func (c *Client) call(req http.Request, fn func(http.Response) error) error {
+	return c.retry(req, fn, false)
+}
+
+// Writes are also retried if no response was received, since the write may
+// or may not have been applied.  Every attempt carries the same idempotency
+// key, so that a write which was applied is answered with its original
+// response rather than being applied again.
+func (c *Client) write(req http.Request, fn func(http.Response) error) (err error) {
+	req = http.BuildRequest(req,
+		http.WithHeader(IdempotencyKeyHeader, uuid.NewV4().String()))
+	return c.retry(req, fn, true)
+}
+
+// Every attempt carries the same request id, so that the retries of a call
+// may be correlated in the server's logs.
+func (c *Client) retry(req http.Request, fn func(http.Response) error, idempotent bool) (err error) {
+	req = http.BuildRequest(req,
+		http.WithHeader(RequestIdHeader, uuid.NewV4().String()))
+
+	backoff := c.Options.Backoff
+	for i := 0; ; i++ {
+		var limited bool
+		var after time.Duration
+		err = c.Raw.Call(req, func(resp http.Response) error {
+			if limited = resp.ReadCode() == 429; limited {
+				after = retryAfter(resp)
+			}
+			return fn(resp)
+		})
+		if i >= c.Options.Retries || !(limited || (idempotent && unanswered(err))) {
+			return
+		}
+
+		wait := backoff
+		if after > 0 {
+			wait = after
+		}
+
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// Returns the delay given by the Retry-After header, in either seconds or
+// as a date, or zero if there is none.
+func retryAfter(resp http.Response) time.Duration {
+	var val string
+	if !resp.ReadHeader(RetryAfterHeader, &val) {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := gohttp.ParseTime(val); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// Returns true if the error occurred before a response was received.
+func unanswered(err error) bool {
+	var e *url.Error
+	return errors.As(err, &e)
+}
+
+// Updates are sent with the etag of the revision they replace, so that a
+// stale update fails its precondition.
 func (c *Client) SaveService(svc core.Service) (ret core.Service, err error) {
-	err = c.Raw.Call(
+	var match *string
+	if svc.Version > 0 {
+		etag := ETag(svc.Update(func(s *core.Service) {
+			s.Version = s.Version - 1
+		}))
+		match = &etag
+	}
+
+	err = c.write(
 		http.BuildRequest(
 			http.Put("/v1/services"),
 			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithHeader(headers.IfMatch, match),
 			http.WithStruct(c.Enc, svc)),
 		http.ExpectAll(
-			http.ExpectCode(200),
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+// The maximum number of attempts made by UpdateService.
+const maxUpdateAttempts = 10
+
+func (c *Client) UpdateService(id uuid.UUID, fn func(*core.Service)) (ret core.Service, err error) {
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var latest core.Service
+		if latest, err = c.GetService(id); err != nil {
+			return
+		}
+
+		ret, err = c.SaveService(latest.Update(fn, func(s *core.Service) {
+			s.Id, s.Version = latest.Id, latest.Version+1
+		}))
+		if !errs.Is(err, core.ErrConflict) {
+			return
+		}
+	}
+
+	err = errors.Wrapf(err, "Unable to update service [%v] after [%v] attempts", id, maxUpdateAttempts)
+	return
+}
+
+func (c *Client) GetService(id uuid.UUID) (ret core.Service, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/services/%v", id),
+			http.WithHeader(headers.Accept, c.Enc.Mime())),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) GetVersion(serviceId uuid.UUID, name string) (ret core.Version, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/services/%v/versions/%v", serviceId, name),
+			http.WithHeader(headers.Accept, c.Enc.Mime())),
+		http.ExpectAll(
+			expectCode(200),
 			http.ExpectStruct(enc.DefaultRegistry, &ret)))
 	return
 }
 
 func (c *Client) SaveVersion(ver core.Version) (ret core.Version, err error) {
-	err = c.Raw.Call(
+	err = c.write(
 		http.BuildRequest(
 			http.Put("/v1/versions"),
 			http.WithHeader(headers.Accept, c.Enc.Mime()),
 			http.WithStruct(c.Enc, ver)),
 		http.ExpectAll(
-			http.ExpectCode(200),
+			expectCode(200),
 			http.ExpectStruct(enc.DefaultRegistry, &ret)))
 	return
 }
 
+// An aborted batch replies with the results of every write as the details
+// of the error, from which the cause of the failure is recovered.
+func (c *Client) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	err = c.write(
+		http.BuildRequest(
+			http.Post("/v1/batch"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithStruct(c.Enc, writes)),
+		func(resp http.Response) (err error) {
+			if err = http.ExpectAll(
+				expectCode(200),
+				http.ExpectStruct(enc.DefaultRegistry, &ret))(resp); err == nil {
+				return
+			}
+
+			var e *Error
+			if !errors.As(err, &e) || e.Code != CodeAborted || len(e.Details) == 0 {
+				return
+			}
+
+			if err = enc.Json.DecodeBinary(e.Details, &ret); err != nil {
+				return
+			}
+			return core.BatchError(ret)
+		})
+	return
+}
+
 func (c *Client) ListServices(filter core.Filter, page core.Page) (ret core.Catalog, err error) {
-	err = c.Raw.Call(
+	err = c.call(
 		http.BuildRequest(
 			http.Get("/v1/services"),
 			http.WithHeader(headers.Accept, c.Enc.Mime()),
@@ -52,7 +264,218 @@ func (c *Client) ListServices(filter core.Filter, page core.Page) (ret core.Cata
 			http.WithQueryParam("limit", page.Limit),
 			http.WithQueryParam("order", page.OrderBy)),
 		http.ExpectAll(
-			http.ExpectCode(200),
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) ListChanges(since uint64, limit uint64) (ret []core.Change, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/changes"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithQueryParam("since", since),
+			http.WithQueryParam("limit", limit)),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) Export(history bool) (ret core.Snapshot, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/export"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithQueryParam("history", history)),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) Import(snapshot core.Snapshot, mode core.ImportMode) (ret core.ImportResult, err error) {
+	err = c.write(
+		http.BuildRequest(
+			http.Post("/v1/import"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithQueryParam("mode", string(mode)),
+			http.WithStruct(c.Enc, snapshot)),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) Watch(filter core.Filter, since uint64) (ret core.Watcher, err error) {
+	var resume *uint64
+	if since > 0 {
+		resume = &since
+	}
+
+	started, failed := make(chan core.Watcher, 1), make(chan error, 1)
+	go func() {
+		failed <- c.call(
+			http.BuildRequest(
+				http.Get("/v1/watch"),
+				http.WithHeader(headers.Accept, EventStream),
+				http.WithHeader(LastEventId, resume),
+				http.WithQueryParam("name", filter.NameContains),
+				http.WithQueryParam("desc", filter.DescContains),
+				http.WithQueryParam("id", filter.ServiceId)),
+			func(resp http.Response) (err error) {
+				if err = expectCode(200)(resp); err != nil {
+					return
+				}
+
+				watcher := newEventWatcher(resp)
+				started <- watcher
+				watcher.read(resp)
+				return
+			})
+	}()
+
+	select {
+	case ret = <-started:
+	case err = <-failed:
+	}
+	return
+}
+
+func (c *Client) SaveWebhook(hook core.Webhook) (ret core.Webhook, err error) {
+	err = c.write(
+		http.BuildRequest(
+			http.Put("/v1/webhooks"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithStruct(c.Enc, hook)),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) DeleteWebhook(id uuid.UUID) (err error) {
+	err = c.write(
+		http.Delete("/v1/webhooks/%v", id),
+		expectCode(204))
+	return
+}
+
+func (c *Client) ListWebhooks() (ret []core.Webhook, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/webhooks"),
+			http.WithHeader(headers.Accept, c.Enc.Mime())),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) ListDeliveries(filter core.DeliveryFilter, page core.Page) (ret []core.Delivery, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/deliveries"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithQueryParam("webhook", filter.WebhookId),
+			http.WithQueryParam("status", filter.Status),
+			http.WithQueryParam("offset", page.Offset),
+			http.WithQueryParam("limit", page.Limit)),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) ListAudit(filter core.AuditFilter, page core.Page) (ret []core.AuditEntry, err error) {
+	var since *string
+	if filter.Since != nil {
+		tmp := filter.Since.UTC().Format(time.RFC3339)
+		since = &tmp
+	}
+
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/audit"),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithQueryParam("service", filter.ServiceId),
+			http.WithQueryParam("actor", filter.Actor),
+			http.WithQueryParam("since", since),
+			http.WithQueryParam("offset", page.Offset),
+			http.WithQueryParam("limit", page.Limit)),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) ListAttempts(id uuid.UUID) (ret []core.Attempt, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/deliveries/%v/attempts", id),
+			http.WithHeader(headers.Accept, c.Enc.Mime())),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) Backup(w io.Writer) (n int64, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/admin/backup"),
+			http.WithHeader(headers.Accept, Sqlite)),
+		func(resp http.Response) (err error) {
+			if err = expectCode(200)(resp); err != nil {
+				return
+			}
+
+			n, err = io.Copy(w, resp)
+			return
+		})
+	return
+}
+
+// Transfers are created, so their bodies accompany a 201 rather than a 200.
+func (c *Client) RequestTransfer(serviceId uuid.UUID, to string) (ret core.Transfer, err error) {
+	err = c.write(
+		http.BuildRequest(
+			http.Post("/v1/services/%v/transfers", serviceId),
+			http.WithHeader(headers.Accept, c.Enc.Mime()),
+			http.WithStruct(c.Enc, TransferRequest{to})),
+		http.ExpectAll(
+			expectCode(201),
+			func(resp http.Response) error {
+				return http.RequireStruct(resp, enc.DefaultRegistry, &ret)
+			}))
+	return
+}
+
+func (c *Client) ApproveTransfer(id uuid.UUID) (ret core.Transfer, err error) {
+	err = c.write(
+		http.BuildRequest(
+			http.Post("/v1/transfers/%v/approve", id),
+			http.WithHeader(headers.Accept, c.Enc.Mime())),
+		http.ExpectAll(
+			expectCode(200),
+			http.ExpectStruct(enc.DefaultRegistry, &ret)))
+	return
+}
+
+func (c *Client) CancelTransfer(id uuid.UUID) (err error) {
+	err = c.write(
+		http.Delete("/v1/transfers/%v", id),
+		expectCode(204))
+	return
+}
+
+func (c *Client) ListTransfers(serviceId uuid.UUID) (ret []core.Transfer, err error) {
+	err = c.call(
+		http.BuildRequest(
+			http.Get("/v1/services/%v/transfers", serviceId),
+			http.WithHeader(headers.Accept, c.Enc.Mime())),
+		http.ExpectAll(
+			expectCode(200),
 			http.ExpectStruct(enc.DefaultRegistry, &ret)))
 	return
 }
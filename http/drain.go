@@ -0,0 +1,188 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+)
+
+// A drainer tracks the requests in flight, so that the server may wait for
+// them to finish before it stops.  Once draining, every response asks its
+// client to close the connection, so that clients reconnect elsewhere
+// rather than sending more requests on a connection that's about to close.
+//
+// Responses are written after their handlers return (e.g. streamed
+// backups), so a request is only finished once its connection has written
+// the response and is waiting on the next request.  Connections are tracked
+// by the network that accepted them (see Network.Drainer).  A drainer that
+// isn't attached to a network only tracks handlers.
+type Drainer struct {
+	lock     sync.Mutex
+	handling int
+	conns    map[*trackedConnection]struct{}
+	draining bool
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{conns: make(map[*trackedConnection]struct{})}
+}
+
+// Returns a middleware that counts the requests being handled, and asks
+// clients to close their connections while draining.  Requests may read
+// their bodies before they reach their handlers (e.g. for idempotency), so
+// this should be the last middleware installed (in order to run first).
+func (d *Drainer) Middleware() http.Middleware {
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) http.Response {
+			d.lock.Lock()
+			d.handling++
+			d.lock.Unlock()
+
+			resp := h(env, req)
+			if resp == nil {
+				resp = http.StatusOK
+			}
+
+			return func(b http.ResponseBuilder) error {
+				d.lock.Lock()
+				if d.draining {
+					b.SetHeader("Connection", "close")
+				}
+				d.lock.Unlock()
+
+				defer func() {
+					d.lock.Lock()
+					defer d.lock.Unlock()
+					d.handling--
+				}()
+				return resp(b)
+			}
+		}
+	}
+}
+
+// Returns the number of requests in flight.
+func (d *Drainer) Active() int {
+	_, active := d.state()
+	return active
+}
+
+// Returns the idle connections and the number of requests in flight.  A
+// request may be counted by its handler and its connection, so the larger
+// of the two counts is in flight.
+func (d *Drainer) state() (idle []*trackedConnection, active int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	busy := 0
+	for c := range d.conns {
+		if c.Idle() {
+			idle = append(idle, c)
+		} else {
+			busy++
+		}
+	}
+
+	active = d.handling
+	if busy > active {
+		active = busy
+	}
+	return
+}
+
+// Begins draining and waits for the requests in flight to finish, or for
+// the timeout to elapse.  Once they've finished, idle connections are
+// closed.
+func (d *Drainer) Drain(timeout time.Duration) error {
+	d.lock.Lock()
+	d.draining = true
+	d.lock.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		idle, active := d.state()
+		if active == 0 {
+			for _, c := range idle {
+				c.Close()
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("Requests still in flight after [%v]: %v", timeout, active)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (d *Drainer) track(raw net.Connection) *trackedConnection {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ret := &trackedConnection{Connection: raw, drainer: d}
+	d.conns[ret] = struct{}{}
+	return ret
+}
+
+func (d *Drainer) untrack(c *trackedConnection) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.conns, c)
+}
+
+// A tracked connection orders its reads and writes in order to tell when
+// it's idle.  A connection is idle once it's waiting on a request, having
+// written its response to the last one.  A read that begins while the
+// response is still being written (i.e. the server's check for clients
+// that hang up) doesn't count.
+type trackedConnection struct {
+	net.Connection
+	drainer *Drainer
+
+	lock    sync.Mutex
+	seq     uint64
+	data    uint64 // the last read that returned data
+	written uint64 // the last write
+	pending uint64 // the read in progress, if any
+}
+
+func (c *trackedConnection) Idle() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.pending > c.written && c.written >= c.data
+}
+
+func (c *trackedConnection) Read(p []byte) (n int, err error) {
+	c.lock.Lock()
+	c.seq++
+	c.pending = c.seq
+	c.lock.Unlock()
+
+	n, err = c.Connection.Read(p)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pending = 0
+	if n > 0 {
+		c.seq++
+		c.data = c.seq
+	}
+	return
+}
+
+func (c *trackedConnection) Write(p []byte) (n int, err error) {
+	n, err = c.Connection.Write(p)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.seq++
+	c.written = c.seq
+	return
+}
+
+func (c *trackedConnection) Close() error {
+	c.drainer.untrack(c)
+	return c.Connection.Close()
+}
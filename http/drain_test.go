@@ -0,0 +1,217 @@
+package http
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gonet "net"
+	gohttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	release := make(chan struct{})
+	slow := func(svc *http.Service) {
+		svc.Register(http.Get("/slow"),
+			func(env http.Environment, req http.Request) (ret http.Response) {
+				<-release
+				return http.StatusOK
+			})
+	}
+
+	network := NewNetwork(nil)
+	drainer := network.Drainer()
+	server, err := http.Serve(ctx,
+		http.Build(slow, HealthHandlers),
+		http.WithListener(network, ":0"),
+		http.WithMiddleware(drainer.Middleware()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	url := fmt.Sprintf("http://localhost:%v", server.Address().(*gonet.TCPAddr).Port)
+
+	type result struct {
+		resp *gohttp.Response
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		resp, err := gohttp.Get(url + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		results <- result{resp, err}
+	}()
+
+	if !t.Run("Active", func(t *testing.T) {
+		deadline := time.Now().Add(time.Second)
+		for drainer.Active() == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, 1, drainer.Active())
+	}) {
+		return
+	}
+
+	if !t.Run("Drain_Timeout", func(t *testing.T) {
+		assert.NotNil(t, drainer.Drain(20*time.Millisecond))
+		assert.Equal(t, 1, drainer.Active())
+	}) {
+		return
+	}
+
+	if !t.Run("Drain", func(t *testing.T) {
+		time.AfterFunc(50*time.Millisecond, func() {
+			close(release)
+		})
+		if !assert.Nil(t, drainer.Drain(time.Second)) {
+			return
+		}
+		assert.Equal(t, 0, drainer.Active())
+
+		res := <-results
+		if !assert.Nil(t, res.err) {
+			return
+		}
+		assert.Equal(t, 200, res.resp.StatusCode)
+		assert.True(t, res.resp.Close)
+	}) {
+		return
+	}
+
+	if !t.Run("Draining_CloseConnections", func(t *testing.T) {
+		resp, err := gohttp.Get(url + "/healthz")
+		if !assert.Nil(t, err) {
+			return
+		}
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.True(t, resp.Close)
+	}) {
+		return
+	}
+}
+
+func TestDrainer_Backup(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	dir, err := ioutil.TempDir("", "catalog-drain-test")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if _, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST")); !assert.Nil(t, err) {
+		return
+	}
+
+	// The backup must be much larger than the socket buffers, so that it's
+	// still being written once the server begins to drain.
+	raw := db.(interface{ DB() *gosql.DB }).DB()
+	if _, err := raw.Exec("create table padding (data blob)"); !assert.Nil(t, err) {
+		return
+	}
+	if _, err := raw.Exec("insert into padding values (randomblob(64 * 1024 * 1024))"); !assert.Nil(t, err) {
+		return
+	}
+
+	backups, err := sqlsvc.NewSqlBackupStore(db)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	network := NewNetwork(nil)
+	drainer := network.Drainer()
+	server, err := http.Serve(ctx,
+		http.Build(AdminHandlers),
+		http.WithListener(network, ":0"),
+		http.WithDependency(BackupStorageKey, backups),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(drainer.Middleware()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	resp, err := gohttp.Get(fmt.Sprintf("http://localhost:%v/v1/admin/backup", server.Address().(*gonet.TCPAddr).Port))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	if !assert.Equal(t, 200, resp.StatusCode) {
+		return
+	}
+
+	path := filepath.Join(dir, "backup.db")
+	file, err := os.Create(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer file.Close()
+
+	if _, err := io.CopyN(file, resp.Body, 1024*1024); !assert.Nil(t, err) {
+		return
+	}
+
+	// The handler has returned, but its body is still being streamed.
+	drained := make(chan error, 1)
+	go func() {
+		drained <- drainer.Drain(10 * time.Second)
+	}()
+	if !assert.Nil(t, server.Close()) {
+		return
+	}
+
+	if !t.Run("Drain_WaitsForBody", func(t *testing.T) {
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case err := <-drained:
+			assert.Fail(t, "Drained before the backup was written", "%v", err)
+		default:
+		}
+		assert.Equal(t, 1, drainer.Active())
+	}) {
+		return
+	}
+
+	if !t.Run("Drain_BackupIntact", func(t *testing.T) {
+		if _, err := io.Copy(file, resp.Body); !assert.Nil(t, err) {
+			return
+		}
+		if !assert.Nil(t, <-drained) {
+			return
+		}
+
+		info, err := file.Stat()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, resp.ContentLength, info.Size())
+		assert.Nil(t, sqlsvc.VerifyBackup(path, "TEST"))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/mime"
+	"github.com/pkopriv2/services-catalog/authz"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Error codes are part of the api.  Unlike messages, they will never change.
+const (
+	CodeInvalid       = "invalid"
+	CodeNoService     = "no_service"
+	CodeNoVersion     = "no_version"
+	CodeNoWebhook     = "no_webhook"
+	CodeNoTransfer    = "no_transfer"
+	CodeUnauthorized  = "unauthorized"
+	CodeForbidden     = "forbidden"
+	CodeConflict      = "conflict"
+	CodePrecondition  = "precondition_failed"
+	CodeAborted       = "aborted"
+	CodeKeyReused     = "idempotency_key_reused"
+	CodeKeyInProgress = "idempotency_key_in_progress"
+	CodeRateLimited   = "rate_limited"
+	CodeInternal      = "internal"
+)
+
+// Every error is returned as a json envelope, regardless of the accepted
+// encoding.  Details are specific to the code (e.g. the results of an
+// aborted batch).  Errors carry the id of the request that failed.
+//
+// Decoded errors unwrap to the matching core error, so they may be matched
+// with errs.Is across the network.
+type Error struct {
+	Status    int             `json:"-"`
+	Code      string          `json:"code"`
+	Message   string          `json:"message"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	RequestId string          `json:"request_id,omitempty"`
+}
+
+// The request id is included, so that a failure reported by a client may be
+// found in the server's logs.
+func (e *Error) Error() (ret string) {
+	ret = e.Message
+	if cause := e.Unwrap(); cause != nil && !strings.Contains(e.Message, cause.Error()) {
+		ret = fmt.Sprintf("%v: %v", e.Message, cause)
+	}
+	if e.RequestId != "" {
+		ret = fmt.Sprintf("%v [request_id=%v]", ret, e.RequestId)
+	}
+	return
+}
+
+func (e *Error) Unwrap() error {
+	for _, m := range errorMappings {
+		if m.Code == e.Code {
+			return m.Err
+		}
+	}
+	return nil
+}
+
+type errorMapping struct {
+	Err    error
+	Status int
+	Code   string
+}
+
+// Errors are mapped by the first core error they contain.
+var errorMappings = []errorMapping{
+	{core.ErrState, 400, CodeInvalid},
+	{core.ErrUnauthorized, 401, CodeUnauthorized},
+	{core.ErrForbidden, 403, CodeForbidden},
+	{core.ErrNoService, 404, CodeNoService},
+	{core.ErrNoVersion, 404, CodeNoVersion},
+	{core.ErrNoWebhook, 404, CodeNoWebhook},
+	{core.ErrNoTransfer, 404, CodeNoTransfer},
+	{core.ErrConflict, 409, CodeConflict},
+	{core.ErrAborted, 422, CodeAborted},
+	{core.ErrRateLimited, 429, CodeRateLimited},
+}
+
+// Replies with the status and code of the error.  Unrecognized errors
+// are internal errors.  Denials carry their reason in the details.
+func replyError(err error) http.Response {
+	status, code, details := classifyError(err)
+	return replyErrorWith(status, code, err, details)
+}
+
+// Returns the status, code and details of the error.  Every api (e.g.
+// GraphQL) reports errors by the same codes.
+func classifyError(err error) (status int, code string, details interface{}) {
+	var denial *authz.Denial
+	if errors.As(err, &denial) {
+		return 403, CodeForbidden, denial
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.Err) || strings.Contains(err.Error(), m.Err.Error()) {
+			return m.Status, m.Code, nil
+		}
+	}
+	return 500, CodeInternal, nil
+}
+
+// Replies with an invalid request, whatever the cause of the error.
+func badRequest(err error) http.Response {
+	return replyErrorWith(400, CodeInvalid, err, nil)
+}
+
+func assertTrue(cond bool, msg string) http.Response {
+	if cond {
+		return nil
+	}
+	return badRequest(errors.New(msg))
+}
+
+func replyErrorWith(status int, code string, err error, details interface{}) http.Response {
+	return func(b http.ResponseBuilder) (e error) {
+		envelope := Error{Code: code, Message: err.Error()}
+		if details != nil {
+			if envelope.Details, e = json.Marshal(details); e != nil {
+				return
+			}
+		}
+
+		var body []byte
+		if e = enc.Json.EncodeBinary(envelope, &body); e != nil {
+			return
+		}
+
+		b.SetCode(status)
+		b.SetBody(mime.Json, body)
+		return
+	}
+}
+
+// Reads the error from a response.  Responses that don't carry an
+// envelope (e.g. from a proxy) are read as plain errors.
+func readError(resp client.Response) error {
+	var typ string
+	if resp.ReadHeader(headers.ContentType, &typ) && strings.HasPrefix(typ, mime.Json) {
+		var body []byte
+		if err := resp.ReadBody(&body); err != nil {
+			return err
+		}
+
+		ret := &Error{Status: resp.ReadCode()}
+		if err := json.Unmarshal(body, ret); err == nil && ret.Code != "" {
+			return ret
+		}
+		return errors.Errorf("Unexpected response [%v]: %v", resp.ReadCode(), string(body))
+	}
+	return client.ReadError(resp)
+}
+
+// Expects the response to have the given code.  Otherwise, the error is
+// read from the response.
+func expectCode(code int) func(client.Response) error {
+	return func(resp client.Response) error {
+		if resp.ReadCode() >= 400 {
+			return readError(resp)
+		}
+		return client.ExpectCode(code)(resp)
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	ETagHeader = "ETag"
+)
+
+// Revisions of a service are immutable, so a service's etag is derived
+// from its (id, version) pair rather than a hash of its content.
+func ETag(svc core.Service) string {
+	return fmt.Sprintf(`"%v.%v"`, svc.Id, svc.Version)
+}
+
+// Parses a strong service etag.  Weak etags may not be used as
+// preconditions for updates.
+func ParseETag(raw string) (id uuid.UUID, version int, err error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 || !strings.HasPrefix(raw, `"`) || !strings.HasSuffix(raw, `"`) {
+		err = errors.Wrapf(core.ErrState, "Invalid etag [%v]", raw)
+		return
+	}
+
+	parts := strings.SplitN(raw[1:len(raw)-1], ".", 2)
+	if len(parts) != 2 {
+		err = errors.Wrapf(core.ErrState, "Invalid etag [%v]", raw)
+		return
+	}
+
+	if id, err = uuid.FromString(parts[0]); err != nil {
+		err = errors.Wrapf(core.ErrState, "Invalid etag [%v]", raw)
+		return
+	}
+	if version, err = strconv.Atoi(parts[1]); err != nil || version < 0 {
+		err = errors.Wrapf(core.ErrState, "Invalid etag [%v]", raw)
+		return
+	}
+	return
+}
+
+// Returns the etag of a listing.  A listing carries its services' versions
+// as well as their revisions, so no service etag describes it.  Instead, the
+// listing carries a weak etag derived from everything in its body.  Updates
+// must take their precondition from the service itself.
+func catalogETag(catalog core.Catalog) string {
+	hash := sha256.New()
+	if err := json.NewEncoder(hash).Encode(catalog); err != nil {
+		panic(err) // a catalog is always encodable
+	}
+	return fmt.Sprintf(`W/"%v"`, hex.EncodeToString(hash.Sum(nil)[:16]))
+}
@@ -0,0 +1,660 @@
+package http
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Queries are limited by their depth (the deepest nesting of selections)
+// and their complexity (the number of fields they select, counting
+// fragments wherever they are spread, and the fields beneath a list once
+// per element).  Lists hold as many elements as their page allows or, if
+// they aren't paged, the list size.  The limits leave room for the
+// standard introspection query.
+var (
+	GraphQLMaxDepth      = 16
+	GraphQLMaxComplexity = 512
+	GraphQLListSize      = 10
+)
+
+// A GraphQL request, as sent by standard GraphQL clients.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// A GraphQL response.  Unlike the rest of the api, errors are reported
+// in the response, as GraphQL clients expect.  Errors raised by the
+// catalog carry their error code as an extension.
+type GraphQLResponse struct {
+	Data   interface{}                `json:"data"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+var graphQLRoute = http.Post("/v1/graphql")
+
+// Register the GraphQL handler
+func GraphQLHandlers(svc *http.Service) {
+	schema, err := GraphQLSchema()
+	if err != nil {
+		panic(err)
+	}
+
+	// Requests that can't be executed (e.g. those that are invalid or that
+	// exceed the limits) reply 400.  Otherwise, the request replies 200,
+	// even if some of its fields failed.
+	register(svc, graphQLRoute,
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getStorage(env, req)
+
+			var gql GraphQLRequest
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &gql); err != nil {
+				ret = badRequest(err)
+				return
+			}
+			gql.Variables, _ = graphQLNumbers(gql.Variables).(map[string]interface{})
+
+			doc, errs := prepareGraphQL(schema, gql)
+			if len(errs) > 0 {
+				ret = http.Reply(
+					http.WithCode(400),
+					http.WithStruct(enc.Json, GraphQLResponse{Errors: errs}))
+				return
+			}
+
+			ctx, cancel := requestContext(env)
+			defer cancel()
+
+			logger.Debug("Executing graphql [operation=%v]", gql.OperationName)
+			result := graphql.Execute(graphql.ExecuteParams{
+				Schema:        schema,
+				AST:           doc,
+				OperationName: gql.OperationName,
+				Args:          gql.Variables,
+				Context:       gocontext.WithValue(ctx, graphQLEnvKey{}, graphQLEnv{storage, req}),
+			})
+
+			ret = http.Ok(enc.Json, GraphQLResponse{result.Data, result.Errors})
+			return
+		})
+}
+
+// Returns the context of a request, which is canceled once the request is
+// handled or the server is closed, so that an execution never outlives
+// either.  (The sdk doesn't expose the context of the underlying request.)
+func requestContext(env http.Environment) (gocontext.Context, gocontext.CancelFunc) {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	go func() {
+		select {
+		case <-env.Control().Closed():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Parses and validates the request, and verifies that it is within the
+// limits.
+func prepareGraphQL(schema graphql.Schema, req GraphQLRequest) (*ast.Document, []gqlerrors.FormattedError) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return nil, gqlerrors.FormatErrors(err)
+	}
+
+	if result := graphql.ValidateDocument(&schema, doc, nil); !result.IsValid {
+		return nil, result.Errors
+	}
+
+	depth, complexity := measureGraphQL(schema, doc, req.OperationName, req.Variables)
+	if depth > GraphQLMaxDepth {
+		return nil, limitError("Query depth [%v] exceeds the maximum [%v]", depth, GraphQLMaxDepth)
+	}
+	if complexity > GraphQLMaxComplexity {
+		return nil, limitError("Query complexity [%v] exceeds the maximum [%v]", complexity, GraphQLMaxComplexity)
+	}
+	return doc, nil
+}
+
+// Returns true if the document of the request contains a mutation, whether
+// or not it's the operation executed.  Requests that can't be parsed are
+// rejected before anything is executed, so they aren't mutations.
+func isGraphQLMutation(body []byte) bool {
+	var req GraphQLRequest
+	if err := enc.Json.DecodeBinary(body, &req); err != nil {
+		return false
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return false
+	}
+
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok && op.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
+
+func limitError(format string, args ...interface{}) []gqlerrors.FormattedError {
+	err := gqlerrors.NewFormattedError(fmt.Sprintf(format, args...))
+	err.Extensions = map[string]interface{}{"code": CodeInvalid}
+	return []gqlerrors.FormattedError{err}
+}
+
+// Returns the depth and complexity of the operation.  When no operation is
+// named, every operation is measured.  Documents have been validated, so
+// fragments exist and don't form cycles.
+//
+// The fields beneath a list are counted once for every element it's
+// expected to hold (see listSize).  Fields of types that aren't part of the
+// schema's operations (i.e. those of introspection) are never counted as
+// lists, since they're bounded by the size of the schema.
+func measureGraphQL(schema graphql.Schema, doc *ast.Document, operation string, vars map[string]interface{}) (depth, complexity int) {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[frag.Name.Value] = frag
+		}
+	}
+
+	conditionOf := func(cond *ast.Named, parent graphql.Type) graphql.Type {
+		switch {
+		case cond == nil:
+			return parent
+		case strings.HasPrefix(cond.Name.Value, "__"):
+			return nil
+		}
+		return schema.Type(cond.Name.Value)
+	}
+
+	var measure func(set *ast.SelectionSet, parent graphql.Type, level int) int
+	measure = func(set *ast.SelectionSet, parent graphql.Type, level int) (ret int) {
+		if set == nil {
+			return
+		}
+		for _, sel := range set.Selections {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				if level > depth {
+					depth = level
+				}
+
+				var typ graphql.Type
+				if obj, ok := parent.(*graphql.Object); ok {
+					if def := obj.Fields()[sel.Name.Value]; def != nil {
+						typ = def.Type
+					}
+				}
+
+				elem, list := graphQLElem(typ)
+				children := measure(sel.SelectionSet, elem, level+1)
+				if list {
+					children *= listSize(sel, vars)
+				}
+				ret += 1 + children
+			case *ast.InlineFragment:
+				ret += measure(sel.SelectionSet, conditionOf(sel.TypeCondition, parent), level)
+			case *ast.FragmentSpread:
+				if frag, ok := fragments[sel.Name.Value]; ok {
+					ret += measure(frag.SelectionSet, conditionOf(frag.TypeCondition, parent), level)
+				}
+			}
+
+			// Nested lists multiply quickly, so the count is capped well
+			// beyond any limit rather than allowed to overflow.
+			if ret > maxGraphQLComplexity {
+				ret = maxGraphQLComplexity
+			}
+		}
+		return
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operation != "" && (op.Name == nil || op.Name.Value != operation) {
+			continue
+		}
+
+		var root graphql.Type = schema.QueryType()
+		if op.Operation == ast.OperationTypeMutation {
+			root = schema.MutationType()
+		}
+		complexity += measure(op.SelectionSet, root, 1)
+	}
+	return
+}
+
+const maxGraphQLComplexity = 1 << 30
+
+// Returns the type of the elements of a list type, or the type itself if
+// it isn't a list.
+func graphQLElem(typ graphql.Type) (graphql.Type, bool) {
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.OfType
+	}
+
+	list, ok := typ.(*graphql.List)
+	if !ok {
+		return typ, false
+	}
+
+	elem := list.OfType
+	if nonNull, ok := elem.(*graphql.NonNull); ok {
+		elem = nonNull.OfType
+	}
+	return elem, true
+}
+
+// Returns the number of elements a list is expected to hold, which is the
+// limit of its page, if one is given.  Limits beyond the maximum page are
+// rejected before any element is resolved, so they count as one.
+func listSize(field *ast.Field, vars map[string]interface{}) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "page" {
+			continue
+		}
+
+		page, _ := graphQLValue(arg.Value, vars).(map[string]interface{})
+
+		limit, _ := page["limit"].(int)
+		switch {
+		case limit > 1024:
+			return 1
+		case limit > 0:
+			return limit
+		}
+	}
+	return GraphQLListSize
+}
+
+// Requests are decoded with json numbers, which GraphQL doesn't accept.
+// Numbers are converted to ints where they're whole, and floats otherwise.
+func graphQLNumbers(val interface{}) interface{} {
+	switch val := val.(type) {
+	case json.Number:
+		if ret, err := strconv.Atoi(val.String()); err == nil {
+			return ret
+		}
+		ret, _ := val.Float64()
+		return ret
+	case map[string]interface{}:
+		for k, v := range val {
+			val[k] = graphQLNumbers(v)
+		}
+	case []interface{}:
+		for i, v := range val {
+			val[i] = graphQLNumbers(v)
+		}
+	}
+	return val
+}
+
+// Returns the value of an argument, with its variables substituted.
+// Objects are returned as maps, and only the kinds of values needed to
+// measure queries are supported.
+func graphQLValue(val ast.Value, vars map[string]interface{}) interface{} {
+	switch val := val.(type) {
+	case *ast.Variable:
+		return vars[val.Name.Value]
+	case *ast.IntValue:
+		ret, _ := strconv.Atoi(val.Value)
+		return ret
+	case *ast.ObjectValue:
+		ret := make(map[string]interface{})
+		for _, f := range val.Fields {
+			ret[f.Name.Value] = graphQLValue(f.Value, vars)
+		}
+		return ret
+	}
+	return nil
+}
+
+// The environment of a resolver.  The request is retained so that
+// mutations may be authorized.
+type graphQLEnv struct {
+	storage core.Storage
+	req     http.Request
+}
+
+type graphQLEnvKey struct{}
+
+func graphQLStorage(p graphql.ResolveParams) core.Storage {
+	return p.Context.Value(graphQLEnvKey{}).(graphQLEnv).storage
+}
+
+func graphQLAuthorize(p graphql.ResolveParams, scope core.Scope) error {
+	return authorize(p.Context.Value(graphQLEnvKey{}).(graphQLEnv).req, scope)
+}
+
+// An error raised by a resolver.  The code and details match those of the
+// equivalent REST error.
+type graphQLError struct {
+	error
+	code    string
+	details interface{}
+}
+
+func (e *graphQLError) Extensions() map[string]interface{} {
+	ret := map[string]interface{}{"code": e.code}
+	if e.details != nil {
+		ret["details"] = e.details
+	}
+	return ret
+}
+
+// Converts errors from the catalog into resolver errors.
+func resolveError(err error) error {
+	_, code, details := classifyError(err)
+	return &graphQLError{err, code, details}
+}
+
+// A service, as it is resolved.  Versions of services that were listed are
+// loaded along with them, while the versions of other services are loaded
+// once they are selected.
+type graphQLService struct {
+	core.Service
+	versions []core.Version
+	loaded   bool
+}
+
+// Returns the schema served by the GraphQL handler.  The types are generated
+// from the core types, so they share the names of their json fields.
+func GraphQLSchema() (graphql.Schema, error) {
+	version := graphQLObject("Version", core.Version{}, nil)
+
+	service := graphQLObject("Service", core.Service{}, graphql.Fields{
+		"versions": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(version))),
+			Description: "The versions of the service",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				svc := p.Source.(graphQLService)
+
+				versions := svc.versions
+				if !svc.loaded {
+					var err error
+					if _, versions, err = loadService(graphQLStorage(p), svc.Id); err != nil {
+						return nil, resolveError(err)
+					}
+				}
+				if versions == nil {
+					versions = []core.Version{}
+				}
+				return versions, nil
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"services": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(service))),
+				Description: "Lists the latest revisions of services",
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: graphQLInput("Filter", core.Filter{})},
+					"page":   {Type: graphQLInput("Page", core.Page{})},
+				},
+				Resolve: resolveServices,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"saveService": &graphql.Field{
+				Type:        graphql.NewNonNull(service),
+				Description: "Creates a service, or updates it when it has a version",
+				Args: graphql.FieldConfigArgument{
+					"service": {Type: graphql.NewNonNull(graphQLInput("ServiceInput", core.Service{}))},
+				},
+				Resolve: resolveSaveService,
+			},
+			"saveVersion": &graphql.Field{
+				Type:        graphql.NewNonNull(version),
+				Description: "Adds a version to a service",
+				Args: graphql.FieldConfigArgument{
+					"version": {Type: graphql.NewNonNull(graphQLInput("VersionInput", core.Version{}))},
+				},
+				Resolve: resolveSaveVersion,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func resolveServices(p graphql.ResolveParams) (interface{}, error) {
+	filter := core.NewFilter()
+	if err := decodeArg(p.Args["filter"], &filter); err != nil {
+		return nil, resolveError(err)
+	}
+
+	page := core.NewPage()
+	if err := decodeArg(p.Args["page"], &page); err != nil {
+		return nil, resolveError(err)
+	}
+	if page.Limit > 1024 {
+		return nil, resolveError(errors.Wrapf(core.ErrState, "Invalid limit. Must be <= 1024"))
+	}
+
+	catalog, err := graphQLStorage(p).ListServices(filter, page)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+
+	ret := make([]graphQLService, 0, len(catalog.Services))
+	for _, svc := range catalog.Services {
+		ret = append(ret, graphQLService{svc, catalog.Versions[svc.Id], true})
+	}
+	return ret, nil
+}
+
+func resolveSaveService(p graphql.ResolveParams) (interface{}, error) {
+	if err := graphQLAuthorize(p, core.ScopeWrite); err != nil {
+		return nil, resolveError(err)
+	}
+
+	var svc core.Service
+	if err := decodeArg(p.Args["service"], &svc); err != nil {
+		return nil, resolveError(err)
+	}
+
+	w := core.SaveServiceWrite(svc)
+	if err := w.Prepare(); err != nil {
+		return nil, resolveError(err)
+	}
+
+	if err := graphQLStorage(p).SaveService(*w.Service); err != nil {
+		return nil, resolveError(err)
+	}
+	return graphQLService{Service: *w.Service}, nil
+}
+
+func resolveSaveVersion(p graphql.ResolveParams) (interface{}, error) {
+	if err := graphQLAuthorize(p, core.ScopeWrite); err != nil {
+		return nil, resolveError(err)
+	}
+
+	var ver core.Version
+	if err := decodeArg(p.Args["version"], &ver); err != nil {
+		return nil, resolveError(err)
+	}
+
+	w := core.SaveVersionWrite(ver)
+	if err := w.Prepare(); err != nil {
+		return nil, resolveError(err)
+	}
+
+	if err := graphQLStorage(p).SaveVersion(*w.Version); err != nil {
+		return nil, resolveError(err)
+	}
+	return *w.Version, nil
+}
+
+// Input objects are decoded through their json encoding, so they are
+// decoded exactly as they are over REST.  Absent arguments leave the
+// value untouched.
+func decodeArg(arg interface{}, ptr interface{}) error {
+	if arg == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw, ptr); err != nil {
+		return errors.Wrapf(core.ErrState, "Invalid argument: %v", err)
+	}
+	return nil
+}
+
+// Ids are sent as strings.
+var graphQLUUID = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "UUID",
+	Description: "A uuid, in its canonical string form",
+	Serialize: func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case uuid.UUID:
+			return value.String()
+		case *uuid.UUID:
+			if value == nil {
+				return nil
+			}
+			return value.String()
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if raw, ok := value.(string); ok {
+			if id, err := uuid.FromString(raw); err == nil {
+				return id
+			}
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) interface{} {
+		if raw, ok := value.(*ast.StringValue); ok {
+			if id, err := uuid.FromString(raw.Value); err == nil {
+				return id
+			}
+		}
+		return nil
+	},
+})
+
+// Returns the GraphQL type of a field, as it is encoded by encoding/json.
+// Only the kinds used by the core types are supported.
+func graphQLType(t reflect.Type) *graphql.Scalar {
+	switch t {
+	case uuidType:
+		return graphQLUUID
+	case timeType:
+		return graphql.DateTime
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return graphQLType(t.Elem())
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return graphql.Int
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	case reflect.String:
+		return graphql.String
+	}
+	panic(fmt.Sprintf("Unsupported graphql type [%v]", t))
+}
+
+// Iterates the json fields of a struct, along with their names.
+func jsonFields(t reflect.Type, fn func(name string, field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fn(name, field)
+	}
+}
+
+// Returns an object type with a field for every json field of the example.
+// Fields are resolved from the source by name, so sources may embed the
+// example's type.  Fields that aren't pointers are never null.
+func graphQLObject(name string, example interface{}, extra graphql.Fields) *graphql.Object {
+	fields := graphql.Fields{}
+	jsonFields(reflect.TypeOf(example), func(tag string, field reflect.StructField) {
+		var typ graphql.Output = graphQLType(field.Type)
+		if field.Type.Kind() != reflect.Ptr {
+			typ = graphql.NewNonNull(typ)
+		}
+
+		goName := field.Name
+		fields[tag] = &graphql.Field{
+			Type: typ,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				val := reflect.Indirect(reflect.ValueOf(p.Source)).FieldByName(goName)
+				if val.Kind() == reflect.Ptr && val.IsNil() {
+					return nil, nil
+				}
+				return reflect.Indirect(val).Interface(), nil
+			},
+		}
+	})
+	for name, field := range extra {
+		fields[name] = field
+	}
+	return graphql.NewObject(graphql.ObjectConfig{Name: name, Fields: fields})
+}
+
+// Returns an input type with a field for every json field of the example.
+// Timestamps are assigned by the server, so they are omitted.  Every field
+// is optional, so that defaults apply and validation matches REST.
+func graphQLInput(name string, example interface{}) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{}
+	jsonFields(reflect.TypeOf(example), func(tag string, field reflect.StructField) {
+		if field.Type == timeType {
+			return
+		}
+		fields[tag] = &graphql.InputObjectFieldConfig{Type: graphQLType(field.Type)}
+	})
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Fields: fields})
+}
@@ -0,0 +1,259 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+// The data of a response is left raw, so that each test may decode it
+// into the shape of its query.
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQL(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(GraphQLHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	call := func(code int, query string, vars map[string]interface{}) (ret graphQLResult, err error) {
+		err = server.Connect().Call(
+			client.BuildRequest(
+				client.Post("/v1/graphql"),
+				client.WithStruct(enc.Json, GraphQLRequest{Query: query, Variables: vars})),
+			func(resp client.Response) error {
+				if resp.ReadCode() != code {
+					return fmt.Errorf("Expected code [%v]. Got [%v]", code, resp.ReadCode())
+				}
+				return client.RequireStruct(resp, enc.DefaultRegistry, &ret)
+			})
+		return
+	}
+
+	var svc core.Service
+	if !t.Run("SaveService", func(t *testing.T) {
+		ret, err := call(200, `mutation {
+			saveService(service: {name: "name", desc: "desc"}) { id name desc version updated versions { name } }
+		}`, nil)
+		if !assert.Nil(t, err) || !assert.Empty(t, ret.Errors) {
+			return
+		}
+
+		var data struct {
+			SaveService struct {
+				core.Service
+				Versions []core.Version `json:"versions"`
+			} `json:"saveService"`
+		}
+		if !assert.Nil(t, json.Unmarshal(ret.Data, &data)) {
+			return
+		}
+
+		svc = data.SaveService.Service
+		assert.Equal(t, "name", svc.Name)
+		assert.Equal(t, 0, svc.Version)
+		assert.False(t, svc.Updated.IsZero())
+		assert.Equal(t, []core.Version{}, data.SaveService.Versions)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Conflict", func(t *testing.T) {
+		ret, err := call(200, `mutation($svc: ServiceInput!) { saveService(service: $svc) { id } }`,
+			map[string]interface{}{
+				"svc": map[string]interface{}{"id": svc.Id.String(), "name": "name", "desc": "desc"},
+			})
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Equal(t, CodeConflict, ret.Errors[0].Extensions["code"])
+	}) {
+		return
+	}
+
+	if !t.Run("SaveVersion", func(t *testing.T) {
+		ret, err := call(200, `mutation($id: UUID!) {
+			saveVersion(version: {service_id: $id, name: "v1"}) { service_id name created }
+		}`, map[string]interface{}{"id": svc.Id.String()})
+		if !assert.Nil(t, err) || !assert.Empty(t, ret.Errors) {
+			return
+		}
+
+		var data struct {
+			SaveVersion core.Version `json:"saveVersion"`
+		}
+		if !assert.Nil(t, json.Unmarshal(ret.Data, &data)) {
+			return
+		}
+		assert.Equal(t, svc.Id, data.SaveVersion.ServiceId)
+		assert.Equal(t, "v1", data.SaveVersion.Name)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveVersion_Invalid", func(t *testing.T) {
+		ret, err := call(200, `mutation($id: UUID!) {
+			saveVersion(version: {service_id: $id}) { name }
+		}`, map[string]interface{}{"id": svc.Id.String()})
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Equal(t, CodeInvalid, ret.Errors[0].Extensions["code"])
+	}) {
+		return
+	}
+
+	if !t.Run("Services", func(t *testing.T) {
+		if err := store.SaveService(core.NewService("other", "desc")); !assert.Nil(t, err) {
+			return
+		}
+
+		ret, err := call(200, `query($name: String) {
+			services(filter: {name_contains: $name}, page: {limit: 10}) { id name versions { name } }
+		}`, map[string]interface{}{"name": "nam"})
+		if !assert.Nil(t, err) || !assert.Empty(t, ret.Errors) {
+			return
+		}
+
+		var data struct {
+			Services []struct {
+				Id       string `json:"id"`
+				Name     string `json:"name"`
+				Desc     string `json:"desc"`
+				Versions []struct {
+					Name string `json:"name"`
+				} `json:"versions"`
+			} `json:"services"`
+		}
+		if !assert.Nil(t, json.Unmarshal(ret.Data, &data)) || !assert.Equal(t, 1, len(data.Services)) {
+			return
+		}
+		assert.Equal(t, svc.Id.String(), data.Services[0].Id)
+		assert.Equal(t, "", data.Services[0].Desc)
+		if assert.Equal(t, 1, len(data.Services[0].Versions)) {
+			assert.Equal(t, "v1", data.Services[0].Versions[0].Name)
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Services_InvalidLimit", func(t *testing.T) {
+		ret, err := call(200, `{ services(page: {limit: 2048}) { id } }`, nil)
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Equal(t, CodeInvalid, ret.Errors[0].Extensions["code"])
+	}) {
+		return
+	}
+
+	if !t.Run("Invalid", func(t *testing.T) {
+		ret, err := call(400, `{ services { unknown } }`, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.NotEmpty(t, ret.Errors)
+	}) {
+		return
+	}
+
+	if !t.Run("MaxDepth", func(t *testing.T) {
+		deep := `{ __schema { types { ` + strings.Repeat(`ofType { `, GraphQLMaxDepth) + `name` +
+			strings.Repeat(` }`, GraphQLMaxDepth) + ` } } }`
+
+		ret, err := call(400, deep, nil)
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Equal(t, CodeInvalid, ret.Errors[0].Extensions["code"])
+		assert.Contains(t, ret.Errors[0].Message, "depth")
+	}) {
+		return
+	}
+
+	if !t.Run("MaxComplexity", func(t *testing.T) {
+		fields := make([]string, GraphQLMaxComplexity)
+		for i := range fields {
+			fields[i] = "a" + strings.Repeat("a", i%8) + ": name"
+		}
+
+		ret, err := call(400, `{ services { `+strings.Join(fields, " ")+` } }`, nil)
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Contains(t, ret.Errors[0].Message, "complexity")
+	}) {
+		return
+	}
+
+	if !t.Run("MaxComplexity_Lists", func(t *testing.T) {
+		query := `query($page: Page) { services(page: $page) { id name versions { name created } } }`
+
+		ret, err := call(400, query, map[string]interface{}{"page": map[string]interface{}{"limit": 100}})
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Contains(t, ret.Errors[0].Message, "complexity")
+
+		ret, err = call(200, query, map[string]interface{}{"page": map[string]interface{}{"limit": 10}})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, ret.Errors)
+
+		ret, err = call(400, `{ services(page: {limit: 600}) { id } }`, nil)
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Contains(t, ret.Errors[0].Message, "complexity")
+	}) {
+		return
+	}
+
+	if !t.Run("Introspection", func(t *testing.T) {
+		ret, err := call(200, testutil.IntrospectionQuery, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, ret.Errors)
+	}) {
+		return
+	}
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/services-catalog/health"
+)
+
+const (
+	HealthRegistryKey = "health.registry"
+)
+
+// Uses the dependency injector to retrieve the health registry
+func getHealthRegistry(env http.Environment) (ret *health.Registry) {
+	env.Assign(HealthRegistryKey, &ret)
+	return
+}
+
+// Register the health handlers.  Probes are sent by orchestrators, so they
+// require no token and are always answered in json.
+func HealthHandlers(svc *http.Service) {
+
+	// The process is alive if it can answer at all.
+	register(svc, http.Get("/healthz"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			ret = http.Ok(enc.Json, health.Report{Status: health.Pass})
+			return
+		})
+
+	// The instance is ready if every registered check passes.  Otherwise,
+	// it replies 503 with the same breakdown.  The errors of failed checks
+	// are logged rather than replied.
+	register(svc, http.Get("/readyz"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			report := getHealthRegistry(env).Check()
+			if !report.Passed() {
+				for _, c := range report.Checks {
+					if c.Err != nil {
+						env.Logger().Info("Readiness check [%v] failed: %v", c.Name, c.Err)
+					}
+				}
+
+				ret = http.Reply(
+					http.WithCode(503),
+					http.WithStruct(enc.Json, report))
+				return
+			}
+
+			ret = http.Ok(enc.Json, report)
+			return
+		})
+}
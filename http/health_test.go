@@ -0,0 +1,101 @@
+package http
+
+import (
+	"os"
+	"testing"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/health"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	reg := health.NewRegistry()
+	reg.Register("storage", sqlsvc.NewPingCheck(db))
+
+	server, err := http.Serve(ctx,
+		http.Build(HealthHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(HealthRegistryKey, reg),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Probes carry no token.
+	probe := func(path string) (code int, report health.Report, err error) {
+		err = server.Connect().Call(
+			client.Get(path),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				return client.RequireStruct(resp, enc.DefaultRegistry, &report)
+			})
+		return
+	}
+
+	if !t.Run("Healthz", func(t *testing.T) {
+		code, report, err := probe("/healthz")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 200, code)
+		assert.Equal(t, health.Pass, report.Status)
+	}) {
+		return
+	}
+
+	if !t.Run("Readyz", func(t *testing.T) {
+		code, report, err := probe("/readyz")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 200, code)
+		assert.Equal(t, health.Pass, report.Status)
+		if assert.Equal(t, 2, len(report.Checks)) {
+			assert.Equal(t, "storage", report.Checks[1].Name)
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Readyz_Draining", func(t *testing.T) {
+		reg.Drain()
+
+		code, report, err := probe("/readyz")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 503, code)
+		assert.Equal(t, health.Fail, report.Status)
+		assert.Equal(t, health.ReasonDraining, report.Checks[0].Reason)
+		assert.Equal(t, health.Pass, report.Checks[1].Status)
+
+		code, _, err = probe("/healthz")
+		assert.Nil(t, err)
+		assert.Equal(t, 200, code)
+	}) {
+		return
+	}
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// Set on responses that were replayed from an earlier request.
+	IdempotentReplayHeader = "Idempotent-Replayed"
+)
+
+// Returns a middleware that makes writes sent with an Idempotency-Key
+// header safe to retry.  The first request with a given key is applied
+// and its response is stored for the window.  Thereafter, requests with
+// the same key are answered with the stored response.  A key that is
+// reused for a different request is rejected with a 422, and a request
+// whose key is still in progress is rejected with a 409, until the lease
+// of its reservation expires (see core.IdempotencyLease).  Server errors are
+// not stored, so that the request may be retried.
+//
+// Keys are scoped by the principal that sent them, so that clients can't
+// replay (or block) each other's requests.  The principal is known once
+// authenticated, so this should be installed before the auth middleware.
+func NewIdempotencyMiddleware(store core.IdempotencyStorage, window time.Duration) http.Middleware {
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) (ret http.Response) {
+			logger := env.Logger()
+
+			var key string
+			switch req.Method() {
+			case "GET", "HEAD":
+				return h(env, req)
+			}
+			if !req.ReadHeader(IdempotencyKeyHeader, &key) {
+				return h(env, req)
+			}
+			if len(key) > 255 {
+				return badRequest(errors.Errorf("Invalid idempotency key. Must be <= 255 characters"))
+			}
+
+			var body []byte
+			if err := req.ReadBody(&body); err != nil {
+				return badRequest(err)
+			}
+			req = &bufferedRequest{req, bytes.NewReader(body), body}
+
+			record := core.NewIdempotencyRecord(scopedKey(req, key), fingerprint(req, body), window)
+
+			existing, reserved, err := store.Reserve(record)
+			if err != nil {
+				return replyError(err)
+			}
+			if !reserved {
+				switch {
+				case existing.Fingerprint != record.Fingerprint:
+					return replyErrorWith(422, CodeKeyReused,
+						errors.Errorf("Idempotency key [%v] was used for a different request", key), nil)
+				case !existing.Completed():
+					return replyErrorWith(409, CodeKeyInProgress,
+						errors.Errorf("Request with idempotency key [%v] is in progress", key), nil)
+				}
+
+				logger.Debug("Replaying response [key=%v,code=%v]", key, existing.Code)
+				return replay(existing)
+			}
+
+			rec := newRecorder()
+			if resp := h(env, req); resp != nil {
+				err = resp(rec)
+			} else {
+				err = http.StatusOK(rec)
+			}
+			if err == nil {
+				err = rec.Close()
+			}
+			if err != nil || rec.code >= 500 {
+				if e := store.Release(record); e != nil {
+					logger.Error("Unable to release idempotency key [%v]: %v", key, e)
+				}
+				if err != nil {
+					return replyError(err)
+				}
+				return rec.Response()
+			}
+
+			record.Code, record.Headers, record.Mime, record.Body = rec.code, rec.headers, rec.mime, rec.body
+			if err := store.Complete(record); err != nil {
+				logger.Error("Unable to store response for idempotency key [%v]: %v", key, err)
+			}
+			return rec.Response()
+		}
+	}
+}
+
+// Keys are prefixed by a hash of the principal's subject, which can't
+// contain the separator.  Unauthenticated keys (e.g. authentication is
+// disabled) are shared.
+func scopedKey(req http.Request, key string) string {
+	principal, ok := Authenticated(req)
+	if !ok {
+		return key
+	}
+
+	hash := sha256.Sum256([]byte(principal.Subject))
+	return hex.EncodeToString(hash[:]) + ":" + key
+}
+
+// The fingerprint covers everything that determines the effect of a write.
+func fingerprint(req http.Request, body []byte) string {
+	var typ string
+	req.ReadHeader(headers.ContentType, &typ)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%v %v\n%v\n", req.Method(), req.URL().RequestURI(), typ)
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(r core.IdempotencyRecord) http.Response {
+	return func(b http.ResponseBuilder) (err error) {
+		b.SetCode(r.Code)
+		if r.Mime != "" {
+			b.SetBody(r.Mime, r.Body)
+		}
+		for k, v := range r.Headers {
+			b.SetHeader(k, v)
+		}
+		b.SetHeader(IdempotentReplayHeader, "true")
+		return
+	}
+}
+
+// The request body may only be read once, so it is buffered in order to
+// compute the fingerprint before the handler reads it.
+type bufferedRequest struct {
+	http.Request
+	reader *bytes.Reader
+	body   []byte
+}
+
+func (r *bufferedRequest) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *bufferedRequest) ReadBody(ptr *[]byte) error {
+	*ptr = r.body
+	return nil
+}
+
+func (r *bufferedRequest) Close() error {
+	return nil
+}
+
+func (r *bufferedRequest) Unwrap() http.Request {
+	return r.Request
+}
+
+// A recorder captures a response, so that it may be both stored and sent.
+type recorder struct {
+	code    int
+	headers map[string]string
+	mime    string
+	body    []byte
+	raw     io.Reader
+}
+
+func newRecorder() *recorder {
+	return &recorder{code: 500, headers: make(map[string]string)}
+}
+
+func (r *recorder) SetCode(code int) {
+	r.code = code
+}
+
+func (r *recorder) SetHeader(name string, val string) {
+	r.headers[name] = val
+}
+
+func (r *recorder) SetBody(mime string, val []byte) {
+	r.mime, r.body, r.raw = mime, val, nil
+}
+
+func (r *recorder) SetBodyRaw(mime string, raw io.Reader) {
+	r.mime, r.body, r.raw = mime, nil, raw
+}
+
+// Reads any raw body into memory.  Writes respond with small bodies, so
+// this is never expected to be large.
+func (r *recorder) Close() (err error) {
+	if r.raw == nil {
+		return
+	}
+
+	r.body, err = ioutil.ReadAll(r.raw)
+	r.raw = nil
+	return
+}
+
+func (r *recorder) Response() http.Response {
+	return func(b http.ResponseBuilder) (err error) {
+		b.SetCode(r.code)
+		if r.mime != "" {
+			b.SetBody(r.mime, r.body)
+		}
+		for k, v := range r.headers {
+			b.SetHeader(k, v)
+		}
+		return
+	}
+}
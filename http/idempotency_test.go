@@ -0,0 +1,251 @@
+package http
+
+import (
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+// A client that drops the responses of the first calls, as though the
+// connection failed after the request was applied.
+type lossyClient struct {
+	raw  client.Client
+	drop int
+}
+
+func (c *lossyClient) Call(req client.Request, fn func(client.Response) error) error {
+	if c.drop > 0 {
+		c.drop--
+		c.raw.Call(req, func(client.Response) error { return nil })
+		return &url.Error{Op: "Put", URL: "/", Err: errs.StateError}
+	}
+	return c.raw.Call(req, fn)
+}
+
+func TestIdempotency(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	keys, err := sqlsvc.NewSqlIdempotencyStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewIdempotencyMiddleware(keys, time.Hour)))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	svc := core.NewService("name", "desc")
+	if !assert.Nil(t, store.SaveService(svc)) {
+		return
+	}
+
+	put := func(key string, v core.Version) (code int, replayed string, ret core.Version, err error) {
+		err = server.Connect().Call(
+			client.BuildRequest(
+				client.Put("/v1/versions"),
+				client.WithHeader(IdempotencyKeyHeader, key),
+				client.WithStruct(enc.Json, v)),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				resp.ReadHeader(IdempotentReplayHeader, &replayed)
+				if code != 200 {
+					return nil
+				}
+				return client.RequireStruct(resp, enc.DefaultRegistry, &ret)
+			})
+		return
+	}
+
+	v := core.NewVersion(svc.Id, "v1")
+	var first core.Version
+	if !t.Run("Apply", func(t *testing.T) {
+		code, replayed, ret, err := put("key-1", v)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 200, code)
+		assert.Equal(t, "", replayed)
+		first = ret
+	}) {
+		return
+	}
+
+	if !t.Run("Replay", func(t *testing.T) {
+		code, replayed, ret, err := put("key-1", v)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 200, code)
+		assert.Equal(t, "true", replayed)
+		assert.Equal(t, first, ret)
+	}) {
+		return
+	}
+
+	if !t.Run("Replay_DifferentRequest", func(t *testing.T) {
+		code, _, _, err := put("key-1", core.NewVersion(svc.Id, "v2"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 422, code)
+	}) {
+		return
+	}
+
+	if !t.Run("NewKey", func(t *testing.T) {
+		transport := NewClient(server.Connect(), enc.Json)
+
+		// Every call is a new write with its own key, so saving the same
+		// version again is applied (and fails) rather than replayed.
+		_, err := transport.SaveVersion(v)
+		assert.NotNil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("Client_Retry", func(t *testing.T) {
+		transport := NewClient(&lossyClient{server.Connect(), 1}, enc.Json, WithBackoff(0))
+
+		ret, err := transport.SaveVersion(core.NewVersion(svc.Id, "v3"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "v3", ret.Name)
+	}) {
+		return
+	}
+
+	if !t.Run("Client_RetriesExhausted", func(t *testing.T) {
+		transport := NewClient(&lossyClient{server.Connect(), 3}, enc.Json, WithRetries(1), WithBackoff(0))
+
+		_, err := transport.SaveVersion(core.NewVersion(svc.Id, "v4"))
+		assert.NotNil(t, err)
+	}) {
+		return
+	}
+}
+
+func TestIdempotency_Principals(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	keys, err := sqlsvc.NewSqlIdempotencyStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewIdempotencyMiddleware(keys, time.Hour)),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	newToken := func(name string) string {
+		token, secret, err := core.NewToken(name, core.ScopeWrite, 0)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		return secret
+	}
+
+	svc := core.NewService("name", "desc")
+	if !assert.Nil(t, store.SaveService(svc)) {
+		return
+	}
+
+	put := func(secret, key string, v core.Version) (code int, replayed string, err error) {
+		err = NewBearerClient(server.Connect(), secret).Call(
+			client.BuildRequest(
+				client.Put("/v1/versions"),
+				client.WithHeader(IdempotencyKeyHeader, key),
+				client.WithStruct(enc.Json, v)),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				resp.ReadHeader(IdempotentReplayHeader, &replayed)
+				return nil
+			})
+		return
+	}
+
+	// Both tokens share a name, but not a subject.
+	alice, bob := newToken("ci"), newToken("ci")
+	v2 := core.NewVersion(svc.Id, "v2")
+
+	if !t.Run("SameKey_DifferentPrincipals", func(t *testing.T) {
+		code, _, err := put(alice, "shared", core.NewVersion(svc.Id, "v1"))
+		if !assert.Nil(t, err) || !assert.Equal(t, 200, code) {
+			return
+		}
+
+		code, replayed, err := put(bob, "shared", v2)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 200, code)
+		assert.Equal(t, "", replayed)
+	}) {
+		return
+	}
+
+	if !t.Run("Replay_SamePrincipal", func(t *testing.T) {
+		code, replayed, err := put(bob, "shared", v2)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 200, code)
+		assert.Equal(t, "true", replayed)
+	}) {
+		return
+	}
+}
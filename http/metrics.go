@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/services-catalog/metrics"
+)
+
+const (
+	MetricsRegistryKey = "metrics.registry"
+)
+
+// Uses the dependency injector to retrieve the metrics registry
+func getMetricsRegistry(env http.Environment) (ret *metrics.Registry) {
+	env.Assign(MetricsRegistryKey, &ret)
+	return
+}
+
+// Returns a middleware that counts requests and records their latency, by
+// method, route and status code.  The latency of a streamed response only
+// covers the time until the stream begins.
+//
+// In order to count the requests rejected by other middleware (e.g. by
+// authentication), this should be the last middleware installed.
+func NewMetricsMiddleware(reg *metrics.Registry) http.Middleware {
+	requests := reg.Counter("catalog_http_requests_total",
+		"The number of requests served", "method", "route", "code")
+	latency := reg.Histogram("catalog_http_request_duration_seconds",
+		"The latency of requests", metrics.DefaultBuckets, "method", "route", "code")
+
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) http.Response {
+			start, method, route := time.Now(), req.Method(), routeOf(req)
+
+			resp := h(env, req)
+			if resp == nil {
+				resp = http.StatusOK
+			}
+
+			return func(b http.ResponseBuilder) (err error) {
+				rec := &codeRecorder{b, 200}
+				if err = resp(rec); err != nil {
+					rec.code = 500
+				}
+
+				code := strconv.Itoa(rec.code)
+				requests.Inc(method, route, code)
+				latency.Observe(time.Since(start).Seconds(), method, route, code)
+				return
+			}
+		}
+	}
+}
+
+// Requests are labelled by the template of their route (e.g.
+// /v1/services/{id}) rather than their path, so that the number of series
+// is bounded.  Every route is documented, so the templates are those of the
+// documented operations.
+func routeOf(req http.Request) string {
+	method, segments := req.Method(), strings.Split(req.URL().EscapedPath(), "/")
+	for _, op := range operations {
+		if op.Route.Method == method && matchesTemplate(op.Route.Path, segments) {
+			return op.Route.Path
+		}
+	}
+	return "unknown"
+}
+
+func matchesTemplate(template string, segments []string) bool {
+	parts := strings.Split(template, "/")
+	if len(parts) != len(segments) {
+		return false
+	}
+	for i, p := range parts {
+		if !strings.HasPrefix(p, "{") && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Captures the status code of a response.
+type codeRecorder struct {
+	http.ResponseBuilder
+	code int
+}
+
+func (r *codeRecorder) SetCode(code int) {
+	r.code = code
+	r.ResponseBuilder.SetCode(code)
+}
+
+// Register the metrics handlers
+func MetricsHandlers(svc *http.Service) {
+
+	register(svc, http.Get("/metrics"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			var buf bytes.Buffer
+			if err := getMetricsRegistry(env).Write(&buf); err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Reply(
+				http.WithCode(200),
+				http.WithContent(metrics.Mime, &buf))
+			return
+		})
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/metrics"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	raw, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	reg := metrics.NewRegistry()
+	store := metrics.NewStorage(raw, reg)
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers, MetricsHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithDependency(MetricsRegistryKey, reg),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))),
+		http.WithMiddleware(NewMetricsMiddleware(reg)))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	token, secret, err := core.NewToken("writer", core.ScopeWrite, 0)
+	if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+		return
+	}
+
+	transport := NewClient(NewBearerClient(server.Connect(), secret), enc.Json)
+
+	svc, err := transport.SaveService(core.NewService("name", "desc"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !t.Run("Requests", func(t *testing.T) {
+		_, err := transport.GetService(svc.Id)
+		assert.Nil(t, err)
+
+		_, err = NewClient(server.Connect(), enc.Json).GetService(svc.Id)
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	if !t.Run("Scrape", func(t *testing.T) {
+		var typ string
+		var body []byte
+		err := NewBearerClient(server.Connect(), secret).Call(
+			client.Get("/metrics"),
+			func(resp client.Response) error {
+				resp.ReadHeader(headers.ContentType, &typ)
+				return client.ExpectAll(
+					client.ExpectCode(200),
+					func(resp client.Response) error {
+						return resp.ReadBody(&body)
+					})(resp)
+			})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		out := string(body)
+		assert.True(t, strings.HasPrefix(typ, metrics.Mime))
+		assert.Contains(t, out, `catalog_http_requests_total{method="PUT",route="/v1/services",code="200"} 1`)
+		assert.Contains(t, out, `catalog_http_requests_total{method="GET",route="/v1/services/{id}",code="200"} 1`)
+		assert.Contains(t, out, `catalog_http_requests_total{method="GET",route="/v1/services/{id}",code="401"} 1`)
+		assert.Contains(t, out, `catalog_http_request_duration_seconds_count{method="GET",route="/v1/services/{id}",code="200"} 1`)
+		assert.Contains(t, out, `catalog_storage_duration_seconds_count{method="SaveService"} 1`)
+		assert.Contains(t, out, "catalog_services 1\n")
+	}) {
+		return
+	}
+}
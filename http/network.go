@@ -0,0 +1,148 @@
+package http
+
+import (
+	"crypto/tls"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/net"
+)
+
+// A network serves requests over tcp, optionally with TLS, and enforces the
+// read and write timeouts of its connections.  The TLS configuration and
+// the timeouts may be changed while the network is serving.  New TLS
+// configurations apply to new connections, and new timeouts apply to the
+// next request of every connection.  Whether TLS is enabled is fixed once
+// the network begins listening.
+//
+// The read timeout bounds the time a client has to send a request, and the
+// write timeout the time the server has to write its response.  As with
+// the standard library's server, a handler that outlasts the read timeout
+// has its connection closed after it responds, and the write timeout also
+// bounds streamed responses.
+type Network struct {
+	tls     atomic.Value // *tls.Config
+	read    int64
+	write   int64
+	drainer *Drainer
+}
+
+// Returns a network that serves TLS if the configuration is non-nil.
+func NewNetwork(config *tls.Config) *Network {
+	ret := &Network{drainer: NewDrainer()}
+	ret.tls.Store(config)
+	return ret
+}
+
+// Returns the drainer of the connections accepted by the network.
+func (n *Network) Drainer() *Drainer {
+	return n.drainer
+}
+
+// Returns the current TLS configuration, or nil if it's plaintext.
+func (n *Network) TLS() *tls.Config {
+	return n.tls.Load().(*tls.Config)
+}
+
+func (n *Network) SetTLS(config *tls.Config) {
+	n.tls.Store(config)
+}
+
+// Sets the read and write timeouts.  A zero timeout is unlimited.
+func (n *Network) SetTimeouts(read, write time.Duration) {
+	atomic.StoreInt64(&n.read, int64(read))
+	atomic.StoreInt64(&n.write, int64(write))
+}
+
+func (n *Network) timeouts() (read, write time.Duration) {
+	return time.Duration(atomic.LoadInt64(&n.read)), time.Duration(atomic.LoadInt64(&n.write))
+}
+
+// Dials over plain tcp.  The network only implements TLS for servers.
+func (n *Network) Dial(timeout time.Duration, addr string) (net.Connection, error) {
+	return net.DialTCP4(timeout, addr)
+}
+
+func (n *Network) Listen(addr string) (ret net.Listener, err error) {
+	raw, err := net.ListenTCP4(addr)
+	if err != nil {
+		return
+	}
+
+	ret = &listener{raw, n, n.TLS() != nil}
+	return
+}
+
+type listener struct {
+	net.Listener
+	net    *Network
+	secure bool
+}
+
+func (l *listener) Network() net.Network {
+	return l.net
+}
+
+func (l *listener) Connect(timeout time.Duration) (net.Connection, error) {
+	return l.net.Dial(timeout, l.Address().String())
+}
+
+// Connections are handed to the server before their TLS handshake, which
+// happens on the first read and so is bounded by the read timeout.
+func (l *listener) Accept() (ret net.Connection, err error) {
+	raw, err := l.Listener.Accept()
+	if err != nil {
+		return
+	}
+
+	ret = &timedConnection{Connection: l.net.drainer.track(raw), net: l.net, reading: 1}
+	if l.secure {
+		ret = tls.Server(ret, &tls.Config{
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return l.net.TLS(), nil
+			},
+		})
+	}
+	return
+}
+
+// A timed connection arms its read deadline on the first read of every
+// request, and its write deadline on the first write of every response.
+// Deadlines are only armed at those boundaries, since the server cancels
+// its own reads by setting a deadline, which must not be overridden.
+type timedConnection struct {
+	net.Connection
+	net     *Network
+	reading int32 // set until the first read of the next request
+	writing int32 // set until the first write of the next response
+}
+
+func (c *timedConnection) Read(p []byte) (int, error) {
+	if atomic.CompareAndSwapInt32(&c.reading, 1, 0) {
+		atomic.StoreInt32(&c.writing, 1)
+
+		var deadline time.Time
+		if read, _ := c.net.timeouts(); read > 0 {
+			deadline = time.Now().Add(read)
+		}
+		if err := c.SetReadDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+	return c.Connection.Read(p)
+}
+
+func (c *timedConnection) Write(p []byte) (int, error) {
+	if atomic.CompareAndSwapInt32(&c.writing, 1, 0) {
+		atomic.StoreInt32(&c.reading, 1)
+
+		var deadline time.Time
+		if _, write := c.net.timeouts(); write > 0 {
+			deadline = time.Now().Add(write)
+		}
+		if err := c.SetWriteDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+	return c.Connection.Write(p)
+}
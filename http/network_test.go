@@ -0,0 +1,175 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	gonet "net"
+	gohttp "net/http"
+	"os"
+	"testing"
+	"time"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/services-catalog/tlstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetwork(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	ca := tlstest.NewCertificate(t, nil)
+	first, second := tlstest.NewCertificate(t, &ca), tlstest.NewCertificate(t, &ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	network := NewNetwork(&tls.Config{Certificates: []tls.Certificate{first}})
+	server, err := http.Serve(ctx,
+		http.Build(HealthHandlers),
+		http.WithListener(network, ":0"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	port := server.Address().(*gonet.TCPAddr).Port
+
+	// Returns the certificate presented by the server, if the request
+	// succeeded.  Every request is made over a new connection.
+	get := func(certs ...tls.Certificate) (*x509.Certificate, error) {
+		client := &gohttp.Client{
+			Transport: &gohttp.Transport{
+				DisableKeepAlives: true,
+				TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certs},
+			},
+		}
+
+		resp, err := client.Get(fmt.Sprintf("https://localhost:%v/healthz", port))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Unexpected status [%v]", resp.StatusCode)
+		}
+		return resp.TLS.PeerCertificates[0], nil
+	}
+
+	if !t.Run("TLS", func(t *testing.T) {
+		cert, err := get()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, first.Leaf.SerialNumber, cert.SerialNumber)
+
+		// Plaintext requests are rejected by the TLS server.
+		resp, err := gohttp.Get(fmt.Sprintf("http://localhost:%v/healthz", port))
+		if !assert.Nil(t, err) {
+			return
+		}
+		resp.Body.Close()
+		assert.Equal(t, 400, resp.StatusCode)
+	}) {
+		return
+	}
+
+	if !t.Run("SetTLS", func(t *testing.T) {
+		network.SetTLS(&tls.Config{Certificates: []tls.Certificate{second}})
+
+		cert, err := get()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, second.Leaf.SerialNumber, cert.SerialNumber)
+	}) {
+		return
+	}
+
+	if !t.Run("SetTLS_ClientCA", func(t *testing.T) {
+		network.SetTLS(&tls.Config{
+			Certificates: []tls.Certificate{second},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})
+
+		_, err := get()
+		assert.NotNil(t, err)
+
+		_, err = get(tlstest.NewCertificate(t, nil))
+		assert.NotNil(t, err)
+
+		_, err = get(first)
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+}
+
+func TestNetwork_Timeouts(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	network := NewNetwork(nil)
+	network.SetTimeouts(100*time.Millisecond, 0)
+
+	server, err := http.Serve(ctx,
+		http.Build(HealthHandlers),
+		http.WithListener(network, ":0"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer server.Close()
+
+	addr := fmt.Sprintf("localhost:%v", server.Address().(*gonet.TCPAddr).Port)
+
+	if !t.Run("Plaintext", func(t *testing.T) {
+		resp, err := gohttp.Get("http://" + addr + "/healthz")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+	}) {
+		return
+	}
+
+	if !t.Run("Read_Idle", func(t *testing.T) {
+		conn, err := gonet.Dial("tcp4", addr)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		// The server closes the connection once the client has failed to
+		// send a request within the read timeout.
+		start := time.Now()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = ioutil.ReadAll(conn)
+		assert.Nil(t, err)
+		assert.True(t, time.Since(start) < 5*time.Second)
+	}) {
+		return
+	}
+
+	if !t.Run("Read_KeepAlive", func(t *testing.T) {
+		client := &gohttp.Client{Transport: &gohttp.Transport{}}
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get("http://" + addr + "/healthz")
+			if !assert.Nil(t, err) {
+				return
+			}
+			resp.Body.Close()
+			assert.Equal(t, 200, resp.StatusCode)
+
+			// Each request of the connection has its own deadline
+			time.Sleep(60 * time.Millisecond)
+		}
+	}) {
+		return
+	}
+}
@@ -0,0 +1,609 @@
+package http
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/mime"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/health"
+	"github.com/pkopriv2/services-catalog/metrics"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	OpenAPIVersion = "3.0.3"
+)
+
+// The server doesn't expose its routes, so every operation served by the
+// handlers is described here.  The document is generated from these, and
+// the schemas are derived from the core types by reflection, so they can't
+// drift from the wire format.  Handlers register their routes through
+// register, which records them, and the tests verify that the registered
+// routes are exactly those described here, and that handlers read exactly
+// the parameters described here.
+type operation struct {
+	Route     http.Route
+	Summary   string
+	Params    []parameter
+	Body      interface{} // an example of the request body, if any
+	Responses []response
+}
+
+type parameter struct {
+	Name     string
+	In       string
+	Example  interface{} // an example of the parameter's type
+	Required bool
+	Desc     string
+}
+
+type response struct {
+	Code int
+	Desc string
+	Mime string
+	Body interface{} // an example of the response body (or its schema), if any
+}
+
+func pathParam(name string, example interface{}, desc string) parameter {
+	return parameter{name, "path", example, true, desc}
+}
+
+func queryParam(name string, example interface{}, desc string) parameter {
+	return parameter{name, "query", example, false, desc}
+}
+
+func headerParam(name string, desc string) parameter {
+	return parameter{name, "header", "", false, desc}
+}
+
+func okResponse(body interface{}) response {
+	return response{200, "Ok", mime.Json, body}
+}
+
+func errorResponse(code int, desc string) response {
+	return response{code, desc, mime.Json, Error{}}
+}
+
+var (
+	idParam         = pathParam("id", uuid.UUID{}, "The id of the service")
+	offsetParam     = queryParam("offset", uint64(0), "The offset of the page")
+	limitParam      = queryParam("limit", uint64(0), "The size of the page. Must be <= 1024")
+	ifMatchParam    = headerParam(headers.IfMatch, "The etag of the revision being replaced")
+	idempotencyKey  = headerParam(IdempotencyKeyHeader, "Makes the write safe to retry")
+	transferIdParam = pathParam("id", uuid.UUID{}, "The id of the transfer")
+
+	invalid         = errorResponse(400, "Invalid request")
+	noService       = errorResponse(404, "No such service")
+	noTransfer      = errorResponse(404, "No such transfer")
+	conflict        = errorResponse(409, "Conflicting write")
+	precondition    = errorResponse(412, "Etag does not match the latest revision")
+	unauthenticated = errorResponse(401, "Missing, invalid or expired bearer token")
+	forbidden       = errorResponse(403, "The token lacks the required scope, or its principal may not modify the service")
+	tooManyRequests = errorResponse(429, "Too many requests. Retry after the delay in the Retry-After header")
+	internal        = errorResponse(500, "Internal error")
+)
+
+var operations = []operation{
+	{
+		Route:     http.Put("/v1/services"),
+		Summary:   "Creates a service, or updates it when it has a version",
+		Params:    []parameter{ifMatchParam, idempotencyKey},
+		Body:      core.Service{},
+		Responses: []response{okResponse(core.Service{}), invalid, conflict, precondition, internal},
+	},
+	{
+		Route:   http.Post("/v1/services"),
+		Summary: "Creates a service",
+		Params:  []parameter{idempotencyKey},
+		Body:    core.Service{},
+		Responses: []response{
+			{201, "Created", mime.Json, core.Service{}}, invalid, conflict, internal},
+	},
+	{
+		Route:   http.Get("/v1/services"),
+		Summary: "Lists the latest revisions of services and their versions",
+		Params: []parameter{
+			queryParam("name", "", "Filters by services whose names contain the value"),
+			queryParam("desc", "", "Filters by services whose descriptions contain the value"),
+			queryParam("id", uuid.UUID{}, "Filters by service id"),
+			offsetParam,
+			limitParam,
+			queryParam("order", "", "The field to order by"),
+		},
+		Responses: []response{okResponse(core.Catalog{}), invalid, internal},
+	},
+	{
+		Route:     http.Get("/v1/services/{id}"),
+		Summary:   "Returns the latest revision of a service",
+		Params:    []parameter{idParam},
+		Responses: []response{okResponse(core.Service{}), invalid, noService, internal},
+	},
+	{
+		Route:     http.Put("/v1/services/{id}"),
+		Summary:   "Replaces a service with a new revision",
+		Params:    []parameter{idParam, ifMatchParam, idempotencyKey},
+		Body:      core.Service{},
+		Responses: []response{okResponse(core.Service{}), invalid, noService, conflict, precondition, internal},
+	},
+	{
+		Route:     http.Get("/v1/services/{id}/versions"),
+		Summary:   "Lists the versions of a service",
+		Params:    []parameter{idParam},
+		Responses: []response{okResponse([]core.Version{}), invalid, noService, internal},
+	},
+	{
+		Route:   http.Get("/v1/services/{id}/versions/{name}"),
+		Summary: "Returns a version of a service",
+		Params:  []parameter{idParam, pathParam("name", "", "The name of the version")},
+		Responses: []response{
+			okResponse(core.Version{}), invalid, errorResponse(404, "No such service or version"), internal},
+	},
+	{
+		Route:     http.Put("/v1/versions"),
+		Summary:   "Adds a version to a service",
+		Params:    []parameter{idempotencyKey},
+		Body:      core.Version{},
+		Responses: []response{okResponse(core.Version{}), invalid, noService, conflict, internal},
+	},
+	{
+		Route:     http.Post("/v1/batch"),
+		Summary:   "Applies a list of writes atomically",
+		Params:    []parameter{idempotencyKey},
+		Body:      []core.Write{},
+		Responses: []response{okResponse([]core.WriteResult{}), invalid, errorResponse(422, "Aborted batch"), internal},
+	},
+	{
+		Route:     http.Post("/v1/services/{id}/transfers"),
+		Summary:   "Requests the transfer of a service to another team",
+		Params:    []parameter{idParam, idempotencyKey},
+		Body:      TransferRequest{},
+		Responses: []response{{201, "Created", mime.Json, core.Transfer{}}, invalid, noService, conflict, internal},
+	},
+	{
+		Route:     http.Get("/v1/services/{id}/transfers"),
+		Summary:   "Lists the transfers of a service",
+		Params:    []parameter{idParam},
+		Responses: []response{okResponse([]core.Transfer{}), invalid, internal},
+	},
+	{
+		Route:     http.Post("/v1/transfers/{id}/approve"),
+		Summary:   "Approves a transfer on behalf of the team that has yet to consent",
+		Params:    []parameter{transferIdParam, idempotencyKey},
+		Responses: []response{okResponse(core.Transfer{}), invalid, noTransfer, conflict, internal},
+	},
+	{
+		Route:     http.Delete("/v1/transfers/{id}"),
+		Summary:   "Cancels a pending transfer",
+		Params:    []parameter{transferIdParam, idempotencyKey},
+		Responses: []response{{204, "Deleted", "", nil}, invalid, noTransfer, internal},
+	},
+	{
+		Route:   http.Get("/v1/changes"),
+		Summary: "Lists the changes following a sequence",
+		Params: []parameter{
+			queryParam("since", uint64(0), "The sequence of the last change read"),
+			limitParam,
+		},
+		Responses: []response{okResponse([]core.Change{}), invalid, internal},
+	},
+	{
+		Route:   http.Get("/v1/watch"),
+		Summary: "Streams changes as server-sent events",
+		Params: []parameter{
+			queryParam("name", "", "Filters by services whose names contain the value"),
+			queryParam("desc", "", "Filters by services whose descriptions contain the value"),
+			queryParam("id", uuid.UUID{}, "Filters by service id"),
+			queryParam("since", uint64(0), "The sequence of the last change read. Zero starts from the end of the log, and 18446744073709551615 from the beginning"),
+			headerParam(LastEventId, "The sequence of the last change read. Overrides since"),
+		},
+		Responses: []response{{200, "Ok", EventStream, &Schema{Type: "string"}}, invalid, internal},
+	},
+	{
+		Route:     http.Get("/v1/export"),
+		Summary:   "Exports a snapshot of the catalog",
+		Params:    []parameter{queryParam("history", false, "Includes every revision of each service")},
+		Responses: []response{okResponse(core.Snapshot{}), invalid, internal},
+	},
+	{
+		Route:   http.Post("/v1/import"),
+		Summary: "Imports a snapshot of the catalog",
+		Params: []parameter{
+			queryParam("mode", "", "One of merge, replace or skip-existing"),
+			idempotencyKey,
+		},
+		Body:      core.Snapshot{},
+		Responses: []response{okResponse(core.ImportResult{}), invalid, internal},
+	},
+	{
+		Route:     http.Get("/v1/admin/backup"),
+		Summary:   "Downloads a backup of the database",
+		Responses: []response{{200, "Ok", Sqlite, &Schema{Type: "string", Format: "binary"}}, internal},
+	},
+	{
+		Route:     http.Put("/v1/webhooks"),
+		Summary:   "Creates a webhook, or replaces it when it has an id",
+		Params:    []parameter{idempotencyKey},
+		Body:      core.Webhook{},
+		Responses: []response{okResponse(core.Webhook{}), invalid, errorResponse(404, "No such webhook"), internal},
+	},
+	{
+		Route:     http.Get("/v1/webhooks"),
+		Summary:   "Lists webhooks",
+		Responses: []response{okResponse([]core.Webhook{}), invalid, internal},
+	},
+	{
+		Route:     http.Get("/v1/webhooks/{id}"),
+		Summary:   "Returns a webhook",
+		Params:    []parameter{pathParam("id", uuid.UUID{}, "The id of the webhook")},
+		Responses: []response{okResponse(core.Webhook{}), invalid, errorResponse(404, "No such webhook"), internal},
+	},
+	{
+		Route:     http.Delete("/v1/webhooks/{id}"),
+		Summary:   "Deletes a webhook",
+		Params:    []parameter{pathParam("id", uuid.UUID{}, "The id of the webhook"), idempotencyKey},
+		Responses: []response{{204, "Deleted", "", nil}, invalid, errorResponse(404, "No such webhook"), internal},
+	},
+	{
+		Route:   http.Get("/v1/deliveries"),
+		Summary: "Lists webhook deliveries",
+		Params: []parameter{
+			queryParam("webhook", uuid.UUID{}, "Filters by webhook id"),
+			queryParam("status", "", "Filters by status. One of pending, delivered or dead"),
+			offsetParam,
+			limitParam,
+		},
+		Responses: []response{okResponse([]core.Delivery{}), invalid, internal},
+	},
+	{
+		Route:     http.Get("/v1/deliveries/{id}/attempts"),
+		Summary:   "Lists the attempts of a delivery",
+		Params:    []parameter{pathParam("id", uuid.UUID{}, "The id of the delivery")},
+		Responses: []response{okResponse([]core.Attempt{}), invalid, internal},
+	},
+	{
+		Route:   http.Get("/v1/audit"),
+		Summary: "Lists the audit log of writes to the catalog",
+		Params: []parameter{
+			queryParam("service", uuid.UUID{}, "Filters by service id"),
+			queryParam("actor", "", "Filters by the subject of the actor"),
+			queryParam("since", time.Time{}, "Filters by entries created at or after the time"),
+			offsetParam,
+			limitParam,
+		},
+		Responses: []response{okResponse([]core.AuditEntry{}), invalid, internal},
+	},
+	{
+		Route:   http.Post("/v1/graphql"),
+		Summary: "Executes a GraphQL query or mutation over services and versions",
+		Body:    GraphQLRequest{},
+		Responses: []response{
+			okResponse(GraphQLResponse{}),
+			{400, "Invalid query, or the query exceeds the depth or complexity limits", mime.Json, GraphQLResponse{}}},
+	},
+	{
+		Route:     http.Get("/metrics"),
+		Summary:   "Returns metrics in the Prometheus text format",
+		Responses: []response{{200, "Ok", metrics.Mime, &Schema{Type: "string"}}, internal},
+	},
+	{
+		Route:     http.Get("/healthz"),
+		Summary:   "Reports that the process is alive",
+		Responses: []response{okResponse(health.Report{})},
+	},
+	{
+		Route:     http.Get("/readyz"),
+		Summary:   "Reports whether the instance is ready to serve traffic, by check",
+		Responses: []response{okResponse(health.Report{}), {503, "A check failed", mime.Json, health.Report{}}},
+	},
+	{
+		Route:     http.Get("/v1/openapi.json"),
+		Summary:   "Returns this document",
+		Responses: []response{{200, "Ok", mime.Json, map[string]interface{}{}}},
+	},
+}
+
+// These are documented even though no operation refers to them, since
+// they describe the query parameters.
+var documentedTypes = []interface{}{
+	core.Service{},
+	core.Version{},
+	core.Catalog{},
+	core.Filter{},
+	core.Page{},
+}
+
+// The routes registered by the handlers, across every server.
+var routes = struct {
+	sync.Mutex
+	all map[http.Route]struct{}
+}{all: make(map[http.Route]struct{})}
+
+// Registers the handler of a route, recording the route.  Every handler
+// should be registered by this, rather than by the service.
+func register(svc *http.Service, route http.Route, fn http.Handler) {
+	routes.Lock()
+	routes.all[route] = struct{}{}
+	routes.Unlock()
+
+	svc.Register(route, fn)
+}
+
+// Returns the routes that have been registered, ordered by path and method.
+func registeredRoutes() (ret []http.Route) {
+	routes.Lock()
+	defer routes.Unlock()
+
+	for r := range routes.all {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Path != ret[j].Path {
+			return ret[i].Path < ret[j].Path
+		}
+		return ret[i].Method < ret[j].Method
+	})
+	return
+}
+
+// Register the handler that serves the OpenAPI document
+func OpenAPIHandlers(svc *http.Service) {
+	doc := OpenAPI()
+
+	register(svc, http.Get("/v1/openapi.json"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			ret = http.Ok(enc.Json, doc)
+			return
+		})
+}
+
+// An OpenAPI 3 document.  Only the parts of the specification used by the
+// catalog are modeled.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+	Security   []SecurityRequirement           `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// Maps the names of security schemes to their required scopes.
+type SecurityRequirement map[string][]string
+
+type Operation struct {
+	Summary     string                 `json:"summary"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Generates the OpenAPI document of the catalog api.
+func OpenAPI() (ret Document) {
+	ret = Document{
+		OpenAPI: OpenAPIVersion,
+		Info:    Info{Title: "Services Catalog", Version: "v1"},
+		Paths:   make(map[string]map[string]Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+			},
+		},
+		Security: []SecurityRequirement{{"bearer": {}}},
+	}
+
+	schemas := ret.Components.Schemas
+	for _, t := range documentedTypes {
+		schemaOf(reflect.TypeOf(t), schemas)
+	}
+
+	for _, op := range operations {
+		item, ok := ret.Paths[op.Route.Path]
+		if !ok {
+			item = make(map[string]Operation)
+			ret.Paths[op.Route.Path] = item
+		}
+
+		doc := Operation{
+			Summary:   op.Summary,
+			Responses: make(map[string]Response),
+		}
+		for _, p := range op.Params {
+			doc.Parameters = append(doc.Parameters, Parameter{
+				Name:        p.Name,
+				In:          p.In,
+				Description: p.Desc,
+				Required:    p.Required,
+				Schema:      schemaOf(reflect.TypeOf(p.Example), schemas),
+			})
+		}
+		if op.Body != nil {
+			doc.RequestBody = &RequestBody{
+				Required: true,
+				Content:  content(reflect.TypeOf(op.Body), schemas),
+			}
+		}
+
+		// Every operation but the public ones requires a token, and every
+		// operation is rate limited.
+		responses := append([]response{}, op.Responses...)
+		if isPublic(op.Route) {
+			doc.Security = &[]SecurityRequirement{}
+		} else {
+			responses = append(responses, unauthenticated, forbidden)
+		}
+		responses = append(responses, tooManyRequests)
+
+		// Operations may list several reasons for the same code.
+		descs := make(map[int][]string)
+		for _, r := range responses {
+			descs[r.Code] = append(descs[r.Code], r.Desc)
+
+			resp := Response{Description: strings.Join(descs[r.Code], ", ")}
+			if r.Mime != "" {
+				resp.Content = map[string]MediaType{
+					r.Mime: {bodySchema(r.Body, schemas)},
+				}
+			}
+			doc.Responses[strconv.Itoa(r.Code)] = resp
+		}
+
+		item[strings.ToLower(op.Route.Method)] = doc
+	}
+	return
+}
+
+// Bodies may be encoded in any of the supported encodings.
+func content(t reflect.Type, schemas map[string]*Schema) map[string]MediaType {
+	schema := schemaOf(t, schemas)
+	return map[string]MediaType{
+		mime.Json: {schema},
+		mime.Yaml: {schema},
+	}
+}
+
+// Bodies that aren't encoded structs (e.g. streams) are described by their
+// schema directly.
+func bodySchema(body interface{}, schemas map[string]*Schema) *Schema {
+	if schema, ok := body.(*Schema); ok {
+		return schema
+	}
+	return schemaOf(reflect.TypeOf(body), schemas)
+}
+
+var (
+	uuidType     = reflect.TypeOf(uuid.UUID{})
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	rawType      = reflect.TypeOf(json.RawMessage{})
+	bytesType    = reflect.TypeOf([]byte{})
+)
+
+// Returns the schema of the type, as it is encoded by encoding/json.  Named
+// structs are added to the components, and referred to by name.
+func schemaOf(t reflect.Type, schemas map[string]*Schema) *Schema {
+	switch t {
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case rawType:
+		return &Schema{}
+	case bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+
+		// Registered before the fields are visited, in case the type
+		// refers to itself.
+		ret := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		schemas[t.Name()] = ret
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+
+			name, opts := f.Name, ""
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				if parts := strings.SplitN(tag, ",", 2); parts[0] != "" {
+					name = parts[0]
+				}
+				if i := strings.Index(tag, ","); i >= 0 {
+					opts = tag[i+1:]
+				}
+			}
+
+			ret.Properties[name] = schemaOf(f.Type, schemas)
+			if f.Type.Kind() != reflect.Ptr && !strings.Contains(opts, "omitempty") {
+				ret.Required = append(ret.Required, name)
+			}
+		}
+		sort.Strings(ret.Required)
+		return ref
+	}
+	return &Schema{}
+}
@@ -0,0 +1,280 @@
+package http
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/health"
+	"github.com/pkopriv2/services-catalog/metrics"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the parameters read by handlers, keyed by the request line.
+type paramRecorder struct {
+	lock  sync.Mutex
+	reads map[string]map[string]bool
+}
+
+func (r *paramRecorder) record(req http.Request, param string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := fmt.Sprintf("%v %v", req.Method(), req.URL().Path)
+	if r.reads[key] == nil {
+		r.reads[key] = make(map[string]bool)
+	}
+	r.reads[key][param] = true
+}
+
+func (r *paramRecorder) Read(method, path string) map[string]bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.reads[fmt.Sprintf("%v %v", method, path)]
+}
+
+func (r *paramRecorder) Middleware(h http.Handler) http.Handler {
+	return func(env http.Environment, req http.Request) http.Response {
+		r.record(req, "served")
+		return h(env, &recordingRequest{req, r})
+	}
+}
+
+type recordingRequest struct {
+	http.Request
+	recorder *paramRecorder
+}
+
+func (r *recordingRequest) Read(p []byte) (int, error) {
+	r.recorder.record(r.Request, "body")
+	return r.Request.Read(p)
+}
+
+func (r *recordingRequest) ReadBody(ptr *[]byte) error {
+	r.recorder.record(r.Request, "body")
+	return r.Request.ReadBody(ptr)
+}
+
+func (r *recordingRequest) ReadHeader(name string, ptr *string) bool {
+	r.recorder.record(r.Request, "header:"+strings.ToLower(name))
+	return r.Request.ReadHeader(name, ptr)
+}
+
+func (r *recordingRequest) ReadPathParam(name string, ptr *string) (bool, error) {
+	r.recorder.record(r.Request, "path:"+name)
+	return r.Request.ReadPathParam(name, ptr)
+}
+
+func (r *recordingRequest) ReadQueryParam(name string, ptr *string) (bool, error) {
+	r.recorder.record(r.Request, "query:"+name)
+	return r.Request.ReadQueryParam(name, ptr)
+}
+
+// Content negotiation is described by the content of bodies, idempotency
+// keys are read by middleware rather than handlers, and request ids are
+// accepted by every operation.
+var negotiated = map[string]bool{
+	"header:" + strings.ToLower(headers.Accept):       true,
+	"header:" + strings.ToLower(headers.ContentType):  true,
+	"header:" + strings.ToLower(IdempotencyKeyHeader): true,
+	"header:" + strings.ToLower(RequestIdHeader):      true,
+}
+
+func TestOpenAPI(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	hooks, err := sqlsvc.NewSqlWebhookStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	transfers, err := sqlsvc.NewSqlTransferStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	audit, err := sqlsvc.NewSqlAuditStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	backups, err := sqlsvc.NewSqlBackupStore(db)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	recorder := &paramRecorder{reads: make(map[string]map[string]bool)}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers, WebhookHandlers, TransferHandlers, AuditHandlers, AdminHandlers, GraphQLHandlers, MetricsHandlers, HealthHandlers, OpenAPIHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithDependency(WebhookStorageKey, hooks),
+		http.WithDependency(TransferStorageKey, transfers),
+		http.WithDependency(AuditStorageKey, audit),
+		http.WithDependency(BackupStorageKey, backups),
+		http.WithDependency(MetricsRegistryKey, metrics.NewRegistry()),
+		http.WithDependency(HealthRegistryKey, health.NewRegistry()),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(recorder.Middleware))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !t.Run("Document", func(t *testing.T) {
+		var doc Document
+		err := server.Connect().Call(
+			client.BuildRequest(
+				client.Get("/v1/openapi.json")),
+			client.ExpectAll(
+				client.ExpectCode(200),
+				client.ExpectStruct(enc.DefaultRegistry, &doc)))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, OpenAPIVersion, doc.OpenAPI)
+		for _, op := range operations {
+			assert.Contains(t, doc.Paths[op.Route.Path], strings.ToLower(op.Route.Method))
+		}
+		for _, name := range []string{"Service", "Version", "Catalog", "Filter", "Page", "Error"} {
+			assert.Contains(t, doc.Components.Schemas, name)
+		}
+
+		svc := doc.Components.Schemas["Service"]
+		if !assert.NotNil(t, svc) {
+			return
+		}
+		assert.Equal(t, []string{"desc", "name"}, svc.Required)
+		assert.Equal(t, "uuid", svc.Properties["id"].Format)
+		assert.Equal(t, "#/components/schemas/Version",
+			doc.Components.Schemas["Catalog"].Properties["versions"].AdditionalProperties.Items.Ref)
+
+		assert.Equal(t, "bearer", doc.Components.SecuritySchemes["bearer"].Scheme)
+		assert.Contains(t, doc.Paths["/v1/services"]["get"].Responses, "401")
+		if public := doc.Paths["/v1/openapi.json"]["get"]; assert.NotNil(t, public.Security) {
+			assert.Empty(t, *public.Security)
+		}
+	}) {
+		return
+	}
+
+	// Every handler was registered above, so the routes registered must be
+	// exactly the documented operations.
+	if !t.Run("Routes", func(t *testing.T) {
+		documented := []string{}
+		for _, op := range operations {
+			documented = append(documented, op.Route.Method+" "+op.Route.Path)
+		}
+		sort.Strings(documented)
+
+		registered := []string{}
+		for _, r := range registeredRoutes() {
+			registered = append(registered, r.Method+" "+r.Path)
+		}
+		sort.Strings(registered)
+
+		assert.Equal(t, documented, registered)
+	}) {
+		return
+	}
+
+	// Every documented operation is called with its parameters filled
+	// in, and the handler must read exactly the documented parameters.
+	if !t.Run("Drift", func(t *testing.T) {
+		for _, op := range operations {
+			expected := make(map[string]bool)
+			if op.Body != nil {
+				expected["body"] = true
+			}
+
+			path := op.Route.Path
+			for _, p := range op.Params {
+				switch p.In {
+				case "path":
+					val := "name"
+					if _, ok := p.Example.(uuid.UUID); ok {
+						val = uuid.NewV1().String()
+					}
+					path = strings.Replace(path, "{"+p.Name+"}", val, 1)
+					expected["path:"+p.Name] = true
+				case "header":
+					expected["header:"+strings.ToLower(p.Name)] = true
+				default:
+					expected[p.In+":"+p.Name] = true
+				}
+			}
+
+			req := []client.Request{
+				client.WithMethod(op.Route.Method),
+				client.WithPath(path),
+			}
+			if op.Body != nil {
+				req = append(req, client.WithStruct(enc.Json, op.Body))
+			}
+
+			err := server.Connect().Call(client.BuildRequest(req...),
+				func(client.Response) error {
+					return nil
+				})
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			actual := make(map[string]bool)
+			for param := range recorder.Read(op.Route.Method, path) {
+				if !negotiated[param] {
+					actual[param] = true
+				}
+			}
+			for param := range expected {
+				if negotiated[param] {
+					delete(expected, param)
+				}
+			}
+
+			if !assert.True(t, actual["served"], "Not served: %v %v", op.Route.Method, op.Route.Path) {
+				continue
+			}
+			delete(actual, "served")
+
+			assert.Equal(t, keys(expected), keys(actual), "Parameters of [%v %v] differ from the spec",
+				op.Route.Method, op.Route.Path)
+		}
+	}) {
+		return
+	}
+}
+
+func keys(m map[string]bool) (ret []string) {
+	ret = []string{}
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+const (
+	RetryAfterHeader = "Retry-After"
+)
+
+// Returns a middleware that limits the rate of requests per client.  Reads
+// and writes are limited separately, so that a client busy reading may still
+// write.  Clients are identified by the subject of their token or, if they
+// aren't authenticated, by their ip.  Requests over the limit are rejected
+// with a 429 and a Retry-After header.
+//
+// Clients are identified by authentication, so this should be installed
+// before the auth middleware (the last middleware is the first to run).
+func NewRateLimitMiddleware(read, write core.RateLimit) http.Middleware {
+	return NewRateLimiterMiddleware(core.NewRateLimiter(read), core.NewRateLimiter(write))
+}
+
+// Returns a middleware that limits requests by the given limiters, whose
+// limits may be changed while the server is running.
+func NewRateLimiterMiddleware(reads, writes *core.RateLimiter) http.Middleware {
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) (ret http.Response) {
+			read, req, err := classify(req)
+			if err != nil {
+				return badRequest(err)
+			}
+
+			limiter := writes
+			if read {
+				limiter = reads
+			}
+
+			key := clientKey(req)
+			if ok, wait := limiter.Allow(key); !ok {
+				env.Logger().Debug("Rate limited request [client=%v,wait=%v]", key, wait)
+				return rateLimited(wait)
+			}
+			return h(env, req)
+		}
+	}
+}
+
+// Reads are requests that require no more than the read scope, save for
+// GraphQL mutations, which are authorized by their resolvers.  Operations
+// are determined by their documents, so GraphQL requests are buffered, and
+// the returned request must be used in place of the original.
+func classify(req http.Request) (read bool, ret http.Request, err error) {
+	ret = req
+	switch req.Method() {
+	case "GET", "HEAD":
+		read = true
+		return
+	}
+
+	if req.Method() == graphQLRoute.Method && req.URL().Path == graphQLRoute.Path {
+		var body []byte
+		if err = req.ReadBody(&body); err != nil {
+			return
+		}
+
+		read, ret = !isGraphQLMutation(body), &bufferedRequest{req, bytes.NewReader(body), body}
+		return
+	}
+
+	scope, required := requiredScope(req)
+	read = !required || scope == core.ScopeRead
+	return
+}
+
+func clientKey(req http.Request) string {
+	actor := actorOf(req)
+	if actor.Subject != "" {
+		return actor.Subject
+	}
+	return "ip:" + actor.ClientIp
+}
+
+// Retry-After is given in whole seconds, rounded up so that a client that
+// waits for it will find a token.
+func rateLimited(wait time.Duration) http.Response {
+	secs := int(math.Ceil(wait.Seconds()))
+	return http.Reply(
+		replyErrorWith(429, CodeRateLimited,
+			errors.Wrapf(core.ErrRateLimited, "Too many requests. Retry after [%vs]", secs), nil),
+		http.WithHeader(RetryAfterHeader, fmt.Sprintf("%v", secs)))
+}
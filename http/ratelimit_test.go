@@ -0,0 +1,180 @@
+package http
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers, GraphQLHandlers, OpenAPIHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewRateLimitMiddleware(
+			core.RateLimit{Rate: 1, Burst: 2},
+			core.RateLimit{Rate: 1, Burst: 1})),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	newToken := func(name string) string {
+		token, secret, err := core.NewToken(name, core.ScopeWrite, 0)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		return secret
+	}
+
+	alice, bob, carol := newToken("alice"), newToken("bob"), newToken("carol")
+
+	svc := core.NewService("name", "desc")
+	if !assert.Nil(t, store.SaveService(svc)) {
+		return
+	}
+
+	impatient := NewClient(NewBearerClient(server.Connect(), alice), enc.Json, WithRetries(0))
+	if !t.Run("Reads_Limited", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := impatient.GetService(svc.Id)
+			if !assert.Nil(t, err) {
+				return
+			}
+		}
+
+		_, err := impatient.GetService(svc.Id)
+		assert.True(t, errs.Is(err, core.ErrRateLimited))
+
+		var code int
+		var after string
+		err = NewBearerClient(server.Connect(), alice).Call(
+			client.BuildRequest(
+				client.Get("/v1/services/"+svc.Id.String()),
+				client.WithHeader(headers.Accept, enc.Json.Mime())),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				resp.ReadHeader(RetryAfterHeader, &after)
+				return nil
+			})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 429, code)
+		assert.Equal(t, "1", after)
+	}) {
+		return
+	}
+
+	if !t.Run("Writes_Separate", func(t *testing.T) {
+		_, err := impatient.SaveVersion(core.NewVersion(svc.Id, "1.0"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		_, err = impatient.SaveVersion(core.NewVersion(svc.Id, "1.1"))
+		assert.True(t, errs.Is(err, core.ErrRateLimited))
+	}) {
+		return
+	}
+
+	if !t.Run("Clients_Separate", func(t *testing.T) {
+		_, err := NewClient(NewBearerClient(server.Connect(), bob), enc.Json, WithRetries(0)).GetService(svc.Id)
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	// Queries are reads, while mutations spend the write budget.
+	if !t.Run("GraphQL_Mutations", func(t *testing.T) {
+		graphql := func(query string) (code int, err error) {
+			err = NewBearerClient(server.Connect(), carol).Call(
+				client.BuildRequest(
+					client.Post("/v1/graphql"),
+					client.WithStruct(enc.Json, GraphQLRequest{Query: query})),
+				func(resp client.Response) error {
+					code = resp.ReadCode()
+					return nil
+				})
+			return
+		}
+
+		var codes []int
+		for _, query := range []string{
+			`mutation { saveVersion(version: {service_id: "` + svc.Id.String() + `", name: "2.0"}) { name } }`,
+			`mutation { saveVersion(version: {service_id: "` + svc.Id.String() + `", name: "2.1"}) { name } }`,
+			`{ services { id } }`,
+		} {
+			code, err := graphql(query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			codes = append(codes, code)
+		}
+		assert.Equal(t, []int{200, 429, 200}, codes)
+	}) {
+		return
+	}
+
+	if !t.Run("Public_LimitedByIp", func(t *testing.T) {
+		var codes []int
+		for i := 0; i < 3; i++ {
+			err := server.Connect().Call(
+				client.Get("/v1/openapi.json"),
+				func(resp client.Response) error {
+					codes = append(codes, resp.ReadCode())
+					return nil
+				})
+			if !assert.Nil(t, err) {
+				return
+			}
+		}
+		assert.Equal(t, []int{200, 200, 429}, codes)
+	}) {
+		return
+	}
+
+	if !t.Run("Client_RetryAfter", func(t *testing.T) {
+		start := time.Now()
+
+		_, err := NewClient(NewBearerClient(server.Connect(), alice), enc.Json).GetService(svc.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, time.Since(start) >= 500*time.Millisecond)
+	}) {
+		return
+	}
+}
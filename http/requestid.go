@@ -0,0 +1,92 @@
+package http
+
+import (
+	"encoding/json"
+	"net/textproto"
+	"regexp"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/mime"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	RequestIdHeader = "X-Request-Id"
+)
+
+// Request ids end up in logs, so those sent by clients are only accepted if
+// they're reasonably sized and plainly printable.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Returns a middleware that assigns every request an id.  The id sent by
+// the client is kept, if it's valid.  Otherwise, a new id is generated.  The
+// id is returned in the X-Request-Id header of every response and in the
+// envelope of every error.
+//
+// Every other middleware should observe the id, so this should be the last
+// middleware installed.
+func NewRequestIdMiddleware() http.Middleware {
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) http.Response {
+			var id string
+			if !req.ReadHeader(RequestIdHeader, &id) || !requestIdPattern.MatchString(id) {
+				id = uuid.NewV4().String()
+			}
+
+			resp := h(env, &identifiedRequest{req, id})
+			if resp == nil {
+				resp = http.StatusOK
+			}
+
+			return func(b http.ResponseBuilder) error {
+				b.SetHeader(RequestIdHeader, id)
+				return resp(&identifiedResponse{b, id, 200})
+			}
+		}
+	}
+}
+
+// An identified request reports its assigned id as its X-Request-Id.
+type identifiedRequest struct {
+	http.Request
+	id string
+}
+
+func (r *identifiedRequest) ReadHeader(name string, ptr *string) bool {
+	if textproto.CanonicalMIMEHeaderKey(name) == RequestIdHeader {
+		*ptr = r.id
+		return true
+	}
+	return r.Request.ReadHeader(name, ptr)
+}
+
+func (r *identifiedRequest) Unwrap() http.Request {
+	return r.Request
+}
+
+// An identified response adds the request id to its error envelope.
+type identifiedResponse struct {
+	http.ResponseBuilder
+	id   string
+	code int
+}
+
+func (r *identifiedResponse) SetCode(code int) {
+	r.code = code
+	r.ResponseBuilder.SetCode(code)
+}
+
+func (r *identifiedResponse) SetBody(typ string, body []byte) {
+	if r.code >= 400 && typ == mime.Json {
+		var envelope Error
+		if err := json.Unmarshal(body, &envelope); err == nil && envelope.Code != "" {
+			envelope.RequestId = r.id
+			var raw []byte
+			if err := enc.Json.EncodeBinary(envelope, &raw); err == nil {
+				body = raw
+			}
+		}
+	}
+	r.ResponseBuilder.SetBody(typ, body)
+}
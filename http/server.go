@@ -1,6 +1,7 @@
 package http
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
@@ -9,6 +10,7 @@ import (
 	"github.com/pkopriv2/golang-sdk/lang/enc"
 	"github.com/pkopriv2/golang-sdk/lang/errs"
 	"github.com/pkopriv2/golang-sdk/lang/mime"
+	"github.com/pkopriv2/services-catalog/authz"
 	"github.com/pkopriv2/services-catalog/core"
 	uuid "github.com/satori/go.uuid"
 )
@@ -17,9 +19,33 @@ const (
 	StorageKey = "storage.services"
 )
 
-// Uses the dependency injector to retrieve the storage implementation
-func getStorage(env http.Environment) (ret core.Storage) {
+// Basic support for handling multiple encodings.  Returns the encoder for the
+// request's accept type, which defaults to json.
+func acceptEncoder(req http.Request) (ret enc.EncoderDecoder, err error) {
+	accept := mime.Json
+	if _, err = http.ParseHeader(req, headers.Accept, http.String, &accept); err != nil {
+		return
+	}
+
+	ok, ret := enc.DefaultRegistry.FindByMime(accept)
+	if !ok {
+		err = errors.Errorf("Invalid accept type: %v", accept)
+	}
+	return
+}
+
+// Uses the dependency injector to retrieve the storage implementation.
+// Writes are attributed to the request's actor, and authenticated requests
+// are authorized against the owners of services.
+func getStorage(env http.Environment, req http.Request) (ret core.Storage) {
 	env.Assign(StorageKey, &ret)
+	ret = core.Attribute(ret, actorOf(req))
+	if span, ok := spanOf(req); ok {
+		ret = core.Trace(ret, span)
+	}
+	if principal, ok := Authenticated(req); ok {
+		ret = authz.NewStorage(ret, principal)
+	}
 	return
 }
 
@@ -27,24 +53,41 @@ func getStorage(env http.Environment) (ret core.Storage) {
 func ServiceHandlers(svc *http.Service) {
 	var emptyId = uuid.UUID{}
 
-	svc.Register(http.Put("/v1/services"),
+	register(svc, http.Put("/v1/services"),
 		func(env http.Environment, req http.Request) (ret http.Response) {
-			logger, storage := env.Logger(), getStorage(env)
+			logger, storage := env.Logger(), getStorage(env, req)
 
 			var svc core.Service
 			if err := http.RequireStruct(req, enc.DefaultRegistry, &svc); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
+			// Updates sent with an etag are written at the revision following
+			// the one the etag identifies, so clients need not increment the
+			// version themselves.  A stale etag fails the precondition.
+			precondition, match, id, version, err := parseIfMatch(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if precondition {
+				if ret = assertTrue(svc.Id == emptyId || svc.Id == id, "Service id does not match etag"); ret != nil {
+					return
+				}
+
+				svc.Id, svc.Version = id, version+1
+			}
+
 			// Do some basic validation.  Would need to better understand
 			// product requirements to constrain these fields further.
 			// For now, just make sure that none of the required fields
 			// are empty.
 			if ret = http.First(
-				http.AssertTrue(svc.Name != "", "Invalid epoch"),
-				http.AssertTrue(svc.Desc != "", "Invalid description"),
-				http.AssertTrue(svc.Version >= 0, "Invalid version"),
+				assertTrue(svc.Name != "", "Invalid epoch"),
+				assertTrue(svc.Desc != "", "Invalid description"),
+				assertTrue(svc.Version >= 0, "Invalid version"),
 			); ret != nil {
 				return
 			}
@@ -52,13 +95,13 @@ func ServiceHandlers(svc *http.Service) {
 			// Basic support for handling multiple encoding types.
 			accept := mime.Json
 			if _, err := http.ParseHeader(req, headers.Accept, http.String, &accept); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
 			ok, enc := enc.DefaultRegistry.FindByMime(accept)
 			if !ok {
-				ret = http.BadRequest(errors.Errorf("Invalid accept type: %v", accept)) // TODO: Is this the right response type?
+				ret = badRequest(errors.Errorf("Invalid accept type: %v", accept)) // TODO: Is this the right response type?
 				return
 			}
 
@@ -70,26 +113,251 @@ func ServiceHandlers(svc *http.Service) {
 			}
 
 			if err := storage.SaveService(svc); err != nil {
-				if errs.Is(err, core.ErrConflict) {
-					ret = http.Conflict(err)
+				if precondition && (errs.Is(err, core.ErrConflict) || errs.Is(err, core.ErrNoService)) {
+					ret = replyErrorWith(412, CodePrecondition,
+						errors.Wrapf(core.ErrConflict, "Service [%v] does not match etag [%v]", svc.Id, match), nil)
+					return
+				}
+
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Reply(
+				http.StatusOK,
+				http.WithHeader(ETagHeader, ETag(svc)),
+				http.WithStruct(enc, svc))
+			return
+		})
+
+	// Creates a service.  The service may be given an id by the caller, but
+	// creating a service that already exists is a conflict.
+	register(svc, http.Post("/v1/services"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getStorage(env, req)
+
+			var svc core.Service
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &svc); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if ret = http.First(
+				assertTrue(svc.Name != "", "Invalid name"),
+				assertTrue(svc.Desc != "", "Invalid description"),
+				assertTrue(svc.Version == 0, "Invalid version. New services must not have a version"),
+			); ret != nil {
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if svc.Id == emptyId {
+				svc = svc.SetId(uuid.NewV1())
+			} else {
+				svc = svc.Update()
+			}
+
+			logger.Debug("Creating service [id=%v,name=%v]", svc.Id, svc.Name)
+			if err := storage.SaveService(svc); err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Reply(
+				http.StatusCreated,
+				http.WithHeader(headers.Location, fmt.Sprintf("/v1/services/%v", svc.Id)),
+				http.WithHeader(ETagHeader, ETag(svc)),
+				http.WithStruct(enc, svc))
+			return
+		})
+
+	register(svc, http.Get("/v1/services/{id}"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getStorage(env, req)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			svc, _, err := loadService(storage, id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Reply(
+				http.StatusOK,
+				http.WithHeader(ETagHeader, ETag(svc)),
+				http.WithStruct(enc, svc))
+			return
+		})
+
+	// Replaces the service with a new revision.  Updates sent with an etag
+	// are written at the revision following the etag's, and updates sent
+	// with a version are written at that version.  Otherwise, the update is
+	// written at the revision following the latest.
+	register(svc, http.Put("/v1/services/{id}"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getStorage(env, req)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			var svc core.Service
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &svc); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			precondition, match, matchId, matchVersion, err := parseIfMatch(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if ret = http.First(
+				assertTrue(svc.Id == emptyId || svc.Id == id, "Service id does not match path"),
+				assertTrue(!precondition || matchId == id, "Service id does not match etag"),
+				assertTrue(svc.Name != "", "Invalid name"),
+				assertTrue(svc.Desc != "", "Invalid description"),
+				assertTrue(svc.Version >= 0, "Invalid version"),
+			); ret != nil {
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			latest, _, err := loadService(storage, id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			switch {
+			case precondition:
+				svc.Version = matchVersion + 1
+			case svc.Version == 0:
+				svc.Version = latest.Version + 1
+			}
+
+			svc.Id = id
+			svc = svc.Update()
+
+			logger.Debug("Updating service [id=%v,version=%v]", svc.Id, svc.Version)
+			if err := storage.SaveService(svc); err != nil {
+				if precondition && errs.Is(err, core.ErrConflict) {
+					ret = replyErrorWith(412, CodePrecondition,
+						errors.Wrapf(core.ErrConflict, "Service [%v] does not match etag [%v]", svc.Id, match), nil)
 					return
 				}
 
-				ret = http.Panic(err)
+				ret = replyError(err)
 				return
 			}
 
-			ret = http.Ok(enc, svc)
+			ret = http.Reply(
+				http.StatusOK,
+				http.WithHeader(ETagHeader, ETag(svc)),
+				http.WithStruct(enc, svc))
 			return
 		})
 
-	svc.Register(http.Put("/v1/versions"),
+	register(svc, http.Get("/v1/services/{id}/versions"),
 		func(env http.Environment, req http.Request) (ret http.Response) {
-			logger, storage := env.Logger(), getStorage(env)
+			storage := getStorage(env, req)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			_, versions, err := loadService(storage, id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			if versions == nil {
+				versions = []core.Version{}
+			}
+
+			ret = http.Ok(enc, versions)
+			return
+		})
+
+	register(svc, http.Get("/v1/services/{id}/versions/{name}"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getStorage(env, req)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			var name string
+			if err := http.RequirePathParam(req, "name", http.String, &name); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			_, versions, err := loadService(storage, id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			for _, v := range versions {
+				if v.Name == name {
+					ret = http.Ok(enc, v)
+					return
+				}
+			}
+
+			ret = replyError(errors.Wrapf(core.ErrNoVersion, "No such version [%v/%v]", id, name))
+			return
+		})
+
+	register(svc, http.Put("/v1/versions"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getStorage(env, req)
 
 			var v core.Version
 			if err := http.RequireStruct(req, enc.DefaultRegistry, &v); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
@@ -98,8 +366,8 @@ func ServiceHandlers(svc *http.Service) {
 			// For now, just make sure that none of the required fields
 			// are empty.
 			if ret = http.First(
-				http.AssertTrue(v.ServiceId != emptyId, "Invalid service id"),
-				http.AssertTrue(v.Name != "", "Invalid name"),
+				assertTrue(v.ServiceId != emptyId, "Invalid service id"),
+				assertTrue(v.Name != "", "Invalid name"),
 			); ret != nil {
 				return
 			}
@@ -107,13 +375,13 @@ func ServiceHandlers(svc *http.Service) {
 			// Basic support for handling multiple encodings.
 			accept := mime.Json
 			if _, err := http.ParseHeader(req, headers.Accept, http.String, &accept); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
 			ok, enc := enc.DefaultRegistry.FindByMime(accept)
 			if !ok {
-				ret = http.BadRequest(errors.Errorf("Invalid accept type: %v", accept)) // TODO: Is this the right response type?
+				ret = badRequest(errors.Errorf("Invalid accept type: %v", accept)) // TODO: Is this the right response type?
 				return
 			}
 
@@ -121,7 +389,7 @@ func ServiceHandlers(svc *http.Service) {
 			logger.Debug("Adding version [service=%v,name=%v]", v.ServiceId, v.Name)
 
 			if err := storage.SaveVersion(v); err != nil {
-				ret = http.Panic(err)
+				ret = replyError(err)
 				return
 			}
 
@@ -129,12 +397,71 @@ func ServiceHandlers(svc *http.Service) {
 			return
 		})
 
+	// Applies a list of service and version writes atomically.  New services
+	// may be given an id by the caller so that later writes in the batch can
+	// refer to them.  A committed batch replies 200, an aborted batch replies
+	// 422, and both contain a result for every write.
+	register(svc, http.Post("/v1/batch"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getStorage(env, req)
+
+			var writes []core.Write
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &writes); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if ret = assertTrue(len(writes) > 0 && len(writes) <= core.MaxBatchSize, fmt.Sprintf("Invalid batch. Must contain between 1 and %v writes", core.MaxBatchSize)); ret != nil {
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			// Apply the same validation as the single write endpoints, but
+			// report every invalid write rather than just the first.
+			invalid := make([]core.WriteResult, len(writes))
+			failed := false
+			for i := range writes {
+				if err := writes[i].Prepare(); err != nil {
+					invalid[i].Error, failed = err.Error(), true
+				}
+			}
+			if failed {
+				for i := range invalid {
+					if invalid[i].Ok() {
+						invalid[i].Error = core.ErrAborted.Error()
+					}
+				}
+				ret = replyErrorWith(422, CodeAborted, core.BatchError(invalid), invalid)
+				return
+			}
+
+			logger.Debug("Applying batch [writes=%v]", len(writes))
+			results, err := storage.SaveBatch(writes)
+			if err != nil {
+				if len(results) != len(writes) {
+					ret = replyError(err)
+					return
+				}
+
+				ret = replyErrorWith(422, CodeAborted, err, results)
+				return
+			}
+
+			ret = http.Ok(enc, results)
+			return
+		})
+
 	// Considered making this a POST /v1/services_list that included a request body.
 	// Instead just made it a simple GET and encoding the various request elements
 	// in the query parameters
-	svc.Register(http.Get("/v1/services"),
+	register(svc, http.Get("/v1/services"),
 		func(env http.Environment, req http.Request) (ret http.Response) {
-			storage := getStorage(env)
+			storage := getStorage(env, req)
 
 			filter := core.NewFilter()
 			if err := http.ParseQueryParams(req,
@@ -142,7 +469,7 @@ func ServiceHandlers(svc *http.Service) {
 				http.Param("desc", http.String, &filter.DescContains),
 				http.Param("id", http.UUID, &filter.ServiceId),
 			); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
@@ -152,7 +479,7 @@ func ServiceHandlers(svc *http.Service) {
 				http.Param("limit", http.Uint64, &page.Limit),
 				http.Param("order", http.String, &page.OrderBy),
 			); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
@@ -160,30 +487,221 @@ func ServiceHandlers(svc *http.Service) {
 			// product requirements to constrain these fields further.
 			// For now, just make sure that none of the required fields
 			// are empty.
-			if ret = http.AssertTrue(page.Limit <= 1024, "Invalid limit. Must be <= 1024"); ret != nil {
+			if ret = assertTrue(page.Limit <= 1024, "Invalid limit. Must be <= 1024"); ret != nil {
 				return
 			}
 
 			// Basic support for handling multiple encodings
 			accept := mime.Json
 			if _, err := http.ParseHeader(req, headers.Accept, http.String, &accept); err != nil {
-				ret = http.BadRequest(err)
+				ret = badRequest(err)
 				return
 			}
 
 			ok, enc := enc.DefaultRegistry.FindByMime(accept)
 			if !ok {
-				ret = http.BadRequest(errors.Errorf("Invalid accept type: %v", accept)) // TODO: Is this the right response type?
+				ret = badRequest(errors.Errorf("Invalid accept type: %v", accept)) // TODO: Is this the right response type?
 				return
 			}
 
 			catalog, err := storage.ListServices(filter, page)
 			if err != nil {
-				ret = http.Panic(err)
+				ret = replyError(err)
 				return
 			}
 
-			ret = http.Ok(enc, catalog)
+			ret = http.Reply(
+				http.StatusOK,
+				http.WithHeader(ETagHeader, catalogETag(catalog)),
+				http.WithStruct(enc, catalog))
 			return
 		})
+
+	// The change feed is read by sequence rather than by offset.  Consumers
+	// should resume from the sequence of the last change they processed.
+	register(svc, http.Get("/v1/changes"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getStorage(env, req)
+
+			var since, limit uint64 = 0, 1024
+			if err := http.ParseQueryParams(req,
+				http.Param("since", http.Uint64, &since),
+				http.Param("limit", http.Uint64, &limit),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if ret = assertTrue(limit <= 1024, "Invalid limit. Must be <= 1024"); ret != nil {
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			changes, err := storage.ListChanges(since, limit)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, changes)
+			return
+		})
+
+	// Streams changes as server-sent events.  The stream starts from the
+	// sequence given by the Last-Event-ID header (or since query param).  A
+	// zero (or missing) sequence starts from the current end of the log, and
+	// core.FromBeginning replays the log in its entirety.
+	register(svc, http.Get("/v1/watch"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getStorage(env, req)
+
+			filter := core.NewFilter()
+			if err := http.ParseQueryParams(req,
+				http.Param("name", http.String, &filter.NameContains),
+				http.Param("desc", http.String, &filter.DescContains),
+				http.Param("id", http.UUID, &filter.ServiceId),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			var since uint64
+			if err := http.ParseQueryParams(req,
+				http.Param("since", http.Uint64, &since),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			var lastId string
+			if req.ReadHeader(LastEventId, &lastId) {
+				seq, err := parseLastEventId(lastId)
+				if err != nil {
+					ret = badRequest(err)
+					return
+				}
+				since = seq
+			}
+
+			since, err := core.WatchStart(storage, since)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Reply(
+				http.WithCode(200),
+				http.WithHeader("Cache-Control", "no-cache"),
+				http.WithContent(EventStream, newEventStream(env, storage, filter, since)))
+			return
+		})
+
+	// Snapshots are encoded according to the accept header, so they may be
+	// requested as json or yaml.
+	register(svc, http.Get("/v1/export"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getStorage(env, req)
+
+			var history bool
+			if err := http.ParseQueryParams(req,
+				http.Param("history", http.Bool, &history),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			snapshot, err := storage.Export(history)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, snapshot)
+			return
+		})
+
+	// Snapshots are decoded according to the content type, so they may be
+	// submitted as json or yaml.
+	register(svc, http.Post("/v1/import"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getStorage(env, req)
+
+			raw := string(core.ImportMerge)
+			if err := http.ParseQueryParams(req,
+				http.Param("mode", http.String, &raw),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			mode, err := core.ParseImportMode(raw)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			var snapshot core.Snapshot
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &snapshot); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			logger.Info("Importing snapshot [services=%v,versions=%v,mode=%v]",
+				len(snapshot.Services), len(snapshot.Versions), mode)
+
+			result, err := storage.Import(snapshot, mode)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, result)
+			return
+		})
+}
+
+// Parses the If-Match header, if one was sent.  Only strong service etags
+// may be used as preconditions.
+func parseIfMatch(req http.Request) (ok bool, match string, id uuid.UUID, version int, err error) {
+	if ok, err = http.ParseHeader(req, headers.IfMatch, http.String, &match); err != nil || !ok {
+		return
+	}
+
+	id, version, err = ParseETag(match)
+	return
+}
+
+// Loads the latest revision of a service and its versions.
+func loadService(storage core.Storage, id uuid.UUID) (svc core.Service, versions []core.Version, err error) {
+	catalog, err := storage.ListServices(
+		core.NewFilter(core.FilterByServiceId(id)),
+		core.NewPage(core.Limit(1)))
+	if err != nil {
+		return
+	}
+
+	if len(catalog.Services) == 0 {
+		err = errors.Wrapf(core.ErrNoService, "No such service [%v]", id)
+		return
+	}
+
+	svc, versions = catalog.Services[0], catalog.Versions[id]
+	return
 }
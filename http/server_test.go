@@ -1,10 +1,16 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
 	http "github.com/pkopriv2/golang-sdk/http/server"
 	"github.com/pkopriv2/golang-sdk/lang/context"
 	"github.com/pkopriv2/golang-sdk/lang/enc"
@@ -76,12 +82,41 @@ func TestServer(t *testing.T) {
 	if !t.Run("SaveVersion_NoService", func(t *testing.T) {
 		_, err = transport.SaveVersion(core.NewVersion(uuid.NewV1(), "version1"))
 		assert.True(t, errs.Is(err, core.ErrNoService))
+
+		var e *Error
+		if !assert.True(t, errors.As(err, &e)) {
+			return
+		}
+		assert.Equal(t, 404, e.Status)
+		assert.Equal(t, CodeNoService, e.Code)
+		assert.True(t, errors.Is(err, core.ErrNoService))
 	}) {
 		return
 	}
 	if !t.Run("SaveVersion_Conflict", func(t *testing.T) {
 		_, err = transport.SaveVersion(v)
 		assert.True(t, errs.Is(err, core.ErrConflict))
+
+		var e *Error
+		if !assert.True(t, errors.As(err, &e)) {
+			return
+		}
+		assert.Equal(t, 409, e.Status)
+		assert.Equal(t, CodeConflict, e.Code)
+		assert.True(t, errors.Is(err, core.ErrConflict))
+	}) {
+		return
+	}
+	if !t.Run("SaveVersion_Invalid", func(t *testing.T) {
+		_, err = transport.SaveVersion(core.Version{ServiceId: svc.Id})
+
+		var e *Error
+		if !assert.True(t, errors.As(err, &e)) {
+			return
+		}
+		assert.Equal(t, 400, e.Status)
+		assert.Equal(t, CodeInvalid, e.Code)
+		assert.True(t, errors.Is(err, core.ErrState))
 	}) {
 		return
 	}
@@ -219,4 +254,548 @@ func TestServer(t *testing.T) {
 	}) {
 		return
 	}
+
+	if !t.Run("ListChanges", func(t *testing.T) {
+		changes, err := transport.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 7, len(changes))
+		assert.Equal(t, uint64(1), changes[0].Seq)
+		assert.Equal(t, svc, *changes[1].Service)
+		assert.Equal(t, v, *changes[2].Version)
+	}) {
+		return
+	}
+
+	if !t.Run("ListChanges_Since", func(t *testing.T) {
+		changes, err := transport.ListChanges(6, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, uint64(7), changes[0].Seq)
+	}) {
+		return
+	}
+
+	if !t.Run("Watch", func(t *testing.T) {
+		watcher, err := transport.Watch(core.NewFilter(core.FilterByName("watched")), 0)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer watcher.Close()
+
+		ignored := core.NewService("ignored", "desc")
+		if !assert.Nil(t, store.SaveService(ignored)) {
+			return
+		}
+		if !assert.Nil(t, store.SaveVersion(core.NewVersion(ignored.Id, "v1"))) {
+			return
+		}
+
+		watched := core.NewService("watched", "desc")
+		if !assert.Nil(t, store.SaveService(watched)) {
+			return
+		}
+		version := core.NewVersion(watched.Id, "v1")
+		if !assert.Nil(t, store.SaveVersion(version)) {
+			return
+		}
+
+		select {
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "Timed out waiting for service change")
+			return
+		case change := <-watcher.Changes():
+			assert.Equal(t, core.ServiceSaved, change.Type)
+			assert.Equal(t, uint64(10), change.Seq)
+			assert.Equal(t, watched, *change.Service)
+		}
+
+		select {
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "Timed out waiting for version change")
+			return
+		case change := <-watcher.Changes():
+			assert.Equal(t, core.VersionSaved, change.Type)
+			assert.Equal(t, uint64(11), change.Seq)
+			assert.Equal(t, version, *change.Version)
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Watch_Resume", func(t *testing.T) {
+		watcher, err := transport.Watch(core.EmptyFilter, 10)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer watcher.Close()
+
+		select {
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "Timed out waiting for change")
+			return
+		case change := <-watcher.Changes():
+			assert.Equal(t, uint64(11), change.Seq)
+		}
+
+		assert.Nil(t, watcher.Close())
+		for range watcher.Changes() {
+		}
+		assert.Nil(t, watcher.Err())
+	}) {
+		return
+	}
+
+	if !t.Run("Watch_FromBeginning", func(t *testing.T) {
+		watcher, err := transport.Watch(core.EmptyFilter, core.FromBeginning)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer watcher.Close()
+
+		select {
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "Timed out waiting for change")
+		case change := <-watcher.Changes():
+			assert.Equal(t, uint64(1), change.Seq)
+		}
+	}) {
+		return
+	}
+
+	batched := core.NewService("batched", "desc")
+	if !t.Run("SaveBatch", func(t *testing.T) {
+		results, err := transport.SaveBatch([]core.Write{
+			core.SaveServiceWrite(batched),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v1")),
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, batched.Id, results[0].Service.Id)
+		assert.Equal(t, batched.Id, results[1].Version.ServiceId)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_NewId", func(t *testing.T) {
+		results, err := transport.SaveBatch([]core.Write{
+			core.SaveServiceWrite(core.Service{Name: "generated", Desc: "desc"}),
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.NotEqual(t, uuid.UUID{}, results[0].Service.Id)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Conflict", func(t *testing.T) {
+		results, err := transport.SaveBatch([]core.Write{
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v2")),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v1")),
+		})
+		assert.True(t, errs.Is(err, core.ErrConflict))
+		if !assert.Equal(t, 2, len(results)) {
+			return
+		}
+
+		assert.True(t, errs.Is(results[0].Err(), core.ErrAborted))
+		assert.True(t, errs.Is(results[1].Err(), core.ErrConflict))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Invalid", func(t *testing.T) {
+		results, err := transport.SaveBatch([]core.Write{
+			core.SaveServiceWrite(core.Service{Name: "nodesc"}),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v2")),
+		})
+		assert.True(t, errs.Is(err, core.ErrState))
+		if !assert.Equal(t, 2, len(results)) {
+			return
+		}
+
+		assert.True(t, errs.Is(results[0].Err(), core.ErrState))
+		assert.True(t, errs.Is(results[1].Err(), core.ErrAborted))
+	}) {
+		return
+	}
+
+	if !t.Run("Export", func(t *testing.T) {
+		snapshot, err := transport.Export(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, core.SnapshotVersion, snapshot.Version)
+
+		ids := make([]uuid.UUID, 0, len(snapshot.Services))
+		for _, s := range snapshot.Services {
+			ids = append(ids, s.Id)
+		}
+		assert.Contains(t, ids, batched.Id)
+	}) {
+		return
+	}
+
+	if !t.Run("Import_Yaml", func(t *testing.T) {
+		yaml := NewClient(server.Connect(), enc.Yaml)
+
+		snapshot, err := yaml.Export(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		imported := core.NewService("imported", "desc")
+		snapshot.Services = append(snapshot.Services, imported)
+		snapshot.Versions = append(snapshot.Versions, core.NewVersion(imported.Id, "v1"))
+
+		result, err := yaml.Import(snapshot, core.ImportSkipExisting)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 1, result.Created)
+		assert.Equal(t, 1, result.Versions)
+		assert.Equal(t, 0, result.Updated)
+
+		catalog, err := transport.ListServices(core.NewFilter(core.FilterByServiceId(imported.Id)), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []core.Service{imported}, catalog.Services)
+	}) {
+		return
+	}
+
+	if !t.Run("Import_InvalidMode", func(t *testing.T) {
+		_, err := transport.Import(core.NewSnapshot(false, nil, nil), core.ImportMode("bogus"))
+		assert.NotNil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices_ETag", func(t *testing.T) {
+		list := func() (etag string, err error) {
+			err = server.Connect().Call(
+				client.BuildRequest(
+					client.Get("/v1/services"),
+					client.WithQueryParam("id", svc.Id)),
+				func(resp client.Response) error {
+					resp.ReadHeader(ETagHeader, &etag)
+					return client.ExpectCode(200)(resp)
+				})
+			return
+		}
+
+		before, err := list()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, strings.HasPrefix(before, `W/"`))
+
+		_, _, err = ParseETag(before)
+		assert.NotNil(t, err)
+
+		// Adding a version changes the listing, though not the service.
+		if _, err := transport.SaveVersion(core.NewVersion(svc.Id, "etag")); !assert.Nil(t, err) {
+			return
+		}
+
+		after, err := list()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.NotEqual(t, before, after)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_IfMatch", func(t *testing.T) {
+		var code int
+		var etag string
+		err := server.Connect().Call(
+			client.BuildRequest(
+				client.Put("/v1/services"),
+				client.WithHeader(headers.IfMatch, ETag(svc)),
+				client.WithStruct(enc.Json, core.Service{Name: svc.Name, Desc: "etag"})),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				resp.ReadHeader(ETagHeader, &etag)
+				return nil
+			})
+		if !assert.Nil(t, err) || !assert.Equal(t, 200, code) {
+			return
+		}
+		assert.Equal(t, ETag(svc.Increment()), etag)
+
+		// The etag is now stale.
+		err = server.Connect().Call(
+			client.BuildRequest(
+				client.Put("/v1/services"),
+				client.WithHeader(headers.IfMatch, ETag(svc)),
+				client.WithStruct(enc.Json, core.Service{Name: svc.Name, Desc: "stale"})),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				return nil
+			})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 412, code)
+	}) {
+		return
+	}
+
+	if !t.Run("UpdateService", func(t *testing.T) {
+		updated, err := transport.UpdateService(svc.Id, func(s *core.Service) {
+			s.Desc = "updated"
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, svc.Version+2, updated.Version)
+		assert.Equal(t, "updated", updated.Desc)
+		svc = updated
+	}) {
+		return
+	}
+
+	if !t.Run("UpdateService_Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		failures := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := transport.UpdateService(svc.Id, func(s *core.Service) {
+					s.Desc = fmt.Sprintf("concurrent-%v", i)
+				}); err != nil {
+					failures <- err
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(failures)
+
+		for err := range failures {
+			assert.Nil(t, err)
+		}
+
+		catalog, err := transport.ListServices(core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, svc.Version+4, catalog.Services[0].Version)
+	}) {
+		return
+	}
+
+	if !t.Run("UpdateService_NoService", func(t *testing.T) {
+		_, err := transport.UpdateService(uuid.NewV1(), func(s *core.Service) {})
+		assert.True(t, errs.Is(err, core.ErrNoService))
+	}) {
+		return
+	}
+
+	if !t.Run("GetService", func(t *testing.T) {
+		latest, err := transport.GetService(svc.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, svc.Id, latest.Id)
+		assert.Equal(t, svc.Version+4, latest.Version)
+		svc = latest
+	}) {
+		return
+	}
+
+	if !t.Run("GetService_NoService", func(t *testing.T) {
+		var code int
+		err := server.Connect().Call(
+			client.BuildRequest(
+				client.Get(fmt.Sprintf("/v1/services/%v", uuid.NewV1()))),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				return nil
+			})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 404, code)
+
+		_, err = transport.GetService(uuid.NewV1())
+		assert.True(t, errors.Is(err, core.ErrNoService))
+	}) {
+		return
+	}
+
+	if !t.Run("GetVersion", func(t *testing.T) {
+		ret, err := transport.GetVersion(v.ServiceId, v.Name)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, v.Name, ret.Name)
+		assert.Equal(t, v.ServiceId, ret.ServiceId)
+	}) {
+		return
+	}
+
+	if !t.Run("GetVersion_Escaped", func(t *testing.T) {
+		escaped, err := transport.SaveVersion(core.NewVersion(svc.Id, "v 100%"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		ret, err := transport.GetVersion(svc.Id, escaped.Name)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, escaped.Name, ret.Name)
+	}) {
+		return
+	}
+
+	if !t.Run("GetVersion_NoVersion", func(t *testing.T) {
+		_, err := transport.GetVersion(v.ServiceId, "missing")
+		assert.True(t, errors.Is(err, core.ErrNoVersion))
+
+		_, err = transport.GetVersion(uuid.NewV1(), v.Name)
+		assert.True(t, errors.Is(err, core.ErrNoService))
+	}) {
+		return
+	}
+
+	if !t.Run("ListVersions", func(t *testing.T) {
+		var versions []core.Version
+		err := server.Connect().Call(
+			client.BuildRequest(
+				client.Get(fmt.Sprintf("/v1/services/%v/versions", v.ServiceId))),
+			client.ExpectAll(
+				client.ExpectCode(200),
+				client.ExpectStruct(enc.DefaultRegistry, &versions)))
+		if !assert.Nil(t, err) || !assert.NotEmpty(t, versions) {
+			return
+		}
+		assert.Equal(t, v.Name, versions[0].Name)
+	}) {
+		return
+	}
+
+	var created core.Service
+	if !t.Run("CreateService", func(t *testing.T) {
+		var code int
+		var location, etag string
+		err := server.Connect().Call(
+			client.BuildRequest(
+				client.Post("/v1/services"),
+				client.WithStruct(enc.Json, core.Service{Name: "created", Desc: "desc"})),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				resp.ReadHeader(headers.Location, &location)
+				resp.ReadHeader(ETagHeader, &etag)
+				return client.RequireStruct(resp, enc.DefaultRegistry, &created)
+			})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 201, code)
+		assert.Equal(t, fmt.Sprintf("/v1/services/%v", created.Id), location)
+		assert.Equal(t, ETag(created), etag)
+	}) {
+		return
+	}
+
+	if !t.Run("CreateService_Conflict", func(t *testing.T) {
+		var code int
+		err := server.Connect().Call(
+			client.BuildRequest(
+				client.Post("/v1/services"),
+				client.WithStruct(enc.Json, core.Service{Id: created.Id, Name: "created", Desc: "desc"})),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				return nil
+			})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 409, code)
+	}) {
+		return
+	}
+
+	put := func(id uuid.UUID, match *string, s core.Service) (code int, ret core.Service, err error) {
+		err = server.Connect().Call(
+			client.BuildRequest(
+				client.Put(fmt.Sprintf("/v1/services/%v", id)),
+				client.WithHeader(headers.IfMatch, match),
+				client.WithStruct(enc.Json, s)),
+			func(resp client.Response) error {
+				code = resp.ReadCode()
+				if code != 200 {
+					return nil
+				}
+				return client.RequireStruct(resp, enc.DefaultRegistry, &ret)
+			})
+		return
+	}
+
+	if !t.Run("PutService", func(t *testing.T) {
+		code, ret, err := put(created.Id, nil, core.Service{Name: "created", Desc: "put"})
+		if !assert.Nil(t, err) || !assert.Equal(t, 200, code) {
+			return
+		}
+		assert.Equal(t, created.Version+1, ret.Version)
+		assert.Equal(t, "put", ret.Desc)
+		created = ret
+	}) {
+		return
+	}
+
+	if !t.Run("PutService_IfMatch", func(t *testing.T) {
+		etag := ETag(created)
+		code, ret, err := put(created.Id, &etag, core.Service{Name: "created", Desc: "match"})
+		if !assert.Nil(t, err) || !assert.Equal(t, 200, code) {
+			return
+		}
+		assert.Equal(t, created.Version+1, ret.Version)
+
+		// The etag is now stale.
+		code, _, err = put(created.Id, &etag, core.Service{Name: "created", Desc: "stale"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 412, code)
+	}) {
+		return
+	}
+
+	if !t.Run("PutService_NoService", func(t *testing.T) {
+		code, _, err := put(uuid.NewV1(), nil, core.Service{Name: "name", Desc: "desc"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 404, code)
+	}) {
+		return
+	}
+
+	if !t.Run("PutService_MismatchedId", func(t *testing.T) {
+		code, _, err := put(created.Id, nil, core.Service{Id: svc.Id, Name: "name", Desc: "desc"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 400, code)
+	}) {
+		return
+	}
 }
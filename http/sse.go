@@ -0,0 +1,223 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Server-sent events are used to stream changes to watchers.  Each
+// change is written as a single event whose id is the change sequence,
+// which allows clients to resume via the standard Last-Event-ID header.
+const (
+	EventStream = "text/event-stream"
+	LastEventId = "Last-Event-ID"
+)
+
+var (
+	ErrNotStreamable = errors.New("Http:NotStreamable")
+)
+
+var (
+	WatchPollInterval = 250 * time.Millisecond
+	WatchHeartbeat    = 15 * time.Second
+	WatchBatchSize    = uint64(256)
+)
+
+// An event stream tails the change log of the underlying storage and
+// writes each matching change as an event.
+//
+// The server framework copies response bodies with io.Copy, so the stream
+// implements io.WriterTo in order to gain access to the underlying writer
+// and flush each batch of events as soon as it is written.  The stream
+// terminates once the client disconnects or the server is closed.
+type eventStream struct {
+	env     http.Environment
+	storage core.Storage
+	matcher *core.ChangeMatcher
+	filter  core.Filter
+	since   uint64
+}
+
+func newEventStream(env http.Environment, storage core.Storage, filter core.Filter, since uint64) *eventStream {
+	return &eventStream{env, storage, core.NewChangeMatcher(storage), filter, since}
+}
+
+func (e *eventStream) Read([]byte) (int, error) {
+	return 0, errors.Wrapf(ErrNotStreamable, "Event streams must be written with WriteTo")
+}
+
+func (e *eventStream) WriteTo(w io.Writer) (n int64, err error) {
+	flush := func() {
+		if f, ok := w.(gohttp.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	// Commit the headers immediately so clients know the watch has begun.
+	if _, err = fmt.Fprint(w, ": watching\n\n"); err != nil {
+		return
+	}
+	flush()
+
+	heartbeat := time.Now()
+	for {
+		changes, err := e.storage.ListChanges(e.since, WatchBatchSize)
+		if err != nil {
+			e.env.Logger().Error("Error reading changes [since=%v]: %+v", e.since, err)
+			return n, err
+		}
+
+		for _, c := range changes {
+			e.since = c.Seq
+
+			ok, err := e.matcher.Matches(e.filter, c)
+			if err != nil {
+				return n, err
+			}
+			if !ok {
+				continue
+			}
+
+			if err = writeEvent(w, c); err != nil {
+				return n, err
+			}
+		}
+
+		if len(changes) > 0 {
+			flush()
+		}
+		if uint64(len(changes)) == WatchBatchSize {
+			continue
+		}
+
+		// Heartbeats are comments which clients ignore.  They ensure that
+		// a disconnected client is detected, even when no changes occur.
+		if time.Since(heartbeat) > WatchHeartbeat {
+			if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return n, err
+			}
+			flush()
+			heartbeat = time.Now()
+		}
+
+		select {
+		case <-e.env.Control().Closed():
+			return n, nil
+		case <-time.After(WatchPollInterval):
+		}
+	}
+}
+
+func writeEvent(w io.Writer, c core.Change) (err error) {
+	var data []byte
+	if err = enc.Json.EncodeBinary(c, &data); err != nil {
+		return
+	}
+
+	// The json encoder emits indented, multi-line output.  Each line
+	// must be framed as a separate data field.
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "id: %v\n", c.Seq)
+	fmt.Fprintf(&buf, "event: %v\n", c.Type)
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		fmt.Fprintf(&buf, "data: %v\n", line)
+	}
+	buf.WriteString("\n")
+
+	_, err = io.WriteString(w, buf.String())
+	return
+}
+
+// A watcher that reads events from a server-sent event stream.
+type eventWatcher struct {
+	body    io.Closer
+	changes chan core.Change
+	closed  chan struct{}
+	once    sync.Once
+	err     error
+}
+
+func newEventWatcher(body io.Closer) *eventWatcher {
+	return &eventWatcher{
+		body:    body,
+		changes: make(chan core.Change, 64),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (w *eventWatcher) Changes() <-chan core.Change {
+	return w.changes
+}
+
+func (w *eventWatcher) Err() error {
+	return w.err
+}
+
+func (w *eventWatcher) Close() (err error) {
+	w.once.Do(func() {
+		close(w.closed)
+		err = w.body.Close()
+	})
+	return
+}
+
+// Reads events from the stream until it is exhausted or the watcher
+// is closed.  Always closes the changes channel on return.
+func (w *eventWatcher) read(r io.Reader) {
+	defer close(w.changes)
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+
+			var change core.Change
+			if err := enc.Json.DecodeBinary([]byte(data.String()), &change); err != nil {
+				w.err = err
+				return
+			}
+			data.Reset()
+
+			select {
+			case <-w.closed:
+				return
+			case w.changes <- change:
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			data.WriteString("\n")
+		}
+	}
+
+	select {
+	case <-w.closed:
+	default:
+		w.err = errs.Or(scanner.Err(), io.ErrUnexpectedEOF)
+	}
+}
+
+// Parses the sequence from which a watch should resume.
+func parseLastEventId(raw string) (ret uint64, err error) {
+	ret, err = strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid %v [%v]", LastEventId, raw)
+	}
+	return
+}
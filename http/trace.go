@@ -0,0 +1,161 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/services-catalog/trace"
+)
+
+// Returns a middleware that records a server span per request.  A request
+// that carries a valid traceparent header joins the caller's trace.
+// Otherwise, it begins a new one.  Storage retrieved by the handlers is
+// traced beneath the request's span.
+//
+// The span is annotated with the request id, so this should be installed
+// before the request id middleware (in order to run after it).
+func NewTraceMiddleware(tracer *trace.Tracer) http.Middleware {
+	return func(h http.Handler) http.Handler {
+		return func(env http.Environment, req http.Request) http.Response {
+			var parent trace.SpanContext
+			var header string
+			if req.ReadHeader(trace.TraceparentHeader, &header) {
+				parent, _ = trace.ParseTraceparent(header)
+			}
+
+			method, route := req.Method(), routeOf(req)
+
+			span := tracer.Start(method+" "+route, trace.Server, parent)
+			span.SetAttribute("http.method", method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("url.path", req.URL().Path)
+			span.SetAttribute("http.request_id", actorOf(req).RequestId)
+
+			resp := h(env, &tracedRequest{req, span})
+			if resp == nil {
+				resp = http.StatusOK
+			}
+
+			return func(b http.ResponseBuilder) (err error) {
+				rec := &codeRecorder{b, 200}
+				if err = resp(rec); err != nil {
+					rec.code = 500
+				}
+
+				// Only server errors fail the span.  Client errors are
+				// the caller's concern.
+				failure := err
+				if failure == nil && rec.code >= 500 {
+					failure = errors.Errorf("Status [%v]", rec.code)
+				}
+
+				span.SetAttribute("http.status_code", strconv.Itoa(rec.code))
+				span.End(failure)
+				return
+			}
+		}
+	}
+}
+
+// A traced request carries the span of the request.
+type tracedRequest struct {
+	http.Request
+	span *trace.Span
+}
+
+func (r *tracedRequest) Unwrap() http.Request {
+	return r.Request
+}
+
+// Returns the span of the request, if it's traced.
+func spanOf(req http.Request) (*trace.Span, bool) {
+	for req != nil {
+		switch r := req.(type) {
+		case *tracedRequest:
+			return r.span, true
+		case interface{ Unwrap() http.Request }:
+			req = r.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// Returns a client that propagates the parent's trace to the server.  If
+// the tracer is non-nil, every call is recorded as a client span beneath the
+// parent, and the server's span is a child of the call's span.  Otherwise,
+// the parent is propagated as is (e.g. a command line tool that only relays
+// the trace of the deploy tooling that invoked it).  An invalid parent with
+// a nil tracer propagates nothing.
+func NewTracingClient(raw client.Client, tracer *trace.Tracer, parent trace.SpanContext) client.Client {
+	return &tracingClient{raw, tracer, parent}
+}
+
+type tracingClient struct {
+	raw    client.Client
+	tracer *trace.Tracer
+	parent trace.SpanContext
+}
+
+// The span begins once the request has been built, since that's the first
+// point at which its method and path are known.
+func (c *tracingClient) Call(req client.Request, fn func(client.Response) error) (err error) {
+	var span *trace.Span
+	err = c.raw.Call(
+		func(b client.RequestBuilder) error {
+			rec := &pathRecorder{RequestBuilder: b, method: "GET"}
+			if err := req(rec); err != nil {
+				return err
+			}
+
+			parent := c.parent
+			if c.tracer != nil {
+				span = c.tracer.Start("HTTP "+rec.method, trace.Client, parent)
+				span.SetAttribute("http.method", rec.method)
+				span.SetAttribute("url.path", rec.path)
+				parent = span.Context()
+			}
+			if parent.Valid() {
+				b.SetHeader(trace.TraceparentHeader, parent.Traceparent())
+			}
+			return nil
+		},
+		func(resp client.Response) error {
+			if span != nil {
+				span.SetAttribute("http.status_code", strconv.Itoa(resp.ReadCode()))
+			}
+			return fn(resp)
+		})
+	if span != nil {
+		span.End(err)
+	}
+	return
+}
+
+// A path recorder observes the method and path of a request as it's built.
+// Paths are escaped as the underlying client escapes them.
+type pathRecorder struct {
+	client.RequestBuilder
+	method string
+	path   string
+}
+
+func (r *pathRecorder) SetMethod(method string) {
+	r.method = method
+	r.RequestBuilder.SetMethod(method)
+}
+
+func (r *pathRecorder) SetPath(path string, args ...interface{}) {
+	escaped := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		escaped = append(escaped, url.PathEscape(fmt.Sprint(arg)))
+	}
+
+	r.path = fmt.Sprintf(path, escaped...)
+	r.RequestBuilder.SetPath(path, args...)
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	"github.com/pkopriv2/golang-sdk/http/headers"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	"github.com/pkopriv2/services-catalog/trace"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Collects exported spans in memory.
+type memoryExporter struct {
+	lock  sync.Mutex
+	spans []trace.SpanData
+}
+
+func (e *memoryExporter) Export(spans []trace.SpanData) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Returns the spans of the trace, keyed by name.
+func (e *memoryExporter) Trace(id trace.TraceId) (ret map[string]trace.SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	ret = make(map[string]trace.SpanData)
+	for _, s := range e.spans {
+		if s.TraceId == id.String() {
+			ret[s.Name] = s
+		}
+	}
+	return
+}
+
+func TestTrace(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	raw, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	exporter := &memoryExporter{}
+	tracer := trace.NewTracer(ctx, exporter)
+
+	log := &syncBuffer{}
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, trace.NewStorage(raw)),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewAccessLogMiddleware(log)),
+		http.WithMiddleware(NewTraceMiddleware(tracer)),
+		http.WithMiddleware(NewRequestIdMiddleware()))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	svc, err := NewClient(server.Connect(), enc.Json).SaveService(core.NewService("name", "desc"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	if _, err = log.Entries(); !assert.Nil(t, err) {
+		return
+	}
+
+	// The spans of the deploy tooling, from which the traced calls descend.
+	deploy, _ := trace.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	relay, _ := trace.ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	// The calls are made first, since their spans are only exported once
+	// the tracer has been closed.
+	var logged, missing []AccessEntry
+	var untraced AccessEntry
+	if !t.Run("Calls", func(t *testing.T) {
+		_, err := NewClient(NewTracingClient(server.Connect(), tracer, deploy), enc.Json).GetService(svc.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if logged, err = log.Entries(); !assert.Nil(t, err) || !assert.Equal(t, 1, len(logged)) {
+			return
+		}
+
+		_, err = NewClient(NewTracingClient(server.Connect(), nil, relay), enc.Json).GetService(uuid.NewV4())
+		if !assert.True(t, errs.Is(err, core.ErrNoService)) {
+			return
+		}
+		if missing, err = log.Entries(); !assert.Nil(t, err) || !assert.Equal(t, 1, len(missing)) {
+			return
+		}
+
+		err = server.Connect().Call(
+			client.BuildRequest(
+				client.Get("/v1/services"),
+				client.WithHeader(headers.Accept, enc.Json.Mime()),
+				client.WithHeader(trace.TraceparentHeader, "garbage")),
+			client.ExpectCode(200))
+		if !assert.Nil(t, err) {
+			return
+		}
+		entries, err := log.Entries()
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		untraced = entries[0]
+
+		assert.Nil(t, tracer.Close())
+	}) {
+		return
+	}
+
+	if !t.Run("Client", func(t *testing.T) {
+		assert.Equal(t, deploy.TraceId.String(), logged[0].TraceId)
+
+		spans := exporter.Trace(deploy.TraceId)
+		client, ok := spans["HTTP GET"]
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, trace.Client, client.Kind)
+		assert.Equal(t, deploy.SpanId.String(), client.ParentId)
+		assert.Equal(t, "/v1/services/"+svc.Id.String(), client.Attributes["url.path"])
+		assert.Equal(t, "200", client.Attributes["http.status_code"])
+
+		server, ok := spans["GET /v1/services/{id}"]
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, trace.Server, server.Kind)
+		assert.Equal(t, client.SpanId, server.ParentId)
+		assert.Equal(t, logged[0].RequestId, server.Attributes["http.request_id"])
+		assert.Equal(t, "/v1/services/{id}", server.Attributes["http.route"])
+
+		storage, ok := spans["storage.ListServices"]
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, server.SpanId, storage.ParentId)
+	}) {
+		return
+	}
+
+	if !t.Run("Propagated", func(t *testing.T) {
+		assert.Equal(t, relay.TraceId.String(), missing[0].TraceId)
+
+		spans := exporter.Trace(relay.TraceId)
+		_, ok := spans["HTTP GET"]
+		assert.False(t, ok)
+
+		server, ok := spans["GET /v1/services/{id}"]
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, relay.SpanId.String(), server.ParentId)
+		assert.Equal(t, "404", server.Attributes["http.status_code"])
+		assert.Equal(t, "", server.Error)
+	}) {
+		return
+	}
+
+	if !t.Run("Untraced", func(t *testing.T) {
+		if !assert.NotEmpty(t, untraced.TraceId) {
+			return
+		}
+		assert.NotEqual(t, deploy.TraceId.String(), untraced.TraceId)
+
+		var found bool
+		for _, s := range exporter.spans {
+			if s.TraceId == untraced.TraceId && s.Kind == trace.Server {
+				found = true
+				assert.Equal(t, "", s.ParentId)
+				assert.Equal(t, "GET /v1/services", s.Name)
+			}
+		}
+		assert.True(t, found)
+	}) {
+		return
+	}
+}
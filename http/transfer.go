@@ -0,0 +1,167 @@
+package http
+
+import (
+	"github.com/pkg/errors"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/services-catalog/authz"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	TransferStorageKey = "storage.transfers"
+)
+
+// The body of a transfer request.
+type TransferRequest struct {
+	To string `json:"to"`
+}
+
+// Uses the dependency injector to retrieve the transfer storage
+// implementation.  Transfers are made on behalf of the request's principal,
+// so they require an authenticated request.
+func getTransfers(env http.Environment, req http.Request) (ret *authz.Transfers, err error) {
+	principal, ok := Authenticated(req)
+	if !ok {
+		err = errors.Wrapf(core.ErrUnauthorized, "Transfers require an authenticated principal")
+		return
+	}
+
+	var raw core.TransferStorage
+	env.Assign(TransferStorageKey, &raw)
+	ret = authz.NewTransfers(core.AttributeTransfers(raw, actorOf(req)), getStorage(env, req), principal)
+	return
+}
+
+// Register the ownership transfer handlers
+func TransferHandlers(svc *http.Service) {
+
+	register(svc, http.Post("/v1/services/{id}/transfers"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger := env.Logger()
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			var body TransferRequest
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &body); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			transfers, err := getTransfers(env, req)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			logger.Debug("Requesting transfer [service=%v,to=%v]", id, body.To)
+			transfer, err := transfers.Request(id, body.To)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Reply(http.StatusCreated, http.WithStruct(enc, transfer))
+			return
+		})
+
+	register(svc, http.Get("/v1/services/{id}/transfers"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			transfers, err := getTransfers(env, req)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			list, err := transfers.List(id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, list)
+			return
+		})
+
+	register(svc, http.Post("/v1/transfers/{id}/approve"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger := env.Logger()
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			transfers, err := getTransfers(env, req)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			logger.Debug("Approving transfer [id=%v]", id)
+			transfer, err := transfers.Approve(id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, transfer)
+			return
+		})
+
+	register(svc, http.Delete("/v1/transfers/{id}"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger := env.Logger()
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			transfers, err := getTransfers(env, req)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			logger.Debug("Cancelling transfer [id=%v]", id)
+			if err := transfers.Cancel(id); err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Empty()
+			return
+		})
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	client "github.com/pkopriv2/golang-sdk/http/client"
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/authz"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferServer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := sqlsvc.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	transfers, err := sqlsvc.NewSqlTransferStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tokens, err := sqlsvc.NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(ServiceHandlers, TransferHandlers, GraphQLHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(StorageKey, store),
+		http.WithDependency(TransferStorageKey, transfers),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware),
+		http.WithMiddleware(NewAuthMiddleware(core.NewAuthenticator(tokens, nil))))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	subjects := make(map[string]string)
+	newToken := func(name string, teams ...string) string {
+		token, secret, err := core.NewToken(name, core.ScopeWrite, 0, teams...)
+		if !assert.Nil(t, err) || !assert.Nil(t, tokens.SaveToken(token)) {
+			t.FailNow()
+		}
+		subjects[name] = token.Principal().Subject
+		return secret
+	}
+
+	alice, bob := newToken("alice", "payments"), newToken("bob", "billing")
+
+	services := func(token string) core.Transport {
+		return NewClient(NewBearerClient(server.Connect(), token), enc.Json)
+	}
+
+	transport := func(token string) core.TransferTransport {
+		return NewTransferClient(NewBearerClient(server.Connect(), token), enc.Json)
+	}
+
+	var svc core.Service
+	if !t.Run("SaveService_Owned", func(t *testing.T) {
+		svc, err = services(alice).SaveService(core.Service{Name: "name", Desc: "desc", Owner: "payments"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "payments", svc.Owner)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Denied", func(t *testing.T) {
+		_, err := services(bob).SaveService(svc.SetDesc("stolen").Increment())
+		assert.True(t, errs.Is(err, core.ErrForbidden))
+
+		var e *Error
+		if !assert.True(t, errors.As(err, &e)) {
+			return
+		}
+		assert.Equal(t, 403, e.Status)
+
+		var denial authz.Denial
+		if !assert.Nil(t, json.Unmarshal(e.Details, &denial)) {
+			return
+		}
+		assert.Equal(t, authz.ReasonNotOwner, denial.Reason)
+		assert.Equal(t, subjects["bob"], denial.Subject)
+		assert.Equal(t, "payments", denial.Team)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Denied_GraphQL", func(t *testing.T) {
+		var ret graphQLResult
+		err := NewBearerClient(server.Connect(), bob).Call(
+			client.BuildRequest(
+				client.Post("/v1/graphql"),
+				client.WithStruct(enc.Json, GraphQLRequest{
+					Query: `mutation($svc: ServiceInput!) { saveService(service: $svc) { id } }`,
+					Variables: map[string]interface{}{
+						"svc": map[string]interface{}{
+							"id": svc.Id.String(), "name": svc.Name, "desc": "stolen", "owner": svc.Owner, "version": svc.Version + 1,
+						},
+					},
+				})),
+			func(resp client.Response) error {
+				return client.RequireStruct(resp, enc.DefaultRegistry, &ret)
+			})
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(ret.Errors)) {
+			return
+		}
+		assert.Equal(t, CodeForbidden, ret.Errors[0].Extensions["code"])
+
+		t.Logf("%+v", ret.Errors)
+		details, _ := ret.Errors[0].Extensions["details"].(map[string]interface{})
+		assert.Equal(t, authz.ReasonNotOwner, details["reason"])
+		assert.Equal(t, "payments", details["team"])
+	}) {
+		return
+	}
+
+	var transfer core.Transfer
+	if !t.Run("RequestTransfer", func(t *testing.T) {
+		transfer, err = transport(alice).RequestTransfer(svc.Id, "billing")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, subjects["alice"], transfer.FromBy)
+		assert.True(t, transfer.Pending())
+	}) {
+		return
+	}
+
+	if !t.Run("ListTransfers", func(t *testing.T) {
+		list, err := transport(bob).ListTransfers(svc.Id)
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(list)) {
+			return
+		}
+		assert.Equal(t, transfer.Id, list[0].Id)
+	}) {
+		return
+	}
+
+	if !t.Run("ApproveTransfer", func(t *testing.T) {
+		transfer, err = transport(bob).ApproveTransfer(transfer.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, transfer.Pending())
+
+		_, err = services(bob).SaveService(svc.SetDesc("ours").Increment())
+		assert.True(t, errs.Is(err, core.ErrConflict), "%v", err)
+
+		catalog, err := services(bob).ListServices(core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			return
+		}
+		assert.Equal(t, "billing", catalog.Services[0].Owner)
+
+		_, err = services(bob).SaveService(catalog.Services[0].SetDesc("ours").Increment())
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("CancelTransfer_NoTransfer", func(t *testing.T) {
+		err := transport(bob).CancelTransfer(uuid.NewV1())
+		assert.True(t, errs.Is(err, core.ErrNoTransfer))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,259 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/webhook"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	WebhookStorageKey = "storage.webhooks"
+)
+
+// Uses the dependency injector to retrieve the webhook storage implementation
+func getWebhookStorage(env http.Environment) (ret core.WebhookStorage) {
+	env.Assign(WebhookStorageKey, &ret)
+	return
+}
+
+// Register the webhook management handlers.  Webhooks may only be delivered
+// to public hosts (see webhook.ValidateUrl).
+func WebhookHandlers(svc *http.Service) {
+	NewWebhookHandlers(false)(svc)
+}
+
+// Returns the webhook management handlers, optionally allowing webhooks to
+// be delivered to private networks.
+func NewWebhookHandlers(private bool) func(*http.Service) {
+	return func(svc *http.Service) {
+		webhookHandlers(svc, private)
+	}
+}
+
+func webhookHandlers(svc *http.Service, private bool) {
+	var emptyId = uuid.UUID{}
+
+	// Mirrors the services endpoint.  A webhook without an id is created,
+	// otherwise the existing webhook is replaced.
+	register(svc, http.Put("/v1/webhooks"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			logger, storage := env.Logger(), getWebhookStorage(env)
+
+			var hook core.Webhook
+			if err := http.RequireStruct(req, enc.DefaultRegistry, &hook); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if err := webhook.ValidateUrl(hook.Url, private); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			// Secrets are only returned when they are generated
+			generated := false
+			if hook.Id == emptyId {
+				hook.Id, hook.Created = uuid.NewV1(), time.Now().UTC()
+				if hook.Secret == "" {
+					secret, err := newSecret()
+					if err != nil {
+						ret = replyError(err)
+						return
+					}
+					hook.Secret, generated = secret, true
+				}
+			} else {
+				cur, err := storage.LoadWebhook(hook.Id)
+				if err != nil {
+					ret = replyError(err)
+					return
+				}
+
+				hook.Created = cur.Created
+				if hook.Secret == "" {
+					hook.Secret = cur.Secret
+				}
+			}
+
+			logger.Debug("Saving webhook [id=%v,url=%v]", hook.Id, hook.Url)
+			if err := storage.SaveWebhook(hook); err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			if !generated {
+				hook.Secret = ""
+			}
+
+			ret = http.Ok(enc, hook)
+			return
+		})
+
+	register(svc, http.Get("/v1/webhooks"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getWebhookStorage(env)
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			hooks, err := storage.ListWebhooks()
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			for i := range hooks {
+				hooks[i].Secret = ""
+			}
+
+			ret = http.Ok(enc, hooks)
+			return
+		})
+
+	register(svc, http.Get("/v1/webhooks/{id}"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getWebhookStorage(env)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			hook, err := storage.LoadWebhook(id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			hook.Secret = ""
+			ret = http.Ok(enc, hook)
+			return
+		})
+
+	register(svc, http.Delete("/v1/webhooks/{id}"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getWebhookStorage(env)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if err := storage.DeleteWebhook(id); err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Empty()
+			return
+		})
+
+	// Dead letters are found by filtering on status=dead
+	register(svc, http.Get("/v1/deliveries"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getWebhookStorage(env)
+
+			var filter core.DeliveryFilter
+			var status *string
+			if err := http.ParseQueryParams(req,
+				http.Param("webhook", http.UUID, &filter.WebhookId),
+				http.Param("status", http.String, &status),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if status != nil {
+				tmp := core.DeliveryStatus(*status)
+				filter.Status = &tmp
+			}
+
+			page := core.NewPage()
+			if err := http.ParseQueryParams(req,
+				http.Param("offset", http.Uint64, &page.Offset),
+				http.Param("limit", http.Uint64, &page.Limit),
+			); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			if ret = assertTrue(page.Limit <= 1024, "Invalid limit. Must be <= 1024"); ret != nil {
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			deliveries, err := storage.ListDeliveries(filter, page)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, deliveries)
+			return
+		})
+
+	register(svc, http.Get("/v1/deliveries/{id}/attempts"),
+		func(env http.Environment, req http.Request) (ret http.Response) {
+			storage := getWebhookStorage(env)
+
+			var id uuid.UUID
+			if err := http.RequirePathParam(req, "id", http.UUID, &id); err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			enc, err := acceptEncoder(req)
+			if err != nil {
+				ret = badRequest(err)
+				return
+			}
+
+			attempts, err := storage.ListAttempts(id)
+			if err != nil {
+				ret = replyError(err)
+				return
+			}
+
+			ret = http.Ok(enc, attempts)
+			return
+		})
+}
+
+// Generates a random 256-bit secret for signing deliveries.
+func newSecret() (ret string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return
+	}
+
+	ret = hex.EncodeToString(buf)
+	return
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"os"
+	"testing"
+
+	http "github.com/pkopriv2/golang-sdk/http/server"
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/net"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	sqlsvc "github.com/pkopriv2/services-catalog/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookServer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, e := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, e) {
+		return
+	}
+
+	hooks, err := sqlsvc.NewSqlWebhookStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	server, err := http.Serve(ctx,
+		http.Build(WebhookHandlers),
+		http.WithListener(net.NewTCP4Network(), ":0"),
+		http.WithDependency(WebhookStorageKey, hooks),
+		http.WithMiddleware(http.TimerMiddleware),
+		http.WithMiddleware(http.RouteMiddleware))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	transport := NewWebhookClient(server.Connect(), enc.Json)
+
+	var hook core.Webhook
+	if !t.Run("SaveWebhook", func(t *testing.T) {
+		hook, err = transport.SaveWebhook(core.Webhook{
+			Url:    "http://hooks.example.com:9999/hook",
+			Events: []core.ChangeType{core.VersionSaved}})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.NotEqual(t, uuid.UUID{}, hook.Id)
+		assert.NotEmpty(t, hook.Secret)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveWebhook_InvalidUrl", func(t *testing.T) {
+		_, err = transport.SaveWebhook(core.Webhook{Url: "localhost"})
+		assert.NotNil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveWebhook_PrivateUrl", func(t *testing.T) {
+		_, err = transport.SaveWebhook(core.Webhook{Url: "http://169.254.169.254/latest/meta-data"})
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveWebhook_Updated", func(t *testing.T) {
+		updated, err := transport.SaveWebhook(hook.Update(func(w *core.Webhook) {
+			w.Url, w.Secret = "https://hooks.example.com/hook", ""
+		}))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, "https://hooks.example.com/hook", updated.Url)
+		assert.Empty(t, updated.Secret)
+
+		stored, err := hooks.LoadWebhook(hook.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, hook.Secret, stored.Secret)
+	}) {
+		return
+	}
+
+	if !t.Run("ListWebhooks", func(t *testing.T) {
+		all, err := transport.ListWebhooks()
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 1, len(all))
+		assert.Equal(t, hook.Id, all[0].Id)
+		assert.Equal(t, []core.ChangeType{core.VersionSaved}, all[0].Events)
+		assert.Empty(t, all[0].Secret)
+	}) {
+		return
+	}
+
+	if !t.Run("DeleteWebhook", func(t *testing.T) {
+		assert.Nil(t, transport.DeleteWebhook(hook.Id))
+	}) {
+		return
+	}
+
+	if !t.Run("DeleteWebhook_NotFound", func(t *testing.T) {
+		assert.True(t, errs.Is(transport.DeleteWebhook(hook.Id), core.ErrNoWebhook))
+	}) {
+		return
+	}
+}
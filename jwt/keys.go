@@ -0,0 +1,179 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// The supported signing algorithms.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// The maximum size of a JWKS document.
+const maxKeySetSize = 1 << 20
+
+// A public key that verifies signatures.
+type Key struct {
+	Id  string
+	Alg string
+	Raw crypto.PublicKey
+}
+
+// A set of keys, as published by an identity provider.
+type KeySet []Key
+
+// Returns the keys that may have signed a token with the given header.
+// Tokens without a key id may have been signed by any key of the algorithm.
+func (s KeySet) Find(alg, kid string) (ret []Key) {
+	for _, k := range s {
+		if k.Alg == alg && (kid == "" || k.Id == kid) {
+			ret = append(ret, k)
+		}
+	}
+	return
+}
+
+// A JSON Web Key.  Only the fields of RSA and EC keys are modeled.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Parses a JWKS document.  Keys that can't verify signatures (e.g.
+// encryption keys or unsupported algorithms) are skipped, but malformed
+// keys are rejected.
+func ParseKeySet(data []byte) (ret KeySet, err error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		err = errors.Wrapf(core.ErrState, "Invalid JWKS document: %v", err)
+		return
+	}
+
+	for i, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, ok, e := parseKey(jwk)
+		if e != nil {
+			err = errors.Wrapf(core.ErrState, "Invalid key [%v] (%v): %v", i, jwk.Kid, e)
+			return
+		}
+		if ok && (jwk.Alg == "" || jwk.Alg == key.Alg) {
+			ret = append(ret, key)
+		}
+	}
+	return
+}
+
+func parseKey(jwk jsonWebKey) (ret Key, ok bool, err error) {
+	switch jwk.Kty {
+	default:
+		return
+	case "RSA":
+		var n, e *big.Int
+		if n, err = decodeInt(jwk.N); err != nil {
+			return
+		}
+		if e, err = decodeInt(jwk.E); err != nil {
+			return
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			err = errors.Errorf("Invalid exponent")
+			return
+		}
+		ret, ok = Key{jwk.Kid, RS256, &rsa.PublicKey{N: n, E: int(e.Int64())}}, true
+		return
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return
+		}
+
+		var x, y *big.Int
+		if x, err = decodeInt(jwk.X); err != nil {
+			return
+		}
+		if y, err = decodeInt(jwk.Y); err != nil {
+			return
+		}
+
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			err = errors.Errorf("Point is not on the curve")
+			return
+		}
+		ret, ok = Key{jwk.Kid, ES256, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, true
+		return
+	}
+}
+
+func decodeInt(raw string) (ret *big.Int, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return
+	}
+	if len(buf) == 0 {
+		err = errors.Errorf("Missing parameter")
+		return
+	}
+	ret = new(big.Int).SetBytes(buf)
+	return
+}
+
+// Returns a function that loads the key set from the location, which may be
+// a file or an http(s) url.
+func keyLoader(location string, timeout time.Duration) func() (KeySet, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return func() (KeySet, error) {
+			data, err := ioutil.ReadFile(location)
+			if err != nil {
+				return nil, err
+			}
+			return ParseKeySet(data)
+		}
+	}
+
+	client := &http.Client{Timeout: timeout}
+	return func() (ret KeySet, err error) {
+		resp, err := client.Get(location)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			err = errors.Errorf("Unable to fetch JWKS [%v]: %v", location, resp.Status)
+			return
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+		if err != nil {
+			return
+		}
+		return ParseKeySet(data)
+	}
+}
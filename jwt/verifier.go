@@ -0,0 +1,365 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	Issuer          string
+	Audience        string
+	Leeway          time.Duration
+	SubjectClaim    string
+	TeamsClaim      string
+	Rules           []Rule
+	KeyTTL          time.Duration
+	RefreshInterval time.Duration
+	FetchTimeout    time.Duration
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{
+		Leeway:          time.Minute,
+		SubjectClaim:    "sub",
+		TeamsClaim:      "groups",
+		KeyTTL:          time.Hour,
+		RefreshInterval: time.Minute,
+		FetchTimeout:    10 * time.Second,
+	}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the issuer that tokens must be issued by.  Required.
+func WithIssuer(iss string) Option {
+	return func(o *Options) {
+		o.Issuer = iss
+	}
+}
+
+// Sets the audience that tokens must be issued to.  Required.
+func WithAudience(aud string) Option {
+	return func(o *Options) {
+		o.Audience = aud
+	}
+}
+
+// Sets the allowed clock skew between the catalog and the issuer.
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *Options) {
+		o.Leeway = leeway
+	}
+}
+
+// Sets the claims that name the subject and the teams it is a member of
+// (by default, sub and groups).
+func WithClaims(subject, teams string) Option {
+	return func(o *Options) {
+		o.SubjectClaim, o.TeamsClaim = subject, teams
+	}
+}
+
+// Adds rules that grant scopes to the principals of tokens.
+func WithRules(rules ...Rule) Option {
+	return func(o *Options) {
+		o.Rules = append(o.Rules, rules...)
+	}
+}
+
+// Sets how long keys are used before they are reloaded, and how often keys
+// may be reloaded when a token is signed by an unknown key.
+func WithKeyRefresh(ttl, interval time.Duration) Option {
+	return func(o *Options) {
+		o.KeyTTL, o.RefreshInterval = ttl, interval
+	}
+}
+
+// A rule grants a scope to the principals of tokens whose claim has the
+// value.  A claim has a value if it equals the value, if it is a list that
+// contains the value, or if it is a space delimited string (e.g. an OAuth
+// scope claim) that contains the value.
+type Rule struct {
+	Claim string
+	Value string
+	Scope core.Scope
+}
+
+// Parses a rule of the form <claim>:<value>=<scope>, e.g.
+// groups:platform-admins=admin.
+func ParseRule(raw string) (ret Rule, err error) {
+	i, j := strings.Index(raw, ":"), strings.LastIndex(raw, "=")
+	if i <= 0 || j <= i+1 {
+		err = errors.Wrapf(core.ErrState, "Invalid rule [%v]. Must be of the form <claim>:<value>=<scope>", raw)
+		return
+	}
+
+	ret = Rule{Claim: raw[:i], Value: raw[i+1 : j]}
+	ret.Scope, err = core.ParseScope(raw[j+1:])
+	return
+}
+
+func (r Rule) Matches(claims map[string]interface{}) bool {
+	switch v := claims[r.Claim].(type) {
+	case string:
+		for _, f := range strings.Fields(v) {
+			if f == r.Value {
+				return true
+			}
+		}
+		return v == r.Value
+	case []interface{}:
+		for _, e := range v {
+			if s, ok := e.(string); ok && s == r.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verifies signed JWTs against the keys of an identity provider, and maps
+// their claims to catalog principals.  Keys are cached, and reloaded once
+// they're older than the key ttl, or when a token is signed by an unknown
+// key (e.g. after a rotation).
+type Verifier struct {
+	opts Options
+	load func() (KeySet, error)
+
+	lock    sync.Mutex
+	keys    KeySet
+	loaded  time.Time
+	loadErr error
+}
+
+// Returns a verifier of the tokens signed by the keys at the location,
+// which may be a JWKS file or url.  The keys are loaded immediately, so that
+// configuration errors are found early.
+func NewVerifier(location string, fns ...Option) (ret *Verifier, err error) {
+	opts := buildOptions(fns...)
+	if opts.Issuer == "" || opts.Audience == "" {
+		err = errors.Wrapf(core.ErrState, "Verifying JWTs requires an issuer and an audience")
+		return
+	}
+
+	ret = &Verifier{opts: opts, load: keyLoader(location, opts.FetchTimeout)}
+	if ret.keys, err = ret.load(); err != nil {
+		err = errors.Wrapf(err, "Unable to load JWKS [%v]", location)
+		return
+	}
+	ret.loaded = time.Now()
+	return
+}
+
+// Returns the keys that may have signed a token, reloading them if they are
+// stale or if no key matches.
+func (v *Verifier) findKeys(alg, kid string) (ret []Key, err error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	ret = v.keys.Find(alg, kid)
+
+	since := time.Since(v.loaded)
+	if (len(ret) > 0 && since < v.opts.KeyTTL) || since < v.opts.RefreshInterval {
+		if len(ret) == 0 {
+			err = v.noKey(alg, kid)
+		}
+		return
+	}
+
+	// A failed reload falls back to the keys already loaded.
+	keys, e := v.load()
+	v.loaded, v.loadErr = time.Now(), e
+	if e == nil {
+		v.keys = keys
+	}
+
+	if ret = v.keys.Find(alg, kid); len(ret) == 0 {
+		err = v.noKey(alg, kid)
+	}
+	return
+}
+
+func (v *Verifier) noKey(alg, kid string) error {
+	if v.loadErr != nil {
+		return errors.Wrapf(core.ErrUnauthorized, "No [%v] key [%v] (last reload failed: %v)", alg, kid, v.loadErr)
+	}
+	return errors.Wrapf(core.ErrUnauthorized, "No [%v] key [%v]", alg, kid)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verifies the token, and returns the principal of its claims.  Invalid
+// and expired tokens are unauthorized.  Principals whose claims match no
+// rule have no scope, so they are authenticated but forbidden from all
+// requests.  Subjects are chosen by the identity provider, so they're
+// namespaced by the issuer (e.g. jwt:<iss>:<sub>) to keep them apart from
+// those of API tokens and other issuers.
+func (v *Verifier) Authenticate(token string) (ret core.Principal, err error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return
+	}
+
+	sub, _ := claims[v.opts.SubjectClaim].(string)
+	if sub == "" {
+		err = errors.Wrapf(core.ErrUnauthorized, "Missing claim [%v]", v.opts.SubjectClaim)
+		return
+	}
+
+	ret = core.Principal{Subject: "jwt:" + v.opts.Issuer + ":" + sub, Name: sub}
+	if teams, ok := claims[v.opts.TeamsClaim].([]interface{}); ok {
+		for _, t := range teams {
+			if s, ok := t.(string); ok {
+				ret.Teams = append(ret.Teams, s)
+			}
+		}
+	}
+
+	for _, r := range v.opts.Rules {
+		if r.Matches(claims) && r.Scope.Allows(ret.Scope) {
+			ret.Scope = r.Scope
+		}
+	}
+	return
+}
+
+// Verifies the token's signature, issuer, audience and lifetime, and
+// returns its claims.
+func (v *Verifier) Verify(token string) (ret map[string]interface{}, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = errors.Wrapf(core.ErrUnauthorized, "Malformed token")
+		return
+	}
+
+	var head header
+	if err = decodeSegment(parts[0], &head); err != nil {
+		return
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		err = errors.Wrapf(core.ErrUnauthorized, "Malformed signature")
+		return
+	}
+
+	// The algorithm is never trusted to choose the key, so an attacker
+	// can't substitute a weaker algorithm (e.g. none or HS256).
+	switch head.Alg {
+	case RS256, ES256:
+	default:
+		err = errors.Wrapf(core.ErrUnauthorized, "Unsupported algorithm [%v]", head.Alg)
+		return
+	}
+
+	keys, err := v.findKeys(head.Alg, head.Kid)
+	if err != nil {
+		return
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	verified := false
+	for _, k := range keys {
+		if verified = verify(k, digest[:], sig); verified {
+			break
+		}
+	}
+	if !verified {
+		err = errors.Wrapf(core.ErrUnauthorized, "Invalid signature")
+		return
+	}
+
+	if err = decodeSegment(parts[1], &ret); err != nil {
+		return
+	}
+
+	err = v.validate(ret)
+	return
+}
+
+func verify(key Key, digest, sig []byte) bool {
+	switch pub := key.Raw.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func (v *Verifier) validate(claims map[string]interface{}) error {
+	if iss, _ := claims["iss"].(string); iss != v.opts.Issuer {
+		return errors.Wrapf(core.ErrUnauthorized, "Invalid issuer [%v]", iss)
+	}
+
+	if !audienceContains(claims["aud"], v.opts.Audience) {
+		return errors.Wrapf(core.ErrUnauthorized, "Invalid audience [%v]", claims["aud"])
+	}
+
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.Wrapf(core.ErrUnauthorized, "Missing claim [exp]")
+	}
+	if expires := time.Unix(int64(exp), 0); !now.Before(expires.Add(v.opts.Leeway)) {
+		return errors.Wrapf(core.ErrUnauthorized, "Token expired at [%v]", expires)
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if notBefore := time.Unix(int64(nbf), 0); now.Add(v.opts.Leeway).Before(notBefore) {
+			return errors.Wrapf(core.ErrUnauthorized, "Token not valid until [%v]", notBefore)
+		}
+	}
+	return nil
+}
+
+// The audience may be a single value or a list.
+func audienceContains(aud interface{}, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []interface{}:
+		for _, a := range v {
+			if a == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, ptr interface{}) error {
+	buf, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.Wrapf(core.ErrUnauthorized, "Malformed token")
+	}
+	if err := json.Unmarshal(buf, ptr); err != nil {
+		return errors.Wrapf(core.ErrUnauthorized, "Malformed token")
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "catalog"
+)
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if !assert.Nil(t, writeKeySet(path, jwkOf("rsa", &rsaKey.PublicKey), jwkOf("ec", &ecKey.PublicKey))) {
+		return
+	}
+
+	verifier, err := NewVerifier(path,
+		WithIssuer(testIssuer),
+		WithAudience(testAudience),
+		WithKeyRefresh(time.Hour, 0),
+		WithRules(
+			Rule{"groups", "engineering", core.ScopeRead},
+			Rule{"groups", "platform", core.ScopeAdmin},
+			Rule{"scope", "catalog.write", core.ScopeWrite}))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	claims := func(fns ...func(map[string]interface{})) map[string]interface{} {
+		ret := map[string]interface{}{
+			"iss":    testIssuer,
+			"aud":    testAudience,
+			"sub":    "alice",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": []string{"engineering"},
+		}
+		for _, fn := range fns {
+			fn(ret)
+		}
+		return ret
+	}
+
+	if !t.Run("RS256", func(t *testing.T) {
+		principal, err := verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "jwt:"+testIssuer+":alice", principal.Subject)
+		assert.Equal(t, "alice", principal.Name)
+		assert.Equal(t, core.ScopeRead, principal.Scope)
+		assert.Equal(t, []string{"engineering"}, principal.Teams)
+	}) {
+		return
+	}
+
+	if !t.Run("ES256", func(t *testing.T) {
+		principal, err := verifier.Authenticate(sign(t, ES256, "ec", ecKey, claims()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, core.ScopeRead, principal.Scope)
+	}) {
+		return
+	}
+
+	if !t.Run("Rules", func(t *testing.T) {
+		principal, err := verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			c["scope"] = "openid catalog.write"
+		})))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, core.ScopeWrite, principal.Scope)
+
+		principal, err = verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			c["groups"] = []string{"engineering", "platform"}
+		})))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, core.ScopeAdmin, principal.Scope)
+	}) {
+		return
+	}
+
+	if !t.Run("Rules_NoMatch", func(t *testing.T) {
+		principal, err := verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			delete(c, "groups")
+		})))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, errs.Is(principal.Authorize(core.ScopeRead), core.ErrForbidden))
+	}) {
+		return
+	}
+
+	if !t.Run("Audience_List", func(t *testing.T) {
+		_, err := verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			c["aud"] = []string{"other", testAudience}
+		})))
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	invalid := map[string]func(map[string]interface{}){
+		"Issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"Audience": func(c map[string]interface{}) { c["aud"] = "other" },
+		"Expired":  func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"NoExpiry": func(c map[string]interface{}) { delete(c, "exp") },
+		"NotYet":   func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"Subject":  func(c map[string]interface{}) { delete(c, "sub") },
+	}
+	for name, fn := range invalid {
+		if !t.Run("Invalid_"+name, func(t *testing.T) {
+			_, err := verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims(fn)))
+			assert.True(t, errs.Is(err, core.ErrUnauthorized))
+		}) {
+			return
+		}
+	}
+
+	if !t.Run("Invalid_Signature", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		_, err = verifier.Authenticate(sign(t, RS256, "rsa", other, claims()))
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	if !t.Run("Invalid_Algorithm", func(t *testing.T) {
+		head := encodeSegment(t, map[string]string{"alg": "none"})
+		_, err := verifier.Authenticate(head + "." + encodeSegment(t, claims()) + ".")
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+
+		// An RSA key may not verify an ES256 signature.
+		_, err = verifier.Authenticate(sign(t, ES256, "rsa", ecKey, claims()))
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	if !t.Run("Invalid_Malformed", func(t *testing.T) {
+		_, err := verifier.Authenticate("not.a.jwt")
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+	}) {
+		return
+	}
+
+	// Rotated keys are picked up as soon as a token refers to them.
+	if !t.Run("Rotation", func(t *testing.T) {
+		rotated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		token := sign(t, ES256, "rotated", rotated, claims())
+
+		_, err = verifier.Authenticate(token)
+		assert.True(t, errs.Is(err, core.ErrUnauthorized))
+
+		if !assert.Nil(t, writeKeySet(path, jwkOf("rotated", &rotated.PublicKey))) {
+			return
+		}
+
+		_, err = verifier.Authenticate(token)
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("Url", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []interface{}{jwkOf("rsa", &rsaKey.PublicKey)},
+			})
+		}))
+		defer server.Close()
+
+		verifier, err := NewVerifier(server.URL, WithIssuer(testIssuer), WithAudience(testAudience))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		principal, err := verifier.Authenticate(sign(t, RS256, "rsa", rsaKey, claims()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "jwt:"+testIssuer+":alice", principal.Subject)
+		assert.Equal(t, "alice", principal.Name)
+	}) {
+		return
+	}
+
+	if !t.Run("ParseRule", func(t *testing.T) {
+		rule, err := ParseRule("groups:platform:admins=admin")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, Rule{"groups", "platform:admins", core.ScopeAdmin}, rule)
+
+		_, err = ParseRule("groups=admin")
+		assert.True(t, errs.Is(err, core.ErrState))
+
+		_, err = ParseRule("groups:platform=root")
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+}
+
+func jwkOf(kid string, key crypto.PublicKey) map[string]string {
+	enc := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": enc(k.N), "e": enc(big.NewInt(int64(k.E)))}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": enc(k.X), "y": enc(k.Y)}
+	}
+	panic("Unsupported key")
+}
+
+func writeKeySet(path string, keys ...map[string]string) error {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func encodeSegment(t *testing.T, val interface{}) string {
+	data, err := json.Marshal(val)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	input := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); !assert.Nil(t, err) {
+			t.FailNow()
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
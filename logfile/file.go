@@ -0,0 +1,142 @@
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	MaxSize    int64
+	MaxBackups int
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{MaxSize: 100 << 20, MaxBackups: 5}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the size in bytes at which the file is rotated (0 never rotates).
+func WithMaxSize(size int64) Option {
+	return func(o *Options) {
+		o.MaxSize = size
+	}
+}
+
+// Sets the number of rotated files that are retained.
+func WithMaxBackups(num int) Option {
+	return func(o *Options) {
+		o.MaxBackups = num
+	}
+}
+
+// A file that rotates once it reaches its maximum size.  The current file
+// keeps its path, and rotated files are suffixed by their age (e.g. the
+// most recently rotated file is <path>.1).  Writes are never split across
+// files, so a file may exceed its maximum by up to one write.
+type File struct {
+	opts  Options
+	path  string
+	lock  sync.Mutex
+	file  *os.File
+	size  int64
+	moved bool // the current file was moved by a rotation that failed to open its replacement
+}
+
+// Opens the file for appending, creating it if necessary.
+func Open(path string, fns ...Option) (ret *File, err error) {
+	ret = &File{opts: buildOptions(fns...), path: path}
+	if err = ret.open(); err != nil {
+		ret = nil
+	}
+	return
+}
+
+func (f *File) open() (err error) {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	f.file, f.size = file, info.Size()
+	return
+}
+
+func (f *File) Write(p []byte) (n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize {
+		if err = f.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return
+}
+
+// Shifts every rotated file to the next suffix, discarding the oldest, and
+// then moves the current file to the first suffix.  The current file is only
+// closed once its replacement is open, so a failed rotation leaves the file
+// writable (and the next write retries the rotation).  If the replacement
+// failed to open, the files have already been moved, so the retry only opens
+// the replacement.  An error closing the rotated file is reported on stderr
+// rather than failing the write, since the rotation itself succeeded.
+func (f *File) rotate() (err error) {
+	if !f.moved {
+		if err = f.move(); err != nil {
+			return
+		}
+		f.moved = true
+	}
+
+	prev := f.file
+	if err = f.open(); err != nil {
+		return
+	}
+	f.moved = false
+
+	if err := prev.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error closing rotated log file [%v]: %v\n", f.backup(1), err)
+	}
+	return
+}
+
+func (f *File) move() (err error) {
+	if f.opts.MaxBackups > 0 {
+		for i := f.opts.MaxBackups - 1; i > 0; i-- {
+			if err = os.Rename(f.backup(i), f.backup(i+1)); err != nil && !os.IsNotExist(err) {
+				return
+			}
+		}
+		if err = os.Rename(f.path, f.backup(1)); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	} else if err = os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	return nil
+}
+
+func (f *File) backup(i int) string {
+	return fmt.Sprintf("%v.%v", f.path, i)
+}
+
+func (f *File) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Close()
+}
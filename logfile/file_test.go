@@ -0,0 +1,136 @@
+package logfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logfile")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	read := func(path string) string {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	file, err := Open(path, WithMaxSize(8), WithMaxBackups(2))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !t.Run("Write", func(t *testing.T) {
+		_, err := file.Write([]byte("aaaa\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, "aaaa\n", read(path))
+	}) {
+		return
+	}
+
+	if !t.Run("Rotate", func(t *testing.T) {
+		for _, line := range []string{"bbbb\n", "cccc\n", "dddd\n"} {
+			if _, err := file.Write([]byte(line)); !assert.Nil(t, err) {
+				return
+			}
+		}
+
+		assert.Equal(t, "dddd\n", read(path))
+		assert.Equal(t, "cccc\n", read(path+".1"))
+		assert.Equal(t, "bbbb\n", read(path+".2"))
+		assert.Equal(t, "", read(path+".3"))
+	}) {
+		return
+	}
+
+	if !t.Run("Reopen", func(t *testing.T) {
+		if !assert.Nil(t, file.Close()) {
+			return
+		}
+
+		file, err = Open(path, WithMaxSize(8), WithMaxBackups(2))
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer file.Close()
+
+		_, err := file.Write([]byte("eeee\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, "eeee\n", read(path))
+		assert.Equal(t, "dddd\n", read(path+".1"))
+	}) {
+		return
+	}
+
+	if !t.Run("Rotate_Failure", func(t *testing.T) {
+		path := filepath.Join(dir, "error.log")
+
+		file, err := Open(path, WithMaxSize(8), WithMaxBackups(1))
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer file.Close()
+
+		if _, err := file.Write([]byte("aaaa\n")); !assert.Nil(t, err) {
+			return
+		}
+
+		// A (non-empty) directory can't be replaced by the rotated file.
+		if !assert.Nil(t, os.MkdirAll(filepath.Join(path+".1", "dir"), 0755)) {
+			return
+		}
+
+		_, err = file.Write([]byte("bbbb\n"))
+		assert.NotNil(t, err)
+
+		if !assert.Nil(t, os.RemoveAll(path+".1")) {
+			return
+		}
+
+		_, err = file.Write([]byte("cccc\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, "cccc\n", read(path))
+		assert.Equal(t, "aaaa\n", read(path+".1"))
+	}) {
+		return
+	}
+
+	if !t.Run("Rotate_OpenFailure", func(t *testing.T) {
+		path := filepath.Join(dir, "moved.log")
+
+		file, err := Open(path, WithMaxSize(8), WithMaxBackups(2))
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer file.Close()
+
+		if _, err := file.Write([]byte("aaaa\n")); !assert.Nil(t, err) {
+			return
+		}
+
+		// As though a rotation moved the file but failed to open its
+		// replacement.
+		if !assert.Nil(t, os.Rename(path, path+".1")) {
+			return
+		}
+		file.moved = true
+
+		_, err = file.Write([]byte("bbbb\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, "bbbb\n", read(path))
+		assert.Equal(t, "aaaa\n", read(path+".1"))
+		assert.Equal(t, "", read(path+".2"))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,68 @@
+package logfile
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+)
+
+// A level that may be changed while it's in use.
+type Level struct {
+	val int32
+}
+
+func NewLevel(lvl context.LogLevel) *Level {
+	return &Level{int32(lvl)}
+}
+
+func (l *Level) Get() context.LogLevel {
+	return context.LogLevel(atomic.LoadInt32(&l.val))
+}
+
+func (l *Level) Set(lvl context.LogLevel) {
+	atomic.StoreInt32(&l.val, int32(lvl))
+}
+
+// Returns a logger whose level is read from the given level on every call,
+// so that changing the level applies to every logger derived from it.
+func NewLogger(out io.Writer, lvl *Level) context.Logger {
+	return &logger{out, lvl, ""}
+}
+
+type logger struct {
+	out    io.Writer
+	lvl    *Level
+	prefix string
+}
+
+func (l *logger) Level() context.LogLevel {
+	return l.lvl.Get()
+}
+
+func (l *logger) Out() io.Writer {
+	return l.out
+}
+
+func (l *logger) Fmt(format string, args ...interface{}) context.Logger {
+	return &logger{l.out, l.lvl, fmt.Sprintf("%v: %v", l.prefix, fmt.Sprintf(format, args...))}
+}
+
+func (l *logger) Debug(format string, args ...interface{}) {
+	l.println(context.Debug, format, args...)
+}
+
+func (l *logger) Info(format string, args ...interface{}) {
+	l.println(context.Info, format, args...)
+}
+
+func (l *logger) Error(format string, args ...interface{}) {
+	l.println(context.Error, format, args...)
+}
+
+func (l *logger) println(lvl context.LogLevel, format string, args ...interface{}) {
+	if l.lvl.Get() >= lvl {
+		fmt.Fprintf(l.out, "%v: %v\n", l.prefix, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,46 @@
+package logfile
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+
+	level := NewLevel(context.Info)
+	root := NewLogger(&out, level)
+	child := root.Fmt("Child(%v)", 1)
+
+	if !t.Run("Level", func(t *testing.T) {
+		root.Debug("hidden")
+		root.Info("shown")
+		child.Error("failed")
+
+		assert.Equal(t, ": shown\n: Child(1): failed\n", out.String())
+		assert.Equal(t, context.Info, child.Level())
+	}) {
+		return
+	}
+
+	if !t.Run("SetLevel_Children", func(t *testing.T) {
+		out.Reset()
+
+		level.Set(context.Debug)
+		child.Debug("debugging")
+		assert.Equal(t, ": Child(1): debugging\n", out.String())
+		assert.Equal(t, context.Debug, root.Level())
+
+		out.Reset()
+
+		level.Set(context.Off)
+		root.Error("hidden")
+		child.Error("hidden")
+		assert.Empty(t, out.String())
+	}) {
+		return
+	}
+}
@@ -19,6 +19,15 @@ var (
 		cli.StartCommand,
 		cli.ListServicesCommand,
 		cli.LoadServicesCommand,
+		cli.WatchCommand,
+		cli.ExportCommand,
+		cli.ImportCommand,
+		cli.PlanCommand,
+		cli.ApplyCommand,
+		cli.DbCommand,
+		cli.TokenCommand,
+		cli.TransferCommand,
+		cli.AuditCommand,
 	)
 )
 
@@ -0,0 +1,143 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// A manifest declares the desired state of some portion of the catalog.
+// Manifests are intended to be kept in source control, so they only
+// describe what a person would write by hand.  Revisions, timestamps and
+// (optionally) ids are managed by the catalog.
+//
+// For example:
+//
+//	services:
+//	  - name: payments
+//	    desc: Accepts payments
+//	    versions: [v1, v2]
+type Manifest struct {
+	Services []Service `yaml:"services"`
+}
+
+// A service manifest.  Because service names need not be unique, a
+// manifest may pin the id of the service it describes.  Otherwise, the
+// service is matched by name, which must then be unambiguous.  Versions
+// are immutable, so only their names are declared.
+type Service struct {
+	Id       *uuid.UUID `yaml:"id,omitempty"`
+	Name     string     `yaml:"name"`
+	Desc     string     `yaml:"desc"`
+	Versions []string   `yaml:"versions,omitempty"`
+
+	// The file that declared the service.  Used for error reporting.
+	Source string `yaml:"-"`
+}
+
+// Loads every manifest within the path.  The path may be a single file or
+// a directory, which is searched recursively for .yaml and .yml files.
+// The services are returned in lexical order of their files.
+func Load(path string) (ret []Service, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch filepath.Ext(file) {
+			case ".yaml", ".yml":
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	for _, file := range files {
+		var services []Service
+		if services, err = loadFile(file); err != nil {
+			return
+		}
+		ret = append(ret, services...)
+	}
+
+	err = Validate(ret)
+	return
+}
+
+func loadFile(file string) (ret []Service, err error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	var manifest Manifest
+	if err = enc.Yaml.DecodeBinary(raw, &manifest); err != nil {
+		err = errors.Wrapf(core.ErrState, "Invalid manifest [%v]: %v", file, err)
+		return
+	}
+
+	ret = manifest.Services
+	for i := range ret {
+		ret[i].Source = file
+	}
+	return
+}
+
+// Verifies that the services are complete and that no service or version
+// is declared twice.
+func Validate(services []Service) error {
+	ids := make(map[uuid.UUID]string)
+	names := make(map[string]string)
+	for _, svc := range services {
+		switch {
+		case svc.Name == "":
+			return errors.Wrapf(core.ErrState, "Invalid manifest [%v]. Service name must not be empty", svc.Source)
+		case svc.Desc == "":
+			return errors.Wrapf(core.ErrState, "Invalid manifest [%v]. Service [%v] must have a description", svc.Source, svc.Name)
+		}
+
+		// Services without ids are identified by their names, so those
+		// names must be unique amongst the manifests.
+		if svc.Id != nil {
+			if prev, ok := ids[*svc.Id]; ok {
+				return errors.Wrapf(core.ErrState, "Service [%v] is declared by both [%v] and [%v]", *svc.Id, prev, svc.Source)
+			}
+			ids[*svc.Id] = svc.Source
+		} else {
+			if prev, ok := names[svc.Name]; ok {
+				return errors.Wrapf(core.ErrState, "Service [%v] is declared by both [%v] and [%v]. Set an id to disambiguate", svc.Name, prev, svc.Source)
+			}
+			names[svc.Name] = svc.Source
+		}
+
+		versions := make(map[string]bool)
+		for _, v := range svc.Versions {
+			switch {
+			case v == "":
+				return errors.Wrapf(core.ErrState, "Invalid manifest [%v]. Service [%v] has an empty version", svc.Source, svc.Name)
+			case versions[v]:
+				return errors.Wrapf(core.ErrState, "Invalid manifest [%v]. Service [%v] declares version [%v] twice", svc.Source, svc.Name, v)
+			}
+			versions[v] = true
+		}
+	}
+	return nil
+}
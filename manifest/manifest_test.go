@@ -0,0 +1,236 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	dir, err := ioutil.TempDir("", "catalog-manifest-test")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := svcsql.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	write := func(name, body string) error {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, []byte(body), 0600)
+	}
+
+	plan := func(prune bool) (ret Plan, err error) {
+		services, err := Load(dir)
+		if err != nil {
+			return
+		}
+
+		snapshot, err := store.Export(false)
+		if err != nil {
+			return
+		}
+		return NewPlan(services, snapshot, prune)
+	}
+
+	apply := func(p Plan) error {
+		_, err := store.SaveBatch(p.Writes())
+		return err
+	}
+
+	legacy := core.NewService("legacy", "desc")
+	if !assert.Nil(t, store.SaveService(legacy)) {
+		return
+	}
+
+	if !t.Run("Load", func(t *testing.T) {
+		if !assert.Nil(t, write("payments.yaml", `
+services:
+  - name: payments
+    desc: Accepts payments
+    versions: [v1, v2]
+`)) {
+			return
+		}
+		if !assert.Nil(t, write("nested/billing.yml", `
+services:
+  - name: billing
+    desc: Sends bills
+`)) {
+			return
+		}
+		if !assert.Nil(t, write("README.md", "ignored")) {
+			return
+		}
+
+		services, err := Load(dir)
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(services)) {
+			return
+		}
+		assert.Equal(t, "billing", services[0].Name)
+		assert.Equal(t, "payments", services[1].Name)
+		assert.Equal(t, []string{"v1", "v2"}, services[1].Versions)
+	}) {
+		return
+	}
+
+	if !t.Run("Plan_Create", func(t *testing.T) {
+		p, err := plan(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		counts := p.Counts()
+		assert.Equal(t, 2, counts[CreateService])
+		assert.Equal(t, 2, counts[CreateVersion])
+		assert.Equal(t, 0, counts[DeleteService])
+		assert.Nil(t, apply(p))
+	}) {
+		return
+	}
+
+	if !t.Run("Plan_Empty", func(t *testing.T) {
+		p, err := plan(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, p.Empty())
+	}) {
+		return
+	}
+
+	if !t.Run("Plan_Update", func(t *testing.T) {
+		if !assert.Nil(t, write("payments.yaml", `
+services:
+  - name: payments
+    desc: Accepts and refunds payments
+    versions: [v1, v2, v3]
+`)) {
+			return
+		}
+
+		p, err := plan(false)
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(p.Actions)) {
+			return
+		}
+
+		update := p.Actions[0]
+		assert.Equal(t, UpdateService, update.Type)
+		assert.Equal(t, update.Prior.Version+1, update.Service.Version)
+		assert.Equal(t, CreateVersion, p.Actions[1].Type)
+		assert.Equal(t, "v3", p.Actions[1].Version.Name)
+		assert.Nil(t, apply(p))
+	}) {
+		return
+	}
+
+	if !t.Run("Plan_Stale", func(t *testing.T) {
+		if !assert.Nil(t, write("payments.yaml", `
+services:
+  - name: payments
+    desc: Refunds payments
+    versions: [v1, v2, v3]
+`)) {
+			return
+		}
+
+		p, err := plan(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		// Someone else updates the service after the plan.
+		svc := p.Actions[0].Prior.SetDesc("changed").Increment()
+		if !assert.Nil(t, store.SaveService(svc)) {
+			return
+		}
+		assert.True(t, errs.Is(apply(p), core.ErrConflict))
+	}) {
+		return
+	}
+
+	if !t.Run("Plan_Prune", func(t *testing.T) {
+		if !assert.Nil(t, write("payments.yaml", `
+services:
+  - name: payments
+    desc: Refunds payments
+    versions: [v3]
+`)) {
+			return
+		}
+
+		p, err := plan(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		counts := p.Counts()
+		assert.Equal(t, 1, counts[UpdateService])
+		assert.Equal(t, 2, counts[DeleteVersion])
+		assert.Equal(t, 1, counts[DeleteService])
+		if !assert.Nil(t, apply(p)) {
+			return
+		}
+
+		catalog, err := store.ListServices(core.EmptyFilter, core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(catalog.Services)) {
+			return
+		}
+
+		p, err = plan(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, p.Empty())
+	}) {
+		return
+	}
+
+	if !t.Run("Plan_Ambiguous", func(t *testing.T) {
+		if !assert.Nil(t, store.SaveService(core.NewService("billing", "Another billing service"))) {
+			return
+		}
+
+		_, err := plan(false)
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("Load_Duplicate", func(t *testing.T) {
+		if !assert.Nil(t, write("duplicate.yaml", `
+services:
+  - name: payments
+    desc: Accepts payments
+`)) {
+			return
+		}
+
+		_, err := Load(dir)
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,231 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The type of change made by an action.
+type ActionType string
+
+const (
+	CreateService ActionType = "create"
+	UpdateService ActionType = "update"
+	DeleteService ActionType = "delete"
+	CreateVersion ActionType = "create-version"
+	DeleteVersion ActionType = "delete-version"
+)
+
+// An action is a single change required to bring the catalog in line
+// with the manifests.  Service actions carry the service as it will be
+// written.  Updates also carry the live service they replace.  Version
+// actions carry the version along with the service it belongs to.
+type Action struct {
+	Type    ActionType
+	Service core.Service
+	Prior   *core.Service
+	Version *core.Version
+}
+
+// A plan is the set of actions that brings the catalog in line with the
+// manifests.  Updates are written at the revision following the one that
+// was planned against, so the plan is only applied if none of the
+// services it updates or deletes have since changed.
+type Plan struct {
+	Actions []Action
+}
+
+func (p Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Returns the number of actions of each type.
+func (p Plan) Counts() (ret map[ActionType]int) {
+	ret = make(map[ActionType]int)
+	for _, a := range p.Actions {
+		ret[a.Type]++
+	}
+	return
+}
+
+// Returns the writes that apply the plan, in order.  Each batch is atomic,
+// but the server bounds the size of a batch, so a larger plan must be split
+// into several batches (in order) and a failure leaves the earlier batches
+// applied.
+func (p Plan) Writes() (ret []core.Write) {
+	ret = make([]core.Write, 0, len(p.Actions))
+	for _, a := range p.Actions {
+		switch a.Type {
+		case CreateService, UpdateService:
+			ret = append(ret, core.SaveServiceWrite(a.Service))
+		case DeleteService:
+			ret = append(ret, core.DeleteServiceWrite(a.Service))
+		case CreateVersion:
+			ret = append(ret, core.SaveVersionWrite(*a.Version))
+		case DeleteVersion:
+			ret = append(ret, core.DeleteVersionWrite(*a.Version))
+		}
+	}
+	return
+}
+
+// Prints the plan as a diff.  Additions are prefixed with '+', updates
+// with '~' and deletions with '-'.
+func (p Plan) Print(w io.Writer) {
+	for _, a := range p.Actions {
+		switch a.Type {
+		case CreateService:
+			fmt.Fprintf(w, "+ service %v [%v]\n", a.Service.Name, a.Service.Id)
+			fmt.Fprintf(w, "    desc: %q\n", a.Service.Desc)
+		case UpdateService:
+			fmt.Fprintf(w, "~ service %v [%v] (version %v -> %v)\n", a.Service.Name, a.Service.Id, a.Prior.Version, a.Service.Version)
+			if a.Prior.Name != a.Service.Name {
+				fmt.Fprintf(w, "    name: %q -> %q\n", a.Prior.Name, a.Service.Name)
+			}
+			if a.Prior.Desc != a.Service.Desc {
+				fmt.Fprintf(w, "    desc: %q -> %q\n", a.Prior.Desc, a.Service.Desc)
+			}
+		case DeleteService:
+			fmt.Fprintf(w, "- service %v [%v]\n", a.Service.Name, a.Service.Id)
+		case CreateVersion:
+			fmt.Fprintf(w, "+ version %v/%v\n", a.Service.Name, a.Version.Name)
+		case DeleteVersion:
+			fmt.Fprintf(w, "- version %v/%v\n", a.Service.Name, a.Version.Name)
+		}
+	}
+
+	counts := p.Counts()
+	fmt.Fprintf(w, "Plan: %v to create, %v to update, %v to delete. %v versions to create, %v to delete.\n",
+		counts[CreateService],
+		counts[UpdateService],
+		counts[DeleteService],
+		counts[CreateVersion],
+		counts[DeleteVersion])
+}
+
+// Computes the plan that brings the catalog, as described by the snapshot,
+// in line with the manifests.  The snapshot must only contain the latest
+// revision of each service.  When pruning, any service or version that is
+// not declared by a manifest is deleted.  Otherwise, the manifests are
+// purely additive.
+//
+// Services are created and updated before their versions are created, and
+// deletions come last.
+func NewPlan(manifests []Service, snapshot core.Snapshot, prune bool) (ret Plan, err error) {
+	if err = Validate(manifests); err != nil {
+		return
+	}
+	if snapshot.History {
+		err = errors.Wrapf(core.ErrState, "Plans require a snapshot of the latest services")
+		return
+	}
+
+	byId := make(map[uuid.UUID]core.Service)
+	byName := make(map[string][]core.Service)
+	for _, svc := range snapshot.Services {
+		byId[svc.Id] = svc
+		byName[svc.Name] = append(byName[svc.Name], svc)
+	}
+
+	versions := make(map[uuid.UUID][]core.Version)
+	for _, v := range snapshot.Versions {
+		versions[v.ServiceId] = append(versions[v.ServiceId], v)
+	}
+
+	var services, creates, deletes []Action
+
+	claimed := make(map[uuid.UUID]string)
+	for _, m := range manifests {
+		live, found, e := match(m, byId, byName)
+		if e != nil {
+			err = e
+			return
+		}
+
+		var svc core.Service
+		switch {
+		case !found:
+			svc = core.NewService(m.Name, m.Desc)
+			if m.Id != nil {
+				svc = svc.SetId(*m.Id)
+			}
+			services = append(services, Action{Type: CreateService, Service: svc})
+		case live.Name != m.Name || live.Desc != m.Desc:
+			prior := live
+			svc = live.Update(func(s *core.Service) {
+				s.Name, s.Desc = m.Name, m.Desc
+			}).Increment()
+			services = append(services, Action{Type: UpdateService, Service: svc, Prior: &prior})
+		default:
+			svc = live
+		}
+
+		if prev, ok := claimed[svc.Id]; ok {
+			err = errors.Wrapf(core.ErrState, "Service [%v] is declared by both [%v] and [%v]", svc.Id, prev, m.Source)
+			return
+		}
+		claimed[svc.Id] = m.Source
+
+		existing := make(map[string]bool)
+		for _, v := range versions[svc.Id] {
+			existing[v.Name] = true
+		}
+
+		declared := make(map[string]bool)
+		for _, name := range m.Versions {
+			declared[name] = true
+			if !existing[name] {
+				v := core.NewVersion(svc.Id, name)
+				creates = append(creates, Action{Type: CreateVersion, Service: svc, Version: &v})
+			}
+		}
+
+		if prune {
+			for _, v := range versions[svc.Id] {
+				if !declared[v.Name] {
+					v := v
+					deletes = append(deletes, Action{Type: DeleteVersion, Service: svc, Version: &v})
+				}
+			}
+		}
+	}
+
+	// Deleting a service deletes its versions, so those aren't listed.
+	if prune {
+		for _, svc := range snapshot.Services {
+			if _, ok := claimed[svc.Id]; !ok {
+				deletes = append(deletes, Action{Type: DeleteService, Service: svc})
+			}
+		}
+	}
+
+	ret.Actions = append(ret.Actions, services...)
+	ret.Actions = append(ret.Actions, creates...)
+	ret.Actions = append(ret.Actions, deletes...)
+	return
+}
+
+// Finds the live service described by the manifest.  Services with pinned
+// ids are matched by id.  Otherwise, the name must match exactly one
+// service.
+func match(m Service, byId map[uuid.UUID]core.Service, byName map[string][]core.Service) (ret core.Service, ok bool, err error) {
+	if m.Id != nil {
+		ret, ok = byId[*m.Id]
+		return
+	}
+
+	switch matches := byName[m.Name]; len(matches) {
+	case 0:
+		return
+	case 1:
+		ret, ok = matches[0], true
+		return
+	default:
+		err = errors.Wrapf(core.ErrState, "Service [%v] in [%v] matches [%v] services. Set an id to disambiguate", m.Name, m.Source, len(matches))
+		return
+	}
+}
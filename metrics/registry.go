@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The mime type of the Prometheus text format.
+const Mime = "text/plain; version=0.0.4"
+
+// The default histogram buckets, in seconds.  These are the buckets used by
+// the Prometheus client libraries.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// A registry holds a set of metrics and writes them in the Prometheus text
+// format.  Metrics are written in the order they were registered.
+type Registry struct {
+	lock    sync.Mutex
+	metrics []metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type metric interface {
+	write(w *bufio.Writer) error
+}
+
+func (r *Registry) register(m metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Registers a counter with the given labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	ret := &Counter{family: newFamily(name, help, labels), values: make(map[string]float64)}
+	r.register(ret)
+	return ret
+}
+
+// Registers a histogram with the given buckets (in increasing order) and
+// labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	ret := &Histogram{family: newFamily(name, help, labels), buckets: buckets, values: make(map[string]*histogram)}
+	r.register(ret)
+	return ret
+}
+
+// Registers a gauge whose value is read when the registry is written.  A
+// gauge that fails to be read is omitted.
+func (r *Registry) GaugeFunc(name, help string, fn func() (float64, error)) {
+	r.register(&gaugeFunc{newFamily(name, help, nil), fn})
+}
+
+// Writes every metric in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) (err error) {
+	r.lock.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.lock.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		if err = m.write(buf); err != nil {
+			return
+		}
+	}
+	return buf.Flush()
+}
+
+// A family is a named metric, with a series per distinct set of label values.
+type family struct {
+	lock   sync.Mutex
+	name   string
+	help   string
+	labels []string
+}
+
+func newFamily(name, help string, labels []string) family {
+	return family{name: name, help: help, labels: labels}
+}
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("Metric [%v] expects labels %v. Got %v", f.name, f.labels, values))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (f *family) header(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, typ)
+}
+
+// Formats the label set of a series, with an optional extra label (e.g. the
+// upper bound of a histogram bucket).
+func (f *family) format(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, val := range strings.Split(key, "\xff") {
+			pairs = append(pairs, f.labels[i]+"="+quote(val))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+quote(extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func quote(val string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(val) + `"`
+}
+
+func formatFloat(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "+Inf"
+	case math.IsInf(val, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+// Returns the keys of the series in order, so that output is stable.
+func sortedKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
+
+// A counter only increases.
+type Counter struct {
+	family
+	values map[string]float64
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *Counter) Add(val float64, labels ...string) {
+	key := c.key(labels)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[key] += val
+}
+
+// Returns the value of the series with the given labels.
+func (c *Counter) Value(labels ...string) float64 {
+	key := c.key(labels)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w *bufio.Writer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.header(w, "counter")
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	for _, k := range sortedKeys(keys) {
+		fmt.Fprintf(w, "%v%v %v\n", c.name, c.format(k), formatFloat(c.values[k]))
+	}
+	return nil
+}
+
+// A histogram counts observations by bucket.
+type Histogram struct {
+	family
+	buckets []float64
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *Histogram) Observe(val float64, labels ...string) {
+	key := h.key(labels)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for i, bound := range h.buckets {
+		if val <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += val
+}
+
+// Returns the number of observations of the series with the given labels.
+func (h *Histogram) Count(labels ...string) uint64 {
+	key := h.key(labels)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if s, ok := h.values[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.header(w, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	for _, k := range sortedKeys(keys) {
+		s := h.values[k]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%v_bucket%v %v\n", h.name, h.format(k, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", h.name, h.format(k, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", h.name, h.format(k), formatFloat(s.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", h.name, h.format(k), s.count)
+	}
+	return nil
+}
+
+type gaugeFunc struct {
+	family
+	fn func() (float64, error)
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) error {
+	val, err := g.fn()
+	if err != nil {
+		return nil
+	}
+
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%v %v\n", g.name, formatFloat(val))
+	return nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.Counter("requests_total", "The number of requests", "method", "path")
+	latency := reg.Histogram("latency_seconds", "The latency", []float64{.1, 1}, "method")
+	reg.GaugeFunc("size", "The size", func() (float64, error) {
+		return 3, nil
+	})
+	reg.GaugeFunc("broken", "Fails to be read", func() (float64, error) {
+		return 0, errors.New("failed")
+	})
+
+	requests.Inc("GET", `/a"b`)
+	requests.Add(2, "GET", "/")
+	latency.Observe(.05, "GET")
+	latency.Observe(.5, "GET")
+	latency.Observe(5, "GET")
+
+	if !t.Run("Values", func(t *testing.T) {
+		assert.Equal(t, float64(2), requests.Value("GET", "/"))
+		assert.Equal(t, float64(0), requests.Value("PUT", "/"))
+		assert.Equal(t, uint64(3), latency.Count("GET"))
+		assert.Panics(t, func() {
+			requests.Inc("GET")
+		})
+	}) {
+		return
+	}
+
+	if !t.Run("Write", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.Nil(t, reg.Write(&buf)) {
+			return
+		}
+
+		assert.Equal(t, `# HELP requests_total The number of requests
+# TYPE requests_total counter
+requests_total{method="GET",path="/"} 2
+requests_total{method="GET",path="/a\"b"} 1
+# HELP latency_seconds The latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="1"} 2
+latency_seconds_bucket{method="GET",le="+Inf"} 3
+latency_seconds_sum{method="GET"} 5.55
+latency_seconds_count{method="GET"} 3
+# HELP size The size
+# TYPE size gauge
+size 3
+`, buf.String())
+	}) {
+		return
+	}
+}
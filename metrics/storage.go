@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	Refresh time.Duration
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{Refresh: 15 * time.Second}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets how long the sizes of the catalog are reused before being counted
+// again.  Storage that can't count the catalog (see core.CountedStorage) is
+// counted by exporting it.
+func WithRefresh(dur time.Duration) Option {
+	return func(o *Options) {
+		o.Refresh = dur
+	}
+}
+
+// Storage instruments any storage implementation.  Every method records its
+// latency and whether it failed with a conflict, and the registry reports the
+// number of services and versions in the catalog.
+type Storage struct {
+	raw core.Storage
+	*instruments
+}
+
+// The instruments are shared by every storage attributed to an actor.
+type instruments struct {
+	opts      Options
+	latency   *Histogram
+	conflicts *Counter
+
+	lock     sync.Mutex
+	counted  time.Time
+	services int
+	versions int
+}
+
+func NewStorage(raw core.Storage, reg *Registry, fns ...Option) *Storage {
+	ret := &Storage{raw, &instruments{
+		opts: buildOptions(fns...),
+		latency: reg.Histogram("catalog_storage_duration_seconds",
+			"The latency of storage operations", DefaultBuckets, "method"),
+		conflicts: reg.Counter("catalog_storage_conflicts_total",
+			"The number of storage operations that failed with a conflict", "method"),
+	}}
+
+	reg.GaugeFunc("catalog_services", "The number of services in the catalog", func() (float64, error) {
+		services, _, err := ret.sizes()
+		return float64(services), err
+	})
+	reg.GaugeFunc("catalog_versions", "The number of versions in the catalog", func() (float64, error) {
+		_, versions, err := ret.sizes()
+		return float64(versions), err
+	})
+	return ret
+}
+
+// Attributes writes to the actor (if the underlying storage is audited).
+// The returned storage shares the instruments of this one.
+func (s *Storage) WithActor(actor core.Actor) core.Storage {
+	return &Storage{core.Attribute(s.raw, actor), s.instruments}
+}
+
+// Traces operations as children of the span (if the underlying storage is
+// traced).  The returned storage shares the instruments of this one.
+func (s *Storage) WithSpan(span core.Span) core.Storage {
+	return &Storage{core.Trace(s.raw, span), s.instruments}
+}
+
+// Guards writes (if the underlying storage is guarded).  The returned
+// storage shares the instruments of this one.
+func (s *Storage) WithGuard(guard core.Guard) core.Storage {
+	return &Storage{core.Guarded(s.raw, guard), s.instruments}
+}
+
+// Records the latency and outcome of a method.  Should be deferred with
+// a pointer to the method's error.
+func (s *Storage) observe(method string, start time.Time, err *error) {
+	s.latency.Observe(time.Since(start).Seconds(), method)
+	if *err != nil && errs.Is(*err, core.ErrConflict) {
+		s.conflicts.Inc(method)
+	}
+}
+
+func (s *Storage) SaveService(svc core.Service) (err error) {
+	defer s.observe("SaveService", time.Now(), &err)
+	err = s.raw.SaveService(svc)
+	return
+}
+
+func (s *Storage) SaveVersion(v core.Version) (err error) {
+	defer s.observe("SaveVersion", time.Now(), &err)
+	err = s.raw.SaveVersion(v)
+	return
+}
+
+func (s *Storage) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	defer s.observe("SaveBatch", time.Now(), &err)
+	ret, err = s.raw.SaveBatch(writes)
+	return
+}
+
+func (s *Storage) ListServices(filter core.Filter, page core.Page) (ret core.Catalog, err error) {
+	defer s.observe("ListServices", time.Now(), &err)
+	ret, err = s.raw.ListServices(filter, page)
+	return
+}
+
+func (s *Storage) ListChanges(since uint64, limit uint64) (ret []core.Change, err error) {
+	defer s.observe("ListChanges", time.Now(), &err)
+	ret, err = s.raw.ListChanges(since, limit)
+	return
+}
+
+func (s *Storage) LatestSeq() (ret uint64, err error) {
+	defer s.observe("LatestSeq", time.Now(), &err)
+	ret, err = s.raw.LatestSeq()
+	return
+}
+
+func (s *Storage) Export(history bool) (ret core.Snapshot, err error) {
+	defer s.observe("Export", time.Now(), &err)
+	ret, err = s.raw.Export(history)
+	return
+}
+
+func (s *Storage) Import(snapshot core.Snapshot, mode core.ImportMode) (ret core.ImportResult, err error) {
+	defer s.observe("Import", time.Now(), &err)
+	ret, err = s.raw.Import(snapshot, mode)
+	return
+}
+
+// Returns the number of services and versions, counting them again if the
+// last count is older than the refresh interval.  Counts are read from the
+// underlying storage, so that they aren't recorded as operations.
+func (s *Storage) sizes() (services, versions int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if time.Since(s.counted) < s.opts.Refresh {
+		return s.services, s.versions, nil
+	}
+
+	if services, versions, err = core.Count(s.raw); err != nil {
+		return
+	}
+
+	s.services, s.versions, s.counted = services, versions, time.Now()
+	return
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, e := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, e) {
+		return
+	}
+
+	raw, err := svcsql.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	reg := NewRegistry()
+	store := NewStorage(raw, reg, WithRefresh(0))
+
+	svc := core.NewService("name", "desc")
+	if !t.Run("SaveService", func(t *testing.T) {
+		if !assert.Nil(t, store.SaveService(svc)) {
+			return
+		}
+		if !assert.Nil(t, store.SaveVersion(core.NewVersion(svc.Id, "1.0"))) {
+			return
+		}
+
+		assert.Equal(t, uint64(1), store.latency.Count("SaveService"))
+		assert.Equal(t, uint64(1), store.latency.Count("SaveVersion"))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Conflict", func(t *testing.T) {
+		err := store.SaveService(svc)
+		assert.True(t, errs.Is(err, core.ErrConflict))
+		assert.Equal(t, float64(1), store.conflicts.Value("SaveService"))
+		assert.Equal(t, uint64(2), store.latency.Count("SaveService"))
+	}) {
+		return
+	}
+
+	if !t.Run("WithActor", func(t *testing.T) {
+		_, err := core.Attribute(store, core.Actor{Subject: "token:ci"}).ListServices(core.NewFilter(), core.NewPage())
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), store.latency.Count("ListServices"))
+	}) {
+		return
+	}
+
+	if !t.Run("Write", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.Nil(t, reg.Write(&buf)) {
+			return
+		}
+
+		out := buf.String()
+		assert.Contains(t, out, `catalog_storage_duration_seconds_count{method="SaveService"} 2`)
+		assert.Contains(t, out, `catalog_storage_conflicts_total{method="SaveService"} 1`)
+		assert.Contains(t, out, "catalog_services 1\n")
+		assert.Contains(t, out, "catalog_versions 1\n")
+
+		// counting the catalog isn't recorded as an operation
+		assert.Equal(t, uint64(0), store.latency.Count("Export"))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,161 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The audit log is an append-only table, written by the same atomics that
+// write the catalog.  Like the change log, sequences are allocated within
+// the writing transaction, which also serializes the hash chain.
+var (
+	SchemaAudit = sql.NewSchema("audit", 0).
+		WithStruct(auditRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_audit_seq", "seq"),
+			sql.NewIndex("idx_audit_service", "service_id", "seq"),
+			sql.NewIndex("idx_audit_actor", "actor", "seq")).
+		Build()
+)
+
+type auditRow struct {
+	Seq       uint64
+	Actor     string
+	ClientIp  string
+	RequestId string
+	Operation core.ChangeType
+	ServiceId uuid.UUID
+	Before    []byte // json encoded service or version
+	After     []byte // json encoded service or version
+	Created   time.Time
+	Prev      string
+	Hash      string
+}
+
+func newAuditRow(e core.AuditEntry) auditRow {
+	return auditRow{
+		Seq:       e.Seq,
+		Actor:     e.Actor.Subject,
+		ClientIp:  e.Actor.ClientIp,
+		RequestId: e.Actor.RequestId,
+		Operation: e.Operation,
+		ServiceId: e.ServiceId,
+		Before:    e.Before,
+		After:     e.After,
+		Created:   e.Created,
+		Prev:      e.Prev,
+		Hash:      e.Hash,
+	}
+}
+
+func (r auditRow) Entry() core.AuditEntry {
+	return core.AuditEntry{
+		Seq: r.Seq,
+		Actor: core.Actor{
+			Subject:   r.Actor,
+			ClientIp:  r.ClientIp,
+			RequestId: r.RequestId,
+		},
+		Operation: r.Operation,
+		ServiceId: r.ServiceId,
+		Before:    r.Before,
+		After:     r.After,
+		Created:   r.Created,
+		Prev:      r.Prev,
+		Hash:      r.Hash,
+	}
+}
+
+type SqlAuditStore struct {
+	db sql.Driver
+}
+
+func NewSqlAuditStore(db sql.Driver, schemas sql.SchemaRegistry) (ret core.AuditStorage, err error) {
+	if err = sql.InitSchemas(db, schemas, SchemaAudit); err != nil {
+		return
+	}
+
+	ret = &SqlAuditStore{db}
+	return
+}
+
+func (s *SqlAuditStore) ListAudit(filter core.AuditFilter, page core.Page) (ret []core.AuditEntry, err error) {
+	query := SchemaAudit.Select()
+	if filter.ServiceId != nil {
+		query = query.Where("service_id = ?", *filter.ServiceId)
+	}
+	if filter.Actor != nil {
+		query = query.Where("actor = ?", *filter.Actor)
+	}
+	if filter.Since != nil {
+		query = query.Where("created >= ?", filter.Since.UTC())
+	}
+
+	var rows []auditRow
+	if err = s.db.Do(
+		sql.Scan(
+			query.
+				OrderBy("seq").
+				Offset(page.Offset).
+				Limit(page.Limit),
+			sql.Slice(&rows, sql.Struct))); err != nil {
+		return
+	}
+
+	ret = make([]core.AuditEntry, 0, len(rows))
+	for _, r := range rows {
+		ret = append(ret, r.Entry())
+	}
+	return
+}
+
+// Returns an atomic that audits the change on behalf of the actor.  Must be
+// executed in the same transaction as the mutation it describes, after the
+// mutation has been applied.
+//
+// The before state of a revised service is its previous revision.  The
+// before state of a deleted service or version is the deleted value.
+func appendAudit(actor core.Actor, change core.Change) sql.Atomic {
+	return func(tx sql.Tx) (err error) {
+		var before interface{}
+		switch change.Type {
+		case core.ServiceSaved:
+			var prev core.Service
+			found, err := tx.Query(sql.Struct(&prev),
+				SchemaService.Select().
+					Where("id = ?", change.ServiceId).
+					Where("version = ?", change.Service.Version-1))
+			if err != nil {
+				return err
+			}
+			if found {
+				before = prev
+			}
+		case core.ServiceDeleted:
+			before = change.Service
+		case core.VersionDeleted:
+			before = change.Version
+		}
+
+		entry, err := core.NewAuditEntry(actor, change, before)
+		if err != nil {
+			return
+		}
+
+		var last auditRow
+		if _, err = tx.Query(sql.Struct(&last),
+			SchemaAudit.Select().
+				OrderBy("seq desc").
+				Limit(1)); err != nil {
+			return
+		}
+
+		_, err = tx.Exec(
+			SchemaAudit.Insert(
+				newAuditRow(entry.Chain(last.Entry()))))
+		return
+	}
+}
@@ -0,0 +1,246 @@
+package sql
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditStore(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	raw, err := NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	audit, err := NewSqlAuditStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	alice := core.Actor{Subject: "token:alice", ClientIp: "10.0.0.1", RequestId: "req-1"}
+	bob := core.Actor{Subject: "token:bob", ClientIp: "10.0.0.2", RequestId: "req-2"}
+
+	start := time.Now().UTC()
+
+	svc := core.NewService("name", "desc")
+	ver := core.NewVersion(svc.Id, "1.0")
+	if !t.Run("SaveService", func(t *testing.T) {
+		assert.Nil(t, core.Attribute(raw, alice).SaveService(svc))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Revision", func(t *testing.T) {
+		assert.Nil(t, core.Attribute(raw, bob).SaveService(svc.Increment().SetDesc("other")))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch", func(t *testing.T) {
+		_, err := core.Attribute(raw, alice).SaveBatch([]core.Write{
+			core.SaveVersionWrite(ver),
+			core.DeleteVersionWrite(ver),
+		})
+		assert.Nil(t, err)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Failed", func(t *testing.T) {
+		err := core.Attribute(raw, alice).SaveService(svc)
+		assert.True(t, errs.Is(err, core.ErrConflict))
+	}) {
+		return
+	}
+
+	if !t.Run("ListAudit", func(t *testing.T) {
+		entries, err := audit.ListAudit(core.NewAuditFilter(), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 4, len(entries)) {
+			return
+		}
+
+		assert.Equal(t, uint64(1), entries[0].Seq)
+		assert.Equal(t, alice, entries[0].Actor)
+		assert.Equal(t, core.ServiceSaved, entries[0].Operation)
+		assert.Nil(t, entries[0].Before)
+
+		var before, after core.Service
+		assert.Nil(t, json.Unmarshal(entries[1].Before, &before))
+		assert.Nil(t, json.Unmarshal(entries[1].After, &after))
+		assert.Equal(t, bob, entries[1].Actor)
+		assert.Equal(t, "desc", before.Desc)
+		assert.Equal(t, "other", after.Desc)
+
+		assert.Equal(t, core.VersionSaved, entries[2].Operation)
+		assert.Equal(t, core.VersionDeleted, entries[3].Operation)
+		assert.NotNil(t, entries[3].Before)
+		assert.Nil(t, entries[3].After)
+
+		assert.Nil(t, core.VerifyAudit("", entries))
+	}) {
+		return
+	}
+
+	if !t.Run("ListAudit_Filter", func(t *testing.T) {
+		entries, err := audit.ListAudit(
+			core.NewAuditFilter(
+				core.AuditByServiceId(svc.Id),
+				core.AuditByActor(bob.Subject),
+				core.AuditSince(start)),
+			core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, uint64(2), entries[0].Seq)
+
+		entries, err = audit.ListAudit(
+			core.NewAuditFilter(core.AuditSince(time.Now().Add(time.Hour))),
+			core.NewPage())
+		assert.Nil(t, err)
+		assert.Empty(t, entries)
+	}) {
+		return
+	}
+
+	if !t.Run("ListAudit_Page", func(t *testing.T) {
+		entries, err := audit.ListAudit(core.NewAuditFilter(), core.NewPage(core.Offset(2), core.Limit(1)))
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, uint64(3), entries[0].Seq)
+	}) {
+		return
+	}
+
+	if !t.Run("ConsentTransfer", func(t *testing.T) {
+		if !assert.Nil(t, core.Attribute(raw, alice).SaveService(svc.Update(func(s *core.Service) {
+			s.Version, s.Owner = 2, "payments"
+		}))) {
+			return
+		}
+
+		transfers, err := NewSqlTransferStore(db, sql.NewSchemaRegistry("TEST"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		transfer := core.NewTransfer(svc.Id, "payments", "billing")
+		transfer.FromBy = alice.Subject
+		if !assert.Nil(t, transfers.SaveTransfer(transfer)) {
+			return
+		}
+
+		_, err = core.AttributeTransfers(transfers, bob).ConsentTransfer(core.Transfer{Id: transfer.Id, ToBy: bob.Subject})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		entries, err := audit.ListAudit(core.NewAuditFilter(), core.NewPage(core.Offset(5)))
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(entries)) {
+			return
+		}
+		assert.Equal(t, bob, entries[0].Actor)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_DeleteService", func(t *testing.T) {
+		catalog, err := raw.ListServices(core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			return
+		}
+
+		// Deletes only identify the service.  The stored revision is audited.
+		latest := catalog.Services[0]
+		_, err = core.Attribute(raw, alice).SaveBatch([]core.Write{
+			core.DeleteServiceWrite(core.Service{Id: latest.Id, Version: latest.Version}),
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		entries, err := audit.ListAudit(core.NewAuditFilter(core.AuditByServiceId(svc.Id)), core.NewPage(core.Limit(1024)))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		last := entries[len(entries)-1]
+		assert.Equal(t, core.ServiceDeleted, last.Operation)
+		assert.Equal(t, alice, last.Actor)
+
+		var before core.Service
+		if !assert.Nil(t, json.Unmarshal(last.Before, &before)) {
+			return
+		}
+		assert.Equal(t, latest, before)
+	}) {
+		return
+	}
+
+	if !t.Run("Import_Replace", func(t *testing.T) {
+		svc := core.NewService("imported", "desc")
+		ver := core.NewVersion(svc.Id, "1.0")
+		if !assert.Nil(t, raw.SaveService(svc)) || !assert.Nil(t, raw.SaveVersion(ver)) {
+			return
+		}
+
+		replacement := svc.SetDesc("replaced")
+		_, err := core.Attribute(raw, bob).Import(
+			core.NewSnapshot(false, []core.Service{replacement}, nil), core.ImportReplace)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		entries, err := audit.ListAudit(
+			core.NewAuditFilter(core.AuditByServiceId(svc.Id), core.AuditByActor(bob.Subject)),
+			core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 3, len(entries)) {
+			return
+		}
+
+		assert.Equal(t, core.VersionDeleted, entries[0].Operation)
+		assert.Equal(t, core.ServiceDeleted, entries[1].Operation)
+		assert.Equal(t, core.ServiceSaved, entries[2].Operation)
+
+		var before core.Service
+		if !assert.Nil(t, json.Unmarshal(entries[1].Before, &before)) {
+			return
+		}
+		assert.Equal(t, svc, before)
+	}) {
+		return
+	}
+
+	if !t.Run("VerifyAudit_Tampered", func(t *testing.T) {
+		err := db.Do(sql.Exec(
+			SchemaAudit.Update().
+				Set("actor", "token:mallory").
+				Where("seq = ?", 2)))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		entries, err := audit.ListAudit(core.NewAuditFilter(), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, errs.Is(core.VerifyAudit("", entries), core.ErrTampered))
+	}) {
+		return
+	}
+}
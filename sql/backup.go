@@ -0,0 +1,121 @@
+package sql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Every schema of the catalog database.  Backups are verified against
+// these before they may be restored.
+func Schemas() []sql.Schema {
+	return []sql.Schema{
+		SchemaService,
+		SchemaVersion,
+		SchemaChange,
+		SchemaAudit,
+		SchemaWebhook,
+		SchemaDelivery,
+		SchemaAttempt,
+		SchemaCursor,
+		SchemaIdempotency,
+		SchemaToken,
+		SchemaTransfer,
+	}
+}
+
+// Sqlite backups use VACUUM INTO, which writes a consistent, compacted copy
+// of the database while it remains online.  It may not be run within a
+// transaction, so it requires access to the underlying database handle.
+type SqlBackupStore struct {
+	db *gosql.DB
+}
+
+func NewSqlBackupStore(db sql.Driver) (ret core.BackupStorage, err error) {
+	raw, ok := db.(interface{ DB() *gosql.DB })
+	if !ok {
+		err = errors.Wrapf(core.ErrState, "Driver does not support backups")
+		return
+	}
+
+	ret = &SqlBackupStore{raw.DB()}
+	return
+}
+
+func (s *SqlBackupStore) Backup(path string) (err error) {
+	if _, err = os.Stat(path); err == nil {
+		err = errors.Wrapf(core.ErrState, "Backup file already exists [%v]", path)
+		return
+	}
+
+	_, err = s.db.Exec("vacuum into ?", path)
+	err = errors.Wrapf(err, "Error backing up database to [%v]", path)
+	return
+}
+
+// Verifies that the file is an intact catalog database whose schemas
+// may be read by this release.  Older schemas are accepted, since they
+// are migrated when the server starts.
+func VerifyBackup(path string, registry string) (err error) {
+	if _, err = os.Stat(path); err != nil {
+		return
+	}
+
+	// Open read-only, otherwise sqlite would happily create a new database.
+	db, err := gosql.Open("sqlite3", fmt.Sprintf("file:%v?mode=ro", path))
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	var integrity string
+	if err = db.QueryRow("pragma integrity_check").Scan(&integrity); err != nil {
+		err = errors.Wrapf(core.ErrState, "Not a sqlite database [%v]: %v", path, err)
+		return
+	}
+	if integrity != "ok" {
+		err = errors.Wrapf(core.ErrState, "Backup failed integrity check [%v]: %v", path, integrity)
+		return
+	}
+
+	rows, err := db.Query(
+		fmt.Sprintf("select name, max(version) from %v group by name", registry))
+	if err != nil {
+		err = errors.Wrapf(core.ErrState, "Not a catalog database [%v]: %v", path, err)
+		return
+	}
+	defer rows.Close()
+
+	found := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var version int
+		if err = rows.Scan(&name, &version); err != nil {
+			return
+		}
+		found[name] = version
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for _, schema := range []sql.Schema{SchemaService, SchemaVersion} {
+		if _, ok := found[schema.Name]; !ok {
+			err = errors.Wrapf(core.ErrState, "Not a catalog database [%v]. Missing schema [%v]", path, schema.Name)
+			return
+		}
+	}
+
+	for _, schema := range Schemas() {
+		if version, ok := found[schema.Name]; ok && version > schema.Version {
+			err = errors.Wrapf(core.ErrState, "Backup schema [%v] has version [%v], which is newer than supported [%v]",
+				schema.Name, version, schema.Version)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	dir, err := ioutil.TempDir("", "catalog-backup-test")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if _, err = NewSqlWebhookStore(db, sql.NewSchemaRegistry("TEST")); !assert.Nil(t, err) {
+		return
+	}
+
+	svc := core.NewService("name", "desc")
+	if !assert.Nil(t, store.SaveService(svc)) {
+		return
+	}
+
+	backups, err := NewSqlBackupStore(db)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	path := filepath.Join(dir, "backup.db")
+	if !t.Run("Backup", func(t *testing.T) {
+		if !assert.Nil(t, backups.Backup(path)) {
+			return
+		}
+
+		restored, err := sql.NewSqlLiteDialer().Connect(ctx, path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer restored.Close()
+
+		store, err := NewSqlStore(restored, sql.NewSchemaRegistry("TEST"))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		catalog, err := store.ListServices(core.EmptyFilter, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []core.Service{svc}, catalog.Services)
+	}) {
+		return
+	}
+
+	if !t.Run("Backup_Exists", func(t *testing.T) {
+		assert.True(t, errs.Is(backups.Backup(path), core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("VerifyBackup", func(t *testing.T) {
+		assert.Nil(t, VerifyBackup(path, "TEST"))
+	}) {
+		return
+	}
+
+	if !t.Run("VerifyBackup_WrongRegistry", func(t *testing.T) {
+		assert.True(t, errs.Is(VerifyBackup(path, "OTHER"), core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("VerifyBackup_NotSqlite", func(t *testing.T) {
+		garbage := filepath.Join(dir, "garbage.db")
+		if !assert.Nil(t, ioutil.WriteFile(garbage, []byte("not a database"), 0600)) {
+			return
+		}
+		assert.True(t, errs.Is(VerifyBackup(garbage, "TEST"), core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("VerifyBackup_Missing", func(t *testing.T) {
+		assert.NotNil(t, VerifyBackup(filepath.Join(dir, "missing.db"), "TEST"))
+
+		_, err := os.Stat(filepath.Join(dir, "missing.db"))
+		assert.True(t, os.IsNotExist(err))
+	}) {
+		return
+	}
+
+	if !t.Run("VerifyBackup_NewerSchema", func(t *testing.T) {
+		newer := filepath.Join(dir, "newer.db")
+		if !assert.Nil(t, backups.Backup(newer)) {
+			return
+		}
+
+		raw, err := sql.NewSqlLiteDialer().Connect(ctx, newer)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer raw.Close()
+
+		if !assert.Nil(t, raw.Do(sql.Exec(
+			sql.Raw("insert into TEST (name, version) values (?, ?)", SchemaService.Name, SchemaService.Version+1)))) {
+			return
+		}
+
+		assert.True(t, errs.Is(VerifyBackup(newer, "TEST"), core.ErrState))
+	}) {
+		return
+	}
+}
@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The change log is a simple append-only table.  Each row stores the
+// encoded service or version that was written, keyed by a sequence
+// that is allocated within the writing transaction.  Because sqlite
+// serializes all transactions, sequences become visible in order and
+// readers never observe gaps.
+var (
+	SchemaChange = sql.NewSchema("change", 0).
+		WithStruct(changeRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_change_seq", "seq"),
+			sql.NewIndex("idx_change_service", "service_id")).
+		Build()
+)
+
+type changeRow struct {
+	Seq       uint64
+	Type      core.ChangeType
+	ServiceId uuid.UUID
+	Payload   []byte // json encoded service or version
+	Created   time.Time
+}
+
+// Returns a query that appends the change to the log, allocating the
+// next sequence number.  Must be executed in the same transaction as
+// the mutation it describes.
+func appendChange(change core.Change) sql.Query {
+	return sql.QueryFn(func(sql.Dialect) (query string, binds []interface{}, err error) {
+		var payload []byte
+		switch {
+		case change.Service != nil:
+			err = enc.Json.EncodeBinary(change.Service, &payload)
+		case change.Version != nil:
+			err = enc.Json.EncodeBinary(change.Version, &payload)
+		}
+		if err != nil {
+			return
+		}
+
+		query = `
+insert into change (seq, type, service_id, payload, created)
+select coalesce(max(seq), 0) + 1, ?, ?, ?, ? from change`
+		binds = []interface{}{
+			string(change.Type),
+			change.ServiceId,
+			payload,
+			change.Created}
+		return
+	})
+}
+
+// Sequences are allocated as max(seq) + 1, so the latest is simply the max.
+func (s *SqlServiceStore) LatestSeq() (ret uint64, err error) {
+	err = s.db.Do(func(tx sql.Tx) (err error) {
+		_, err = tx.Query(sql.Value(&ret), sql.Raw("select coalesce(max(seq), 0) from change"))
+		return
+	})
+	return
+}
+
+func (s *SqlServiceStore) ListChanges(since uint64, limit uint64) (ret []core.Change, err error) {
+	var rows []changeRow
+	err = s.db.Do(
+		sql.Scan(
+			SchemaChange.Select().
+				Where("seq > ?", since).
+				OrderBy("seq").
+				Limit(limit),
+			sql.Slice(&rows, sql.Struct)))
+	if err != nil {
+		return
+	}
+
+	ret = make([]core.Change, 0, len(rows))
+	for _, r := range rows {
+		change := core.Change{
+			Seq:       r.Seq,
+			Type:      r.Type,
+			ServiceId: r.ServiceId,
+			Created:   r.Created,
+		}
+
+		switch r.Type {
+		case core.ServiceSaved, core.ServiceDeleted:
+			change.Service = &core.Service{}
+			err = enc.Json.DecodeBinary(r.Payload, change.Service)
+		case core.VersionSaved, core.VersionDeleted:
+			change.Version = &core.Version{}
+			err = enc.Json.DecodeBinary(r.Payload, change.Version)
+		}
+		if err != nil {
+			return
+		}
+
+		ret = append(ret, change)
+	}
+	return
+}
@@ -0,0 +1,40 @@
+package sql
+
+import (
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/pkopriv2/services-catalog/health"
+)
+
+// Returns a check that the database answers a trivial query.
+func NewPingCheck(db sql.Driver) health.Check {
+	return func() error {
+		return db.Do(func(tx sql.Tx) (err error) {
+			var one int
+			_, err = tx.Query(sql.Value(&one), sql.Raw("select 1"))
+			return
+		})
+	}
+}
+
+// Returns a check that every schema of the catalog has been migrated to the
+// version expected by this release.
+func NewMigrationCheck(db sql.Driver, registry string) health.Check {
+	return func() error {
+		return db.Do(func(tx sql.Tx) (err error) {
+			for _, schema := range Schemas() {
+				var version int
+				if _, err = tx.Query(sql.Value(&version),
+					sql.Raw("select coalesce(max(version), -1) from "+registry+" where name = ?", schema.Name)); err != nil {
+					return
+				}
+				if version != schema.Version {
+					return errors.Wrapf(core.ErrState, "Schema [%v] has version [%v]. Expected [%v]",
+						schema.Name, version, schema.Version)
+				}
+			}
+			return
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package sql
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecks(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !t.Run("Ping", func(t *testing.T) {
+		assert.Nil(t, NewPingCheck(db)())
+	}) {
+		return
+	}
+
+	if !t.Run("Migrations_Missing", func(t *testing.T) {
+		if _, err := NewSqlStore(db, sql.NewSchemaRegistry("TEST")); !assert.Nil(t, err) {
+			return
+		}
+
+		err := NewMigrationCheck(db, "TEST")()
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("Migrations", func(t *testing.T) {
+		if !assert.Nil(t, sql.InitSchemas(db, sql.NewSchemaRegistry("TEST"), Schemas()...)) {
+			return
+		}
+		assert.Nil(t, NewMigrationCheck(db, "TEST")())
+	}) {
+		return
+	}
+
+	if !t.Run("Ping_Closed", func(t *testing.T) {
+		if !assert.Nil(t, db.Close()) {
+			return
+		}
+		assert.NotNil(t, NewPingCheck(db)())
+	}) {
+		return
+	}
+}
@@ -0,0 +1,156 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Idempotency records are stored alongside the catalog, so that they
+// survive restarts of the server.  Expired records (including reservations
+// whose lease expired) are purged as new keys are reserved.
+var (
+	SchemaIdempotency = sql.NewSchema("idempotency", 0).
+		WithStruct(idempotencyRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_idempotency_uniq", "name"),
+			sql.NewIndex("idx_idempotency_expires", "expires")).
+		Build()
+)
+
+// The response headers are stored as a json encoded map.
+type idempotencyRow struct {
+	Name        string
+	Fingerprint string
+	Code        int
+	Headers     []byte
+	Mime        string
+	Body        []byte
+	Created     time.Time
+	Expires     time.Time
+}
+
+func newIdempotencyRow(r core.IdempotencyRecord) (ret idempotencyRow, err error) {
+	ret = idempotencyRow{
+		Name:        r.Key,
+		Fingerprint: r.Fingerprint,
+		Code:        r.Code,
+		Mime:        r.Mime,
+		Body:        r.Body,
+		Created:     r.Created,
+		Expires:     r.Expires,
+	}
+	err = enc.Json.EncodeBinary(r.Headers, &ret.Headers)
+	return
+}
+
+func (r idempotencyRow) Record() (ret core.IdempotencyRecord, err error) {
+	ret = core.IdempotencyRecord{
+		Key:         r.Name,
+		Fingerprint: r.Fingerprint,
+		Code:        r.Code,
+		Mime:        r.Mime,
+		Body:        r.Body,
+		Created:     r.Created,
+		Expires:     r.Expires,
+	}
+	err = enc.Json.DecodeBinary(r.Headers, &ret.Headers)
+	return
+}
+
+type SqlIdempotencyStore struct {
+	db sql.Driver
+}
+
+func NewSqlIdempotencyStore(db sql.Driver, schemas sql.SchemaRegistry) (ret core.IdempotencyStorage, err error) {
+	if err = sql.InitSchemas(db, schemas, SchemaIdempotency); err != nil {
+		return
+	}
+
+	ret = &SqlIdempotencyStore{db}
+	return
+}
+
+// Purging and reserving happen in the same transaction, so an expired
+// record is replaced rather than replayed.  The reservation expires with
+// its lease, until it's completed.
+func (s *SqlIdempotencyStore) Reserve(record core.IdempotencyRecord) (existing core.IdempotencyRecord, reserved bool, err error) {
+	if record.Key == "" {
+		err = errors.Wrapf(core.ErrState, "Key must not be empty")
+		return
+	}
+
+	row, err := newIdempotencyRow(record)
+	if err != nil {
+		return
+	}
+	row.Expires = record.LeaseExpires()
+
+	err = s.db.Do(func(tx sql.Tx) (err error) {
+		existing, reserved = core.IdempotencyRecord{}, false
+		if _, err = tx.Exec(SchemaIdempotency.Delete().Where("expires <= ?", record.Created)); err != nil {
+			return
+		}
+
+		var prev idempotencyRow
+		found, err := tx.Query(sql.Struct(&prev),
+			SchemaIdempotency.Select().Where("name = ?", record.Key))
+		if err != nil {
+			return
+		}
+		if found {
+			existing, err = prev.Record()
+			return
+		}
+
+		if _, err = tx.Exec(SchemaIdempotency.Insert(row)); err != nil {
+			return
+		}
+		reserved = true
+		return
+	})
+	return
+}
+
+// Reservations are identified by their creation, so that a request whose
+// reservation was reclaimed can't complete (or release) its successor's.
+func (s *SqlIdempotencyStore) Complete(record core.IdempotencyRecord) (err error) {
+	row, err := newIdempotencyRow(record)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if errs.Is(err, sql.ErrNone) {
+			err = errors.Wrapf(core.ErrState, "Key [%v] is not reserved", record.Key)
+		}
+	}()
+
+	return s.db.Do(
+		sql.ExpectOne(
+			SchemaIdempotency.Select().
+				Where("name = ?", record.Key).
+				Where("fingerprint = ?", record.Fingerprint).
+				Where("created = ?", record.Created).
+				Where("code = 0")).
+			ThenExec(
+				SchemaIdempotency.Update().
+					Set("code", row.Code).
+					Set("headers", row.Headers).
+					Set("mime", row.Mime).
+					Set("body", row.Body).
+					Set("expires", row.Expires).
+					Where("name = ?", record.Key)))
+}
+
+func (s *SqlIdempotencyStore) Release(record core.IdempotencyRecord) (err error) {
+	return s.db.Do(
+		sql.Exec(
+			SchemaIdempotency.Delete().
+				Where("name = ?", record.Key).
+				Where("created = ?", record.Created)))
+}
@@ -0,0 +1,150 @@
+package sql
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := NewSqlIdempotencyStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	record := core.NewIdempotencyRecord("key", "fingerprint", time.Hour)
+	if !t.Run("Reserve", func(t *testing.T) {
+		_, reserved, err := store.Reserve(record)
+		assert.Nil(t, err)
+		assert.True(t, reserved)
+	}) {
+		return
+	}
+
+	if !t.Run("Reserve_InProgress", func(t *testing.T) {
+		existing, reserved, err := store.Reserve(record)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, reserved)
+		assert.False(t, existing.Completed())
+	}) {
+		return
+	}
+
+	if !t.Run("Complete", func(t *testing.T) {
+		record.Code, record.Mime, record.Body = 200, "application/json", []byte(`{}`)
+		record.Headers = map[string]string{"ETag": `"etag"`}
+		if !assert.Nil(t, store.Complete(record)) {
+			return
+		}
+
+		existing, reserved, err := store.Reserve(record)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, reserved)
+		assert.Equal(t, record.Code, existing.Code)
+		assert.Equal(t, record.Headers, existing.Headers)
+		assert.Equal(t, record.Body, existing.Body)
+	}) {
+		return
+	}
+
+	if !t.Run("Complete_NotReserved", func(t *testing.T) {
+		other := core.NewIdempotencyRecord("other", "fingerprint", time.Hour)
+		assert.True(t, errs.Is(store.Complete(other), core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("Release", func(t *testing.T) {
+		if !assert.Nil(t, store.Release(record)) {
+			return
+		}
+
+		_, reserved, err := store.Reserve(record)
+		assert.Nil(t, err)
+		assert.True(t, reserved)
+	}) {
+		return
+	}
+
+	if !t.Run("Expired", func(t *testing.T) {
+		expired := core.NewIdempotencyRecord("expired", "fingerprint", time.Millisecond)
+		if _, reserved, err := store.Reserve(expired); !assert.Nil(t, err) || !assert.True(t, reserved) {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		_, reserved, err := store.Reserve(core.NewIdempotencyRecord("expired", "fingerprint", time.Hour))
+		assert.Nil(t, err)
+		assert.True(t, reserved)
+	}) {
+		return
+	}
+
+	if !t.Run("Lease_Expired", func(t *testing.T) {
+		abandoned := core.NewIdempotencyRecord("leased", "fingerprint", time.Hour)
+		abandoned.Created = abandoned.Created.Add(-2 * core.IdempotencyLease)
+		if _, reserved, err := store.Reserve(abandoned); !assert.Nil(t, err) || !assert.True(t, reserved) {
+			return
+		}
+
+		retry := core.NewIdempotencyRecord("leased", "fingerprint", time.Hour)
+		_, reserved, err := store.Reserve(retry)
+		if !assert.Nil(t, err) || !assert.True(t, reserved) {
+			return
+		}
+
+		abandoned.Code = 200
+		assert.True(t, errs.Is(store.Complete(abandoned), core.ErrState))
+		assert.Nil(t, store.Release(abandoned))
+
+		existing, reserved, err := store.Reserve(retry)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, reserved)
+		assert.Equal(t, retry.Created.Unix(), existing.Created.Unix())
+	}) {
+		return
+	}
+
+	if !t.Run("Complete_OutlivesLease", func(t *testing.T) {
+		record := core.NewIdempotencyRecord("completed", "fingerprint", time.Hour)
+		record.Created = record.Created.Add(-2 * core.IdempotencyLease)
+		if _, reserved, err := store.Reserve(record); !assert.Nil(t, err) || !assert.True(t, reserved) {
+			return
+		}
+
+		record.Code = 200
+		if !assert.Nil(t, store.Complete(record)) {
+			return
+		}
+
+		existing, reserved, err := store.Reserve(core.NewIdempotencyRecord("completed", "fingerprint", time.Hour))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, reserved)
+		assert.Equal(t, 200, existing.Code)
+	}) {
+		return
+	}
+}
@@ -0,0 +1,25 @@
+package sql
+
+import (
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+)
+
+// Adds a nullable column to a table, then sets the column of the existing
+// rows to the value.
+//
+// The schema registry reads back the first version it recorded for a schema,
+// so migrations are rerun on every start.  Migrations must therefore be
+// idempotent, and adding a column is skipped if the column already exists.
+func addColumn(table string, col sql.Column, val interface{}) sql.Atomic {
+	return func(tx sql.Tx) (err error) {
+		var num int
+		if _, err = tx.Query(sql.Value(&num),
+			sql.Raw("select count(*) from pragma_table_info(?) where name = ?", table, col.Name)); err != nil || num > 0 {
+			return
+		}
+
+		return sql.Exec(
+			sql.AddColumn(table, col),
+			sql.Update(table).Set(col.Name, val).Where(col.Name+" is null"))(tx)
+	}
+}
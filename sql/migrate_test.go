@@ -0,0 +1,56 @@
+package sql
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// The first release of the service schema, without owners.
+	type service struct {
+		Id      string
+		Name    string
+		Desc    string
+		Version int
+		Updated time.Time
+	}
+
+	v0 := sql.NewSchema("service", 0).WithStruct(service{}).Build()
+	if !assert.Nil(t, sql.InitSchemas(db, sql.NewSchemaRegistry("TEST"), v0)) {
+		return
+	}
+
+	svc := core.NewService("name", "desc")
+	if !assert.Nil(t, db.Do(sql.Exec(v0.Insert(service{svc.Id.String(), svc.Name, svc.Desc, 0, svc.Updated})))) {
+		return
+	}
+
+	// Migrations are rerun on every start, so they must be repeatable.
+	for i := 0; i < 2; i++ {
+		if !assert.Nil(t, sql.InitSchemas(db, sql.NewSchemaRegistry("TEST"), SchemaService)) {
+			return
+		}
+	}
+
+	var owner string
+	var found bool
+	if !assert.Nil(t, db.Do(sql.QueryOne(sql.Raw("select owner from service"), sql.Value(&owner), &found))) {
+		return
+	}
+	assert.True(t, found)
+	assert.Equal(t, "", owner)
+}
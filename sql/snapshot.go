@@ -0,0 +1,160 @@
+package sql
+
+import (
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Both tables are read within a single transaction, so the snapshot is
+// consistent.
+func (s *SqlServiceStore) Export(history bool) (ret core.Snapshot, err error) {
+	query := SchemaService.SelectAs("s").OrderBy("s.id", "s.version")
+	if !history {
+		query = query.Where(latestService("s"))
+	}
+
+	services, versions := []core.Service{}, []core.Version{}
+	if err = s.db.Do(
+		sql.Scan(query,
+			sql.Slice(&services, sql.Struct)).
+			Then(sql.Scan(
+				SchemaVersion.Select().OrderBy("service_id", "created", "name"),
+				sql.Slice(&versions, sql.Struct)))); err != nil {
+		return
+	}
+
+	ret = core.NewSnapshot(history, services, versions)
+	return
+}
+
+// Both tables are counted within a single transaction.  Every revision of a
+// service shares its id, so services are counted by their ids.
+func (s *SqlServiceStore) Count() (services, versions int, err error) {
+	err = s.db.Do(func(tx sql.Tx) (err error) {
+		if _, err = tx.Query(sql.Value(&services), sql.Raw("select count(distinct id) from service")); err != nil {
+			return
+		}
+		_, err = tx.Query(sql.Value(&versions), sql.Raw("select count(*) from version"))
+		return
+	})
+	return
+}
+
+// Rows are inserted verbatim, which preserves the ids, revisions and
+// timestamps of the snapshot.  Every inserted or replaced row is appended to
+// the change log (and audited), so that watchers and webhooks observe
+// imports like any other write.
+func (s *SqlServiceStore) Import(snapshot core.Snapshot, mode core.ImportMode) (ret core.ImportResult, err error) {
+	if err = snapshot.Validate(); err != nil {
+		return
+	}
+	if _, err = core.ParseImportMode(string(mode)); err != nil {
+		return
+	}
+
+	// Group the snapshot by service, retaining the order of the document.
+	var ids []uuid.UUID
+	revisions := make(map[uuid.UUID][]core.Service)
+	versions := make(map[uuid.UUID][]core.Version)
+	for _, svc := range snapshot.Services {
+		if _, ok := revisions[svc.Id]; !ok {
+			ids = append(ids, svc.Id)
+		}
+		revisions[svc.Id] = append(revisions[svc.Id], svc)
+	}
+	for _, v := range snapshot.Versions {
+		versions[v.ServiceId] = append(versions[v.ServiceId], v)
+	}
+
+	err = s.db.Do(func(tx sql.Tx) (err error) {
+		ret = core.ImportResult{}
+		for _, id := range ids {
+			var latest int
+			if _, err = tx.Query(sql.Value(&latest),
+				sql.Raw("select coalesce(max(version), -1) from service where id = ?", id)); err != nil {
+				return
+			}
+
+			exists := latest >= 0
+			switch {
+			case !exists:
+				ret.Created++
+			case mode == core.ImportSkipExisting:
+				ret.Skipped++
+				continue
+			case mode == core.ImportReplace:
+				if err = replaceService(s.actor, id, latest).Exec(tx); err != nil {
+					return
+				}
+				latest = -1
+				ret.Updated++
+			default:
+				ret.Updated++
+			}
+
+			// When merging, only the revisions newer than the latest are added.
+			for _, svc := range revisions[id] {
+				if svc.Version <= latest {
+					continue
+				}
+
+				if err = sql.Exec(
+					SchemaService.Insert(svc)).
+					Then(record(s.actor, core.NewServiceChange(svc))).Exec(tx); err != nil {
+					return
+				}
+				ret.Revisions++
+			}
+
+			for _, v := range versions[id] {
+				var found bool
+				if found, err = tx.Query(sql.Nil(),
+					SchemaVersion.Select().
+						Where("service_id = ?", v.ServiceId).
+						Where("name = ?", v.Name)); err != nil {
+					return
+				}
+				if found {
+					continue
+				}
+
+				if err = sql.Exec(
+					SchemaVersion.Insert(v)).
+					Then(record(s.actor, core.NewVersionChange(v))).Exec(tx); err != nil {
+					return
+				}
+				ret.Versions++
+			}
+		}
+		return
+	})
+	if errs.Is(err, sql.ErrSqliteUnique) { // not portable
+		err = core.ErrConflict
+	}
+	return
+}
+
+// Deletes a service and its versions before it's replaced by an import.
+// The deletes are recorded and audited like any other, so that watchers and
+// webhooks observe the versions the import removed.
+func replaceService(actor core.Actor, id uuid.UUID, latest int) sql.Atomic {
+	return func(tx sql.Tx) (err error) {
+		var versions []core.Version
+		if _, err = tx.Scan(sql.Slice(&versions, sql.Struct),
+			SchemaVersion.Select().
+				Where("service_id = ?", id).
+				OrderBy("created", "name")); err != nil {
+			return
+		}
+
+		for _, v := range versions {
+			if err = deleteVersion(actor, v).Exec(tx); err != nil {
+				return
+			}
+		}
+
+		return deleteService(actor, core.Service{Id: id, Version: latest}).Exec(tx)
+	}
+}
@@ -0,0 +1,218 @@
+package sql
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	newStore := func() (core.Storage, error) {
+		db, err := sql.NewSqlLiteDialer().Embed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	}
+
+	src, err := newStore()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	svc1 := core.NewService("svc1", "desc")
+	svc1v1 := svc1.SetDesc("updated").Increment()
+	svc2 := core.NewService("svc2", "desc")
+	ver1 := core.NewVersion(svc1.Id, "v1")
+	ver2 := core.NewVersion(svc2.Id, "v1")
+	for _, err := range []error{
+		src.SaveService(svc1),
+		src.SaveService(svc1v1),
+		src.SaveService(svc2),
+		src.SaveVersion(ver1),
+		src.SaveVersion(ver2),
+	} {
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	var latest, history core.Snapshot
+	if !t.Run("Export", func(t *testing.T) {
+		latest, err = src.Export(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, core.SnapshotVersion, latest.Version)
+		assert.False(t, latest.History)
+		assert.ElementsMatch(t, []core.Service{svc1v1, svc2}, latest.Services)
+		assert.ElementsMatch(t, []core.Version{ver1, ver2}, latest.Versions)
+	}) {
+		return
+	}
+
+	if !t.Run("Export_History", func(t *testing.T) {
+		history, err = src.Export(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.True(t, history.History)
+		assert.ElementsMatch(t, []core.Service{svc1, svc1v1, svc2}, history.Services)
+	}) {
+		return
+	}
+
+	dst, err := newStore()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !t.Run("Import", func(t *testing.T) {
+		result, err := dst.Import(history, core.ImportMerge)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, core.ImportResult{Created: 2, Revisions: 3, Versions: 2}, result)
+
+		exported, err := dst.Export(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, history.Services, exported.Services)
+		assert.Equal(t, history.Versions, exported.Versions)
+
+		// Every imported row is recorded in the change log
+		changes, err := dst.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 5, len(changes))
+	}) {
+		return
+	}
+
+	// Diverge the two catalogs
+	svc1v2 := svc1v1.SetDesc("again").Increment()
+	ver3 := core.NewVersion(svc1.Id, "v2")
+	if !assert.Nil(t, src.SaveService(svc1v2)) {
+		return
+	}
+	if !assert.Nil(t, src.SaveVersion(ver3)) {
+		return
+	}
+	svc3 := core.NewService("svc3", "desc")
+	if !assert.Nil(t, dst.SaveService(svc3)) {
+		return
+	}
+	if !assert.Nil(t, dst.SaveVersion(core.NewVersion(svc2.Id, "local"))) {
+		return
+	}
+
+	if !t.Run("Import_SkipExisting", func(t *testing.T) {
+		snapshot, err := src.Export(true)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		result, err := dst.Import(snapshot, core.ImportSkipExisting)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, core.ImportResult{Skipped: 2}, result)
+	}) {
+		return
+	}
+
+	if !t.Run("Import_Merge", func(t *testing.T) {
+		snapshot, err := src.Export(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		result, err := dst.Import(snapshot, core.ImportMerge)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, core.ImportResult{Updated: 2, Revisions: 1, Versions: 1}, result)
+
+		catalog, err := dst.ListServices(core.EmptyFilter, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []core.Service{svc1v2, svc2, svc3}, catalog.Services)
+		assert.Equal(t, 2, len(catalog.Versions[svc2.Id]))
+	}) {
+		return
+	}
+
+	if !t.Run("Import_Replace", func(t *testing.T) {
+		before, err := dst.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		result, err := dst.Import(latest, core.ImportReplace)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, core.ImportResult{Updated: 2, Revisions: 2, Versions: 2}, result)
+
+		// The replaced services and versions are deleted in the change log
+		changes, err := dst.ListChanges(before[len(before)-1].Seq, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		deleted := make(map[core.ChangeType][]string)
+		for _, c := range changes {
+			switch c.Type {
+			case core.ServiceDeleted:
+				deleted[c.Type] = append(deleted[c.Type], c.Service.Name)
+			case core.VersionDeleted:
+				deleted[c.Type] = append(deleted[c.Type], c.Version.Name)
+			}
+		}
+		assert.Equal(t, []string{"svc1", "svc2"}, deleted[core.ServiceDeleted])
+		assert.ElementsMatch(t, []string{"v1", "v2", "v1", "local"}, deleted[core.VersionDeleted])
+		assert.Equal(t, 10, len(changes))
+
+		catalog, err := dst.ListServices(core.EmptyFilter, core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []core.Service{svc1v1, svc2, svc3}, catalog.Services)
+		assert.Equal(t, []core.Version{ver1}, catalog.Versions[svc1.Id])
+		assert.Equal(t, []core.Version{ver2}, catalog.Versions[svc2.Id])
+	}) {
+		return
+	}
+
+	if !t.Run("Import_Invalid", func(t *testing.T) {
+		snapshot := latest
+		snapshot.Version = core.SnapshotVersion + 1
+
+		_, err := dst.Import(snapshot, core.ImportMerge)
+		assert.True(t, errs.Is(err, core.ErrState))
+
+		_, err = dst.Import(latest, core.ImportMode("bogus"))
+		assert.True(t, errs.Is(err, core.ErrState))
+
+		snapshot = latest
+		snapshot.Services = nil
+		_, err = dst.Import(snapshot, core.ImportMerge)
+		assert.True(t, errs.Is(err, core.ErrState))
+	}) {
+		return
+	}
+}
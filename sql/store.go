@@ -14,13 +14,17 @@ import (
 // there are two tables, services and versions.  When listing
 // the catalog, versions are joined to services and a composite
 // type is returned.
+//
+// Version 1 added the owning team.  Existing services are unowned.
 var (
-	SchemaService = sql.NewSchema("service", 0).
+	SchemaService = sql.NewSchema("service", 1).
 		WithStruct(core.Service{}).
 		WithIndices(
 			sql.NewUniqueIndex("idx_service_uniq", "id", "version"),
 			sql.NewIndex("idx_service_name", "name"),  // index for searching on name
 			sql.NewIndex("idx_service_desc", "desc")). // index for searching on description
+		WithMigration(0,
+			addColumn("service", sql.NewColumn("owner", sql.String), "")).
 		Build()
 )
 
@@ -34,79 +38,281 @@ var (
 
 var emptyId = uuid.UUID{}
 
+// Writes are audited on behalf of the store's actor.  Stores are attributed
+// to actors by WithActor, so the actor of the original store is empty.
 type SqlServiceStore struct {
-	db sql.Driver
+	db    sql.Driver
+	actor core.Actor
+	guard core.Guard
 }
 
 func NewSqlStore(db sql.Driver, schemas sql.SchemaRegistry) (ret core.Storage, err error) {
 	if err = sql.InitSchemas(db, schemas,
 		SchemaService,
-		SchemaVersion); err != nil {
+		SchemaVersion,
+		SchemaChange,
+		SchemaAudit); err != nil {
 		return
 	}
 
-	ret = &SqlServiceStore{db}
+	ret = &SqlServiceStore{db: db}
 	return
 }
 
+func (s *SqlServiceStore) WithActor(actor core.Actor) core.Storage {
+	return &SqlServiceStore{s.db, actor, s.guard}
+}
+
+// Traces every transaction and statement as children of the span.
+func (s *SqlServiceStore) WithSpan(span core.Span) core.Storage {
+	return &SqlServiceStore{NewTracedDriver(s.db, span), s.actor, s.guard}
+}
+
+// Consults the guard within the transaction of every write.
+func (s *SqlServiceStore) WithGuard(guard core.Guard) core.Storage {
+	return &SqlServiceStore{s.db, s.actor, guard}
+}
+
 func (s *SqlServiceStore) SaveService(service core.Service) (err error) {
-	if service.Id == emptyId {
-		err = errors.Wrapf(core.ErrState, "Id must not be empty")
+	if err = validateService(service); err != nil {
 		return
 	}
-	if service.Name == "" {
-		err = errors.Wrapf(core.ErrState, "Name must not be empty")
+
+	defer func() {
+		err = storeError(err, service.Id)
+	}()
+	return s.db.Do(s.guarded(core.SaveServiceWrite(service), saveService(s.actor, service)))
+}
+
+func (s *SqlServiceStore) SaveVersion(version core.Version) (err error) {
+	if err = validateVersion(version); err != nil {
 		return
 	}
 
 	defer func() {
-		switch {
-		case errs.Is(err, sql.ErrSqliteUnique): // not portable
-			err = core.ErrConflict
-		case errs.Is(err, sql.ErrNone):
-			err = errors.Wrapf(core.ErrNoService, "No such service [%v]", service.Id)
-		}
+		err = storeError(err, version.ServiceId)
 	}()
+	return s.db.Do(s.guarded(core.SaveVersionWrite(version), saveVersion(s.actor, version)))
+}
 
-	// If this is the first version, just go ahead and insert.  If a concurrent
-	// insert is happening, the unique constraint will prevent one from winning.
-	if service.Version <= 0 {
-		return s.db.Do(sql.Exec(SchemaService.Insert(service)))
+// Every write is validated before the transaction begins, so that all the
+// invalid writes are reported together.  Thereafter, the first failed write
+// aborts the transaction.
+func (s *SqlServiceStore) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	ret = make([]core.WriteResult, len(writes))
+
+	invalid := false
+	for i, w := range writes {
+		if e := validateWrite(w); e != nil {
+			ret[i].Error, invalid = e.Error(), true
+		}
 	}
+	if invalid {
+		for i := range ret {
+			if ret[i].Ok() {
+				ret[i].Error = core.ErrAborted.Error()
+			}
+		}
+		err = core.BatchError(ret)
+		return
+	}
+
+	failed, cause := -1, error(nil)
+	if err = s.db.Do(func(tx sql.Tx) (err error) {
+		for i, w := range writes {
+			var write sql.Atomic
+			switch {
+			case w.Service != nil && w.Delete:
+				write = deleteService(s.actor, *w.Service)
+			case w.Service != nil:
+				write = saveService(s.actor, *w.Service)
+			case w.Delete:
+				write = deleteVersion(s.actor, *w.Version)
+			default:
+				write = saveVersion(s.actor, *w.Version)
+			}
+			if err = s.guarded(w, write).Exec(tx); err != nil {
+				failed, cause = i, storeError(err, w.ServiceId())
+				return
+			}
+		}
+		return
+	}); err != nil {
+		if failed < 0 {
+			return
+		}
+
+		ret = core.NewAbortedResults(len(writes), failed, cause)
+		err = core.BatchError(ret)
+		return
+	}
+
+	ret = core.NewCommittedResults(writes)
+	return
+}
 
-	return s.db.Do(
-		sql.ExpectOne(
+// Returns the write, preceded by the store's guard (if any).  The guard is
+// given the latest revision of the service as the transaction sees it.
+func (s *SqlServiceStore) guarded(w core.Write, write sql.Atomic) sql.Atomic {
+	if s.guard == nil {
+		return write
+	}
+
+	return func(tx sql.Tx) (err error) {
+		var stored core.Service
+		found, err := tx.Query(sql.Struct(&stored),
 			SchemaService.SelectAs("s").
-				Where("s.id = ?", service.Id).
-				Where("s.version = ?", service.Version-1)).
-			ThenExec(SchemaService.Insert(service)))
+				Where("s.id = ?", w.ServiceId()).
+				Where(latestService("s")))
+		if err != nil {
+			return
+		}
+
+		var cur *core.Service
+		if found {
+			cur = &stored
+		}
+		if err = s.guard(cur, w); err != nil {
+			return
+		}
+		return write.Exec(tx)
+	}
 }
 
-func (s *SqlServiceStore) SaveVersion(version core.Version) (err error) {
+func validateWrite(w core.Write) error {
+	if err := w.Validate(); err != nil {
+		return errors.Wrapf(err, "Exactly one of service or version must be set")
+	}
+	switch {
+	case w.Service != nil && w.Delete:
+		if w.Service.Id == emptyId {
+			return errors.Wrapf(core.ErrState, "Id must not be empty")
+		}
+		return nil
+	case w.Service != nil:
+		return validateService(*w.Service)
+	}
+	return validateVersion(*w.Version)
+}
+
+func validateService(service core.Service) error {
+	if service.Id == emptyId {
+		return errors.Wrapf(core.ErrState, "Id must not be empty")
+	}
+	if service.Name == "" {
+		return errors.Wrapf(core.ErrState, "Name must not be empty")
+	}
+	return nil
+}
+
+func validateVersion(version core.Version) error {
 	if version.ServiceId == emptyId {
-		err = errors.Wrapf(core.ErrState, "ServiceId must not be empty")
-		return
+		return errors.Wrapf(core.ErrState, "ServiceId must not be empty")
 	}
 	if version.Name == "" {
-		err = errors.Wrapf(core.ErrState, "Name must not be empty")
-		return
+		return errors.Wrapf(core.ErrState, "Name must not be empty")
 	}
+	return nil
+}
+
+// Translates sql errors into the standard core errors.
+func storeError(err error, serviceId uuid.UUID) error {
+	switch {
+	case errs.Is(err, sql.ErrSqliteUnique): // not portable
+		return core.ErrConflict
+	case errs.Is(err, sql.ErrNone):
+		return errors.Wrapf(core.ErrNoService, "No such service [%v]", serviceId)
+	}
+	return err
+}
+
+// Returns an atomic that appends the change to the log and audits it on
+// behalf of the actor.
+func record(actor core.Actor, change core.Change) sql.Atomic {
+	return sql.Exec(appendChange(change)).Then(appendAudit(actor, change))
+}
+
+// If this is the first version, just go ahead and insert.  If a concurrent
+// insert is happening, the unique constraint will prevent one from winning.
+// Otherwise, the previous version must exist.
+func saveService(actor core.Actor, service core.Service) sql.Atomic {
+	if service.Version <= 0 {
+		return sql.Exec(
+			SchemaService.Insert(service)).
+			Then(record(actor, core.NewServiceChange(service)))
+	}
+
+	return sql.ExpectOne(
+		SchemaService.SelectAs("s").
+			Where("s.id = ?", service.Id).
+			Where("s.version = ?", service.Version-1)).
+		ThenExec(
+			SchemaService.Insert(service)).
+		Then(record(actor, core.NewServiceChange(service)))
+}
+
+// The service must exist before a version can be added.
+func saveVersion(actor core.Actor, version core.Version) sql.Atomic {
+	return sql.ExpectOne(
+		SchemaService.SelectAs("s").
+			Where("s.id = ?", version.ServiceId).
+			Where(latestService("s"))).
+		ThenExec(
+			SchemaVersion.Insert(version)).
+		Then(record(actor, core.NewVersionChange(version)))
+}
+
+// Deletes must name the latest revision of the service, so that a service
+// is never deleted on the basis of stale state.  The stored revision is
+// recorded in the change, since the deleted service need only identify the
+// id and revision.
+func deleteService(actor core.Actor, service core.Service) sql.Atomic {
+	return func(tx sql.Tx) (err error) {
+		var stored core.Service
+		found, err := tx.Query(sql.Struct(&stored),
+			SchemaService.SelectAs("s").
+				Where("s.id = ?", service.Id).
+				Where(latestService("s")))
+		if err != nil {
+			return
+		}
 
-	defer func() {
 		switch {
-		case errs.Is(err, sql.ErrSqliteUnique): // not portable
-			err = core.ErrConflict
-		case errs.Is(err, sql.ErrNone):
-			err = errors.Wrapf(core.ErrNoService, "No such service [%v]", version.ServiceId)
+		case !found:
+			return sql.ErrNone
+		case stored.Version != service.Version:
+			return errors.Wrapf(core.ErrConflict, "Service [%v] is at version [%v]", service.Id, stored.Version)
 		}
-	}()
 
-	return s.db.Do(
-		sql.ExpectOne(
-			SchemaService.SelectAs("s").
-				Where("s.id = ?", version.ServiceId).
-				Where(latestService("s"))).
-			ThenExec(SchemaVersion.Insert(version)))
+		return sql.Exec(
+			SchemaVersion.Delete().Where("service_id = ?", service.Id),
+			SchemaService.Delete().Where("id = ?", service.Id)).
+			Then(record(actor, core.NewServiceDeletedChange(stored))).Exec(tx)
+	}
+}
+
+// The stored version is recorded in the change, since the deleted version
+// need only identify the service and name.
+func deleteVersion(actor core.Actor, version core.Version) sql.Atomic {
+	return func(tx sql.Tx) (err error) {
+		var stored core.Version
+		found, err := tx.Query(sql.Struct(&stored),
+			SchemaVersion.Select().
+				Where("service_id = ?", version.ServiceId).
+				Where("name = ?", version.Name))
+		if err != nil {
+			return
+		}
+		if !found {
+			return errors.Wrapf(core.ErrNoVersion, "No such version [%v/%v]", version.ServiceId, version.Name)
+		}
+
+		return sql.Exec(
+			SchemaVersion.Delete().
+				Where("service_id = ?", version.ServiceId).
+				Where("name = ?", version.Name)).
+			Then(record(actor, core.NewVersionDeletedChange(stored))).Exec(tx)
+	}
 }
 
 func (s *SqlServiceStore) ListServices(filter core.Filter, page core.Page) (ret core.Catalog, err error) {
@@ -131,6 +337,7 @@ select
 	s.id,
 	s.name,
 	s.desc,
+	s.owner,
 	s.version,
 	s.updated,
 	v.service_id,
@@ -185,8 +392,8 @@ order by s.%v, s.id, v.created
 		page.OrderBy)
 
 	type row struct {
-		core.Service
-		core.Version
+		Service core.Service
+		Version core.Version
 	}
 	var results []row
 
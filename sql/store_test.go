@@ -189,4 +189,221 @@ func TestServiceStore(t *testing.T) {
 	}) {
 		return
 	}
+
+	// Run through the change log.  Failed writes must not produce changes.
+	if !t.Run("ListChanges_All", func(t *testing.T) {
+		changes, err := store.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 5, len(changes))
+		for i, c := range changes {
+			assert.Equal(t, uint64(i+1), c.Seq)
+		}
+
+		assert.Equal(t, core.ServiceSaved, changes[0].Type)
+		assert.Equal(t, core.ServiceSaved, changes[1].Type)
+		assert.Equal(t, svc, *changes[1].Service)
+		assert.Equal(t, core.VersionSaved, changes[2].Type)
+		assert.Equal(t, v, *changes[2].Version)
+	}) {
+		return
+	}
+
+	if !t.Run("ListChanges_Since", func(t *testing.T) {
+		changes, err := store.ListChanges(3, 1)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, uint64(4), changes[0].Seq)
+	}) {
+		return
+	}
+
+	if !t.Run("LatestSeq", func(t *testing.T) {
+		seq, err := store.LatestSeq()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, uint64(5), seq)
+	}) {
+		return
+	}
+
+	// Batches are atomic.  A failed batch must leave nothing behind.
+	batched := core.NewService("batched", "description")
+	if !t.Run("SaveBatch", func(t *testing.T) {
+		results, err := store.SaveBatch([]core.Write{
+			core.SaveServiceWrite(batched),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v1")),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v2")),
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, batched, *results[0].Service)
+		assert.Equal(t, "v2", results[2].Version.Name)
+
+		catalog, err := store.ListServices(core.NewFilter(core.FilterByServiceId(batched.Id)), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 2, len(catalog.Versions[batched.Id]))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Conflict", func(t *testing.T) {
+		other := core.NewService("other", "description")
+
+		results, err := store.SaveBatch([]core.Write{
+			core.SaveServiceWrite(other),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v3")),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v1")),
+		})
+		assert.True(t, errs.Is(err, core.ErrConflict))
+		if !assert.Equal(t, 3, len(results)) {
+			return
+		}
+
+		assert.True(t, errs.Is(results[0].Err(), core.ErrAborted))
+		assert.True(t, errs.Is(results[1].Err(), core.ErrAborted))
+		assert.True(t, errs.Is(results[2].Err(), core.ErrConflict))
+
+		catalog, err := store.ListServices(core.NewFilter(core.FilterByServiceId(other.Id)), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, catalog.Services)
+
+		changes, err := store.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 8, len(changes))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_Invalid", func(t *testing.T) {
+		results, err := store.SaveBatch([]core.Write{
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "")),
+			core.SaveVersionWrite(core.NewVersion(batched.Id, "v3")),
+			{},
+		})
+		assert.True(t, errs.Is(err, core.ErrState))
+		if !assert.Equal(t, 3, len(results)) {
+			return
+		}
+
+		assert.True(t, errs.Is(results[0].Err(), core.ErrState))
+		assert.True(t, errs.Is(results[1].Err(), core.ErrAborted))
+		assert.True(t, errs.Is(results[2].Err(), core.ErrState))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_NoService", func(t *testing.T) {
+		results, err := store.SaveBatch([]core.Write{
+			core.SaveVersionWrite(core.NewVersion(uuid.NewV1(), "v1")),
+		})
+		assert.True(t, errs.Is(err, core.ErrNoService))
+		assert.True(t, errs.Is(results[0].Err(), core.ErrNoService))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_DeleteVersion", func(t *testing.T) {
+		deleted := core.NewService("deleted", "desc")
+		if _, err := store.SaveBatch([]core.Write{
+			core.SaveServiceWrite(deleted),
+			core.SaveVersionWrite(core.NewVersion(deleted.Id, "v1")),
+			core.SaveVersionWrite(core.NewVersion(deleted.Id, "v2")),
+		}); !assert.Nil(t, err) {
+			return
+		}
+
+		if _, err := store.SaveBatch([]core.Write{
+			core.DeleteVersionWrite(core.Version{ServiceId: deleted.Id, Name: "v1"}),
+		}); !assert.Nil(t, err) {
+			return
+		}
+
+		catalog, err := store.ListServices(core.NewFilter(core.FilterByServiceId(deleted.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Versions[deleted.Id])) {
+			return
+		}
+		assert.Equal(t, "v2", catalog.Versions[deleted.Id][0].Name)
+
+		_, err = store.SaveBatch([]core.Write{
+			core.DeleteVersionWrite(core.Version{ServiceId: deleted.Id, Name: "v1"}),
+		})
+		assert.True(t, errs.Is(err, core.ErrNoVersion))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveBatch_DeleteService", func(t *testing.T) {
+		deleted := core.NewService("deleted", "desc")
+		if _, err := store.SaveBatch([]core.Write{
+			core.SaveServiceWrite(deleted),
+			core.SaveVersionWrite(core.NewVersion(deleted.Id, "v1")),
+		}); !assert.Nil(t, err) {
+			return
+		}
+
+		// Stale revisions may not be deleted.
+		updated := deleted.Increment()
+		if !assert.Nil(t, store.SaveService(updated)) {
+			return
+		}
+
+		_, err := store.SaveBatch([]core.Write{core.DeleteServiceWrite(deleted)})
+		if !assert.True(t, errs.Is(err, core.ErrConflict)) {
+			return
+		}
+
+		if _, err := store.SaveBatch([]core.Write{core.DeleteServiceWrite(updated)}); !assert.Nil(t, err) {
+			return
+		}
+
+		catalog, err := store.ListServices(core.NewFilter(core.FilterByServiceId(deleted.Id)), core.NewPage())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, catalog.Services)
+		assert.Empty(t, catalog.Versions)
+
+		changes, err := store.ListChanges(0, 1024)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		last := changes[len(changes)-1]
+		assert.Equal(t, core.ServiceDeleted, last.Type)
+		assert.Equal(t, updated.Id, last.Service.Id)
+	}) {
+		return
+	}
+
+	if !t.Run("Count", func(t *testing.T) {
+		snapshot, err := store.Export(false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		services, versions, err := store.(core.CountedStorage).Count()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, len(snapshot.Services), services)
+		assert.Equal(t, len(snapshot.Versions), versions)
+	}) {
+		return
+	}
 }
@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Tokens are stored alongside the catalog.  Only the hashes of their
+// secrets are stored, and tokens are looked up by hash.
+//
+// Version 1 added the teams of the token, which are stored comma delimited.
+var (
+	SchemaToken = sql.NewSchema("token", 1).
+		WithStruct(tokenRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_token_uniq", "id"),
+			sql.NewUniqueIndex("idx_token_hash", "hash")).
+		WithMigration(0,
+			addColumn("token", sql.NewColumn("teams", sql.String), "")).
+		Build()
+)
+
+type tokenRow struct {
+	Id      uuid.UUID
+	Name    string
+	Scope   string
+	Hash    string
+	Created time.Time
+	Expires time.Time
+	Teams   string
+}
+
+func newTokenRow(t core.Token) tokenRow {
+	return tokenRow{t.Id, t.Name, string(t.Scope), t.Hash, t.Created, t.Expires, strings.Join(t.Teams, ",")}
+}
+
+func (r tokenRow) Token() (ret core.Token) {
+	ret = core.Token{
+		Id:      r.Id,
+		Name:    r.Name,
+		Scope:   core.Scope(r.Scope),
+		Hash:    r.Hash,
+		Created: r.Created,
+		Expires: r.Expires,
+	}
+	if r.Teams != "" {
+		ret.Teams = strings.Split(r.Teams, ",")
+	}
+	return
+}
+
+type SqlTokenStore struct {
+	db sql.Driver
+}
+
+func NewSqlTokenStore(db sql.Driver, schemas sql.SchemaRegistry) (ret core.TokenStorage, err error) {
+	if err = sql.InitSchemas(db, schemas, SchemaToken); err != nil {
+		return
+	}
+
+	ret = &SqlTokenStore{db}
+	return
+}
+
+func (s *SqlTokenStore) SaveToken(token core.Token) (err error) {
+	if token.Hash == "" {
+		err = errors.Wrapf(core.ErrState, "Token must have a hash")
+		return
+	}
+
+	return s.db.Do(
+		sql.Exec(
+			SchemaToken.Insert(newTokenRow(token))))
+}
+
+func (s *SqlTokenStore) LoadTokenByHash(hash string) (ret core.Token, err error) {
+	var row tokenRow
+	var found bool
+	if err = s.db.Do(
+		sql.QueryOne(
+			SchemaToken.Select().Where("hash = ?", hash),
+			sql.Struct(&row), &found)); err != nil {
+		return
+	}
+	if !found {
+		err = errors.Wrapf(core.ErrNoToken, "No such token")
+		return
+	}
+
+	ret = row.Token()
+	return
+}
+
+func (s *SqlTokenStore) ListTokens() (ret []core.Token, err error) {
+	var rows []tokenRow
+	if err = s.db.Do(
+		sql.Scan(
+			SchemaToken.Select().OrderBy("created", "id"),
+			sql.Slice(&rows, sql.Struct))); err != nil {
+		return
+	}
+
+	ret = make([]core.Token, 0, len(rows))
+	for _, r := range rows {
+		ret = append(ret, r.Token())
+	}
+	return
+}
+
+func (s *SqlTokenStore) RevokeToken(id uuid.UUID) (err error) {
+	defer func() {
+		if errs.Is(err, sql.ErrNone) {
+			err = errors.Wrapf(core.ErrNoToken, "No such token [%v]", id)
+		}
+	}()
+
+	return s.db.Do(
+		sql.ExpectOne(
+			SchemaToken.Select().Where("id = ?", id)).
+			ThenExec(
+				SchemaToken.Delete().Where("id = ?", id)))
+}
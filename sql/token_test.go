@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenStore(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := NewSqlTokenStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	token, secret, err := core.NewToken("ci", core.ScopeWrite, time.Hour)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !t.Run("SaveToken", func(t *testing.T) {
+		assert.Nil(t, store.SaveToken(token))
+	}) {
+		return
+	}
+
+	if !t.Run("LoadTokenByHash", func(t *testing.T) {
+		loaded, err := store.LoadTokenByHash(core.HashSecret(secret))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, token.Id, loaded.Id)
+		assert.Equal(t, core.ScopeWrite, loaded.Scope)
+		assert.True(t, token.Expires.Equal(loaded.Expires))
+	}) {
+		return
+	}
+
+	if !t.Run("LoadTokenByHash_NoToken", func(t *testing.T) {
+		_, err := store.LoadTokenByHash(core.HashSecret("unknown"))
+		assert.True(t, errs.Is(err, core.ErrNoToken))
+	}) {
+		return
+	}
+
+	if !t.Run("ListTokens", func(t *testing.T) {
+		other, _, err := core.NewToken("admin", core.ScopeAdmin, 0, "payments", "billing")
+		if !assert.Nil(t, err) || !assert.Nil(t, store.SaveToken(other)) {
+			return
+		}
+
+		tokens, err := store.ListTokens()
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(tokens)) {
+			return
+		}
+		assert.Equal(t, token.Id, tokens[0].Id)
+		assert.Equal(t, other.Id, tokens[1].Id)
+		assert.True(t, tokens[1].Expires.IsZero())
+		assert.Equal(t, []string{"payments", "billing"}, tokens[1].Teams)
+		assert.Nil(t, tokens[0].Teams)
+	}) {
+		return
+	}
+
+	if !t.Run("RevokeToken", func(t *testing.T) {
+		if !assert.Nil(t, store.RevokeToken(token.Id)) {
+			return
+		}
+
+		_, err := store.LoadTokenByHash(core.HashSecret(secret))
+		assert.True(t, errs.Is(err, core.ErrNoToken))
+	}) {
+		return
+	}
+
+	if !t.Run("RevokeToken_NoToken", func(t *testing.T) {
+		assert.True(t, errs.Is(store.RevokeToken(uuid.NewV1()), core.ErrNoToken))
+	}) {
+		return
+	}
+}
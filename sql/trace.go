@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Every supported driver speaks the sqlite dialect, so it's used to render
+// the statements recorded on spans.
+var traceDialect = &sql.SqlLiteDialect{}
+
+// Returns a driver that records every transaction as a child of the span,
+// and every statement as a child of its transaction.  Statements are
+// recorded without their bindings, which may hold sensitive values.
+func NewTracedDriver(db sql.Driver, span core.Span) sql.Driver {
+	return &tracedDriver{db, span}
+}
+
+type tracedDriver struct {
+	sql.Driver
+	span core.Span
+}
+
+func (d *tracedDriver) Do(fn func(sql.Tx) error) (err error) {
+	span := d.span.Child("sql.transaction")
+	defer func() { span.End(err) }()
+
+	err = d.Driver.Do(func(tx sql.Tx) error {
+		return fn(&tracedTx{tx, span})
+	})
+	return
+}
+
+type tracedTx struct {
+	raw  sql.Tx
+	span core.Span
+}
+
+func (t *tracedTx) start(name string, q sql.Query) core.Span {
+	span := t.span.Child(name)
+	if stmt, _, err := q.ToSql(traceDialect); err == nil {
+		span.SetAttribute("db.statement", stmt)
+	}
+	return span
+}
+
+func (t *tracedTx) Exec(q sql.Query) (ret int64, err error) {
+	span := t.start("sql.exec", q)
+	defer func() { span.End(err) }()
+	ret, err = t.raw.Exec(q)
+	return
+}
+
+func (t *tracedTx) Query(o sql.Object, q sql.Query) (ret bool, err error) {
+	span := t.start("sql.query", q)
+	defer func() { span.End(err) }()
+	ret, err = t.raw.Query(o, q)
+	return
+}
+
+func (t *tracedTx) Scan(b sql.Buffer, q sql.Query) (ret int64, err error) {
+	span := t.start("sql.scan", q)
+	defer func() { span.End(err) }()
+	ret, err = t.raw.Scan(b, q)
+	return
+}
@@ -0,0 +1,213 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Transfers are stored alongside the catalog, so that a transfer completes
+// in the same transaction as the save of its service.  At most one transfer
+// of a service may be pending, which is enforced when transfers are saved.
+var (
+	SchemaTransfer = sql.NewSchema("transfer", 0).
+		WithStruct(transferRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_transfer_uniq", "id"),
+			sql.NewIndex("idx_transfer_service", "service_id", "created")).
+		Build()
+)
+
+// From and to are reserved words, so the teams are stored by other names.
+type transferRow struct {
+	Id        uuid.UUID
+	ServiceId uuid.UUID
+	FromTeam  string
+	ToTeam    string
+	FromBy    string
+	ToBy      string
+	Pending   bool
+	Created   time.Time
+	Completed time.Time
+}
+
+func newTransferRow(t core.Transfer) transferRow {
+	return transferRow{t.Id, t.ServiceId, t.From, t.To, t.FromBy, t.ToBy, t.Pending(), t.Created, t.Completed}
+}
+
+func (r transferRow) Transfer() core.Transfer {
+	return core.Transfer{
+		Id:        r.Id,
+		ServiceId: r.ServiceId,
+		From:      r.FromTeam,
+		To:        r.ToTeam,
+		FromBy:    r.FromBy,
+		ToBy:      r.ToBy,
+		Created:   r.Created,
+		Completed: r.Completed,
+	}
+}
+
+// Completed transfers save their services on behalf of the store's actor.
+type SqlTransferStore struct {
+	db    sql.Driver
+	actor core.Actor
+}
+
+func NewSqlTransferStore(db sql.Driver, schemas sql.SchemaRegistry) (ret core.TransferStorage, err error) {
+	if err = sql.InitSchemas(db, schemas, SchemaTransfer); err != nil {
+		return
+	}
+
+	ret = &SqlTransferStore{db: db}
+	return
+}
+
+func (s *SqlTransferStore) WithActor(actor core.Actor) core.TransferStorage {
+	return &SqlTransferStore{s.db, actor}
+}
+
+func (s *SqlTransferStore) SaveTransfer(transfer core.Transfer) (err error) {
+	if transfer.ServiceId == emptyId {
+		err = errors.Wrapf(core.ErrState, "ServiceId must not be empty")
+		return
+	}
+	if err = core.ValidateTeam(transfer.To); err != nil {
+		return
+	}
+	if !transfer.Pending() || transfer.Consented() {
+		err = errors.Wrapf(core.ErrState, "Transfer must be pending")
+		return
+	}
+
+	defer func() {
+		if errs.Is(err, sql.ErrNotEmpty) {
+			err = errors.Wrapf(core.ErrConflict, "Service [%v] has a pending transfer", transfer.ServiceId)
+		}
+	}()
+
+	return s.db.Do(
+		sql.ExpectNone(
+			SchemaTransfer.Select().
+				Where("service_id = ?", transfer.ServiceId).
+				Where("pending = ?", true)).
+			ThenExec(
+				SchemaTransfer.Insert(newTransferRow(transfer))))
+}
+
+func (s *SqlTransferStore) LoadTransfer(id uuid.UUID) (ret core.Transfer, err error) {
+	err = s.db.Do(func(tx sql.Tx) (err error) {
+		ret, err = loadTransfer(tx, id)
+		return
+	})
+	return
+}
+
+func (s *SqlTransferStore) ListTransfers(serviceId uuid.UUID) (ret []core.Transfer, err error) {
+	var rows []transferRow
+	if err = s.db.Do(
+		sql.Scan(
+			SchemaTransfer.Select().
+				Where("service_id = ?", serviceId).
+				OrderBy("created", "id"),
+			sql.Slice(&rows, sql.Struct))); err != nil {
+		return
+	}
+
+	ret = make([]core.Transfer, 0, len(rows))
+	for _, r := range rows {
+		ret = append(ret, r.Transfer())
+	}
+	return
+}
+
+// The consents are merged with those already stored, so that concurrent
+// approvals by both teams aren't lost.  The service is saved with the same
+// atomic as any other save, so the completion of a transfer is published
+// as a change like any other.
+func (s *SqlTransferStore) ConsentTransfer(transfer core.Transfer) (ret core.Transfer, err error) {
+	err = s.db.Do(func(tx sql.Tx) (err error) {
+		if ret, err = loadTransfer(tx, transfer.Id); err != nil {
+			return
+		}
+		if !ret.Pending() {
+			return errors.Wrapf(core.ErrConflict, "Transfer [%v] is no longer pending", ret.Id)
+		}
+
+		if ret.FromBy == "" {
+			ret.FromBy = transfer.FromBy
+		}
+		if ret.ToBy == "" {
+			ret.ToBy = transfer.ToBy
+		}
+
+		if ret.Consented() {
+			var service core.Service
+			found, e := tx.Query(sql.Struct(&service),
+				SchemaService.SelectAs("s").
+					Where("s.id = ?", ret.ServiceId).
+					Where(latestService("s")))
+			if e != nil {
+				return e
+			}
+			if !found {
+				return errors.Wrapf(core.ErrNoService, "No such service [%v]", ret.ServiceId)
+			}
+			if service.Owner != ret.From {
+				return errors.Wrapf(core.ErrConflict, "Service [%v] is no longer owned by [%v]", ret.ServiceId, ret.From)
+			}
+
+			service = service.Increment().SetOwner(ret.To)
+			if err = saveService(s.actor, service).Exec(tx); err != nil {
+				return storeError(err, service.Id)
+			}
+			ret.Completed = service.Updated
+		}
+
+		_, err = tx.Exec(
+			SchemaTransfer.Update().
+				Set("from_by", ret.FromBy).
+				Set("to_by", ret.ToBy).
+				Set("pending", ret.Pending()).
+				Set("completed", ret.Completed).
+				Where("id = ?", ret.Id))
+		return
+	})
+	return
+}
+
+func (s *SqlTransferStore) CancelTransfer(id uuid.UUID) (err error) {
+	defer func() {
+		if errs.Is(err, sql.ErrNone) {
+			err = errors.Wrapf(core.ErrNoTransfer, "No pending transfer [%v]", id)
+		}
+	}()
+
+	return s.db.Do(
+		sql.ExpectOne(
+			SchemaTransfer.Select().
+				Where("id = ?", id).
+				Where("pending = ?", true)).
+			ThenExec(
+				SchemaTransfer.Delete().Where("id = ?", id)))
+}
+
+func loadTransfer(tx sql.Tx, id uuid.UUID) (ret core.Transfer, err error) {
+	var row transferRow
+	found, err := tx.Query(sql.Struct(&row),
+		SchemaTransfer.Select().Where("id = ?", id))
+	if err != nil {
+		return
+	}
+	if !found {
+		err = errors.Wrapf(core.ErrNoTransfer, "No such transfer [%v]", id)
+		return
+	}
+
+	ret = row.Transfer()
+	return
+}
@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferStore(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, err := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	store, err := NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	transfers, err := NewSqlTransferStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	svc := core.NewService("name", "desc")
+	svc.Owner = "payments"
+	if !assert.Nil(t, store.SaveService(svc)) {
+		return
+	}
+
+	transfer := core.NewTransfer(svc.Id, "payments", "billing")
+	transfer.FromBy = "alice"
+	if !t.Run("SaveTransfer", func(t *testing.T) {
+		assert.Nil(t, transfers.SaveTransfer(transfer))
+	}) {
+		return
+	}
+
+	if !t.Run("SaveTransfer_Conflict", func(t *testing.T) {
+		err := transfers.SaveTransfer(core.NewTransfer(svc.Id, "payments", "other"))
+		assert.True(t, errs.Is(err, core.ErrConflict))
+	}) {
+		return
+	}
+
+	if !t.Run("LoadTransfer", func(t *testing.T) {
+		loaded, err := transfers.LoadTransfer(transfer.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "billing", loaded.To)
+		assert.Equal(t, "alice", loaded.FromBy)
+		assert.True(t, loaded.Pending())
+	}) {
+		return
+	}
+
+	if !t.Run("LoadTransfer_NoTransfer", func(t *testing.T) {
+		_, err := transfers.LoadTransfer(uuid.NewV1())
+		assert.True(t, errs.Is(err, core.ErrNoTransfer))
+	}) {
+		return
+	}
+
+	if !t.Run("ConsentTransfer", func(t *testing.T) {
+		consented, err := transfers.ConsentTransfer(core.Transfer{Id: transfer.Id, ToBy: "bob"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "alice", consented.FromBy)
+		assert.Equal(t, "bob", consented.ToBy)
+		assert.False(t, consented.Pending())
+
+		catalog, err := store.ListServices(core.NewFilter(core.FilterByServiceId(svc.Id)), core.NewPage())
+		if !assert.Nil(t, err) || !assert.Equal(t, 1, len(catalog.Services)) {
+			return
+		}
+		assert.Equal(t, "billing", catalog.Services[0].Owner)
+		assert.Equal(t, svc.Version+1, catalog.Services[0].Version)
+	}) {
+		return
+	}
+
+	if !t.Run("ConsentTransfer_Completed", func(t *testing.T) {
+		_, err := transfers.ConsentTransfer(core.Transfer{Id: transfer.Id, ToBy: "bob"})
+		assert.True(t, errs.Is(err, core.ErrConflict))
+	}) {
+		return
+	}
+
+	// A transfer fails if the service changed hands since it was requested.
+	if !t.Run("ConsentTransfer_NotOwned", func(t *testing.T) {
+		stale := core.NewTransfer(svc.Id, "payments", "other")
+		stale.FromBy = "alice"
+		if !assert.Nil(t, transfers.SaveTransfer(stale)) {
+			return
+		}
+
+		_, err := transfers.ConsentTransfer(core.Transfer{Id: stale.Id, ToBy: "carol"})
+		assert.True(t, errs.Is(err, core.ErrConflict))
+
+		loaded, err := transfers.LoadTransfer(stale.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, loaded.Pending())
+		assert.Equal(t, "", loaded.ToBy)
+	}) {
+		return
+	}
+
+	if !t.Run("ListTransfers", func(t *testing.T) {
+		list, err := transfers.ListTransfers(svc.Id)
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(list)) {
+			return
+		}
+		assert.Equal(t, transfer.Id, list[0].Id)
+		assert.False(t, list[0].Pending())
+		assert.True(t, list[1].Pending())
+	}) {
+		return
+	}
+
+	if !t.Run("CancelTransfer", func(t *testing.T) {
+		list, err := transfers.ListTransfers(svc.Id)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Nil(t, transfers.CancelTransfer(list[1].Id))
+		assert.True(t, errs.Is(transfers.CancelTransfer(list[1].Id), core.ErrNoTransfer))
+		assert.True(t, errs.Is(transfers.CancelTransfer(transfer.Id), core.ErrNoTransfer))
+	}) {
+		return
+	}
+}
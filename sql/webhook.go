@@ -0,0 +1,255 @@
+package sql
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Webhooks are stored alongside the catalog.  Deliveries form a simple
+// work queue that is fed from the change log.  The cursor table tracks
+// the position of the queue within the change log.
+var (
+	SchemaWebhook = sql.NewSchema("webhook", 0).
+		WithStruct(webhookRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_webhook_uniq", "id")).
+		Build()
+)
+
+var (
+	SchemaDelivery = sql.NewSchema("webhook_delivery", 0).
+		WithStruct(core.Delivery{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_delivery_uniq", "id"),
+			sql.NewUniqueIndex("idx_delivery_change", "webhook_id", "seq"),
+			sql.NewIndex("idx_delivery_due", "status", "next_attempt")).
+		Build()
+)
+
+var (
+	SchemaAttempt = sql.NewSchema("webhook_attempt", 0).
+		WithStruct(core.Attempt{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_attempt_uniq", "delivery_id", "num")).
+		Build()
+)
+
+var (
+	SchemaCursor = sql.NewSchema("webhook_cursor", 0).
+		WithStruct(cursorRow{}).
+		WithIndices(
+			sql.NewUniqueIndex("idx_cursor_uniq", "name")).
+		Build()
+)
+
+const webhookCursor = "webhooks"
+
+// The subscribed events are stored as a comma separated list.
+type webhookRow struct {
+	Id      uuid.UUID
+	Url     string
+	Secret  string
+	Events  string
+	Filter  core.Filter
+	Created time.Time
+}
+
+func newWebhookRow(w core.Webhook) webhookRow {
+	events := make([]string, 0, len(w.Events))
+	for _, e := range w.Events {
+		events = append(events, string(e))
+	}
+	return webhookRow{w.Id, w.Url, w.Secret, strings.Join(events, ","), w.Filter, w.Created}
+}
+
+func (r webhookRow) Webhook() (ret core.Webhook) {
+	ret = core.Webhook{
+		Id:      r.Id,
+		Url:     r.Url,
+		Secret:  r.Secret,
+		Filter:  r.Filter,
+		Created: r.Created,
+	}
+	for _, e := range strings.Split(r.Events, ",") {
+		if e != "" {
+			ret.Events = append(ret.Events, core.ChangeType(e))
+		}
+	}
+	return
+}
+
+type cursorRow struct {
+	Name string
+	Seq  uint64
+}
+
+type SqlWebhookStore struct {
+	db sql.Driver
+}
+
+func NewSqlWebhookStore(db sql.Driver, schemas sql.SchemaRegistry) (ret core.WebhookStorage, err error) {
+	if err = sql.InitSchemas(db, schemas,
+		SchemaWebhook,
+		SchemaDelivery,
+		SchemaAttempt,
+		SchemaCursor); err != nil {
+		return
+	}
+
+	ret = &SqlWebhookStore{db}
+	return
+}
+
+func (s *SqlWebhookStore) SaveWebhook(hook core.Webhook) (err error) {
+	if hook.Id == emptyId {
+		err = errors.Wrapf(core.ErrState, "Id must not be empty")
+		return
+	}
+	if hook.Url == "" {
+		err = errors.Wrapf(core.ErrState, "Url must not be empty")
+		return
+	}
+
+	return s.db.Do(
+		sql.Exec(
+			SchemaWebhook.Delete().Where("id = ?", hook.Id),
+			SchemaWebhook.Insert(newWebhookRow(hook))))
+}
+
+func (s *SqlWebhookStore) DeleteWebhook(id uuid.UUID) (err error) {
+	defer func() {
+		if errs.Is(err, sql.ErrNone) {
+			err = errors.Wrapf(core.ErrNoWebhook, "No such webhook [%v]", id)
+		}
+	}()
+
+	return s.db.Do(
+		sql.ExpectOne(
+			SchemaWebhook.Select().Where("id = ?", id)).
+			ThenExec(
+				SchemaAttempt.Delete().Where(
+					"delivery_id in (select id from webhook_delivery where webhook_id = ?)", id),
+				SchemaDelivery.Delete().Where("webhook_id = ?", id),
+				SchemaWebhook.Delete().Where("id = ?", id)))
+}
+
+func (s *SqlWebhookStore) LoadWebhook(id uuid.UUID) (ret core.Webhook, err error) {
+	var row webhookRow
+	var found bool
+	if err = s.db.Do(
+		sql.QueryOne(
+			SchemaWebhook.Select().Where("id = ?", id),
+			sql.Struct(&row), &found)); err != nil {
+		return
+	}
+	if !found {
+		err = errors.Wrapf(core.ErrNoWebhook, "No such webhook [%v]", id)
+		return
+	}
+
+	ret = row.Webhook()
+	return
+}
+
+func (s *SqlWebhookStore) ListWebhooks() (ret []core.Webhook, err error) {
+	var rows []webhookRow
+	if err = s.db.Do(
+		sql.Scan(
+			SchemaWebhook.Select().OrderBy("created", "id"),
+			sql.Slice(&rows, sql.Struct))); err != nil {
+		return
+	}
+
+	ret = make([]core.Webhook, 0, len(rows))
+	for _, r := range rows {
+		ret = append(ret, r.Webhook())
+	}
+	return
+}
+
+func (s *SqlWebhookStore) EnqueueDeliveries(cursor uint64, deliveries []core.Delivery) (err error) {
+	queries := make([]sql.Query, 0, len(deliveries)+2)
+	for _, d := range deliveries {
+		queries = append(queries, SchemaDelivery.Insert(d))
+	}
+
+	queries = append(queries,
+		SchemaCursor.Delete().Where("name = ?", webhookCursor),
+		SchemaCursor.Insert(cursorRow{webhookCursor, cursor}))
+
+	err = s.db.Do(sql.Exec(queries...))
+	return
+}
+
+func (s *SqlWebhookStore) ReadCursor() (ret uint64, err error) {
+	var found bool
+	err = s.db.Do(
+		sql.QueryOne(
+			sql.Select("seq").
+				From(SchemaCursor.Name).
+				Where("name = ?", webhookCursor),
+			sql.Value(&ret), &found))
+	return
+}
+
+func (s *SqlWebhookStore) ListDue(webhookId uuid.UUID, now time.Time, limit uint64) (ret []core.Delivery, err error) {
+	err = s.db.Do(
+		sql.Scan(
+			SchemaDelivery.Select().
+				Where("webhook_id = ?", webhookId).
+				Where("status = ?", string(core.DeliveryPending)).
+				Where("next_attempt <= ?", now).
+				OrderBy("next_attempt", "seq").
+				Limit(limit),
+			sql.Slice(&ret, sql.Struct)))
+	return
+}
+
+func (s *SqlWebhookStore) RecordAttempt(delivery core.Delivery, attempt core.Attempt) (err error) {
+	err = s.db.Do(
+		sql.Exec(
+			SchemaAttempt.Insert(attempt),
+			SchemaDelivery.Update().
+				Set("status", string(delivery.Status)).
+				Set("attempts", delivery.Attempts).
+				Set("next_attempt", delivery.NextAttempt).
+				Set("last_error", delivery.LastError).
+				Set("updated", delivery.Updated).
+				Where("id = ?", delivery.Id)))
+	return
+}
+
+func (s *SqlWebhookStore) ListDeliveries(filter core.DeliveryFilter, page core.Page) (ret []core.Delivery, err error) {
+	query := SchemaDelivery.Select()
+	if filter.WebhookId != nil {
+		query = query.Where("webhook_id = ?", *filter.WebhookId)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", string(*filter.Status))
+	}
+
+	err = s.db.Do(
+		sql.Scan(
+			query.
+				OrderBy("created desc", "seq desc").
+				Offset(page.Offset).
+				Limit(page.Limit),
+			sql.Slice(&ret, sql.Struct)))
+	return
+}
+
+func (s *SqlWebhookStore) ListAttempts(id uuid.UUID) (ret []core.Attempt, err error) {
+	err = s.db.Do(
+		sql.Scan(
+			SchemaAttempt.Select().
+				Where("delivery_id = ?", id).
+				OrderBy("num"),
+			sql.Slice(&ret, sql.Struct)))
+	return
+}
@@ -0,0 +1,135 @@
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidTraceparent = errors.New("Trace:ErrInvalidTraceparent")
+)
+
+// The header that propagates traces between services, as defined by
+// https://www.w3.org/TR/trace-context/
+const (
+	TraceparentHeader = "traceparent"
+)
+
+type TraceId [16]byte
+
+func (t TraceId) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceId) IsZero() bool {
+	return t == TraceId{}
+}
+
+type SpanId [8]byte
+
+func (s SpanId) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanId) IsZero() bool {
+	return s == SpanId{}
+}
+
+func newTraceId() (ret TraceId) {
+	if _, err := rand.Read(ret[:]); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func newSpanId() (ret SpanId) {
+	if _, err := rand.Read(ret[:]); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// A span context identifies a span across process boundaries.  The zero
+// value identifies no span, and a span started beneath it is the root of
+// a new trace.
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+func (c SpanContext) Valid() bool {
+	return !c.TraceId.IsZero() && !c.SpanId.IsZero()
+}
+
+// Returns the context formatted as a version 00 traceparent header.
+func (c SpanContext) Traceparent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%v-%v-%v", c.TraceId, c.SpanId, flags)
+}
+
+// Parses a traceparent header.  Headers of later versions are parsed as if
+// they were version 00, ignoring any trailing fields, as the specification
+// requires.
+func ParseTraceparent(val string) (ret SpanContext, err error) {
+	val = strings.TrimSpace(val)
+
+	fields := strings.Split(val, "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || len(fields[1]) != 32 || len(fields[2]) != 16 || len(fields[3]) != 2 {
+		err = errors.Wrapf(ErrInvalidTraceparent, "Malformed traceparent [%v]", val)
+		return
+	}
+
+	version, err := decodeHex(fields[0], 1)
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(fields) != 4) {
+		err = errors.Wrapf(ErrInvalidTraceparent, "Invalid traceparent version [%v]", val)
+		return
+	}
+
+	traceId, err := decodeHex(fields[1], 16)
+	if err != nil {
+		err = errors.Wrapf(ErrInvalidTraceparent, "Invalid trace id [%v]", val)
+		return
+	}
+
+	spanId, err := decodeHex(fields[2], 8)
+	if err != nil {
+		err = errors.Wrapf(ErrInvalidTraceparent, "Invalid parent id [%v]", val)
+		return
+	}
+
+	flags, err := decodeHex(fields[3], 1)
+	if err != nil {
+		err = errors.Wrapf(ErrInvalidTraceparent, "Invalid trace flags [%v]", val)
+		return
+	}
+
+	copy(ret.TraceId[:], traceId)
+	copy(ret.SpanId[:], spanId)
+	ret.Sampled = flags[0]&0x01 == 0x01
+	if !ret.Valid() {
+		err = errors.Wrapf(ErrInvalidTraceparent, "Zero ids in traceparent [%v]", val)
+		ret = SpanContext{}
+	}
+	return
+}
+
+// Decodes lowercase hex, which is the only case the specification allows.
+func decodeHex(val string, size int) (ret []byte, err error) {
+	if strings.ToLower(val) != val {
+		err = errors.Errorf("Uppercase hex [%v]", val)
+		return
+	}
+	ret, err = hex.DecodeString(val)
+	if err == nil && len(ret) != size {
+		err = errors.Errorf("Expected [%v] bytes, got [%v]", size, len(ret))
+	}
+	return
+}
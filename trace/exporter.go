@@ -0,0 +1,34 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// An exporter ships finished spans to wherever they're analyzed.  Exports
+// are never concurrent with one another.
+type Exporter interface {
+	Export([]SpanData) error
+}
+
+// Writes every span as a line of json.
+type JsonExporter struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJsonExporter(w io.Writer) *JsonExporter {
+	return &JsonExporter{encoder: json.NewEncoder(w)}
+}
+
+func (e *JsonExporter) Export(spans []SpanData) (err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, s := range spans {
+		if err = e.encoder.Encode(s); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,206 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The default endpoint of an OpenTelemetry collector receiving OTLP/HTTP.
+const (
+	DefaultOtlpEndpoint = "http://localhost:4318/v1/traces"
+)
+
+type OtlpOption func(*OtlpOptions)
+
+type OtlpOptions struct {
+	ServiceName string
+	Headers     map[string]string
+	Timeout     time.Duration
+}
+
+func buildOtlpOptions(fns ...OtlpOption) (ret OtlpOptions) {
+	ret = OtlpOptions{ServiceName: "services-catalog", Headers: map[string]string{}, Timeout: 10 * time.Second}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the service.name attribute of the exported resource.
+func WithServiceName(name string) OtlpOption {
+	return func(o *OtlpOptions) {
+		o.ServiceName = name
+	}
+}
+
+// Adds a header to every export (e.g. the credentials of a hosted collector).
+func WithHeader(name, val string) OtlpOption {
+	return func(o *OtlpOptions) {
+		o.Headers[name] = val
+	}
+}
+
+// Sets the timeout of a single export.
+func WithExportTimeout(dur time.Duration) OtlpOption {
+	return func(o *OtlpOptions) {
+		o.Timeout = dur
+	}
+}
+
+// Exports spans to an OpenTelemetry collector, using the json encoding of
+// OTLP/HTTP.  Failed exports aren't retried.
+type OtlpExporter struct {
+	endpoint string
+	opts     OtlpOptions
+	client   *http.Client
+}
+
+func NewOtlpExporter(endpoint string, fns ...OtlpOption) *OtlpExporter {
+	opts := buildOtlpOptions(fns...)
+	return &OtlpExporter{endpoint, opts, &http.Client{Timeout: opts.Timeout}}
+}
+
+func (e *OtlpExporter) Export(spans []SpanData) (err error) {
+	body, err := json.Marshal(newOtlpRequest(e.opts.ServiceName, spans))
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, val := range e.opts.Headers {
+		req.Header.Set(name, val)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("Collector [%v] rejected export [%v]: %s", e.endpoint, resp.StatusCode, msg)
+	}
+	return
+}
+
+// The following mirror the messages of the OTLP trace service, as they're
+// encoded in json.  Ids are hex and timestamps are strings of nanoseconds.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// The span kinds and status codes of OTLP.
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+func newOtlpRequest(service string, spans []SpanData) otlpRequest {
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		converted = append(converted, newOtlpSpan(s))
+	}
+
+	return otlpRequest{[]otlpResourceSpans{{
+		Resource: otlpResource{[]otlpAttribute{{"service.name", otlpValue{service}}}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{"github.com/pkopriv2/services-catalog/trace"},
+			Spans: converted,
+		}},
+	}}}
+}
+
+func newOtlpSpan(s SpanData) (ret otlpSpan) {
+	ret = otlpSpan{
+		TraceId:           s.TraceId,
+		SpanId:            s.SpanId,
+		ParentSpanId:      s.ParentId,
+		Name:              s.Name,
+		Kind:              otlpKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		Status:            otlpStatus{Code: otlpStatusOk},
+	}
+
+	switch s.Kind {
+	case Server:
+		ret.Kind = otlpKindServer
+	case Client:
+		ret.Kind = otlpKindClient
+	}
+
+	if s.Error != "" {
+		ret.Status = otlpStatus{otlpStatusError, s.Error}
+	}
+
+	keys := make([]string, 0, len(s.Attributes))
+	for k := range s.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ret.Attributes = append(ret.Attributes, otlpAttribute{k, otlpValue{s.Attributes[k]}})
+	}
+	return
+}
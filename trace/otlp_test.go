@@ -0,0 +1,98 @@
+package trace
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOtlpExporter(t *testing.T) {
+	var received []otlpRequest
+	var headers []http.Header
+	status := 200
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err == nil {
+			received = append(received, req)
+		}
+		headers = append(headers, r.Header)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	start := time.Unix(1700000000, 5)
+	spans := []SpanData{
+		{
+			TraceId:    "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:     "00f067aa0ba902b7",
+			Name:       "GET /v1/services",
+			Kind:       Server,
+			Start:      start,
+			End:        start.Add(time.Millisecond),
+			Attributes: map[string]string{"http.route": "/v1/services", "http.method": "GET"},
+		},
+		{
+			TraceId:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:   "b7ad6b7169203331",
+			ParentId: "00f067aa0ba902b7",
+			Name:     "storage.ListServices",
+			Kind:     Internal,
+			Start:    start,
+			End:      start,
+			Error:    "boom",
+		},
+	}
+
+	exporter := NewOtlpExporter(server.URL+"/v1/traces",
+		WithServiceName("catalog"),
+		WithHeader("Authorization", "Bearer secret"))
+
+	if !t.Run("Export", func(t *testing.T) {
+		if !assert.Nil(t, exporter.Export(spans)) || !assert.Equal(t, 1, len(received)) {
+			return
+		}
+		assert.Equal(t, "application/json", headers[0].Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", headers[0].Get("Authorization"))
+
+		resource := received[0].ResourceSpans[0]
+		assert.Equal(t, []otlpAttribute{{"service.name", otlpValue{"catalog"}}}, resource.Resource.Attributes)
+
+		exported := resource.ScopeSpans[0].Spans
+		if !assert.Equal(t, 2, len(exported)) {
+			return
+		}
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exported[0].TraceId)
+		assert.Equal(t, "00f067aa0ba902b7", exported[0].SpanId)
+		assert.Equal(t, "", exported[0].ParentSpanId)
+		assert.Equal(t, otlpKindServer, exported[0].Kind)
+		assert.Equal(t, "1700000000000000005", exported[0].StartTimeUnixNano)
+		assert.Equal(t, "1700000000001000005", exported[0].EndTimeUnixNano)
+		assert.Equal(t, otlpStatus{Code: otlpStatusOk}, exported[0].Status)
+		assert.Equal(t, []otlpAttribute{
+			{"http.method", otlpValue{"GET"}},
+			{"http.route", otlpValue{"/v1/services"}},
+		}, exported[0].Attributes)
+
+		assert.Equal(t, "00f067aa0ba902b7", exported[1].ParentSpanId)
+		assert.Equal(t, otlpKindInternal, exported[1].Kind)
+		assert.Equal(t, otlpStatus{otlpStatusError, "boom"}, exported[1].Status)
+	}) {
+		return
+	}
+
+	if !t.Run("Export_Rejected", func(t *testing.T) {
+		status = 503
+		assert.NotNil(t, exporter.Export(spans))
+	}) {
+		return
+	}
+}
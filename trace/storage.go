@@ -0,0 +1,119 @@
+package trace
+
+import (
+	"strconv"
+
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Storage traces any storage implementation.  Every method is recorded as a
+// child of the span the storage was traced with, and the underlying storage
+// is traced with that child (e.g. so its sql statements nest beneath it).
+//
+// Operations made outside of a trace aren't recorded, so that background
+// work, like polling the change log, doesn't flood the exporter.
+type Storage struct {
+	raw    core.Storage
+	parent core.Span
+}
+
+func NewStorage(raw core.Storage) *Storage {
+	return &Storage{raw: raw}
+}
+
+// Traces operations as children of the span.
+func (s *Storage) WithSpan(span core.Span) core.Storage {
+	return &Storage{s.raw, span}
+}
+
+// Attributes writes to the actor (if the underlying storage is audited).
+func (s *Storage) WithActor(actor core.Actor) core.Storage {
+	return &Storage{core.Attribute(s.raw, actor), s.parent}
+}
+
+// Guards writes (if the underlying storage is guarded).
+func (s *Storage) WithGuard(guard core.Guard) core.Storage {
+	return &Storage{core.Guarded(s.raw, guard), s.parent}
+}
+
+// Starts the span of a method and returns the storage it should be
+// performed against.  The span must be ended with the method's error.
+func (s *Storage) start(method string) (core.Span, core.Storage) {
+	if s.parent == nil {
+		return noopSpan{}, s.raw
+	}
+
+	span := s.parent.Child("storage." + method)
+	return span, core.Trace(s.raw, span)
+}
+
+func (s *Storage) SaveService(svc core.Service) (err error) {
+	span, raw := s.start("SaveService")
+	defer func() { span.End(err) }()
+	span.SetAttribute("catalog.service_id", svc.Id.String())
+	err = raw.SaveService(svc)
+	return
+}
+
+func (s *Storage) SaveVersion(v core.Version) (err error) {
+	span, raw := s.start("SaveVersion")
+	defer func() { span.End(err) }()
+	span.SetAttribute("catalog.service_id", v.ServiceId.String())
+	err = raw.SaveVersion(v)
+	return
+}
+
+func (s *Storage) SaveBatch(writes []core.Write) (ret []core.WriteResult, err error) {
+	span, raw := s.start("SaveBatch")
+	defer func() { span.End(err) }()
+	span.SetAttribute("catalog.writes", strconv.Itoa(len(writes)))
+	ret, err = raw.SaveBatch(writes)
+	return
+}
+
+func (s *Storage) ListServices(filter core.Filter, page core.Page) (ret core.Catalog, err error) {
+	span, raw := s.start("ListServices")
+	defer func() { span.End(err) }()
+	ret, err = raw.ListServices(filter, page)
+	return
+}
+
+func (s *Storage) ListChanges(since uint64, limit uint64) (ret []core.Change, err error) {
+	span, raw := s.start("ListChanges")
+	defer func() { span.End(err) }()
+	span.SetAttribute("catalog.since", strconv.FormatUint(since, 10))
+	ret, err = raw.ListChanges(since, limit)
+	return
+}
+
+func (s *Storage) LatestSeq() (ret uint64, err error) {
+	span, raw := s.start("LatestSeq")
+	defer func() { span.End(err) }()
+	ret, err = raw.LatestSeq()
+	return
+}
+
+func (s *Storage) Export(history bool) (ret core.Snapshot, err error) {
+	span, raw := s.start("Export")
+	defer func() { span.End(err) }()
+	ret, err = raw.Export(history)
+	return
+}
+
+func (s *Storage) Import(snapshot core.Snapshot, mode core.ImportMode) (ret core.ImportResult, err error) {
+	span, raw := s.start("Import")
+	defer func() { span.End(err) }()
+	ret, err = raw.Import(snapshot, mode)
+	return
+}
+
+// A span that records nothing.
+type noopSpan struct{}
+
+func (noopSpan) Child(string) core.Span {
+	return noopSpan{}
+}
+
+func (noopSpan) SetAttribute(string, string) {}
+
+func (noopSpan) End(error) {}
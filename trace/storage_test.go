@@ -0,0 +1,141 @@
+package trace
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/errs"
+	"github.com/pkopriv2/golang-sdk/lang/sql"
+	"github.com/pkopriv2/services-catalog/core"
+	svcsql "github.com/pkopriv2/services-catalog/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	db, e := sql.NewSqlLiteDialer().Embed(ctx)
+	if !assert.Nil(t, e) {
+		return
+	}
+
+	raw, err := svcsql.NewSqlStore(db, sql.NewSchemaRegistry("TEST"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Spans are only readable once the tracer has flushed them.
+	traced := func(fn func(core.Storage)) (ret []SpanData, err error) {
+		buf := &bytes.Buffer{}
+		tracer := NewTracer(ctx, NewJsonExporter(buf))
+
+		root := tracer.Start("root", Server, SpanContext{})
+		fn(core.Trace(NewStorage(raw), root))
+		root.End(nil)
+
+		if err = tracer.Close(); err != nil {
+			return
+		}
+		return decodeSpans(buf)
+	}
+
+	byName := func(spans []SpanData, name string) (ret []SpanData) {
+		for _, s := range spans {
+			if s.Name == name {
+				ret = append(ret, s)
+			}
+		}
+		return
+	}
+
+	svc := core.NewService("name", "desc")
+	if !t.Run("SaveService", func(t *testing.T) {
+		spans, err := traced(func(store core.Storage) {
+			assert.Nil(t, store.SaveService(svc))
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		roots := byName(spans, "root")
+		saves := byName(spans, "storage.SaveService")
+		txns := byName(spans, "sql.transaction")
+		execs := byName(spans, "sql.exec")
+		if !assert.Equal(t, 1, len(roots)) || !assert.Equal(t, 1, len(saves)) || !assert.Equal(t, 1, len(txns)) || !assert.NotEmpty(t, execs) {
+			return
+		}
+
+		assert.Equal(t, roots[0].SpanId, saves[0].ParentId)
+		assert.Equal(t, svc.Id.String(), saves[0].Attributes["catalog.service_id"])
+		assert.Equal(t, saves[0].SpanId, txns[0].ParentId)
+		for _, s := range append(execs, byName(spans, "sql.query")...) {
+			assert.Equal(t, txns[0].SpanId, s.ParentId)
+			assert.Equal(t, roots[0].TraceId, s.TraceId)
+			assert.NotEmpty(t, s.Attributes["db.statement"])
+		}
+
+		var inserted bool
+		for _, s := range execs {
+			inserted = inserted || strings.HasPrefix(strings.ToLower(s.Attributes["db.statement"]), "insert")
+		}
+		assert.True(t, inserted)
+	}) {
+		return
+	}
+
+	if !t.Run("SaveService_Conflict", func(t *testing.T) {
+		spans, err := traced(func(store core.Storage) {
+			assert.True(t, errs.Is(store.SaveService(svc), core.ErrConflict))
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		saves := byName(spans, "storage.SaveService")
+		if !assert.Equal(t, 1, len(saves)) {
+			return
+		}
+		assert.NotEmpty(t, saves[0].Error)
+	}) {
+		return
+	}
+
+	if !t.Run("ListServices_WithActor", func(t *testing.T) {
+		spans, err := traced(func(store core.Storage) {
+			catalog, err := core.Attribute(store, core.Actor{Subject: "token:reader"}).
+				ListServices(core.NewFilter(), core.NewPage())
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(catalog.Services))
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		lists := byName(spans, "storage.ListServices")
+		queries := append(byName(spans, "sql.scan"), byName(spans, "sql.query")...)
+		if !assert.Equal(t, 1, len(lists)) || !assert.NotEmpty(t, queries) {
+			return
+		}
+	}) {
+		return
+	}
+
+	if !t.Run("Untraced", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		tracer := NewTracer(ctx, NewJsonExporter(buf))
+
+		_, err := NewStorage(raw).ListServices(core.NewFilter(), core.NewPage())
+		assert.Nil(t, err)
+
+		if !assert.Nil(t, tracer.Close()) {
+			return
+		}
+		assert.Equal(t, 0, buf.Len())
+	}) {
+		return
+	}
+}
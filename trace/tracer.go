@@ -0,0 +1,252 @@
+package trace
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+type Kind string
+
+const (
+	Internal Kind = "internal"
+	Server   Kind = "server"
+	Client   Kind = "client"
+)
+
+// The record of a finished span, as it's handed to exporters.  Ids are
+// lowercase hex.
+type SpanData struct {
+	TraceId    string            `json:"trace_id"`
+	SpanId     string            `json:"span_id"`
+	ParentId   string            `json:"parent_id,omitempty"`
+	Name       string            `json:"name"`
+	Kind       Kind              `json:"kind"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+type Option func(*Options)
+
+type Options struct {
+	BatchSize int
+	QueueSize int
+	Interval  time.Duration
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{BatchSize: 512, QueueSize: 4096, Interval: time.Second}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets the maximum number of spans handed to the exporter at once.
+func WithBatchSize(num int) Option {
+	return func(o *Options) {
+		o.BatchSize = num
+	}
+}
+
+// Sets the number of finished spans that may await export.  Spans that end
+// while the queue is full are dropped.
+func WithQueueSize(num int) Option {
+	return func(o *Options) {
+		o.QueueSize = num
+	}
+}
+
+// Sets how long finished spans may wait before a partial batch is exported.
+func WithInterval(dur time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = dur
+	}
+}
+
+// A tracer starts spans and exports them in batches once they've ended.
+// Exporting happens in the background, so that a slow or unavailable
+// collector never delays a request.  A tracer without an exporter still
+// propagates traces, but records nothing.
+type Tracer struct {
+	ctx      context.Context
+	exporter Exporter
+	opts     Options
+	queue    chan SpanData
+	done     chan struct{}
+	dropped  uint64
+}
+
+func NewTracer(ctx context.Context, exporter Exporter, fns ...Option) *Tracer {
+	opts := buildOptions(fns...)
+
+	ret := &Tracer{
+		ctx:      ctx.Sub("Tracer"),
+		exporter: exporter,
+		opts:     opts,
+		queue:    make(chan SpanData, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	if exporter == nil {
+		close(ret.done)
+		return ret
+	}
+
+	go ret.run()
+	return ret
+}
+
+// Stops the tracer, after exporting the spans that have already ended.
+func (t *Tracer) Close() error {
+	err := t.ctx.Close()
+	<-t.done
+	return err
+}
+
+// Starts a span beneath the parent.  If the parent is invalid, the span is
+// the root of a new, sampled trace.  Otherwise, the span joins the parent's
+// trace and inherits its sampling decision.
+func (t *Tracer) Start(name string, kind Kind, parent SpanContext) *Span {
+	ctx := SpanContext{TraceId: parent.TraceId, SpanId: newSpanId(), Sampled: parent.Sampled}
+	if !parent.Valid() {
+		ctx.TraceId, ctx.Sampled = newTraceId(), true
+	}
+
+	return &Span{
+		tracer: t,
+		ctx:    ctx,
+		parent: parent.SpanId,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+
+	select {
+	case t.queue <- data:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	logger := t.ctx.Logger()
+	logger.Info("Starting")
+	defer logger.Info("Stopping")
+
+	timer := time.NewTicker(t.opts.Interval)
+	defer timer.Stop()
+
+	batch := make([]SpanData, 0, t.opts.BatchSize)
+	flush := func() {
+		if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+			logger.Error("Dropped [%v] spans. The export queue was full", dropped)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			logger.Error("Error exporting [%v] spans: %+v", len(batch), err)
+		}
+		batch = make([]SpanData, 0, t.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case <-t.ctx.Control().Closed():
+			for {
+				select {
+				case data := <-t.queue:
+					if batch = append(batch, data); len(batch) >= t.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case data := <-t.queue:
+			if batch = append(batch, data); len(batch) >= t.opts.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// A span is safe for concurrent use.  It's immutable once it has ended.
+type Span struct {
+	tracer *Tracer
+	ctx    SpanContext
+	parent SpanId
+	name   string
+	kind   Kind
+	start  time.Time
+
+	lock  sync.Mutex
+	attrs map[string]string
+	ended bool
+}
+
+// Returns the context that identifies this span to other services.
+func (s *Span) Context() SpanContext {
+	return s.ctx
+}
+
+func (s *Span) Child(name string) core.Span {
+	return s.tracer.Start(name, Internal, s.ctx)
+}
+
+func (s *Span) SetAttribute(key, val string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ended {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = make(map[string]string)
+	}
+	s.attrs[key] = val
+}
+
+func (s *Span) End(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	if !s.ctx.Sampled {
+		return
+	}
+
+	data := SpanData{
+		TraceId:    s.ctx.TraceId.String(),
+		SpanId:     s.ctx.SpanId.String(),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      s.start.UTC(),
+		End:        time.Now().UTC(),
+		Attributes: s.attrs,
+	}
+	if !s.parent.IsZero() {
+		data.ParentId = s.parent.String()
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	s.tracer.enqueue(data)
+}
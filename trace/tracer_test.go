@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/stretchr/testify/assert"
+)
+
+// Decodes the spans written by a json exporter.
+func decodeSpans(buf *bytes.Buffer) (ret []SpanData, err error) {
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var span SpanData
+		if err = json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			return
+		}
+		ret = append(ret, span)
+	}
+	err = scanner.Err()
+	return
+}
+
+func TestTraceparent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	if !t.Run("Parse", func(t *testing.T) {
+		ctx, err := ParseTraceparent(header)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", ctx.TraceId.String())
+		assert.Equal(t, "00f067aa0ba902b7", ctx.SpanId.String())
+		assert.True(t, ctx.Sampled)
+		assert.Equal(t, header, ctx.Traceparent())
+	}) {
+		return
+	}
+
+	if !t.Run("Parse_FutureVersion", func(t *testing.T) {
+		ctx, err := ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, ctx.Sampled)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", ctx.Traceparent())
+	}) {
+		return
+	}
+
+	if !t.Run("Parse_Invalid", func(t *testing.T) {
+		for _, val := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		} {
+			_, err := ParseTraceparent(val)
+			assert.True(t, errors.Is(err, ErrInvalidTraceparent), val)
+		}
+	}) {
+		return
+	}
+}
+
+func TestTracer(t *testing.T) {
+	ctx := context.NewContext(os.Stdout, context.Info)
+	defer ctx.Close()
+
+	buf := &bytes.Buffer{}
+	tracer := NewTracer(ctx, NewJsonExporter(buf), WithBatchSize(2))
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	server := tracer.Start("GET /v1/services", Server, parent)
+	server.SetAttribute("http.route", "/v1/services")
+
+	child := server.Child("storage.ListServices")
+	child.End(errors.New("boom"))
+	child.End(nil)
+	server.End(nil)
+
+	root := tracer.Start("root", Internal, SpanContext{})
+	root.End(nil)
+
+	unsampled, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	tracer.Start("unsampled", Server, unsampled).End(nil)
+
+	if !assert.Nil(t, tracer.Close()) {
+		return
+	}
+
+	spans, err := decodeSpans(buf)
+	if !assert.Nil(t, err) || !assert.Equal(t, 3, len(spans)) {
+		return
+	}
+
+	if !t.Run("Child", func(t *testing.T) {
+		assert.Equal(t, "storage.ListServices", spans[0].Name)
+		assert.Equal(t, Internal, spans[0].Kind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceId)
+		assert.Equal(t, server.Context().SpanId.String(), spans[0].ParentId)
+		assert.Equal(t, "boom", spans[0].Error)
+	}) {
+		return
+	}
+
+	if !t.Run("Parent", func(t *testing.T) {
+		assert.Equal(t, "GET /v1/services", spans[1].Name)
+		assert.Equal(t, Server, spans[1].Kind)
+		assert.Equal(t, "00f067aa0ba902b7", spans[1].ParentId)
+		assert.Equal(t, "/v1/services", spans[1].Attributes["http.route"])
+		assert.Equal(t, "", spans[1].Error)
+		assert.False(t, spans[1].End.Before(spans[1].Start))
+	}) {
+		return
+	}
+
+	if !t.Run("Root", func(t *testing.T) {
+		assert.Equal(t, "root", spans[2].Name)
+		assert.Equal(t, "", spans[2].ParentId)
+		assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[2].TraceId)
+		assert.True(t, root.Context().Sampled)
+	}) {
+		return
+	}
+}
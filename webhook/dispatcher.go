@@ -0,0 +1,414 @@
+package webhook
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkopriv2/golang-sdk/lang/context"
+	"github.com/pkopriv2/golang-sdk/lang/enc"
+	"github.com/pkopriv2/golang-sdk/lang/mime"
+	"github.com/pkopriv2/services-catalog/core"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Every delivery is posted with the following headers.  The timestamp is
+// the unix time (in seconds) of the attempt.  The signature is the hex
+// encoded HMAC-SHA256 of the timestamp and the body (joined by a '.'), keyed
+// by the webhook secret, and prefixed with the algorithm (e.g. sha256=...).
+// Receivers should verify the signature before trusting the payload.
+const (
+	SignatureHeader = "X-Catalog-Signature"
+	TimestampHeader = "X-Catalog-Timestamp"
+	EventHeader     = "X-Catalog-Event"
+	DeliveryHeader  = "X-Catalog-Delivery"
+)
+
+// The maximum age of a verified delivery.  Since the timestamp is signed,
+// a captured delivery can't be replayed once it's older than this.  Every
+// attempt is signed anew, so retries aren't affected.
+const MaxSkew = 5 * time.Minute
+
+// Signs the timestamp and body with the given secret.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifies the signature of the timestamp and body in constant time, and
+// that the timestamp is within MaxSkew of now.
+func Verify(secret string, timestamp string, body []byte, sig string) bool {
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	skew := time.Since(time.Unix(secs, 0))
+	if skew > MaxSkew || skew < -MaxSkew {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(sig))
+}
+
+type Option func(*Options)
+
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    uint64
+	MaxAttempts  int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	Private      bool
+}
+
+func buildOptions(fns ...Option) (ret Options) {
+	ret = Options{
+		PollInterval: time.Second,
+		BatchSize:    256,
+		MaxAttempts:  10,
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Hour,
+		Timeout:      10 * time.Second,
+	}
+	for _, fn := range fns {
+		fn(&ret)
+	}
+	return
+}
+
+// Sets how often the dispatcher polls for new changes and due deliveries.
+func WithPollInterval(dur time.Duration) Option {
+	return func(o *Options) {
+		o.PollInterval = dur
+	}
+}
+
+// Sets the number of attempts after which a delivery is declared dead.
+func WithMaxAttempts(num int) Option {
+	return func(o *Options) {
+		o.MaxAttempts = num
+	}
+}
+
+// Sets the bounds of the exponential backoff between attempts.
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *Options) {
+		o.MinBackoff, o.MaxBackoff = min, max
+	}
+}
+
+// Sets the timeout of a single attempt.
+func WithTimeout(dur time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = dur
+	}
+}
+
+// Allows deliveries to private networks (e.g. to subscribers within the
+// same cluster).  See ValidateUrl.
+func WithPrivateNetworks() Option {
+	return func(o *Options) {
+		o.Private = true
+	}
+}
+
+// The dispatcher is a background worker that delivers changes to webhooks.
+//
+// It operates in two phases.  First, it tails the change log and enqueues a
+// delivery for every webhook that matches each change.  The enqueued
+// deliveries and the new position within the log are committed atomically,
+// so every change is enqueued exactly once.  Second, it attempts every
+// delivery that is due.  Failed attempts are retried with exponential backoff
+// until the maximum number of attempts has been reached, at which point the
+// delivery is declared dead.
+//
+// Every webhook has its own worker, which attempts the webhook's deliveries
+// serially, so ordering is preserved in the absence of failures.  However, a
+// failing delivery does not block later deliveries to the same webhook.
+// Every attempt is bounded by the timeout, and workers don't wait on one
+// another, so a slow webhook only delays its own deliveries.
+type Dispatcher struct {
+	ctx     context.Context
+	catalog core.Storage
+	hooks   core.WebhookStorage
+	client  *http.Client
+	opts    Options
+	closed  gocontext.Context // canceled once the dispatcher is closed
+	lock    sync.Mutex
+	busy    map[uuid.UUID]bool // webhooks with a running worker
+	workers sync.WaitGroup
+}
+
+func NewDispatcher(ctx context.Context, catalog core.Storage, hooks core.WebhookStorage, fns ...Option) *Dispatcher {
+	opts := buildOptions(fns...)
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.Private {
+		dialer.Control = dialPublic
+	}
+
+	// Proxies are ignored, since they would connect on the dispatcher's
+	// behalf, and redirects aren't followed, since they may point anywhere.
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	closed, cancel := gocontext.WithCancel(gocontext.Background())
+
+	ret := &Dispatcher{
+		ctx:     ctx.Sub("WebhookDispatcher"),
+		catalog: catalog,
+		hooks:   hooks,
+		client:  client,
+		opts:    opts,
+		closed:  closed,
+		busy:    make(map[uuid.UUID]bool),
+	}
+	go func() {
+		<-ret.ctx.Control().Closed()
+		cancel()
+	}()
+	go ret.run()
+	return ret
+}
+
+// Cancels any attempts in progress and waits for the workers to stop.
+func (d *Dispatcher) Close() error {
+	err := d.ctx.Close()
+
+	// Workers are only started while holding the lock, and never once the
+	// dispatcher is closed.
+	d.lock.Lock()
+	d.lock.Unlock()
+	d.workers.Wait()
+	return err
+}
+
+func (d *Dispatcher) run() {
+	logger := d.ctx.Logger()
+	logger.Info("Starting")
+	defer logger.Info("Stopping")
+
+	for {
+		if err := d.enqueue(); err != nil {
+			logger.Error("Error enqueuing deliveries: %+v", err)
+		}
+		if err := d.deliver(); err != nil {
+			logger.Error("Error attempting deliveries: %+v", err)
+		}
+
+		select {
+		case <-d.ctx.Control().Closed():
+			return
+		case <-time.After(d.opts.PollInterval):
+		}
+	}
+}
+
+// Reads the change log from the cursor and enqueues deliveries until
+// the end of the log has been reached.
+func (d *Dispatcher) enqueue() (err error) {
+	for {
+		cursor, err := d.hooks.ReadCursor()
+		if err != nil {
+			return err
+		}
+
+		changes, err := d.catalog.ListChanges(cursor, d.opts.BatchSize)
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+
+		hooks, err := d.hooks.ListWebhooks()
+		if err != nil {
+			return err
+		}
+
+		matcher := core.NewChangeMatcher(d.catalog)
+
+		var deliveries []core.Delivery
+		for _, c := range changes {
+			var payload []byte
+			if err = enc.Json.EncodeBinary(c, &payload); err != nil {
+				return err
+			}
+
+			for _, h := range hooks {
+				if !h.Accepts(c.Type) {
+					continue
+				}
+
+				ok, err := matcher.Matches(h.Filter, c)
+				if err != nil {
+					return err
+				}
+				if ok {
+					deliveries = append(deliveries, core.NewDelivery(h, c, payload))
+				}
+			}
+		}
+
+		if err = d.hooks.EnqueueDeliveries(changes[len(changes)-1].Seq, deliveries); err != nil {
+			return err
+		}
+		if uint64(len(changes)) < d.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// Starts a worker for every webhook that doesn't already have one.  Workers
+// run independently of the poll, so a slow webhook never delays the others.
+func (d *Dispatcher) deliver() (err error) {
+	hooks, err := d.hooks.ListWebhooks()
+	if err != nil {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, hook := range hooks {
+		if d.busy[hook.Id] || d.ctx.Control().IsClosed() {
+			continue
+		}
+
+		d.busy[hook.Id] = true
+		d.workers.Add(1)
+		go d.work(hook)
+	}
+	return
+}
+
+func (d *Dispatcher) work(hook core.Webhook) {
+	defer d.workers.Done()
+	defer func() {
+		d.lock.Lock()
+		delete(d.busy, hook.Id)
+		d.lock.Unlock()
+	}()
+
+	if err := d.deliverAll(hook); err != nil {
+		d.ctx.Logger().Error("Error attempting deliveries to webhook [%v]: %+v", hook.Id, err)
+	}
+}
+
+// Attempts the webhook's deliveries that are currently due, in order.
+func (d *Dispatcher) deliverAll(hook core.Webhook) (err error) {
+	due, err := d.hooks.ListDue(hook.Id, time.Now().UTC(), d.opts.BatchSize)
+	if err != nil {
+		return
+	}
+
+	for _, delivery := range due {
+		if d.ctx.Control().IsClosed() {
+			return
+		}
+
+		attempt := d.attempt(hook, delivery)
+		if err = d.hooks.RecordAttempt(d.next(delivery, attempt), attempt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Posts the delivery to the webhook.
+func (d *Dispatcher) attempt(hook core.Webhook, delivery core.Delivery) (ret core.Attempt) {
+	ret = core.Attempt{
+		DeliveryId: delivery.Id,
+		Num:        delivery.Attempts + 1,
+		Started:    time.Now().UTC(),
+	}
+	defer func() {
+		ret.Duration = time.Since(ret.Started)
+	}()
+
+	// The webhook may predate validation, so its url is checked again.
+	if err := ValidateUrl(hook.Url, d.opts.Private); err != nil {
+		ret.Error = err.Error()
+		return
+	}
+
+	ctx, cancel := gocontext.WithTimeout(d.closed, d.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", hook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		ret.Error = err.Error()
+		return
+	}
+
+	timestamp := strconv.FormatInt(ret.Started.Unix(), 10)
+	req.Header.Set("Content-Type", mime.Json)
+	req.Header.Set(EventHeader, string(delivery.Type))
+	req.Header.Set(DeliveryHeader, delivery.Id.String())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		ret.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	// Drain (a bounded amount of) the body so the connection may be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	ret.Code = resp.StatusCode
+	if !ret.Ok() {
+		ret.Error = fmt.Sprintf("Unexpected response [%v]", strings.TrimSpace(resp.Status))
+	}
+	return
+}
+
+// Returns the state of the delivery following the attempt.
+func (d *Dispatcher) next(delivery core.Delivery, attempt core.Attempt) (ret core.Delivery) {
+	ret = delivery
+	ret.Attempts = attempt.Num
+	ret.LastError = attempt.Error
+	ret.Updated = time.Now().UTC()
+
+	switch {
+	case attempt.Ok():
+		ret.Status = core.DeliveryDelivered
+	case ret.Attempts >= d.opts.MaxAttempts:
+		ret.Status = core.DeliveryDead
+		d.ctx.Logger().Info("Delivery [%v] to webhook [%v] is dead after [%v] attempts: %v",
+			ret.Id, ret.WebhookId, ret.Attempts, ret.LastError)
+	default:
+		ret.NextAttempt = ret.Updated.Add(backoff(d.opts.MinBackoff, d.opts.MaxBackoff, ret.Attempts))
+	}
+	return
+}
+
+// Returns the delay before the next attempt, doubling with every
+// failed attempt.
+func backoff(min, max time.Duration, attempts int) (ret time.Duration) {
+	ret = min
+	for i := 1; i < attempts && ret < max; i++ {
+		ret *= 2
+	}
+	if ret > max {
+		ret = max
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/pkopriv2/services-catalog/core"
+)
+
+// Deliveries are posted by the server, so a subscriber could otherwise point
+// the server at addresses that only it can reach (e.g. a cloud metadata
+// endpoint or an internal admin port).  Unless private networks are allowed,
+// a subscriber's url must name a public host, and every connection made for
+// a delivery must be to a public address.  The latter is checked as each
+// connection is dialed, since a hostname may resolve to a private address
+// (or be re-pointed at one after it was registered).
+
+// Validates the url of a webhook.  Urls must be absolute http(s) urls
+// without credentials.  Unless private networks are allowed, the host may
+// not be localhost or a non-public ip.
+func ValidateUrl(raw string, private bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.Wrapf(core.ErrState, "Invalid webhook url [%v]", raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Wrapf(core.ErrState, "Invalid webhook url [%v]. Must be an http(s) url", raw)
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return errors.Wrapf(core.ErrState, "Invalid webhook url [%v]. Must have a host and no credentials", raw)
+	}
+	if private {
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.Wrapf(core.ErrState, "Invalid webhook url [%v]. Must not be local", raw)
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIp(ip) {
+		return errors.Wrapf(core.ErrState, "Invalid webhook url [%v]. Must not be a private address", raw)
+	}
+	return nil
+}
+
+// Reserved networks that net.IP doesn't classify: "this" network (which
+// linux routes to the local host) and carrier-grade NAT.
+var reserved = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+func publicIp(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rejects connections to non-public addresses.  Used as the control of the
+// dispatcher's dialer, so it sees the resolved address of every connection.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIp(ip) {
+		return errors.Wrapf(core.ErrState, "Refusing to connect to non-public address [%v]", host)
+	}
+	return nil
+}